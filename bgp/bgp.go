@@ -23,6 +23,11 @@ type Parameters struct {
 	Writer           io.Writer
 	WaitDuration     time.Duration
 	Output           string
+
+	// PeerAddress, AFI and SAFI select the routes fetched by GetRoutes.
+	PeerAddress string
+	AFI         string
+	SAFI        string
 }
 
 // Status is used to get bgp state from cilium agents
@@ -0,0 +1,310 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package bgp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/cilium/cilium-cli/defaults"
+	"github.com/cilium/cilium-cli/status"
+)
+
+const (
+	// RoutesAvailable selects the routes available in the local RIB.
+	RoutesAvailable = "available"
+	// RoutesAdvertised selects the routes advertised to the peers.
+	RoutesAdvertised = "advertised"
+)
+
+// Route is a BGP route as returned by 'cilium bgp routes -o json' in the
+// cilium agent.
+type Route struct {
+	Prefix    string       `json:"prefix"`
+	RouterAsn int64        `json:"router-asn"`
+	Neighbor  string       `json:"neighbor,omitempty"`
+	Paths     []*RoutePath `json:"paths"`
+}
+
+// RoutePath is a BGP path of a Route.
+type RoutePath struct {
+	Family struct {
+		Afi  string `json:"afi"`
+		Safi string `json:"safi"`
+	} `json:"family"`
+	Nlri struct {
+		Base64 string `json:"base64"`
+	} `json:"nlri"`
+	PathAttributesBase64 []string `json:"path-attributes-base64"`
+	Best                 bool     `json:"best"`
+	Stale                bool     `json:"stale"`
+	AgeNanoseconds       int64    `json:"age-nanoseconds"`
+}
+
+// GetRoutes gets the available or advertised BGP routes from all/specific
+// cilium agent pods.
+func (s *Status) GetRoutes(ctx context.Context, tableType string) error {
+	if tableType != RoutesAvailable && tableType != RoutesAdvertised {
+		return fmt.Errorf("invalid table type %q, must be one of: %s, %s", tableType, RoutesAvailable, RoutesAdvertised)
+	}
+	if tableType == RoutesAvailable && s.params.PeerAddress != "" {
+		return fmt.Errorf("peer address can only be specified for %s routes", RoutesAdvertised)
+	}
+
+	ctx, cancelFn := context.WithTimeout(ctx, s.params.WaitDuration)
+	defer cancelFn()
+
+	err := s.initTargetCiliumPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.fetchRoutesConcurrently(ctx, tableType)
+	if err != nil {
+		return err
+	}
+
+	return s.writeRoutes(res, tableType)
+}
+
+func (s *Status) fetchRoutesConcurrently(ctx context.Context, tableType string) (map[string][]*Route, error) {
+	allFetchedData := make(map[string][]*Route)
+
+	// res contains data returned from cilium pod
+	type res struct {
+		nodeName string
+		data     []*Route
+		err      error
+	}
+	resCh := make(chan res)
+
+	var wg sync.WaitGroup
+
+	// max number of concurrent go routines will be number of cilium agent pods
+	wg.Add(len(s.ciliumPods))
+
+	// concurrently fetch routes from each cilium pod
+	for _, pod := range s.ciliumPods {
+		go func(ctx context.Context, pod *corev1.Pod) {
+			defer wg.Done()
+
+			routes, err := s.fetchRoutesFromPod(ctx, pod, tableType)
+			resCh <- res{
+				nodeName: pod.Spec.NodeName,
+				data:     routes,
+				err:      err,
+			}
+		}(ctx, pod)
+	}
+
+	// close resCh when data from all nodes is collected
+	go func() {
+		wg.Wait()
+		close(resCh)
+	}()
+
+	// read from the channel till it is closed.
+	// on error, store error and continue to next node.
+	var err error
+	for fetchedData := range resCh {
+		if fetchedData.err != nil {
+			err = errors.Join(err, fetchedData.err)
+		} else {
+			allFetchedData[fetchedData.nodeName] = fetchedData.data
+		}
+	}
+
+	return allFetchedData, err
+}
+
+func (s *Status) fetchRoutesFromPod(ctx context.Context, pod *corev1.Pod, tableType string) ([]*Route, error) {
+	if tableType == RoutesAvailable || s.params.PeerAddress != "" {
+		return s.fetchRoutesTableFromPod(ctx, pod, routesCommand(tableType, s.params.AFI, s.params.SAFI, 0, s.params.PeerAddress))
+	}
+
+	// The agent only returns advertised routes for a given peer, so query
+	// all of them.
+	peers, err := s.fetchPeeringStateFromPod(ctx, pod)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]*Route, 0)
+	for _, peer := range peers {
+		peerRoutes, err := s.fetchRoutesTableFromPod(ctx, pod, routesCommand(tableType, s.params.AFI, s.params.SAFI, peer.LocalAsn, peer.PeerAddress))
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, peerRoutes...)
+	}
+	return routes, nil
+}
+
+func (s *Status) fetchRoutesTableFromPod(ctx context.Context, pod *corev1.Pod, cmd []string) ([]*Route, error) {
+	output, err := s.client.ExecInPod(ctx, pod.Namespace, pod.Name, defaults.AgentContainerName, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bgp routes from %s: %v", pod.Name, err)
+	}
+
+	routes := make([]*Route, 0)
+
+	err = json.Unmarshal(output.Bytes(), &routes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bgp routes from %s: %v", pod.Name, err)
+	}
+
+	return routes, nil
+}
+
+// routesCommand returns the command listing the routes of the given table in
+// the cilium agent, optionally restricted to a virtual router and a peer.
+func routesCommand(tableType, afi, safi string, routerAsn int64, peerAddress string) []string {
+	cmd := []string{"cilium", "bgp", "routes", tableType, afi, safi}
+	if routerAsn != 0 {
+		cmd = append(cmd, "vrouter", strconv.FormatInt(routerAsn, 10))
+	}
+	if peerAddress != "" {
+		cmd = append(cmd, "peer", peerAddress)
+	}
+	return append(cmd, "-o", "json")
+}
+
+func (s *Status) writeRoutes(res map[string][]*Route, tableType string) error {
+	if s.params.Output == status.OutputJSON {
+		jsonStatus, err := json.MarshalIndent(res, "", " ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(jsonStatus))
+	} else {
+		printRoutesSummary(os.Stdout, res, tableType == RoutesAdvertised)
+	}
+
+	return nil
+}
+
+func printRoutesSummary(out io.Writer, routesPerNode map[string][]*Route, printPeer bool) {
+	// sort by node names
+	var nodes []string
+	for node := range routesPerNode {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	// sort routes per node by virtual router, peer and prefix
+	for _, routes := range routesPerNode {
+		sort.SliceStable(routes, func(i, j int) bool {
+			if routes[i].RouterAsn != routes[j].RouterAsn {
+				return routes[i].RouterAsn < routes[j].RouterAsn
+			}
+			if routes[i].Neighbor != routes[j].Neighbor {
+				return routes[i].Neighbor < routes[j].Neighbor
+			}
+			return routes[i].Prefix < routes[j].Prefix
+		})
+	}
+
+	// tab writer with min width 5 and padding 3
+	w := tabwriter.NewWriter(out, minWidth, 0, padding, paddingChar, 0)
+	if printPeer {
+		fmt.Fprintln(w, "Node\tVRouter\tPeer\tPrefix\tNextHop\tAge\tBest")
+	} else {
+		fmt.Fprintln(w, "Node\tVRouter\tPrefix\tNextHop\tAge\tBest")
+	}
+
+	for _, node := range nodes {
+		first := true
+		for _, route := range routesPerNode[node] {
+			for _, path := range route.Paths {
+				if first {
+					// print name for first row of routes
+					fmt.Fprintf(w, "%s\t", node)
+					first = false
+				} else {
+					// skip name for all rest of the routes
+					fmt.Fprint(w, "\t")
+				}
+
+				fmt.Fprintf(w, "%d\t", route.RouterAsn)
+				if printPeer {
+					fmt.Fprintf(w, "%s\t", route.Neighbor)
+				}
+				fmt.Fprintf(w, "%s\t", route.Prefix)
+				fmt.Fprintf(w, "%s\t", nextHop(path))
+				fmt.Fprintf(w, "%s\t", time.Duration(path.AgeNanoseconds).Round(time.Second).String())
+				fmt.Fprintf(w, "%t\n", path.Best)
+			}
+		}
+	}
+	w.Flush()
+}
+
+const (
+	// attrFlagExtendedLength is set in the flags of path attributes whose
+	// length is encoded on two bytes.
+	attrFlagExtendedLength = 0x10
+
+	attrTypeNextHop     = 3
+	attrTypeMPReachNLRI = 14
+)
+
+// nextHop returns the next hop of the given path, as found in its NEXT_HOP or
+// MP_REACH_NLRI path attributes (RFC 4271 and RFC 4760), or "-" if none.
+func nextHop(path *RoutePath) string {
+	for _, attr := range path.PathAttributesBase64 {
+		b, err := base64.StdEncoding.DecodeString(attr)
+		if err != nil || len(b) < 3 {
+			continue
+		}
+
+		typ, value := b[1], b[3:]
+		length := int(b[2])
+		if b[0]&attrFlagExtendedLength != 0 {
+			if len(b) < 4 {
+				continue
+			}
+			value = b[4:]
+			length = int(binary.BigEndian.Uint16(b[2:4]))
+		}
+		if len(value) < length {
+			continue
+		}
+		value = value[:length]
+
+		switch typ {
+		case attrTypeNextHop:
+			if addr, ok := netip.AddrFromSlice(value); ok {
+				return addr.String()
+			}
+		case attrTypeMPReachNLRI:
+			// AFI (2 bytes), SAFI (1 byte), next hop length (1 byte) and next
+			// hop. IPv6 next hops may be followed by a link-local address.
+			if len(value) < 4 || len(value) < 4+int(value[3]) {
+				continue
+			}
+			nh := value[4 : 4+int(value[3])]
+			if len(nh) == 32 {
+				nh = nh[:16]
+			}
+			if addr, ok := netip.AddrFromSlice(nh); ok {
+				return addr.String()
+			}
+		}
+	}
+	return "-"
+}
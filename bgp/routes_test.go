@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package bgp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// ORIGIN: IGP
+	originAttr = base64.StdEncoding.EncodeToString([]byte{0x40, 1, 1, 0})
+	// NEXT_HOP: 10.0.0.1
+	nextHopAttr = base64.StdEncoding.EncodeToString([]byte{0x40, 3, 4, 10, 0, 0, 1})
+	// MP_REACH_NLRI: ipv6/unicast, next hop fd00::1 followed by fe80::1, with
+	// an extended length.
+	mpReachAttr = base64.StdEncoding.EncodeToString([]byte{
+		0x90, 14, 0, 37,
+		0, 2, 1, 32,
+		0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+		0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+		0,
+	})
+
+	route1 = &Route{
+		Prefix:    "10.100.0.1/32",
+		RouterAsn: 65001,
+		Neighbor:  "192.168.0.2",
+		Paths: []*RoutePath{{
+			PathAttributesBase64: []string{originAttr, nextHopAttr},
+			Best:                 true,
+			AgeNanoseconds:       int64(time.Minute),
+		}},
+	}
+
+	route2 = &Route{
+		Prefix:    "fd00:100::1/128",
+		RouterAsn: 65001,
+		Neighbor:  "192.168.0.2",
+		Paths: []*RoutePath{{
+			PathAttributesBase64: []string{originAttr, mpReachAttr},
+			Best:                 true,
+			AgeNanoseconds:       int64(time.Second),
+		}},
+	}
+)
+
+func TestNextHop(t *testing.T) {
+	require.Equal(t, "10.0.0.1", nextHop(route1.Paths[0]))
+	require.Equal(t, "fd00::1", nextHop(route2.Paths[0]))
+	require.Equal(t, "-", nextHop(&RoutePath{PathAttributesBase64: []string{originAttr}}))
+
+	// Truncated attributes are ignored.
+	require.Equal(t, "-", nextHop(&RoutePath{PathAttributesBase64: []string{
+		base64.StdEncoding.EncodeToString([]byte{0x40, 3, 4, 10}),
+		"not base64",
+	}}))
+}
+
+func TestRoutesCommand(t *testing.T) {
+	require.Equal(t,
+		[]string{"cilium", "bgp", "routes", "available", "ipv4", "unicast", "-o", "json"},
+		routesCommand(RoutesAvailable, "ipv4", "unicast", 0, ""))
+	require.Equal(t,
+		[]string{"cilium", "bgp", "routes", "advertised", "ipv6", "unicast", "vrouter", "65001", "peer", "192.168.0.2", "-o", "json"},
+		routesCommand(RoutesAdvertised, "ipv6", "unicast", 65001, "192.168.0.2"))
+}
+
+func Test_printRoutesSummary(t *testing.T) {
+	var out bytes.Buffer
+	printRoutesSummary(&out, map[string][]*Route{
+		"node_2": {route1},
+		"node_1": {route2, route1},
+	}, true)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, []string{"Node", "VRouter", "Peer", "Prefix", "NextHop", "Age", "Best"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{"node_1", "65001", "192.168.0.2", "10.100.0.1/32", "10.0.0.1", "1m0s", "true"}, strings.Fields(lines[1]))
+	require.Equal(t, []string{"65001", "192.168.0.2", "fd00:100::1/128", "fd00::1", "1s", "true"}, strings.Fields(lines[2]))
+	require.Equal(t, []string{"node_2", "65001", "192.168.0.2", "10.100.0.1/32", "10.0.0.1", "1m0s", "true"}, strings.Fields(lines[3]))
+
+	// The peer column is omitted for available routes.
+	out.Reset()
+	printRoutesSummary(&out, map[string][]*Route{"node_1": {route1}}, false)
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Equal(t, []string{"Node", "VRouter", "Prefix", "NextHop", "Age", "Best"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{"node_1", "65001", "10.100.0.1/32", "10.0.0.1", "1m0s", "true"}, strings.Fields(lines[1]))
+}
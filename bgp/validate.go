@@ -0,0 +1,365 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package bgp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cilium/cilium/api/v1/models"
+	ciliumv2alpha1 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2alpha1"
+	"github.com/cilium/cilium/pkg/k8s/slim/k8s/apis/labels"
+	slimv1 "github.com/cilium/cilium/pkg/k8s/slim/k8s/apis/meta/v1"
+	"golang.org/x/exp/slices"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cilium/cilium-cli/status"
+)
+
+// Severity is the severity of a Finding.
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Names of the validation checks.
+const (
+	checkInvalidNodeSelector    = "invalid-node-selector"
+	checkNodeSelectorNoMatch    = "node-selector-no-match"
+	checkNodeMultiplePolicies   = "node-multiple-policies"
+	checkDuplicateLocalASN      = "duplicate-local-asn"
+	checkConflictingLocalASN    = "conflicting-local-asn"
+	checkInvalidNeighbor        = "invalid-neighbor"
+	checkInvalidServiceSelector = "invalid-service-selector"
+	checkServiceSelectorNoMatch = "service-selector-no-match"
+	checkPeerMissing            = "peer-missing"
+	checkPeerUnexpected         = "peer-unexpected"
+	checkPeerNotEstablished     = "peer-not-established"
+)
+
+// sessionEstablished is the state of established BGP sessions.
+const sessionEstablished = "established"
+
+// Finding is an issue found while validating BGP peering policies.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	// Check is the name of the check which reported the finding.
+	Check string `json:"check"`
+	// Policy is the name of the CiliumBGPPeeringPolicy, if any.
+	Policy string `json:"policy,omitempty"`
+	// Node is the name of the node, if any.
+	Node    string `json:"node,omitempty"`
+	Message string `json:"message"`
+}
+
+// peerKey identifies a BGP peer of a virtual router.
+type peerKey struct {
+	localASN int64
+	address  string
+}
+
+func (k peerKey) String() string {
+	return fmt.Sprintf("%s (local ASN %d)", k.address, k.localASN)
+}
+
+// Validate cross-checks the CiliumBGPPeeringPolicies against the nodes and
+// LoadBalancer services of the cluster, and against the BGP sessions of the
+// cilium agents. It returns an error if any finding has SeverityError.
+func (s *Status) Validate(ctx context.Context) error {
+	ctx, cancelFn := context.WithTimeout(ctx, s.params.WaitDuration)
+	defer cancelFn()
+
+	policies, err := s.client.ListCiliumBGPPeeringPolicies(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to list CiliumBGPPeeringPolicies: %w", err)
+	}
+	nodes, err := s.client.ListNodes(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to list nodes: %w", err)
+	}
+	services, err := s.client.ListServices(ctx, corev1.NamespaceAll, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to list services: %w", err)
+	}
+
+	findings, nodePolicies := validatePolicies(policies.Items, nodes.Items, services.Items)
+
+	err = s.initTargetCiliumPods(ctx)
+	if err != nil {
+		return err
+	}
+	// Compare the sessions of the agents which could be reached, and report
+	// the others as an error.
+	peers, fetchErr := s.fetchPeeringStateConcurrently(ctx)
+	findings = append(findings, comparePeers(nodePolicies, peers)...)
+
+	if findings == nil {
+		findings = []Finding{}
+	}
+	if s.params.Output == status.OutputJSON {
+		jsonFindings, err := json.MarshalIndent(findings, "", " ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(s.params.Writer, string(jsonFindings))
+	} else {
+		writeFindings(s.params.Writer, findings)
+	}
+
+	var errs int
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errs++
+		}
+	}
+	if errs > 0 {
+		err = fmt.Errorf("%d errors found in BGP peering policies", errs)
+	}
+	return errors.Join(err, fetchErr)
+}
+
+func writeFindings(w io.Writer, findings []Finding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "✅ No issues found")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, minWidth, 0, padding, paddingChar, 0)
+	fmt.Fprintln(tw, "Severity\tCheck\tPolicy\tNode\tMessage")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.Severity, f.Check, orDash(f.Policy), orDash(f.Node), f.Message)
+	}
+	tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// validatePolicies checks the policies against the nodes and services of the
+// cluster. It also returns the policy applied to each node selected by any
+// policy, which is nil if the node is selected by several policies.
+func validatePolicies(policies []ciliumv2alpha1.CiliumBGPPeeringPolicy, nodes []corev1.Node, services []corev1.Service) ([]Finding, map[string]*ciliumv2alpha1.CiliumBGPPeeringPolicy) {
+	var findings []Finding
+
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	selectedBy := make(map[string][]*ciliumv2alpha1.CiliumBGPPeeringPolicy)
+	for i := range policies {
+		policy := &policies[i]
+		report := func(severity Severity, check, format string, a ...any) {
+			findings = append(findings, Finding{Severity: severity, Check: check, Policy: policy.Name, Message: fmt.Sprintf(format, a...)})
+		}
+
+		// A nil node selector selects all nodes.
+		nodeSelector := labels.Everything()
+		if policy.Spec.NodeSelector != nil {
+			var err error
+			if nodeSelector, err = slimv1.LabelSelectorAsSelector(policy.Spec.NodeSelector); err != nil {
+				report(SeverityError, checkInvalidNodeSelector, "invalid node selector: %s", err)
+				continue
+			}
+		}
+		matched := 0
+		for _, node := range nodes {
+			if nodeSelector.Matches(labels.Set(node.Labels)) {
+				selectedBy[node.Name] = append(selectedBy[node.Name], policy)
+				matched++
+			}
+		}
+		if matched == 0 {
+			report(SeverityWarning, checkNodeSelectorNoMatch, "node selector %q matches no node", nodeSelector)
+		}
+
+		routerASNs := make(map[int64]bool)
+		neighborASNs := make(map[string][]int64)
+		for _, router := range policy.Spec.VirtualRouters {
+			if routerASNs[router.LocalASN] {
+				report(SeverityError, checkDuplicateLocalASN, "local ASN %d is used by several virtual routers", router.LocalASN)
+			}
+			routerASNs[router.LocalASN] = true
+
+			neighbors := make(map[string]bool)
+			for _, neighbor := range router.Neighbors {
+				prefix, err := netip.ParsePrefix(neighbor.PeerAddress)
+				if err != nil {
+					report(SeverityError, checkInvalidNeighbor, "neighbor %q of virtual router %d is not a valid CIDR", neighbor.PeerAddress, router.LocalASN)
+					continue
+				}
+				if err := neighbor.Validate(); err != nil {
+					report(SeverityError, checkInvalidNeighbor, "neighbor %s of virtual router %d is invalid: %s", neighbor.PeerAddress, router.LocalASN, err)
+				}
+				addr := prefix.Addr().String()
+				if neighbors[addr] {
+					report(SeverityError, checkInvalidNeighbor, "neighbor %s is configured several times in virtual router %d", addr, router.LocalASN)
+					continue
+				}
+				neighbors[addr] = true
+				if !slices.Contains(neighborASNs[addr], router.LocalASN) {
+					neighborASNs[addr] = append(neighborASNs[addr], router.LocalASN)
+				}
+			}
+
+			if router.ServiceSelector != nil {
+				serviceSelector, err := slimv1.LabelSelectorAsSelector(router.ServiceSelector)
+				if err != nil {
+					report(SeverityError, checkInvalidServiceSelector, "invalid service selector of virtual router %d: %s", router.LocalASN, err)
+					continue
+				}
+				if !matchesLoadBalancer(serviceSelector, services) {
+					report(SeverityWarning, checkServiceSelectorNoMatch, "service selector %q of virtual router %d matches no LoadBalancer service", serviceSelector, router.LocalASN)
+				}
+			}
+		}
+
+		var addrs []string
+		for addr := range neighborASNs {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+		for _, addr := range addrs {
+			if asns := neighborASNs[addr]; len(asns) > 1 {
+				report(SeverityWarning, checkConflictingLocalASN, "neighbor %s is configured in virtual routers with different local ASNs %s", addr, joinASNs(asns))
+			}
+		}
+	}
+
+	nodePolicies := make(map[string]*ciliumv2alpha1.CiliumBGPPeeringPolicy)
+	for _, node := range nodes {
+		switch policies := selectedBy[node.Name]; len(policies) {
+		case 0:
+		case 1:
+			nodePolicies[node.Name] = policies[0]
+		default:
+			nodePolicies[node.Name] = nil
+			names := make([]string, 0, len(policies))
+			for _, p := range policies {
+				names = append(names, p.Name)
+			}
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Check:    checkNodeMultiplePolicies,
+				Node:     node.Name,
+				Message:  fmt.Sprintf("node is selected by several policies %s, none of them is applied", strings.Join(names, ", ")),
+			})
+		}
+	}
+
+	return findings, nodePolicies
+}
+
+func matchesLoadBalancer(selector labels.Selector, services []corev1.Service) bool {
+	for _, svc := range services {
+		if svc.Spec.Type == corev1.ServiceTypeLoadBalancer && selector.Matches(labels.Set(svc.Labels)) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinASNs(asns []int64) string {
+	s := make([]string, 0, len(asns))
+	for _, asn := range asns {
+		s = append(s, fmt.Sprint(asn))
+	}
+	return strings.Join(s, ", ")
+}
+
+// comparePeers compares the peers the policies intend each node to have with
+// the live BGP sessions of its agent. Nodes for which no session could be
+// fetched, or selected by several policies, are skipped.
+func comparePeers(nodePolicies map[string]*ciliumv2alpha1.CiliumBGPPeeringPolicy, peersPerNode map[string][]*models.BgpPeer) []Finding {
+	var findings []Finding
+
+	var nodes []string
+	for node := range peersPerNode {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		policy, ok := nodePolicies[node]
+		if ok && policy == nil {
+			continue
+		}
+		policyName := ""
+		intended := make(map[peerKey]bool)
+		if policy != nil {
+			policyName = policy.Name
+			for _, router := range policy.Spec.VirtualRouters {
+				for _, neighbor := range router.Neighbors {
+					if prefix, err := netip.ParsePrefix(neighbor.PeerAddress); err == nil {
+						intended[peerKey{localASN: router.LocalASN, address: prefix.Addr().String()}] = true
+					}
+				}
+			}
+		}
+
+		live := make(map[peerKey]bool)
+		for _, peer := range peersPerNode[node] {
+			key := peerKey{localASN: peer.LocalAsn, address: peer.PeerAddress}
+			if addr, err := netip.ParseAddr(peer.PeerAddress); err == nil {
+				key.address = addr.String()
+			}
+			live[key] = true
+
+			switch {
+			case !intended[key]:
+				findings = append(findings, Finding{
+					Severity: SeverityWarning,
+					Check:    checkPeerUnexpected,
+					Policy:   policyName,
+					Node:     node,
+					Message:  fmt.Sprintf("peer %s is configured on the node but not in the policy", key),
+				})
+			case peer.SessionState != sessionEstablished:
+				findings = append(findings, Finding{
+					Severity: SeverityWarning,
+					Check:    checkPeerNotEstablished,
+					Policy:   policyName,
+					Node:     node,
+					Message:  fmt.Sprintf("session with peer %s is %s", key, peer.SessionState),
+				})
+			}
+		}
+
+		var missing []peerKey
+		for key := range intended {
+			if !live[key] {
+				missing = append(missing, key)
+			}
+		}
+		sort.Slice(missing, func(i, j int) bool {
+			if missing[i].localASN != missing[j].localASN {
+				return missing[i].localASN < missing[j].localASN
+			}
+			return missing[i].address < missing[j].address
+		})
+		for _, key := range missing {
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Check:    checkPeerMissing,
+				Policy:   policyName,
+				Node:     node,
+				Message:  fmt.Sprintf("peer %s of the policy is not configured on the node", key),
+			})
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package bgp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cilium/cilium/api/v1/models"
+	ciliumv2alpha1 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2alpha1"
+	slimv1 "github.com/cilium/cilium/pkg/k8s/slim/k8s/apis/meta/v1"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func bgpPolicy(name string, nodeSelector map[string]string, routers ...ciliumv2alpha1.CiliumBGPVirtualRouter) ciliumv2alpha1.CiliumBGPPeeringPolicy {
+	p := ciliumv2alpha1.CiliumBGPPeeringPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       ciliumv2alpha1.CiliumBGPPeeringPolicySpec{VirtualRouters: routers},
+	}
+	if nodeSelector != nil {
+		p.Spec.NodeSelector = &slimv1.LabelSelector{MatchLabels: nodeSelector}
+	}
+	return p
+}
+
+func virtualRouter(localASN int64, peerAddresses ...string) ciliumv2alpha1.CiliumBGPVirtualRouter {
+	r := ciliumv2alpha1.CiliumBGPVirtualRouter{LocalASN: localASN}
+	for _, addr := range peerAddresses {
+		r.Neighbors = append(r.Neighbors, ciliumv2alpha1.CiliumBGPNeighbor{PeerAddress: addr, PeerASN: 65000})
+	}
+	return r
+}
+
+func bgpNode(name string, lbls map[string]string) corev1.Node {
+	return corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: lbls}}
+}
+
+func TestValidatePolicies(t *testing.T) {
+	rack1 := map[string]string{"rack": "rack1"}
+	rack2 := map[string]string{"rack": "rack2"}
+
+	withServices := virtualRouter(65001, "10.0.0.1/32")
+	withServices.ServiceSelector = &slimv1.LabelSelector{MatchLabels: map[string]string{"bgp": "announce"}}
+	noServices := virtualRouter(65002)
+	noServices.ServiceSelector = &slimv1.LabelSelector{MatchLabels: map[string]string{"bgp": "none"}}
+	invalidTimers := virtualRouter(65003, "10.0.0.3/32")
+	invalidTimers.Neighbors[0].KeepAliveTimeSeconds = pointer.Int32(100)
+
+	policies := []ciliumv2alpha1.CiliumBGPPeeringPolicy{
+		bgpPolicy("rack1", rack1, withServices, noServices),
+		bgpPolicy("all", nil, virtualRouter(65010, "10.0.1.1/32")),
+		bgpPolicy("none", map[string]string{"rack": "rack3"}),
+		bgpPolicy("invalid", rack2,
+			virtualRouter(65001, "10.0.0.1", "10.0.0.2/32", "10.0.0.2/32"),
+			virtualRouter(65001, "10.0.0.2/32"),
+			invalidTimers,
+		),
+	}
+	nodes := []corev1.Node{
+		bgpNode("node-1", rack1),
+		bgpNode("node-2", rack2),
+	}
+	services := []corev1.Service{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "lb", Labels: map[string]string{"bgp": "announce"}},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-ip", Labels: map[string]string{"bgp": "none"}},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+		},
+	}
+
+	findings, nodePolicies := validatePolicies(policies, nodes, services)
+	require.Equal(t, []Finding{
+		{Severity: SeverityError, Check: checkInvalidNeighbor, Policy: "invalid", Message: `neighbor "10.0.0.1" of virtual router 65001 is not a valid CIDR`},
+		{Severity: SeverityError, Check: checkInvalidNeighbor, Policy: "invalid", Message: "neighbor 10.0.0.2 is configured several times in virtual router 65001"},
+		{Severity: SeverityError, Check: checkDuplicateLocalASN, Policy: "invalid", Message: "local ASN 65001 is used by several virtual routers"},
+		{Severity: SeverityError, Check: checkInvalidNeighbor, Policy: "invalid", Message: "neighbor 10.0.0.3/32 of virtual router 65003 is invalid: KeepAliveTimeSeconds larger than HoldTimeSeconds for peer ASN:65000 IP:10.0.0.3/32"},
+		{Severity: SeverityWarning, Check: checkNodeSelectorNoMatch, Policy: "none", Message: `node selector "rack=rack3" matches no node`},
+		{Severity: SeverityWarning, Check: checkServiceSelectorNoMatch, Policy: "rack1", Message: `service selector "bgp=none" of virtual router 65002 matches no LoadBalancer service`},
+		{Severity: SeverityError, Check: checkNodeMultiplePolicies, Node: "node-1", Message: "node is selected by several policies all, rack1, none of them is applied"},
+		{Severity: SeverityError, Check: checkNodeMultiplePolicies, Node: "node-2", Message: "node is selected by several policies all, invalid, none of them is applied"},
+	}, findings)
+	require.Equal(t, map[string]*ciliumv2alpha1.CiliumBGPPeeringPolicy{"node-1": nil, "node-2": nil}, nodePolicies)
+
+	// The same neighbor in virtual routers with different local ASNs.
+	findings, _ = validatePolicies([]ciliumv2alpha1.CiliumBGPPeeringPolicy{
+		bgpPolicy("conflict", nil, virtualRouter(65001, "10.0.0.1/32"), virtualRouter(65002, "10.0.0.1/32")),
+	}, nodes, nil)
+	require.Equal(t, []Finding{
+		{Severity: SeverityWarning, Check: checkConflictingLocalASN, Policy: "conflict", Message: "neighbor 10.0.0.1 is configured in virtual routers with different local ASNs 65001, 65002"},
+	}, findings)
+
+	// Selectors which cannot be parsed.
+	invalidSelector := &slimv1.LabelSelector{MatchExpressions: []slimv1.LabelSelectorRequirement{{Key: "rack", Operator: "Bogus"}}}
+	invalidNodes := bgpPolicy("invalid-nodes", nil)
+	invalidNodes.Spec.NodeSelector = invalidSelector
+	invalidServices := virtualRouter(65001)
+	invalidServices.ServiceSelector = invalidSelector
+	findings, _ = validatePolicies([]ciliumv2alpha1.CiliumBGPPeeringPolicy{
+		invalidNodes,
+		bgpPolicy("invalid-services", rack1, invalidServices),
+	}, nodes, services)
+	require.Len(t, findings, 2)
+	require.Equal(t, checkInvalidNodeSelector, findings[0].Check)
+	require.Equal(t, "invalid-nodes", findings[0].Policy)
+	require.Equal(t, checkInvalidServiceSelector, findings[1].Check)
+	require.Equal(t, "invalid-services", findings[1].Policy)
+}
+
+func TestComparePeers(t *testing.T) {
+	policy := bgpPolicy("policy", nil, virtualRouter(65001, "10.0.0.1/32", "10.0.0.2/32", "fd00::1/128"))
+	nodePolicies := map[string]*ciliumv2alpha1.CiliumBGPPeeringPolicy{
+		"node-1": &policy,
+		"node-2": &policy,
+		"node-3": nil,
+	}
+	peersPerNode := map[string][]*models.BgpPeer{
+		"node-1": {
+			{LocalAsn: 65001, PeerAddress: "10.0.0.1", SessionState: sessionEstablished},
+			{LocalAsn: 65001, PeerAddress: "10.0.0.2", SessionState: sessionEstablished},
+			{LocalAsn: 65001, PeerAddress: "fd00:0::1", SessionState: sessionEstablished},
+		},
+		"node-2": {
+			{LocalAsn: 65001, PeerAddress: "10.0.0.1", SessionState: "active"},
+			{LocalAsn: 65002, PeerAddress: "10.0.0.2", SessionState: sessionEstablished},
+		},
+		// Nodes selected by several policies are skipped.
+		"node-3": {
+			{LocalAsn: 65001, PeerAddress: "10.0.0.1", SessionState: sessionEstablished},
+		},
+		"node-4": {
+			{LocalAsn: 65001, PeerAddress: "10.0.0.1", SessionState: sessionEstablished},
+		},
+	}
+
+	require.Equal(t, []Finding{
+		{Severity: SeverityWarning, Check: checkPeerNotEstablished, Policy: "policy", Node: "node-2", Message: "session with peer 10.0.0.1 (local ASN 65001) is active"},
+		{Severity: SeverityWarning, Check: checkPeerUnexpected, Policy: "policy", Node: "node-2", Message: "peer 10.0.0.2 (local ASN 65002) is configured on the node but not in the policy"},
+		{Severity: SeverityError, Check: checkPeerMissing, Policy: "policy", Node: "node-2", Message: "peer 10.0.0.2 (local ASN 65001) of the policy is not configured on the node"},
+		{Severity: SeverityError, Check: checkPeerMissing, Policy: "policy", Node: "node-2", Message: "peer fd00::1 (local ASN 65001) of the policy is not configured on the node"},
+		{Severity: SeverityWarning, Check: checkPeerUnexpected, Node: "node-4", Message: "peer 10.0.0.1 (local ASN 65001) is configured on the node but not in the policy"},
+	}, comparePeers(nodePolicies, peersPerNode))
+}
+
+func TestWriteFindings(t *testing.T) {
+	var out bytes.Buffer
+	writeFindings(&out, nil)
+	require.Equal(t, "✅ No issues found\n", out.String())
+
+	out.Reset()
+	writeFindings(&out, []Finding{{Severity: SeverityError, Check: checkPeerMissing, Node: "node-1", Message: "missing"}})
+	require.Equal(t, "Severity   Check          Policy   Node     Message\nerror      peer-missing   -        node-1   missing\n", out.String())
+}
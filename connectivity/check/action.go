@@ -78,12 +78,27 @@ type Action struct {
 	// started is the timestamp the test started
 	started time.Time
 
+	// completed is the timestamp the test completed
+	completed time.Time
+
 	// failed is true when Fail was called on the Action
 	failed bool
 
+	// failures holds the messages passed to Fail and friends
+	failures []string
+
+	// Command executed by the action, if any
+	cmd []string
+
+	// Exit code of the command executed by the action
+	exitCode ExitCode
+
 	// Output from action if there is any
 	cmdOutput string
 
+	// metricsValidations holds the outcome of each metrics assertion.
+	metricsValidations []metricsValidation
+
 	// metricsPerSource collected at the initialisation of an Action.
 	metricsPerSource promMetricsPerSource
 }
@@ -148,6 +163,11 @@ func (a *Action) Run(f func(*Action)) {
 	// Emit unbuffered progress indicator.
 	a.test.progress()
 
+	// Store completion time of the Action, f might call Fatal().
+	defer func() {
+		a.completed = time.Now()
+	}()
+
 	// Retrieve Prometheus metrics only if there are expectations.
 	for _, m := range a.expIngress.Metrics {
 		err := a.collectMetricsPerSource(m)
@@ -237,8 +257,9 @@ func (a *Action) collectMetricsPerSource(m MetricsResult) error {
 }
 
 // fail marks the Action as failed.
-func (a *Action) fail() {
+func (a *Action) fail(msg string) {
 	a.failed = true
+	a.failures = append(a.failures, msg)
 }
 
 // WriteDataToPod writes data to a file in the source pod
@@ -301,6 +322,13 @@ func (a *Action) ExecInPod(ctx context.Context, cmd []string) {
 		}
 		break
 	}
+
+	a.cmd = cmd
+	a.exitCode = 0
+	if err != nil {
+		a.exitCode, _ = a.extractExitCode(err)
+	}
+
 	// Check for inconclusive results.
 	if err == nil && strings.TrimSpace(pingHeaderPattern.ReplaceAllString(output.String(), "")) == "" {
 		a.Failf("inconclusive results: command %q was successful but without output", cmdStr)
@@ -615,6 +643,9 @@ func (a *Action) GetEgressRequirements(p FlowParameters) (reqs []filters.FlowSet
 			} else {
 				egress.Except = append(egress.Except, filters.FlowRequirement{Filter: filters.And(filters.Or(filters.And(ipRequest, tcpRequest), filters.And(ipResponse, tcpResponse)), filters.RST()), Msg: "RST"})
 			}
+			if p.UntranslatedDstIP != "" {
+				egress.Except = append(egress.Except, filters.FlowRequirement{Filter: filters.IP(srcIP, p.UntranslatedDstIP), Msg: "Untranslated destination"})
+			}
 			if a.expEgress.L7Proxy || a.expEgress.HTTP.Status != "" || a.expEgress.HTTP.Method != "" || a.expEgress.HTTP.URL != "" {
 				// HTTP access logs may come from a separate Envoy proxy upstream connection which may be
 				// kept open. Add a separate flow requirement with FIN filter replaced with a L7/HTTP
@@ -1025,6 +1056,13 @@ func (a *Action) ValidateMetrics(ctx context.Context, pod Pod, results []Metrics
 	}
 }
 
+// metricsValidation is the outcome of a metrics assertion on a node.
+type metricsValidation struct {
+	source string
+	node   string
+	err    error
+}
+
 func (a *Action) validateMetric(ctx context.Context, node string, result MetricsResult) {
 	if result.IsEmpty() {
 		a.Debugf("there is no source configured to retrieve metrics for node %s", node)
@@ -1043,7 +1081,8 @@ func (a *Action) validateMetric(ctx context.Context, node string, result Metrics
 		// Collect the new metrics.
 		newMetrics, err := a.collectPrometheusMetricsForNode(result.Source, node)
 		if err != nil {
-			a.Failf("failed to collect new metrics on node %s: %w", node, err)
+			a.metricsValidations = append(a.metricsValidations, metricsValidation{source: result.Source.Name, node: node, err: err})
+			a.Failf("failed to collect new metrics on node %s: %s", node, err)
 			return
 		}
 
@@ -1054,6 +1093,7 @@ func (a *Action) validateMetric(ctx context.Context, node string, result Metrics
 		} else {
 			// Metrics check succeed, let's exit.
 			a.Debugf("checked metrics properly on node %s\n", node)
+			a.metricsValidations = append(a.metricsValidations, metricsValidation{source: result.Source.Name, node: node})
 			return
 
 		}
@@ -1061,6 +1101,7 @@ func (a *Action) validateMetric(ctx context.Context, node string, result Metrics
 		select {
 		case <-ctx.Done():
 			// Context timeout is reached, let's exit.
+			a.metricsValidations = append(a.metricsValidations, metricsValidation{source: result.Source.Name, node: node, err: err})
 			a.Failf("failed to collect metrics on node %s, context timeout: %s\n", node, ctx.Err())
 			return
 		case <-ticker.C:
@@ -0,0 +1,373 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package check
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	ciliumv2alpha1 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2alpha1"
+	slimv1 "github.com/cilium/cilium/pkg/k8s/slim/k8s/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	"github.com/cilium/cilium-cli/defaults"
+	"github.com/cilium/cilium-cli/internal/utils"
+	"github.com/cilium/cilium-cli/k8s"
+)
+
+const (
+	bgpPeerDeploymentName = "bgp-peer"
+	bgpPeerConfigMapName  = "bgp-peer-config"
+	// EchoBGPServiceName is the name of the LoadBalancer service announced
+	// over BGP to the stand-in BGP peer.
+	EchoBGPServiceName = "echo-bgp"
+
+	kindBGPPeerName = "bgp-peer"
+	kindEchoBGPName = "echo-bgp"
+
+	// BGPPeerASN is the ASN of the stand-in BGP peer.
+	BGPPeerASN = 65000
+	// BGPCiliumASN is the ASN of the Cilium virtual routers peering with the
+	// stand-in BGP peer.
+	BGPCiliumASN = 65001
+)
+
+// bgpServiceLabels select the services announced to the stand-in BGP peer,
+// and allocated an IP from the LoadBalancer IP pool of the BGP tests.
+var bgpServiceLabels = map[string]string{
+	"kind": kindEchoBGPName,
+}
+
+// bgpPeerDaemons enables bgpd in addition to zebra, which installs the routes
+// learned by the stand-in BGP peer in the kernel of its node.
+const bgpPeerDaemons = `bgpd=yes
+vtysh_enable=yes
+zebra_options="  -A 127.0.0.1 -s 90000000"
+bgpd_options="   -A 127.0.0.1"
+`
+
+// bgpPeerConfig makes the stand-in BGP peer accept sessions from any Cilium
+// node, and use all of them as next hops for the prefixes they share, such as
+// LoadBalancer IPs.
+var bgpPeerConfig = fmt.Sprintf(`frr defaults traditional
+hostname bgp-peer
+log stdout
+!
+router bgp %d
+ no bgp ebgp-requires-policy
+ bgp bestpath as-path multipath-relax
+ neighbor CILIUM peer-group
+ neighbor CILIUM remote-as %d
+ bgp listen range 0.0.0.0/0 peer-group CILIUM
+ !
+ address-family ipv4 unicast
+  maximum-paths 16
+ exit-address-family
+exit
+!
+`, BGPPeerASN, BGPCiliumASN)
+
+// needsBGPPeer returns whether the stand-in BGP peer of the BGP tests must be
+// deployed. It runs on a node without Cilium, on which it installs the routes
+// it learns, hence the tests are considered unsafe.
+func (ct *ConnectivityTest) needsBGPPeer() bool {
+	return ct.params.IncludeUnsafeTests && !ct.params.Perf &&
+		ct.Features[FeatureBGPControlPlane].Enabled &&
+		ct.Features[FeatureNodeWithoutCilium].Enabled
+}
+
+// deployBGPPeer deploys FRR in the host network of a node without Cilium, as
+// a stand-in BGP peer for the Cilium nodes.
+func (ct *ConnectivityTest) deployBGPPeer(ctx context.Context) error {
+	client := ct.clients.src
+
+	_, err := client.GetConfigMap(ctx, ct.params.TestNamespace, bgpPeerConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s configmap...", client.ClusterName(), bgpPeerConfigMapName)
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: bgpPeerConfigMapName},
+			Data: map[string]string{
+				"daemons":  bgpPeerDaemons,
+				"frr.conf": bgpPeerConfig,
+			},
+		}
+		_, err = client.CreateConfigMap(ctx, ct.params.TestNamespace, cm, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create configmap %s: %w", bgpPeerConfigMapName, err)
+		}
+	}
+
+	_, err = client.GetDeployment(ctx, ct.params.TestNamespace, bgpPeerDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s deployment...", client.ClusterName(), bgpPeerDeploymentName)
+		dep := newDeployment(deploymentParameters{
+			Name:      bgpPeerDeploymentName,
+			Kind:      kindBGPPeerName,
+			Image:     ct.params.FRRImage,
+			NamedPort: "bgp",
+			Port:      179,
+			// The configmap is copied as FRR needs to own its configuration.
+			Command:     []string{"/bin/sh", "-c", "cp /etc/frr-config/* /etc/frr/ && exec /usr/lib/frr/docker-start"},
+			HostNetwork: true,
+			Tolerations: []corev1.Toleration{
+				{Operator: corev1.TolerationOpExists},
+			},
+			NodeSelector: map[string]string{
+				defaults.CiliumNoScheduleLabel: "true",
+			},
+		})
+		container := &dep.Spec.Template.Spec.Containers[0]
+		// to install IP routes
+		container.SecurityContext.Capabilities.Add = append(container.SecurityContext.Capabilities.Add, "NET_ADMIN", "SYS_ADMIN")
+		container.VolumeMounts = []corev1.VolumeMount{
+			{Name: bgpPeerConfigMapName, MountPath: "/etc/frr-config", ReadOnly: true},
+		}
+		dep.Spec.Template.Spec.Volumes = []corev1.Volume{
+			{
+				Name: bgpPeerConfigMapName,
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: bgpPeerConfigMapName},
+					},
+				},
+			},
+		}
+
+		_, err = client.CreateServiceAccount(ctx, ct.params.TestNamespace, k8s.NewServiceAccount(bgpPeerDeploymentName), metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create service account %s: %w", bgpPeerDeploymentName, err)
+		}
+		_, err = client.CreateDeployment(ctx, ct.params.TestNamespace, dep, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create deployment %s: %w", bgpPeerDeploymentName, err)
+		}
+	}
+
+	return nil
+}
+
+// validateBGPPeer waits for the stand-in BGP peer to be ready, and records its
+// pod for the BGP tests.
+func (ct *ConnectivityTest) validateBGPPeer(ctx context.Context) error {
+	client := ct.clients.src
+
+	if err := WaitForDeployment(ctx, ct, client, ct.params.TestNamespace, bgpPeerDeploymentName); err != nil {
+		return err
+	}
+
+	pods, err := client.ListPods(ctx, ct.params.TestNamespace, metav1.ListOptions{LabelSelector: "kind=" + kindBGPPeerName})
+	if err != nil {
+		return fmt.Errorf("unable to list BGP peer pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		ct.bgpPeerPods[pod.Name] = Pod{
+			K8sClient: client,
+			Pod:       pod.DeepCopy(),
+			Outside:   true,
+		}
+	}
+
+	return nil
+}
+
+// bgpResourceName returns the name of the cluster-wide resources created by
+// the BGP tests, derived from the test namespace so that concurrent runs in
+// different namespaces don't conflict.
+func (ct *ConnectivityTest) bgpResourceName() string {
+	return ct.params.TestNamespace + "-bgp"
+}
+
+// deleteBGPResources deletes the cluster-wide resources created by the BGP
+// tests, in case they were left behind by an interrupted run.
+func (ct *ConnectivityTest) deleteBGPResources(ctx context.Context, client *k8s.Client) {
+	_ = client.DeleteCiliumBGPPeeringPolicy(ctx, ct.bgpResourceName(), metav1.DeleteOptions{})
+	_ = client.DeleteCiliumLoadBalancerIPPool(ctx, ct.bgpResourceName(), metav1.DeleteOptions{})
+}
+
+// WithBGPPeeringPolicy instructs the test runner to peer the Cilium nodes with
+// the stand-in BGP peer for the duration of the test. A CiliumBGPPeeringPolicy
+// exporting the PodCIDRs and the LoadBalancer IP of the echo-bgp service, in
+// front of the echo-same-node pods, is applied before running the test, along
+// with the IP pool of the service, and deleted after its completion.
+func (t *Test) WithBGPPeeringPolicy() *Test {
+	if !t.Context().Params().IncludeUnsafeTests {
+		t.Fatal("WithBGPPeeringPolicy() requires enabling --include-unsafe-tests")
+	}
+
+	return t.WithSetupFunc(func(ctx context.Context, t *Test, ct *ConnectivityTest) error {
+		return ct.applyBGPPeeringPolicy(ctx, t)
+	})
+}
+
+func (ct *ConnectivityTest) applyBGPPeeringPolicy(ctx context.Context, t *Test) error {
+	client := ct.client
+	name := ct.bgpResourceName()
+
+	peer, ok := ct.BGPPeerPod()
+	if !ok {
+		return fmt.Errorf("no BGP peer pod available")
+	}
+	peerIP := peer.Pod.Status.HostIP
+	if GetIPFamily(peerIP) != IPFamilyV4 {
+		return fmt.Errorf("BGP peer pod %s has no IPv4 host IP", peer.Name())
+	}
+
+	// The Cilium agents refuse to apply any policy to nodes selected by
+	// several of them.
+	policies, err := client.ListCiliumBGPPeeringPolicies(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to list CiliumBGPPeeringPolicies: %w", err)
+	}
+	for _, p := range policies.Items {
+		if p.Name != name {
+			return fmt.Errorf("CiliumBGPPeeringPolicy %s would conflict with the policy of the test", p.Name)
+		}
+	}
+
+	t.Infof("🌐 Creating CiliumLoadBalancerIPPool '%s'...", name)
+	pool := &ciliumv2alpha1.CiliumLoadBalancerIPPool{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: ciliumv2alpha1.CiliumLoadBalancerIPPoolSpec{
+			ServiceSelector: &slimv1.LabelSelector{MatchLabels: bgpServiceLabels},
+			Cidrs: []ciliumv2alpha1.CiliumLoadBalancerIPPoolCIDRBlock{
+				{Cidr: ciliumv2alpha1.IPv4orIPv6CIDR(ct.params.BGPLoadBalancerCIDR)},
+			},
+		},
+	}
+	if _, err := client.CreateCiliumLoadBalancerIPPool(ctx, pool, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("unable to create CiliumLoadBalancerIPPool %s: %w", name, err)
+	}
+	t.WithFinalizer(func() error {
+		return client.DeleteCiliumLoadBalancerIPPool(context.TODO(), name, metav1.DeleteOptions{})
+	})
+
+	t.Infof("🌐 Creating LoadBalancer service '%s'...", EchoBGPServiceName)
+	svc := newService(EchoBGPServiceName, map[string]string{"name": echoSameNodeDeploymentName}, bgpServiceLabels, "http", 8080)
+	svc.Spec.Type = corev1.ServiceTypeLoadBalancer
+	ipFamPol := corev1.IPFamilyPolicySingleStack
+	svc.Spec.IPFamilyPolicy = &ipFamPol
+	svc.Spec.IPFamilies = []corev1.IPFamily{corev1.IPv4Protocol}
+	if _, err := client.CreateService(ctx, ct.params.TestNamespace, svc, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("unable to create service %s: %w", EchoBGPServiceName, err)
+	}
+	t.WithFinalizer(func() error {
+		return client.DeleteService(context.TODO(), ct.params.TestNamespace, EchoBGPServiceName, metav1.DeleteOptions{})
+	})
+
+	t.Infof("🌐 Applying CiliumBGPPeeringPolicy '%s' peering with %s...", name, peerIP)
+	policy := &ciliumv2alpha1.CiliumBGPPeeringPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: ciliumv2alpha1.CiliumBGPPeeringPolicySpec{
+			VirtualRouters: []ciliumv2alpha1.CiliumBGPVirtualRouter{
+				{
+					LocalASN:        BGPCiliumASN,
+					ExportPodCIDR:   pointer.Bool(true),
+					ServiceSelector: &slimv1.LabelSelector{MatchLabels: bgpServiceLabels},
+					Neighbors: []ciliumv2alpha1.CiliumBGPNeighbor{
+						{
+							PeerAddress: peerIP + "/32",
+							PeerASN:     BGPPeerASN,
+							// Retry quickly in case the first connection attempts fail.
+							ConnectRetryTimeSeconds: pointer.Int32(5),
+						},
+					},
+				},
+			},
+		},
+	}
+	if _, err := client.CreateCiliumBGPPeeringPolicy(ctx, policy, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("unable to create CiliumBGPPeeringPolicy %s: %w", name, err)
+	}
+	t.WithFinalizer(func() error {
+		if err := client.DeleteCiliumBGPPeeringPolicy(context.TODO(), name, metav1.DeleteOptions{}); err != nil {
+			return err
+		}
+		// Wait for the routes to be withdrawn, as they would conflict with the
+		// static routes installed by the other tests on nodes without Cilium.
+		return waitForBGPPeerRoutesWithdrawn(context.TODO(), t, peer)
+	})
+
+	return nil
+}
+
+func waitForBGPPeerRoutesWithdrawn(ctx context.Context, t *Test, peer Pod) error {
+	w := utils.NewWaitObserver(ctx, utils.WaitParameters{Timeout: time.Minute})
+	defer w.Cancel()
+
+	for {
+		routes, err := BGPPeerRoutes(ctx, peer)
+		if err == nil && len(routes) > 0 {
+			err = fmt.Errorf("%d routes still installed", len(routes))
+		}
+		if err == nil {
+			return nil
+		}
+		t.Debugf("Waiting for BGP peer %s to withdraw routes: %s", peer.Name(), err)
+		if err := w.Retry(err); err != nil {
+			return fmt.Errorf("BGP peer %s did not withdraw routes: %w", peer.Name(), err)
+		}
+	}
+}
+
+// BGPPeerSessions returns the state of the BGP sessions of the stand-in BGP
+// peer, by peer address.
+func BGPPeerSessions(ctx context.Context, peer Pod) (map[string]string, error) {
+	var summary struct {
+		Peers map[string]struct {
+			State string `json:"state"`
+		} `json:"peers"`
+	}
+	if err := execVtysh(ctx, peer, "show bgp ipv4 unicast summary json", &summary); err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]string, len(summary.Peers))
+	for addr, p := range summary.Peers {
+		sessions[addr] = p.State
+	}
+	return sessions, nil
+}
+
+// BGPPeerRoutes returns the next hops of the BGP routes installed in the
+// kernel by the stand-in BGP peer, by prefix.
+func BGPPeerRoutes(ctx context.Context, peer Pod) (map[string][]string, error) {
+	var table map[string][]struct {
+		Installed bool `json:"installed"`
+		Nexthops  []struct {
+			IP string `json:"ip"`
+		} `json:"nexthops"`
+	}
+	if err := execVtysh(ctx, peer, "show ip route bgp json", &table); err != nil {
+		return nil, err
+	}
+
+	routes := make(map[string][]string)
+	for prefix, entries := range table {
+		for _, entry := range entries {
+			if !entry.Installed {
+				continue
+			}
+			for _, nh := range entry.Nexthops {
+				routes[prefix] = append(routes[prefix], nh.IP)
+			}
+		}
+	}
+	return routes, nil
+}
+
+func execVtysh(ctx context.Context, peer Pod, command string, v any) error {
+	stdout, err := peer.K8sClient.ExecInPod(ctx, peer.Pod.Namespace, peer.Pod.Name, bgpPeerDeploymentName,
+		[]string{"vtysh", "-c", command})
+	if err != nil {
+		return fmt.Errorf("failed to run %q in %s: %w", command, peer.Name(), err)
+	}
+	if err := json.Unmarshal(stdout.Bytes(), v); err != nil {
+		return fmt.Errorf("failed to unmarshal output of %q in %s: %w", command, peer.Name(), err)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/netip"
 	"regexp"
 	"strings"
 	"time"
@@ -32,6 +33,7 @@ type Parameters struct {
 	RunTests              []*regexp.Regexp
 	SkipTests             []*regexp.Regexp
 	PostTestSleepDuration time.Duration
+	TestConcurrency       int
 	FlowValidation        string
 	AllFlows              bool
 	Writer                io.ReadWriter
@@ -45,24 +47,34 @@ type Parameters struct {
 	PerfCRR               bool
 	PerfHostNet           bool
 	PerfSamples           int
+	PerfLatency           bool
+	PerfService           bool
+	PerfNodeMatrix        bool
+	PerfReportFile        string
 	CurlImage             string
 	PerformanceImage      string
 	JSONMockImage         string
+	AgnhostImage          string
 	AgentDaemonSetName    string
 	DNSTestServerImage    string
+	FRRImage              string
 	IncludeUnsafeTests    bool
 	AgentPodSelector      string
 	NodeSelector          map[string]string
 	DeploymentAnnotations annotationsMap
 	NamespaceAnnotations  annotations
 	ExternalTarget        string
+	ExternalOtherTarget   string
 	ExternalCIDR          string
 	ExternalIP            string
 	ExternalOtherIP       string
+	BGPLoadBalancerCIDR   string
 	PodCIDRs              []podCIDRs
 	NodesWithoutCiliumIPs []nodesWithoutCiliumIP
 	JunitFile             string
 	JunitProperties       map[string]string
+	JSONReportFile        string
+	SuiteFile             string
 
 	IncludeConnDisruptTest        bool
 	ConnDisruptTestSetup          bool
@@ -88,6 +100,12 @@ type Parameters struct {
 
 	ExternalTargetCANamespace string
 	ExternalTargetCAName      string
+
+	Offline bool
+
+	// SecondaryTestNamespace is the namespace of the echo and client pods
+	// used by the cross-namespace tests. The tests are skipped if empty.
+	SecondaryTestNamespace string
 }
 
 type podCIDRs struct {
@@ -203,6 +221,16 @@ func (p Parameters) validate() error {
 		return fmt.Errorf("invalid flow validation mode %q", p.FlowValidation)
 	}
 
+	if p.SecondaryTestNamespace != "" && p.SecondaryTestNamespace == p.TestNamespace {
+		return fmt.Errorf("secondary test namespace must differ from test namespace %q", p.TestNamespace)
+	}
+
+	if p.BGPLoadBalancerCIDR != "" {
+		if prefix, err := netip.ParsePrefix(p.BGPLoadBalancerCIDR); err != nil || !prefix.Addr().Is4() {
+			return fmt.Errorf("invalid BGP LoadBalancer CIDR %q, must be an IPv4 CIDR", p.BGPLoadBalancerCIDR)
+		}
+	}
+
 	return nil
 }
 
@@ -290,6 +318,12 @@ type FlowParameters struct {
 	// DstPort to be matched. This is useful if the destination port is NATed,
 	// which is for example the case for service ports, NodePort or HostPort
 	AltDstPort uint32
+
+	// UntranslatedDstIP, if non-empty, indicates a destination address which
+	// must not be observed, because it is expected to be translated before
+	// Hubble can observe the packet. This is for example the case of the
+	// ClusterIP of a Service with socket-LB
+	UntranslatedDstIP string
 }
 
 type flowsSet []*observer.GetFlowsResponse_Flow
@@ -55,22 +55,37 @@ type ConnectivityTest struct {
 	// Clients for source and destination clusters.
 	clients *deploymentClients
 
-	ciliumPods        map[string]Pod
-	echoPods          map[string]Pod
-	echoExternalPods  map[string]Pod
-	clientPods        map[string]Pod
-	perfClientPods    map[string]Pod
-	perfServerPod     map[string]Pod
-	PerfResults       map[PerfTests]PerfResult
-	echoServices      map[string]Service
-	ingressService    map[string]Service
-	externalWorkloads map[string]ExternalWorkload
+	ciliumPods             map[string]Pod
+	echoPods               map[string]Pod
+	echoExternalPods       map[string]Pod
+	clientPods             map[string]Pod
+	secondaryEchoPods      map[string]Pod
+	secondaryClientPods    map[string]Pod
+	bgpPeerPods            map[string]Pod
+	perfClientPods         map[string]Pod
+	perfServerPod          map[string]Pod
+	perfNodeMatrixPods     map[string]Pod
+	perfServices           map[string]Service
+	PerfResults            map[PerfTests]PerfResult
+	echoServices           map[string]Service
+	ingressService         map[string]Service
+	echoBackendsServices   map[string]Service
+	echoExternalIPServices map[string]Service
+	externalWorkloads      map[string]ExternalWorkload
+
+	// CA of the in-cluster external targets deployed in offline mode.
+	externalTargetCA []byte
+	// Address of the DNS server resolving the in-cluster external targets.
+	externalTargetDNSIP string
 
 	hostNetNSPodsByNode map[string]Pod
 
 	tests     []*Test
 	testNames map[string]struct{}
 
+	// Serializes flushing the output of concurrently running Tests.
+	outputMu *sync.Mutex
+
 	lastFlowTimestamps map[string]time.Time
 
 	nodes                 map[string]*corev1.Node
@@ -81,18 +96,33 @@ type ConnectivityTest struct {
 	helmYAMLValues string
 }
 
+// Targets of the network performance tests.
+const (
+	PerfTargetPod        = "pod"
+	PerfTargetClusterIP  = "cluster-ip"
+	PerfTargetNodePort   = "node-port"
+	PerfTargetNodeMatrix = "node-matrix"
+)
+
 type PerfTests struct {
-	Pod  string
-	Test string
+	Pod    string
+	Test   string
+	Target string
+	// DestinationNode distinguishes the results of the node matrix, where
+	// each client pod runs tests against every node.
+	DestinationNode string
 }
 
 type PerfResult struct {
-	Metric   string
-	Scenario string
-	Duration time.Duration
-	Samples  int
-	Values   []float64
-	Avg      float64
+	Metric          string
+	Scenario        string
+	Target          string
+	SourceNode      string
+	DestinationNode string
+	Duration        time.Duration
+	Samples         int
+	Values          []float64
+	Avg             float64
 }
 
 // verbose returns the value of the user-provided verbosity flag.
@@ -105,6 +135,14 @@ func (ct *ConnectivityTest) debug() bool {
 	return ct.params.Debug
 }
 
+// testConcurrency returns the number of Tests allowed to run concurrently.
+func (ct *ConnectivityTest) testConcurrency() int {
+	if ct.params.TestConcurrency < 1 {
+		return 1
+	}
+	return ct.params.TestConcurrency
+}
+
 // timestamp returns the value of the user-provided timestamp flag.
 func (ct *ConnectivityTest) timestamp() bool {
 	return ct.params.Timestamp
@@ -182,24 +220,32 @@ func NewConnectivityTest(client *k8s.Client, p Parameters, version string) (*Con
 	}
 
 	k := &ConnectivityTest{
-		client:              client,
-		params:              p,
-		version:             version,
-		ciliumPods:          make(map[string]Pod),
-		echoPods:            make(map[string]Pod),
-		echoExternalPods:    make(map[string]Pod),
-		clientPods:          make(map[string]Pod),
-		perfClientPods:      make(map[string]Pod),
-		perfServerPod:       make(map[string]Pod),
-		PerfResults:         make(map[PerfTests]PerfResult),
-		echoServices:        make(map[string]Service),
-		ingressService:      make(map[string]Service),
-		externalWorkloads:   make(map[string]ExternalWorkload),
-		hostNetNSPodsByNode: make(map[string]Pod),
-		nodes:               make(map[string]*corev1.Node),
-		tests:               []*Test{},
-		testNames:           make(map[string]struct{}),
-		lastFlowTimestamps:  make(map[string]time.Time),
+		client:                 client,
+		params:                 p,
+		version:                version,
+		ciliumPods:             make(map[string]Pod),
+		echoPods:               make(map[string]Pod),
+		echoExternalPods:       make(map[string]Pod),
+		clientPods:             make(map[string]Pod),
+		secondaryEchoPods:      make(map[string]Pod),
+		secondaryClientPods:    make(map[string]Pod),
+		bgpPeerPods:            make(map[string]Pod),
+		perfClientPods:         make(map[string]Pod),
+		perfServerPod:          make(map[string]Pod),
+		perfNodeMatrixPods:     make(map[string]Pod),
+		perfServices:           make(map[string]Service),
+		PerfResults:            make(map[PerfTests]PerfResult),
+		echoServices:           make(map[string]Service),
+		ingressService:         make(map[string]Service),
+		echoBackendsServices:   make(map[string]Service),
+		echoExternalIPServices: make(map[string]Service),
+		externalWorkloads:      make(map[string]ExternalWorkload),
+		hostNetNSPodsByNode:    make(map[string]Pod),
+		nodes:                  make(map[string]*corev1.Node),
+		tests:                  []*Test{},
+		testNames:              make(map[string]struct{}),
+		outputMu:               &sync.Mutex{},
+		lastFlowTimestamps:     make(map[string]time.Time),
 	}
 
 	return k, nil
@@ -224,6 +270,7 @@ func (ct *ConnectivityTest) NewTest(name string) *Test {
 		name:      name,
 		scenarios: make(map[Scenario][]*Action),
 		cnps:      make(map[string]*ciliumv2.CiliumNetworkPolicy),
+		ccnps:     make(map[string]*ciliumv2.CiliumClusterwideNetworkPolicy),
 		knps:      make(map[string]*networkingv1.NetworkPolicy),
 		cegps:     make(map[string]*ciliumv2.CiliumEgressGatewayPolicy),
 		verbose:   ct.verbose(),
@@ -231,6 +278,12 @@ func (ct *ConnectivityTest) NewTest(name string) *Test {
 		warnBuf:   &bytes.Buffer{},
 	}
 
+	// Hold back all output of Tests that might run concurrently with others
+	// until they complete.
+	if ct.testConcurrency() > 1 {
+		t.outBuf = &bytes.Buffer{}
+	}
+
 	// Setting the internal buffer to nil causes the logger to
 	// write directly to stdout in verbose or debug mode.
 	if ct.verbose() || ct.debug() {
@@ -351,45 +404,47 @@ func (ct *ConnectivityTest) Run(ctx context.Context) error {
 	ct.Log("🏃 Running tests...")
 
 	// Execute all tests in the order they were registered by the test suite.
+	// Tests that don't alter the cluster are allowed to run concurrently, up
+	// to the user-specified limit. All others wait for any running Tests to
+	// complete and are executed on their own.
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, ct.testConcurrency())
 	for _, t := range ct.tests {
 		if err := ctx.Err(); err != nil {
+			wg.Wait()
 			return err
 		}
 
-		done := make(chan bool)
-
-		go func() {
-			defer func() { done <- true }()
-
-			if err := t.Run(ctx); err != nil {
-				// We know for sure we're inside a separate goroutine, so Fatal()
-				// is safe and will properly record failure statistics.
-				if t.ctx.params.CollectSysdumpOnFailure {
-					t.collectSysdump()
-				}
-				t.Fatalf("Running test %s: %s", t.Name(), err)
-			}
-
-			// Exit immediately if context was cancelled.
-			if err := ctx.Err(); err != nil {
-				return
-			}
-
-			// Pause after each test run if requested by the user.
-			if duration := ct.PostTestSleepDuration(); duration != time.Duration(0) {
-				ct.Infof("Pausing for %s after test %s", duration, t)
-				time.Sleep(duration)
-			}
-		}()
+		if ct.testConcurrency() == 1 || t.runsExclusively() {
+			wg.Wait()
+			ct.runTest(ctx, t)
+			continue
+		}
 
-		// Waiting for the goroutine to finish before starting another Test.
-		<-done
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(t *Test) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			ct.runTest(ctx, t)
+		}(t)
 	}
+	wg.Wait()
 
 	if err := ct.writeJunit(); err != nil {
 		ct.Failf("writing to junit file %s failed: %s", ct.Params().JunitFile, err)
 	}
 
+	if err := ct.writeJSONReport(); err != nil {
+		ct.Failf("writing to JSON report file %s failed: %s", ct.Params().JSONReportFile, err)
+	}
+
+	if err := ct.writePerfReport(); err != nil {
+		ct.Failf("writing to performance report file %s failed: %s", ct.Params().PerfReportFile, err)
+	}
+
 	if ct.Params().FlushCT {
 		var wg sync.WaitGroup
 
@@ -414,10 +469,47 @@ func (ct *ConnectivityTest) Run(ctx context.Context) error {
 	return ct.report()
 }
 
+// runTest executes Test t in a separate goroutine and waits for it to finish.
+// Output of Tests running concurrently with others is held back until they
+// complete, so it doesn't interleave with the output of other Tests.
+func (ct *ConnectivityTest) runTest(ctx context.Context, t *Test) {
+	done := make(chan bool)
+
+	go func() {
+		defer func() { done <- true }()
+
+		if err := t.Run(ctx); err != nil {
+			// We know for sure we're inside a separate goroutine, so Fatal()
+			// is safe and will properly record failure statistics.
+			if t.ctx.params.CollectSysdumpOnFailure {
+				t.collectSysdump()
+			}
+			t.Fatalf("Running test %s: %s", t.Name(), err)
+		}
+
+		// Exit immediately if context was cancelled.
+		if err := ctx.Err(); err != nil {
+			return
+		}
+
+		// Pause after each test run if requested by the user.
+		if duration := ct.PostTestSleepDuration(); duration != time.Duration(0) {
+			t.Infof("Pausing for %s after test %s", duration, t)
+			time.Sleep(duration)
+		}
+	}()
+
+	// Waiting for the goroutine to finish before returning.
+	<-done
+
+	t.flushOutput()
+}
+
 // skip marks the Test as skipped.
 func (ct *ConnectivityTest) skip(t *Test, reason string) {
-	ct.Logf("[=] Skipping Test [%s] (%s)", t.Name(), reason)
+	t.header("[=] Skipping Test [%s] (%s)", t.Name(), reason)
 	t.skipped = true
+	t.skipReason = reason
 }
 
 func (ct *ConnectivityTest) writeJunit() error {
@@ -532,14 +624,19 @@ func (ct *ConnectivityTest) report() error {
 	if ct.params.Perf {
 		// Report Performance results
 		ct.Headerf("🔥 Performance Test Summary: ")
-		ct.Logf("%s", strings.Repeat("-", 145))
-		ct.Logf("📋 %-15s | %-50s | %-15s | %-15s | %-15s | %-15s", "Scenario", "Pod", "Test", "Num Samples", "Duration", "Avg value")
-		ct.Logf("%s", strings.Repeat("-", 145))
-		for p, d := range ct.PerfResults {
-			ct.Logf("📋 %-15s | %-50s | %-15s | %-15d | %-15s | %.2f (%s)", d.Scenario, p.Pod, p.Test, d.Samples, d.Duration, d.Avg, d.Metric)
-			ct.Debugf("Individual Values from run : %s", d.Values)
+		ct.Logf("%s", strings.Repeat("-", 178))
+		ct.Logf("📋 %-15s | %-50s | %-30s | %-20s | %-15s | %-15s | %-15s", "Scenario", "Pod", "Target", "Test", "Num Samples", "Duration", "Avg value")
+		ct.Logf("%s", strings.Repeat("-", 178))
+		for _, p := range ct.sortedPerfTests() {
+			d := ct.PerfResults[p]
+			target := d.Target
+			if p.DestinationNode != "" {
+				target = fmt.Sprintf("%s -> %s", target, p.DestinationNode)
+			}
+			ct.Logf("📋 %-15s | %-50s | %-30s | %-20s | %-15d | %-15s | %.2f (%s)", d.Scenario, p.Pod, target, p.Test, d.Samples, d.Duration, d.Avg, d.Metric)
+			ct.Debugf("Individual Values from run : %v", d.Values)
 		}
-		ct.Logf("%s", strings.Repeat("-", 145))
+		ct.Logf("%s", strings.Repeat("-", 178))
 	}
 
 	ct.Headerf("✅ All %d tests (%d actions) successful, %d tests skipped, %d scenarios skipped.", nt-nst, na, nst, nss)
@@ -886,10 +983,39 @@ func (ct *ConnectivityTest) PerfClientPods() map[string]Pod {
 	return ct.perfClientPods
 }
 
+func (ct *ConnectivityTest) PerfNodeMatrixPods() map[string]Pod {
+	return ct.perfNodeMatrixPods
+}
+
+func (ct *ConnectivityTest) PerfServices() map[string]Service {
+	return ct.perfServices
+}
+
 func (ct *ConnectivityTest) EchoPods() map[string]Pod {
 	return ct.echoPods
 }
 
+// SecondaryClientPods returns the client pods of the secondary test
+// namespace. It is empty unless a secondary test namespace is configured.
+func (ct *ConnectivityTest) SecondaryClientPods() map[string]Pod {
+	return ct.secondaryClientPods
+}
+
+// SecondaryEchoPods returns the echo pods of the secondary test namespace. It
+// is empty unless a secondary test namespace is configured.
+func (ct *ConnectivityTest) SecondaryEchoPods() map[string]Pod {
+	return ct.secondaryEchoPods
+}
+
+// BGPPeerPod returns the pod of the stand-in BGP peer. It is only deployed
+// when the BGP tests are enabled.
+func (ct *ConnectivityTest) BGPPeerPod() (Pod, bool) {
+	for _, pod := range ct.bgpPeerPods {
+		return pod, true
+	}
+	return Pod{}, false
+}
+
 func (ct *ConnectivityTest) EchoServices() map[string]Service {
 	return ct.echoServices
 }
@@ -902,6 +1028,18 @@ func (ct *ConnectivityTest) IngressService() map[string]Service {
 	return ct.ingressService
 }
 
+// EchoExternalIPServices returns the Services exposing the same-node echo
+// server on the external IPs of a node.
+func (ct *ConnectivityTest) EchoExternalIPServices() map[string]Service {
+	return ct.echoExternalIPServices
+}
+
+// EchoBackendsServices returns the Services in front of the echo backends,
+// which reply with their pod name on /hostname.
+func (ct *ConnectivityTest) EchoBackendsServices() map[string]Service {
+	return ct.echoBackendsServices
+}
+
 func (ct *ConnectivityTest) ExternalWorkloads() map[string]ExternalWorkload {
 	return ct.externalWorkloads
 }
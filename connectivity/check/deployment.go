@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
@@ -25,9 +26,21 @@ const (
 	perfClientDeploymentName       = "perf-client"
 	perfClientAcrossDeploymentName = "perf-client-other-node"
 	perfServerDeploymentName       = "perf-server"
+	perfNodeMatrixDaemonSetName    = "perf-node-matrix"
 
 	perfHostNetNamingSuffix = "-host-net"
 
+	// PerfControlPort is the port netserver listens on for control
+	// connections.
+	PerfControlPort = 12865
+	// PerfNodeMatrixControlPort is the port the netserver of the node matrix
+	// listens on for control connections. It differs from PerfControlPort
+	// such that both netservers can run in the host network of the same node.
+	PerfNodeMatrixControlPort = 12866
+
+	perfServiceControlPortName = "control"
+	perfServiceDataPortName    = "data-tcp"
+
 	clientDeploymentName  = "client"
 	client2DeploymentName = "client2"
 
@@ -52,6 +65,19 @@ const (
 	testConnDisruptServiceName          = "test-conn-disrupt"
 	KindTestConnDisrupt                 = "test-conn-disrupt"
 
+	echoExternalIPServiceName = "echo-external-ip"
+	kindEchoExternalIPName    = "echo-external-ip"
+	// EchoExternalIPServicePort is the port of the Service exposing the
+	// same-node echo server on the external IPs of a node.
+	EchoExternalIPServicePort = 40080
+
+	echoBackendsDeploymentName = "echo-backends"
+	echoAffinityServiceName    = "echo-affinity"
+	KindEchoBackends           = "echo-backends"
+	// echoBackendsShutdownDelay is how long the echo backends keep serving
+	// after receiving SIGTERM, to test graceful termination.
+	echoBackendsShutdownDelay = 45 * time.Second
+
 	EchoServerHostPort = 40000
 
 	IngressServiceName         = "ingress-service"
@@ -67,6 +93,7 @@ type perfDeploymentNameManager struct {
 	clientDeploymentName       string
 	clientAcrossDeploymentName string
 	serverDeploymentName       string
+	nodeMatrixDaemonSetName    string
 }
 
 func (nm *perfDeploymentNameManager) ClientName() string {
@@ -81,6 +108,10 @@ func (nm *perfDeploymentNameManager) ServerName() string {
 	return nm.serverDeploymentName
 }
 
+func (nm *perfDeploymentNameManager) NodeMatrixName() string {
+	return nm.nodeMatrixDaemonSetName
+}
+
 func newPerfDeploymentNameManager(params *Parameters) *perfDeploymentNameManager {
 	suffix := ""
 	if params.PerfHostNet {
@@ -91,6 +122,7 @@ func newPerfDeploymentNameManager(params *Parameters) *perfDeploymentNameManager
 		clientDeploymentName:       perfClientDeploymentName + suffix,
 		clientAcrossDeploymentName: perfClientAcrossDeploymentName + suffix,
 		serverDeploymentName:       perfServerDeploymentName + suffix,
+		nodeMatrixDaemonSetName:    perfNodeMatrixDaemonSetName + suffix,
 	}
 }
 
@@ -326,6 +358,65 @@ func newService(name string, selector map[string]string, labels map[string]strin
 	}
 }
 
+// newPerfService returns a NodePort service in front of the netperf server,
+// exposing its control port and a data port. The port of the latter is only a
+// placeholder until setPerfServiceDataPort sets it to its allocated NodePort.
+func newPerfService(name string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"kind": kindPerfName},
+		},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeNodePort,
+			Ports: []corev1.ServicePort{
+				{Name: perfServiceControlPortName, Protocol: corev1.ProtocolTCP, Port: PerfControlPort},
+				{Name: perfServiceDataPortName, Protocol: corev1.ProtocolTCP, Port: PerfControlPort + 1},
+			},
+			Selector: map[string]string{"name": name},
+		},
+	}
+}
+
+// setPerfServiceDataPort sets the service port and target port of the data
+// port of the perf service to its allocated NodePort, for both TCP and UDP.
+// As netperf connects to the port it asks netserver to listen on, all of them
+// need to be the same.
+func setPerfServiceDataPort(svc *corev1.Service) {
+	for i, p := range svc.Spec.Ports {
+		if p.Name != perfServiceDataPortName {
+			continue
+		}
+		svc.Spec.Ports[i].Port = p.NodePort
+		svc.Spec.Ports[i].TargetPort = intstr.FromInt(int(p.NodePort))
+		svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{
+			Name:       "data-udp",
+			Protocol:   corev1.ProtocolUDP,
+			Port:       p.NodePort,
+			TargetPort: intstr.FromInt(int(p.NodePort)),
+			NodePort:   p.NodePort,
+		})
+		return
+	}
+}
+
+// PerfServicePorts returns the NodePort forwarding to the netserver control
+// port and the data port of the perf service s. The data port is zero if it
+// hasn't been set to its allocated NodePort yet.
+func PerfServicePorts(s Service) (controlNodePort, dataPort int) {
+	for _, p := range s.Service.Spec.Ports {
+		switch p.Name {
+		case perfServiceControlPortName:
+			controlNodePort = int(p.NodePort)
+		case perfServiceDataPortName:
+			if p.Port == p.NodePort {
+				dataPort = int(p.Port)
+			}
+		}
+	}
+	return controlNodePort, dataPort
+}
+
 func newLocalReadinessProbe(port int, path string) *corev1.Probe {
 	return &corev1.Probe{
 		ProbeHandler: corev1.ProbeHandler{
@@ -539,6 +630,45 @@ func (ct *ConnectivityTest) deploy(ctx context.Context) error {
 			}
 		}
 
+		if ct.params.PerfService {
+			svc, err := ct.clients.src.GetService(ctx, ct.params.TestNamespace, nm.ServerName(), metav1.GetOptions{})
+			if err != nil {
+				ct.Logf("✨ [%s] Deploying %s service...", ct.clients.src.ClusterName(), nm.ServerName())
+				svc, err = ct.clients.src.CreateService(ctx, ct.params.TestNamespace, newPerfService(nm.ServerName()), metav1.CreateOptions{})
+				if err != nil {
+					return fmt.Errorf("unable to create service %s: %w", nm.ServerName(), err)
+				}
+			}
+			// The NodePorts are allocated by the apiserver on creation.
+			if _, dataPort := PerfServicePorts(Service{Service: svc}); dataPort == 0 {
+				setPerfServiceDataPort(svc)
+				_, err = ct.clients.src.UpdateService(ctx, ct.params.TestNamespace, svc, metav1.UpdateOptions{})
+				if err != nil {
+					return fmt.Errorf("unable to update service %s: %w", nm.ServerName(), err)
+				}
+			}
+		}
+
+		if ct.params.PerfNodeMatrix {
+			_, err = ct.clients.src.GetDaemonSet(ctx, ct.params.TestNamespace, nm.NodeMatrixName(), metav1.GetOptions{})
+			if err != nil {
+				ct.Logf("✨ [%s] Deploying %s daemonset...", ct.clients.src.ClusterName(), nm.NodeMatrixName())
+				ds := newDaemonSet(daemonSetParameters{
+					Name:         nm.NodeMatrixName(),
+					Kind:         kindPerfName,
+					Image:        ct.params.PerformanceImage,
+					Labels:       map[string]string{"role": "matrix"},
+					Command:      []string{"/bin/bash", "-c", fmt.Sprintf("netserver -p %d;sleep 10000000", PerfNodeMatrixControlPort)},
+					HostNetwork:  ct.params.PerfHostNet,
+					NodeSelector: ct.params.NodeSelector,
+				})
+				_, err = ct.clients.src.CreateDaemonSet(ctx, ct.params.TestNamespace, ds, metav1.CreateOptions{})
+				if err != nil {
+					return fmt.Errorf("unable to create daemonset %s: %w", nm.NodeMatrixName(), err)
+				}
+			}
+		}
+
 		// Deploy second client on a different node
 		if !ct.params.SingleNode {
 			_, err := ct.clients.src.GetDeployment(ctx, ct.params.TestNamespace, nm.ClientAcrossName(), metav1.GetOptions{})
@@ -783,6 +913,14 @@ func (ct *ConnectivityTest) deploy(ctx context.Context) error {
 		}
 	}
 
+	// The clients are configured to resolve the names of the external
+	// targets, which hence need to be deployed first.
+	if ct.params.Offline && !ct.params.Perf {
+		if err := ct.deployExternalTargets(ctx); err != nil {
+			return err
+		}
+	}
+
 	_, err = ct.clients.src.GetDeployment(ctx, ct.params.TestNamespace, clientDeploymentName, metav1.GetOptions{})
 	if err != nil {
 		ct.Logf("✨ [%s] Deploying %s deployment...", ct.clients.src.ClusterName(), clientDeploymentName)
@@ -800,6 +938,9 @@ func (ct *ConnectivityTest) deploy(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("unable to create service account %s: %s", clientDeploymentName, err)
 		}
+		if ct.params.Offline {
+			ct.withExternalTargets(clientDeployment)
+		}
 		_, err = ct.clients.src.CreateDeployment(ctx, ct.params.TestNamespace, clientDeployment, metav1.CreateOptions{})
 		if err != nil {
 			return fmt.Errorf("unable to create deployment %s: %s", clientDeploymentName, err)
@@ -839,6 +980,9 @@ func (ct *ConnectivityTest) deploy(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("unable to create service account %s: %s", client2DeploymentName, err)
 		}
+		if ct.params.Offline {
+			ct.withExternalTargets(clientDeployment)
+		}
 		_, err = ct.clients.src.CreateDeployment(ctx, ct.params.TestNamespace, clientDeployment, metav1.CreateOptions{})
 		if err != nil {
 			return fmt.Errorf("unable to create deployment %s: %s", client2DeploymentName, err)
@@ -977,6 +1121,39 @@ func (ct *ConnectivityTest) deploy(ctx context.Context) error {
 		}
 	}
 
+	if ct.needsEchoBackends() {
+		if err := ct.deployEchoBackends(ctx); err != nil {
+			return err
+		}
+	}
+
+	if ct.params.SecondaryTestNamespace != "" && !ct.params.Perf {
+		if err := ct.deploySecondaryNamespace(ctx); err != nil {
+			return err
+		}
+	}
+
+	if ct.needsBGPPeer() {
+		if err := ct.deployBGPPeer(ctx); err != nil {
+			return err
+		}
+	}
+
+	if ct.Features[FeatureKPRExternalIPs].Enabled && !ct.params.Perf {
+		_, err = ct.clients.src.GetService(ctx, ct.params.TestNamespace, echoExternalIPServiceName, metav1.GetOptions{})
+		if err != nil {
+			ct.Logf("✨ [%s] Deploying %s service...", ct.clients.src.ClusterName(), echoExternalIPServiceName)
+			svc, err := ct.newExternalIPService()
+			if err != nil {
+				return err
+			}
+			_, err = ct.clients.src.CreateService(ctx, ct.params.TestNamespace, svc, metav1.CreateOptions{})
+			if err != nil {
+				return fmt.Errorf("unable to create service %s: %w", echoExternalIPServiceName, err)
+			}
+		}
+	}
+
 	// Create one Ingress service for echo deployment
 	if ct.Features[FeatureIngressController].Enabled {
 		_, err = ct.clients.src.GetIngress(ctx, ct.params.TestNamespace, IngressServiceName, metav1.GetOptions{})
@@ -1014,6 +1191,98 @@ func (ct *ConnectivityTest) deploy(ctx context.Context) error {
 	return nil
 }
 
+// newExternalIPService returns a Service in front of the same-node echo server,
+// exposed on the internal IPs of a Cilium node as external IPs.
+func (ct *ConnectivityTest) newExternalIPService() (*corev1.Service, error) {
+	names := maps.Keys(ct.nodes)
+	slices.Sort(names)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no node available for the external IPs of service %s", echoExternalIPServiceName)
+	}
+
+	var externalIPs []string
+	for _, addr := range ct.nodes[names[0]].Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			externalIPs = append(externalIPs, addr.Address)
+		}
+	}
+
+	svc := newService(echoExternalIPServiceName, map[string]string{"name": echoSameNodeDeploymentName},
+		map[string]string{"kind": kindEchoExternalIPName}, "http", EchoExternalIPServicePort)
+	svc.Spec.Type = corev1.ServiceTypeClusterIP
+	svc.Spec.Ports[0].TargetPort = intstr.FromInt(8080)
+	svc.Spec.ExternalIPs = externalIPs
+	return svc, nil
+}
+
+// needsEchoBackends returns whether the echo backends, used by the session
+// affinity and graceful termination tests, need to be deployed.
+func (ct *ConnectivityTest) needsEchoBackends() bool {
+	return !ct.params.Perf && (ct.Features[FeatureKPRSessionAffinity].Enabled ||
+		ct.Features[FeatureKPRGracefulTermination].Enabled)
+}
+
+// deployEchoBackends deploys a set of echo servers replying with their pod
+// name, behind a regular Service and a Service with ClientIP session affinity.
+func (ct *ConnectivityTest) deployEchoBackends(ctx context.Context) error {
+	_, err := ct.clients.src.GetService(ctx, ct.params.TestNamespace, echoBackendsDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s service...", ct.clients.src.ClusterName(), echoBackendsDeploymentName)
+		svc := newService(echoBackendsDeploymentName, map[string]string{"name": echoBackendsDeploymentName},
+			map[string]string{"kind": KindEchoBackends}, "http", 8080)
+		_, err = ct.clients.src.CreateService(ctx, ct.params.TestNamespace, svc, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create service %s: %w", echoBackendsDeploymentName, err)
+		}
+	}
+
+	_, err = ct.clients.src.GetService(ctx, ct.params.TestNamespace, echoAffinityServiceName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s service...", ct.clients.src.ClusterName(), echoAffinityServiceName)
+		svc := newService(echoAffinityServiceName, map[string]string{"name": echoBackendsDeploymentName},
+			map[string]string{"kind": KindEchoBackends}, "http", 8080)
+		svc.Spec.SessionAffinity = corev1.ServiceAffinityClientIP
+		_, err = ct.clients.src.CreateService(ctx, ct.params.TestNamespace, svc, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create service %s: %w", echoAffinityServiceName, err)
+		}
+	}
+
+	_, err = ct.clients.src.GetDeployment(ctx, ct.params.TestNamespace, echoBackendsDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s deployment...", ct.clients.src.ClusterName(), echoBackendsDeploymentName)
+		containerPort := 8080
+		dep := newDeployment(deploymentParameters{
+			Name:     echoBackendsDeploymentName,
+			Kind:     KindEchoBackends,
+			Image:    ct.params.AgnhostImage,
+			Replicas: 2,
+			Port:     containerPort,
+			Command: []string{"/agnhost", "netexec",
+				fmt.Sprintf("--http-port=%d", containerPort),
+				fmt.Sprintf("--delay-shutdown=%d", int(echoBackendsShutdownDelay.Seconds())),
+			},
+			Annotations:    ct.params.DeploymentAnnotations.Match(echoBackendsDeploymentName),
+			NodeSelector:   ct.params.NodeSelector,
+			ReadinessProbe: newLocalReadinessProbe(containerPort, "/"),
+		})
+		// Give the backends enough time to complete the delayed shutdown.
+		gracePeriod := int64((echoBackendsShutdownDelay + 15*time.Second).Seconds())
+		dep.Spec.Template.Spec.TerminationGracePeriodSeconds = &gracePeriod
+
+		_, err = ct.clients.src.CreateServiceAccount(ctx, ct.params.TestNamespace, k8s.NewServiceAccount(echoBackendsDeploymentName), metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create service account %s: %w", echoBackendsDeploymentName, err)
+		}
+		_, err = ct.clients.src.CreateDeployment(ctx, ct.params.TestNamespace, dep, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create deployment %s: %w", echoBackendsDeploymentName, err)
+		}
+	}
+
+	return nil
+}
+
 // deploymentList returns 2 lists of Deployments to be used for running tests with.
 func (ct *ConnectivityTest) deploymentList() (srcList []string, dstList []string) {
 	if !ct.params.Perf {
@@ -1045,6 +1314,14 @@ func (ct *ConnectivityTest) deploymentList() (srcList []string, dstList []string
 		dstList = append(dstList, echoExternalNodeDeploymentName)
 	}
 
+	if ct.needsEchoBackends() {
+		srcList = append(srcList, echoBackendsDeploymentName)
+	}
+
+	if ct.params.Offline && !ct.params.Perf {
+		srcList = append(srcList, externalTargetDeploymentName, externalOtherTargetDeploymentName, externalTargetDNSDeploymentName)
+	}
+
 	return srcList, dstList
 }
 
@@ -1056,17 +1333,41 @@ func (ct *ConnectivityTest) deleteDeployments(ctx context.Context, client *k8s.C
 	_ = client.DeleteDeployment(ctx, ct.params.TestNamespace, client2DeploymentName, metav1.DeleteOptions{})
 	_ = client.DeleteDeployment(ctx, ct.params.TestNamespace, testConnDisruptClientDeploymentName, metav1.DeleteOptions{})
 	_ = client.DeleteDeployment(ctx, ct.params.TestNamespace, testConnDisruptServerDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteDeployment(ctx, ct.params.TestNamespace, echoBackendsDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteDeployment(ctx, ct.params.TestNamespace, externalTargetDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteDeployment(ctx, ct.params.TestNamespace, externalOtherTargetDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteDeployment(ctx, ct.params.TestNamespace, externalTargetDNSDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteDeployment(ctx, ct.params.TestNamespace, bgpPeerDeploymentName, metav1.DeleteOptions{})
 	_ = client.DeleteServiceAccount(ctx, ct.params.TestNamespace, echoSameNodeDeploymentName, metav1.DeleteOptions{})
 	_ = client.DeleteServiceAccount(ctx, ct.params.TestNamespace, echoOtherNodeDeploymentName, metav1.DeleteOptions{})
 	_ = client.DeleteServiceAccount(ctx, ct.params.TestNamespace, clientDeploymentName, metav1.DeleteOptions{})
 	_ = client.DeleteServiceAccount(ctx, ct.params.TestNamespace, client2DeploymentName, metav1.DeleteOptions{})
 	_ = client.DeleteServiceAccount(ctx, ct.params.TestNamespace, testConnDisruptClientDeploymentName, metav1.DeleteOptions{})
 	_ = client.DeleteServiceAccount(ctx, ct.params.TestNamespace, testConnDisruptServerDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteServiceAccount(ctx, ct.params.TestNamespace, echoBackendsDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteServiceAccount(ctx, ct.params.TestNamespace, externalTargetDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteServiceAccount(ctx, ct.params.TestNamespace, externalOtherTargetDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteServiceAccount(ctx, ct.params.TestNamespace, externalTargetDNSDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteServiceAccount(ctx, ct.params.TestNamespace, bgpPeerDeploymentName, metav1.DeleteOptions{})
 	_ = client.DeleteService(ctx, ct.params.TestNamespace, echoSameNodeDeploymentName, metav1.DeleteOptions{})
 	_ = client.DeleteService(ctx, ct.params.TestNamespace, echoOtherNodeDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteService(ctx, ct.params.TestNamespace, echoBackendsDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteService(ctx, ct.params.TestNamespace, echoAffinityServiceName, metav1.DeleteOptions{})
+	_ = client.DeleteService(ctx, ct.params.TestNamespace, echoExternalIPServiceName, metav1.DeleteOptions{})
+	_ = client.DeleteService(ctx, ct.params.TestNamespace, externalTargetDNSDeploymentName, metav1.DeleteOptions{})
+	_ = client.DeleteService(ctx, ct.params.TestNamespace, EchoBGPServiceName, metav1.DeleteOptions{})
+	_ = client.DeleteSecret(ctx, ct.params.TestNamespace, externalTargetTLSSecretName, metav1.DeleteOptions{})
+	_ = client.DeleteConfigMap(ctx, ct.params.TestNamespace, externalTargetCAConfigMapName, metav1.DeleteOptions{})
+	_ = client.DeleteConfigMap(ctx, ct.params.TestNamespace, externalTargetDNSConfigMapName, metav1.DeleteOptions{})
 	_ = client.DeleteConfigMap(ctx, ct.params.TestNamespace, corednsConfigMapName, metav1.DeleteOptions{})
+	_ = client.DeleteConfigMap(ctx, ct.params.TestNamespace, bgpPeerConfigMapName, metav1.DeleteOptions{})
 	_ = client.DeleteNamespace(ctx, ct.params.TestNamespace, metav1.DeleteOptions{})
 
+	if ct.params.SecondaryTestNamespace != "" {
+		ct.deleteSecondaryNamespace(ctx, client)
+	}
+	ct.deleteBGPResources(ctx, client)
+
 	_, err := client.GetNamespace(ctx, ct.params.TestNamespace, metav1.GetOptions{})
 	if err == nil {
 		ct.Logf("⌛ [%s] Waiting for namespace %s to disappear", client.ClusterName(), ct.params.TestNamespace)
@@ -1101,6 +1402,13 @@ func (ct *ConnectivityTest) validateDeployment(ctx context.Context) error {
 	}
 
 	if ct.params.Perf {
+		nm := newPerfDeploymentNameManager(&ct.params)
+		if ct.params.PerfNodeMatrix {
+			if err := WaitForDaemonSet(ctx, ct, ct.clients.src, ct.Params().TestNamespace, nm.NodeMatrixName()); err != nil {
+				return err
+			}
+		}
+
 		perfPods, err := ct.client.ListPods(ctx, ct.params.TestNamespace, metav1.ListOptions{LabelSelector: "kind=" + kindPerfName})
 		if err != nil {
 			return fmt.Errorf("unable to list perf pods: %w", err)
@@ -1117,6 +1425,15 @@ func (ct *ConnectivityTest) validateDeployment(ctx context.Context) error {
 					return err
 				}
 			}
+			if perfPod.GetLabels()["role"] == "matrix" {
+				ct.perfNodeMatrixPods[perfPod.Name] = Pod{
+					K8sClient: ct.client,
+					Pod:       perfPod.DeepCopy(),
+					port:      PerfNodeMatrixControlPort,
+				}
+				continue
+			}
+
 			_, hasLabel := perfPod.GetLabels()["server"]
 			if hasLabel {
 				ct.perfServerPod[perfPod.Name] = Pod{
@@ -1131,6 +1448,30 @@ func (ct *ConnectivityTest) validateDeployment(ctx context.Context) error {
 				}
 			}
 		}
+
+		if ct.params.PerfService {
+			svc, err := ct.clients.src.GetService(ctx, ct.params.TestNamespace, nm.ServerName(), metav1.GetOptions{})
+			if err != nil {
+				return fmt.Errorf("unable to get perf service %s: %w", nm.ServerName(), err)
+			}
+			s := Service{Service: svc.DeepCopy()}
+
+			// Wait until the service is propagated to the cilium agents
+			// running on the nodes hosting the client pods.
+			nodes := make(map[string]struct{})
+			for _, client := range ct.PerfClientPods() {
+				nodes[client.NodeName()] = struct{}{}
+			}
+			for _, agent := range ct.CiliumPods() {
+				if _, ok := nodes[agent.NodeName()]; ok {
+					if err := WaitForServiceEndpoints(ctx, ct, agent, s, 1, []IPFamily{IPFamilyV4}); err != nil {
+						return err
+					}
+				}
+			}
+
+			ct.perfServices[svc.Name] = s
+		}
 		return nil
 	}
 
@@ -1209,6 +1550,18 @@ func (ct *ConnectivityTest) validateDeployment(ctx context.Context) error {
 		}
 	}
 
+	if ct.params.SecondaryTestNamespace != "" {
+		if err := ct.validateSecondaryNamespace(ctx); err != nil {
+			return err
+		}
+	}
+
+	if ct.needsBGPPeer() {
+		if err := ct.validateBGPPeer(ctx); err != nil {
+			return err
+		}
+	}
+
 	for _, client := range ct.clients.clients() {
 		echoPods, err := client.ListPods(ctx, ct.params.TestNamespace, metav1.ListOptions{LabelSelector: "kind=" + kindEchoName})
 		if err != nil {
@@ -1277,6 +1630,42 @@ func (ct *ConnectivityTest) validateDeployment(ctx context.Context) error {
 		}
 	}
 
+	if ct.Features[FeatureKPRExternalIPs].Enabled && !ct.params.Perf {
+		externalIPServices, err := ct.clients.src.ListServices(ctx, ct.params.TestNamespace, metav1.ListOptions{LabelSelector: "kind=" + kindEchoExternalIPName})
+		if err != nil {
+			return fmt.Errorf("unable to list external IP services: %w", err)
+		}
+
+		for _, svc := range externalIPServices.Items {
+			s := Service{Service: svc.DeepCopy()}
+			for _, agent := range ct.CiliumPods() {
+				if err := WaitForServiceEndpoints(ctx, ct, agent, s, 1, ct.Features.IPFamilies()); err != nil {
+					return err
+				}
+			}
+			ct.echoExternalIPServices[svc.Name] = s
+		}
+	}
+
+	if ct.needsEchoBackends() {
+		echoBackendsServices, err := ct.clients.src.ListServices(ctx, ct.params.TestNamespace, metav1.ListOptions{LabelSelector: "kind=" + KindEchoBackends})
+		if err != nil {
+			return fmt.Errorf("unable to list echo backends services: %w", err)
+		}
+
+		for _, svc := range echoBackendsServices.Items {
+			s := Service{Service: svc.DeepCopy()}
+			client := ct.RandomClientPod()
+			if client == nil {
+				return fmt.Errorf("no client pod available")
+			}
+			if err := WaitForService(ctx, ct, *client, s); err != nil {
+				return err
+			}
+			ct.echoBackendsServices[svc.Name] = s
+		}
+	}
+
 	if ct.Features[FeatureIngressController].Enabled {
 		ingressServices, err := ct.clients.src.ListServices(ctx, ct.params.TestNamespace, metav1.ListOptions{LabelSelector: "cilium.io/ingress=true"})
 		if err != nil {
@@ -1345,6 +1734,8 @@ func (ct *ConnectivityTest) validateDeployment(ctx context.Context) error {
 		ct.Infof("Skipping IPCache check")
 	} else {
 		pods := append(maps.Values(ct.clientPods), maps.Values(ct.echoPods)...)
+		pods = append(pods, maps.Values(ct.secondaryClientPods)...)
+		pods = append(pods, maps.Values(ct.secondaryEchoPods)...)
 		// Set the timeout for all IP cache lookup retries
 		for _, cp := range ct.ciliumPods {
 			if err := WaitForIPCache(ctx, ct, cp, pods); err != nil {
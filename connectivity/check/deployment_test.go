@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package check
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestSetPerfServiceDataPort(t *testing.T) {
+	svc := newPerfService("perf-server")
+	// NodePorts allocated by the apiserver.
+	svc.Spec.Ports[0].NodePort = 30001
+	svc.Spec.Ports[1].NodePort = 30002
+
+	controlNodePort, dataPort := PerfServicePorts(Service{Service: svc})
+	assert.Equal(t, 30001, controlNodePort)
+	assert.Equal(t, 0, dataPort)
+
+	setPerfServiceDataPort(svc)
+	controlNodePort, dataPort = PerfServicePorts(Service{Service: svc})
+	assert.Equal(t, 30001, controlNodePort)
+	assert.Equal(t, 30002, dataPort)
+
+	assert.Equal(t, []corev1.ServicePort{
+		{Name: "control", Protocol: corev1.ProtocolTCP, Port: PerfControlPort, NodePort: 30001},
+		{Name: "data-tcp", Protocol: corev1.ProtocolTCP, Port: 30002, TargetPort: intstr.FromInt(30002), NodePort: 30002},
+		{Name: "data-udp", Protocol: corev1.ProtocolUDP, Port: 30002, TargetPort: intstr.FromInt(30002), NodePort: 30002},
+	}, svc.Spec.Ports)
+}
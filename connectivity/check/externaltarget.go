@@ -0,0 +1,498 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package check
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/cfssl/config"
+	"github.com/cloudflare/cfssl/csr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cilium/cilium-cli/defaults"
+	"github.com/cilium/cilium-cli/internal/certs"
+	"github.com/cilium/cilium-cli/k8s"
+)
+
+const (
+	externalTargetDeploymentName      = "external-target"
+	externalOtherTargetDeploymentName = "external-target-other"
+	kindExternalTargetName            = "external-target"
+
+	externalTargetDNSDeploymentName = "external-target-dns"
+	externalTargetDNSConfigMapName  = "external-target-dns"
+	kindExternalTargetDNSName       = "external-target-dns"
+
+	// externalTargetDomain is the domain of the external targets, served by
+	// the external targets DNS server. The .test TLD is reserved for testing
+	// by RFC 2606, hence it never resolves on the Internet.
+	externalTargetDomain = "cilium.test"
+
+	externalTargetTLSSecretName   = "external-target-tls"
+	externalTargetCAConfigMapName = "external-target-ca"
+	externalTargetTLSMountPath    = "/etc/external-target"
+
+	// clientCABundlePath is the path of the CA bundle trusted by the client
+	// image, replaced by the external targets CA in offline mode.
+	clientCABundlePath = "/etc/ssl/certs/ca-certificates.crt"
+)
+
+// externalTargetFQDN returns the domain name of the given external target.
+func externalTargetFQDN(name string) string {
+	return name + "." + externalTargetDomain
+}
+
+// externalTargetNodeAffinity returns the node affinity of the external
+// targets. They are required to run on the given nodes without Cilium if
+// there are enough of them for both targets, so that their traffic is seen as
+// coming from the world. Otherwise, they only prefer these nodes, and fall
+// back to the host network of Cilium nodes.
+func externalTargetNodeAffinity(nodesWithoutCilium []string) *corev1.NodeAffinity {
+	if len(nodesWithoutCilium) == 0 {
+		return nil
+	}
+
+	term := corev1.NodeSelectorTerm{
+		MatchFields: []corev1.NodeSelectorRequirement{
+			{Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: nodesWithoutCilium},
+		},
+	}
+	if len(nodesWithoutCilium) < 2 {
+		return &corev1.NodeAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{
+				{Weight: 100, Preference: term},
+			},
+		}
+	}
+	return &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{term},
+		},
+	}
+}
+
+// newExternalTargetDeployment returns a host network Deployment serving HTTP
+// on port 80 and HTTPS on port 443, scheduled according to the given node
+// affinity.
+func (ct *ConnectivityTest) newExternalTargetDeployment(name string, nodeAffinity *corev1.NodeAffinity) *appsv1.Deployment {
+	dep := newDeployment(deploymentParameters{
+		Name:      name,
+		Kind:      kindExternalTargetName,
+		Image:     ct.params.AgnhostImage,
+		Port:      80,
+		NamedPort: "http",
+		Command:   []string{"/agnhost", "netexec", "--http-port=80", "--udp-port=-1"},
+		Affinity: &corev1.Affinity{
+			NodeAffinity: nodeAffinity,
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+					{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"kind": kindExternalTargetName},
+						},
+						TopologyKey: corev1.LabelHostname,
+					},
+				},
+			},
+		},
+		Annotations:    ct.params.DeploymentAnnotations.Match(name),
+		ReadinessProbe: newLocalReadinessProbe(80, "/"),
+		HostNetwork:    true,
+		Tolerations: []corev1.Toleration{
+			{Operator: corev1.TolerationOpExists},
+		},
+	})
+
+	spec := &dep.Spec.Template.Spec
+	spec.Containers = append(spec.Containers, corev1.Container{
+		Name:  name + "-https",
+		Image: ct.params.AgnhostImage,
+		Command: []string{"/agnhost", "netexec", "--http-port=443", "--udp-port=-1",
+			"--tls-cert-file=" + externalTargetTLSMountPath + "/" + corev1.TLSCertKey,
+			"--tls-private-key-file=" + externalTargetTLSMountPath + "/" + corev1.TLSPrivateKeyKey,
+		},
+		Ports: []corev1.ContainerPort{
+			{Name: "https", ContainerPort: 443},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: externalTargetTLSSecretName, MountPath: externalTargetTLSMountPath, ReadOnly: true},
+		},
+	})
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: externalTargetTLSSecretName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: externalTargetTLSSecretName},
+		},
+	})
+
+	return dep
+}
+
+// newExternalTargetDNSConfigMap returns the configuration of the external
+// targets DNS server. It answers for the domain of the external targets, and
+// forwards the other queries to the cluster DNS.
+func newExternalTargetDNSConfigMap(ips map[string]string) *corev1.ConfigMap {
+	var hosts strings.Builder
+	for _, name := range []string{externalTargetDeploymentName, externalOtherTargetDeploymentName} {
+		fmt.Fprintf(&hosts, "\t\t%s %s\n", ips[name], externalTargetFQDN(name))
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: externalTargetDNSConfigMapName},
+		Data: map[string]string{
+			"Corefile": fmt.Sprintf(`%s {
+	hosts {
+%s	}
+	log
+}
+. {
+	forward . /etc/resolv.conf
+	ready
+	log
+}
+`, externalTargetDomain, hosts.String()),
+		},
+	}
+}
+
+// newExternalTargetDNSDeployment returns the Deployment of the external
+// targets DNS server.
+func (ct *ConnectivityTest) newExternalTargetDNSDeployment() *appsv1.Deployment {
+	dep := newDeployment(deploymentParameters{
+		Name:           externalTargetDNSDeploymentName,
+		Kind:           kindExternalTargetDNSName,
+		Image:          ct.params.DNSTestServerImage,
+		Port:           53,
+		NamedPort:      "dns-53",
+		Command:        []string{"/coredns", "-conf", "/etc/coredns/Corefile"},
+		Annotations:    ct.params.DeploymentAnnotations.Match(externalTargetDNSDeploymentName),
+		ReadinessProbe: newLocalReadinessProbe(8181, "/ready"),
+		NodeSelector:   ct.params.NodeSelector,
+	})
+
+	spec := &dep.Spec.Template.Spec
+	spec.Containers[0].Ports = append(spec.Containers[0].Ports,
+		corev1.ContainerPort{Name: "dns-udp-53", ContainerPort: 53, Protocol: corev1.ProtocolUDP})
+	spec.Containers[0].VolumeMounts = append(spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      externalTargetDNSConfigMapName,
+		MountPath: "/etc/coredns",
+		ReadOnly:  true,
+	})
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: externalTargetDNSConfigMapName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: externalTargetDNSConfigMapName},
+			},
+		},
+	})
+
+	return dep
+}
+
+// newExternalTargetDNSService returns the Service of the external targets DNS
+// server, used as name server by the clients.
+func newExternalTargetDNSService() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   externalTargetDNSDeploymentName,
+			Labels: map[string]string{"kind": kindExternalTargetDNSName},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: map[string]string{"name": externalTargetDNSDeploymentName},
+			Ports: []corev1.ServicePort{
+				{Name: "dns", Port: 53, Protocol: corev1.ProtocolUDP},
+				{Name: "dns-tcp", Port: 53, Protocol: corev1.ProtocolTCP},
+			},
+		},
+	}
+}
+
+// withExternalTargets configures the given client Deployment to resolve the
+// names of the external targets through their DNS server, and to trust their
+// CA instead of the system CAs.
+func (ct *ConnectivityTest) withExternalTargets(dep *appsv1.Deployment) {
+	ndots := "5"
+	spec := &dep.Spec.Template.Spec
+	spec.DNSPolicy = corev1.DNSNone
+	spec.DNSConfig = &corev1.PodDNSConfig{
+		Nameservers: []string{ct.externalTargetDNSIP},
+		Searches: []string{
+			ct.params.TestNamespace + ".svc.cluster.local",
+			"svc.cluster.local",
+			"cluster.local",
+		},
+		Options: []corev1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}},
+	}
+	withExternalTargetCA(dep)
+}
+
+// withExternalTargetCA makes the given client Deployment trust the CA of the
+// external targets instead of the system CAs.
+func withExternalTargetCA(dep *appsv1.Deployment) {
+	spec := &dep.Spec.Template.Spec
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: externalTargetCAConfigMapName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: externalTargetCAConfigMapName},
+			},
+		},
+	})
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, corev1.VolumeMount{
+			Name:      externalTargetCAConfigMapName,
+			MountPath: clientCABundlePath,
+			SubPath:   defaults.CASecretCertName,
+			ReadOnly:  true,
+		})
+	}
+}
+
+// deployExternalTargets deploys the in-cluster stand-ins for the external
+// targets used by the world, FQDN and CIDR tests, along with the DNS server
+// resolving their names, and points the corresponding parameters to them. The
+// external targets run on nodes without Cilium if possible, so that the tests
+// relying on the world identity keep testing traffic leaving the cluster.
+func (ct *ConnectivityTest) deployExternalTargets(ctx context.Context) error {
+	onCiliumNodes := len(ct.nodesWithoutCilium) < 2
+	if onCiliumNodes {
+		ct.Warnf("Offline mode: found %d nodes without Cilium (labeled %s=true) out of the 2 needed, the external targets may run in the host network of Cilium nodes. "+
+			"The tests relying on the world identity and the external CIDR are then less accurate, as their traffic stays within the cluster.",
+			len(ct.nodesWithoutCilium), defaults.CiliumNoScheduleLabel)
+	}
+	nodeAffinity := externalTargetNodeAffinity(ct.nodesWithoutCilium)
+
+	for _, name := range []string{externalTargetDeploymentName, externalOtherTargetDeploymentName} {
+		_, err := ct.clients.src.GetDeployment(ctx, ct.params.TestNamespace, name, metav1.GetOptions{})
+		if err != nil {
+			ct.Logf("✨ [%s] Deploying %s deployment...", ct.clients.src.ClusterName(), name)
+			_, err = ct.clients.src.CreateServiceAccount(ctx, ct.params.TestNamespace, k8s.NewServiceAccount(name), metav1.CreateOptions{})
+			if err != nil {
+				return fmt.Errorf("unable to create service account %s: %w", name, err)
+			}
+			_, err = ct.clients.src.CreateDeployment(ctx, ct.params.TestNamespace, ct.newExternalTargetDeployment(name, nodeAffinity), metav1.CreateOptions{})
+			if err != nil {
+				return fmt.Errorf("unable to create deployment %s: %w", name, err)
+			}
+		}
+	}
+
+	ips, err := ct.waitForExternalTargetIPs(ctx)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(ips[externalTargetDeploymentName])
+	if err != nil {
+		return fmt.Errorf("unable to parse host IP of %s: %w", externalTargetDeploymentName, err)
+	}
+	otherIP, err := netip.ParseAddr(ips[externalOtherTargetDeploymentName])
+	if err != nil {
+		return fmt.Errorf("unable to parse host IP of %s: %w", externalOtherTargetDeploymentName, err)
+	}
+	// The external targets may themselves run on Cilium nodes in the
+	// fallback case, for which the external CIDR can't exclude them.
+	var ciliumNodeIPs []netip.Addr
+	if !onCiliumNodes {
+		ciliumNodeIPs = ct.ciliumNodeIPs()
+	}
+	cidr, err := externalCIDR(ip, otherIP, ciliumNodeIPs)
+	if err != nil {
+		return err
+	}
+
+	ct.params.ExternalTarget = externalTargetFQDN(externalTargetDeploymentName)
+	ct.params.ExternalOtherTarget = externalTargetFQDN(externalOtherTargetDeploymentName)
+	ct.params.ExternalIP = ip.String()
+	ct.params.ExternalOtherIP = otherIP.String()
+	ct.params.ExternalCIDR = cidr.String()
+
+	if err := ct.createExternalTargetCerts(ctx); err != nil {
+		return err
+	}
+	if err := ct.deployExternalTargetDNS(ctx, ips); err != nil {
+		return err
+	}
+
+	ct.Infof("Offline mode: using %s (%s) and %s (%s) as external targets, %s as external CIDR, resolved by %s",
+		ct.params.ExternalTarget, ct.params.ExternalIP, ct.params.ExternalOtherTarget, ct.params.ExternalOtherIP,
+		ct.params.ExternalCIDR, ct.externalTargetDNSIP)
+	return nil
+}
+
+// deployExternalTargetDNS deploys the DNS server resolving the names of the
+// external targets to the given IPs, indexed by deployment name.
+func (ct *ConnectivityTest) deployExternalTargetDNS(ctx context.Context, ips map[string]string) error {
+	_, err := ct.clients.src.GetConfigMap(ctx, ct.params.TestNamespace, externalTargetDNSConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s configmap...", ct.clients.src.ClusterName(), externalTargetDNSConfigMapName)
+		_, err = ct.clients.src.CreateConfigMap(ctx, ct.params.TestNamespace, newExternalTargetDNSConfigMap(ips), metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create configmap %s: %w", externalTargetDNSConfigMapName, err)
+		}
+	}
+
+	_, err = ct.clients.src.GetDeployment(ctx, ct.params.TestNamespace, externalTargetDNSDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s deployment...", ct.clients.src.ClusterName(), externalTargetDNSDeploymentName)
+		_, err = ct.clients.src.CreateServiceAccount(ctx, ct.params.TestNamespace, k8s.NewServiceAccount(externalTargetDNSDeploymentName), metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create service account %s: %w", externalTargetDNSDeploymentName, err)
+		}
+		_, err = ct.clients.src.CreateDeployment(ctx, ct.params.TestNamespace, ct.newExternalTargetDNSDeployment(), metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create deployment %s: %w", externalTargetDNSDeploymentName, err)
+		}
+	}
+
+	svc, err := ct.clients.src.GetService(ctx, ct.params.TestNamespace, externalTargetDNSDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s service...", ct.clients.src.ClusterName(), externalTargetDNSDeploymentName)
+		svc, err = ct.clients.src.CreateService(ctx, ct.params.TestNamespace, newExternalTargetDNSService(), metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create service %s: %w", externalTargetDNSDeploymentName, err)
+		}
+	}
+	ct.externalTargetDNSIP = svc.Spec.ClusterIP
+
+	return nil
+}
+
+// ciliumNodeIPs returns the addresses of the nodes running Cilium.
+func (ct *ConnectivityTest) ciliumNodeIPs() []netip.Addr {
+	var ips []netip.Addr
+	for _, node := range ct.nodes {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type != corev1.NodeInternalIP && addr.Type != corev1.NodeExternalIP {
+				continue
+			}
+			if ip, err := netip.ParseAddr(addr.Address); err == nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// waitForExternalTargetIPs waits for the external targets to be scheduled and
+// returns their host IPs, indexed by deployment name.
+func (ct *ConnectivityTest) waitForExternalTargetIPs(ctx context.Context) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, LongTimeout)
+	defer cancel()
+
+	for {
+		ips := make(map[string]string)
+		pods, err := ct.clients.src.ListPods(ctx, ct.params.TestNamespace, metav1.ListOptions{LabelSelector: "kind=" + kindExternalTargetName})
+		if err == nil {
+			for _, pod := range pods.Items {
+				if pod.DeletionTimestamp == nil && pod.Status.HostIP != "" {
+					ips[pod.Labels["name"]] = pod.Status.HostIP
+				}
+			}
+		}
+		if len(ips) == 2 && ips[externalTargetDeploymentName] != ips[externalOtherTargetDeploymentName] {
+			return ips, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timeout waiting for the external targets to be scheduled on two distinct nodes: %w", ctx.Err())
+		case <-time.After(PollInterval):
+		}
+	}
+}
+
+// createExternalTargetCerts creates the certificate served by the external
+// targets, along with the ConfigMap containing the CA trusted by the clients.
+// The certificate and CA are reused if they already exist.
+func (ct *ConnectivityTest) createExternalTargetCerts(ctx context.Context) error {
+	secret, err := ct.clients.src.GetSecret(ctx, ct.params.TestNamespace, externalTargetTLSSecretName, metav1.GetOptions{})
+	if err != nil {
+		cm := certs.NewCertManager(ct.clients.src, certs.Parameters{Namespace: ct.params.TestNamespace})
+		if err := cm.GenerateCA(); err != nil {
+			return fmt.Errorf("unable to generate external targets CA: %w", err)
+		}
+
+		certReq := &csr.CertificateRequest{
+			KeyRequest: csr.NewKeyRequest(),
+			Hosts: []string{
+				ct.params.ExternalTarget,
+				ct.params.ExternalOtherTarget,
+				ct.params.ExternalIP,
+				ct.params.ExternalOtherIP,
+			},
+			CN: ct.params.ExternalTarget,
+		}
+		signConf := &config.Signing{
+			Default: &config.SigningProfile{
+				Expiry: 365 * 24 * time.Hour,
+				Usage:  []string{"signing", "key encipherment", "server auth"},
+			},
+		}
+		cert, key, err := cm.GenerateCertificate("", certReq, signConf)
+		if err != nil {
+			return fmt.Errorf("unable to generate certificate %s: %w", externalTargetTLSSecretName, err)
+		}
+
+		data := map[string][]byte{
+			corev1.TLSCertKey:         cert,
+			corev1.TLSPrivateKeyKey:   key,
+			defaults.CASecretCertName: cm.CACertBytes(),
+		}
+		ct.Logf("✨ [%s] Deploying %s secret...", ct.clients.src.ClusterName(), externalTargetTLSSecretName)
+		secret, err = ct.clients.src.CreateSecret(ctx, ct.params.TestNamespace,
+			k8s.NewTLSSecret(externalTargetTLSSecretName, ct.params.TestNamespace, data), metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create secret %s: %w", externalTargetTLSSecretName, err)
+		}
+	}
+	ct.externalTargetCA = secret.Data[defaults.CASecretCertName]
+
+	_, err = ct.clients.src.GetConfigMap(ctx, ct.params.TestNamespace, externalTargetCAConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s configmap...", ct.clients.src.ClusterName(), externalTargetCAConfigMapName)
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: externalTargetCAConfigMapName},
+			Data:       map[string]string{defaults.CASecretCertName: string(ct.externalTargetCA)},
+		}
+		_, err = ct.clients.src.CreateConfigMap(ctx, ct.params.TestNamespace, cm, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create configmap %s: %w", externalTargetCAConfigMapName, err)
+		}
+	}
+
+	return nil
+}
+
+// externalCIDR returns the longest prefix containing both external target IPs,
+// failing if it also contains any of the given Cilium node IPs: the traffic to
+// the latter isn't world traffic, and would be affected by the CIDR policies.
+func externalCIDR(ip, otherIP netip.Addr, ciliumNodeIPs []netip.Addr) (netip.Prefix, error) {
+	cidr := commonPrefix(ip, otherIP)
+	for _, nodeIP := range ciliumNodeIPs {
+		if cidr.Contains(nodeIP) {
+			return netip.Prefix{}, fmt.Errorf("no external CIDR containing the external targets IPs %s and %s excludes the Cilium node IP %s, "+
+				"use nodes without Cilium with adjacent IPs", ip, otherIP, nodeIP)
+		}
+	}
+	return cidr, nil
+}
+
+// commonPrefix returns the longest prefix containing both addresses.
+func commonPrefix(a, b netip.Addr) netip.Prefix {
+	for bits := a.BitLen(); bits > 0; bits-- {
+		if p, err := a.Prefix(bits); err == nil && p.Contains(b) {
+			return p
+		}
+	}
+	p, _ := a.Prefix(0)
+	return p
+}
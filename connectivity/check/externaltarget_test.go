@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package check
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{a: "10.0.0.1", b: "10.0.0.2", want: "10.0.0.0/30"},
+		{a: "172.18.0.2", b: "172.18.0.5", want: "172.18.0.0/29"},
+		{a: "10.0.1.10", b: "10.0.2.10", want: "10.0.0.0/22"},
+		{a: "192.168.1.1", b: "10.0.0.1", want: "0.0.0.0/0"},
+		{a: "fd00::10", b: "fd00::11", want: "fd00::10/127"},
+	}
+	for _, tt := range tests {
+		got := commonPrefix(netip.MustParseAddr(tt.a), netip.MustParseAddr(tt.b))
+		assert.Equal(t, tt.want, got.String(), "%s and %s", tt.a, tt.b)
+	}
+}
+
+func TestExternalCIDR(t *testing.T) {
+	tests := []struct {
+		ip, otherIP string
+		nodeIPs     []string
+		want        string
+		wantErr     bool
+	}{
+		{ip: "172.18.0.4", otherIP: "172.18.0.5", nodeIPs: []string{"172.18.0.2", "172.18.0.3"}, want: "172.18.0.4/31"},
+		{ip: "172.18.0.2", otherIP: "172.18.0.5", nodeIPs: []string{"172.18.0.8", "fc00::2"}, want: "172.18.0.0/29"},
+		{ip: "172.18.0.2", otherIP: "172.18.0.5", nodeIPs: []string{"172.18.0.3"}, wantErr: true},
+		{ip: "fd00::10", otherIP: "fd00::11", nodeIPs: []string{"fd00::12"}, want: "fd00::10/127"},
+	}
+	for _, tt := range tests {
+		var nodeIPs []netip.Addr
+		for _, ip := range tt.nodeIPs {
+			nodeIPs = append(nodeIPs, netip.MustParseAddr(ip))
+		}
+		got, err := externalCIDR(netip.MustParseAddr(tt.ip), netip.MustParseAddr(tt.otherIP), nodeIPs)
+		if tt.wantErr {
+			assert.Error(t, err, "%s and %s", tt.ip, tt.otherIP)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got.String(), "%s and %s", tt.ip, tt.otherIP)
+	}
+}
+
+func TestNewExternalTargetDNSConfigMap(t *testing.T) {
+	cm := newExternalTargetDNSConfigMap(map[string]string{
+		externalTargetDeploymentName:      "172.18.0.4",
+		externalOtherTargetDeploymentName: "172.18.0.5",
+	})
+	corefile := cm.Data["Corefile"]
+	assert.Contains(t, corefile, "cilium.test {")
+	assert.Contains(t, corefile, "172.18.0.4 external-target.cilium.test\n")
+	assert.Contains(t, corefile, "172.18.0.5 external-target-other.cilium.test\n")
+	assert.Contains(t, corefile, "forward . /etc/resolv.conf")
+}
+
+func TestExternalTargetNodeAffinity(t *testing.T) {
+	assert.Nil(t, externalTargetNodeAffinity(nil))
+
+	affinity := externalTargetNodeAffinity([]string{"node-1"})
+	assert.Nil(t, affinity.RequiredDuringSchedulingIgnoredDuringExecution)
+	assert.Len(t, affinity.PreferredDuringSchedulingIgnoredDuringExecution, 1)
+
+	affinity = externalTargetNodeAffinity([]string{"node-1", "node-2"})
+	assert.Empty(t, affinity.PreferredDuringSchedulingIgnoredDuringExecution)
+	assert.Equal(t, []string{"node-1", "node-2"},
+		affinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchFields[0].Values)
+}
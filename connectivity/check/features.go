@@ -7,8 +7,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
+	"golang.org/x/exp/slices"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -40,6 +42,7 @@ const (
 	FeatureKPRGracefulTermination Feature = "kpr-graceful-termination"
 	FeatureKPRHostPort            Feature = "kpr-hostport"
 	FeatureKPRSocketLB            Feature = "kpr-socket-lb"
+	FeatureKPRSocketLBHostnsOnly  Feature = "kpr-socket-lb-hostns-only"
 	FeatureKPRNodePort            Feature = "kpr-nodeport"
 	FeatureKPRSessionAffinity     Feature = "kpr-session-affinity"
 
@@ -67,8 +70,39 @@ const (
 	FeatureIngressController Feature = "ingress-controller"
 
 	FeatureEgressGateway Feature = "enable-ipv4-egress-gateway"
+
+	FeatureBGPControlPlane Feature = "enable-bgp-control-plane"
 )
 
+// knownFeatures lists all the features detected by the connectivity tests.
+var knownFeatures = []Feature{
+	FeatureCNIChaining, FeatureMonitorAggregation, FeatureL7Proxy, FeatureHostFirewall,
+	FeatureICMPPolicy, FeatureTunnel, FeatureEndpointRoutes,
+	FeatureKPRMode, FeatureKPRExternalIPs, FeatureKPRGracefulTermination, FeatureKPRHostPort,
+	FeatureKPRSocketLB, FeatureKPRSocketLBHostnsOnly, FeatureKPRNodePort, FeatureKPRSessionAffinity,
+	FeatureHostPort, FeatureNodeWithoutCilium, FeatureHealthChecking,
+	FeatureEncryptionPod, FeatureEncryptionNode, FeatureIPv4, FeatureIPv6, FeatureFlavor,
+	FeatureSecretBackendK8s, FeatureCNP, FeatureKNP, FeatureAuthSpiffe, FeatureIngressController,
+	FeatureEgressGateway, FeatureBGPControlPlane,
+}
+
+// IsKnownFeature returns true if f is one of the features detected by the
+// connectivity tests.
+func IsKnownFeature(f Feature) bool {
+	return slices.Contains(knownFeatures, f)
+}
+
+// KnownFeatures returns the sorted names of the features detected by the
+// connectivity tests.
+func KnownFeatures() []string {
+	names := make([]string, 0, len(knownFeatures))
+	for _, f := range knownFeatures {
+		names = append(names, string(f))
+	}
+	sort.Strings(names)
+	return names
+}
+
 // FeatureStatus describes the status of a feature. Some features are either
 // turned on or off (c.f. Enabled), while others additionally might include a
 // Mode string which provides more information about in what mode a
@@ -266,6 +300,10 @@ func (ct *ConnectivityTest) extractFeaturesFromConfigMap(ctx context.Context, cl
 		Enabled: cm.Data["enable-ipv4-egress-gateway"] == "true",
 	}
 
+	result[FeatureBGPControlPlane] = FeatureStatus{
+		Enabled: cm.Data["enable-bgp-control-plane"] == "true",
+	}
+
 	return nil
 }
 
@@ -429,6 +467,7 @@ func (ct *ConnectivityTest) extractFeaturesFromCiliumStatus(ctx context.Context,
 			}
 			if f.SocketLB != nil {
 				result[FeatureKPRSocketLB] = FeatureStatus{Enabled: f.SocketLB.Enabled}
+				result[FeatureKPRSocketLBHostnsOnly] = FeatureStatus{Enabled: f.SocketLB.Enabled && f.BpfSocketLBHostnsOnly}
 			}
 		}
 	}
@@ -170,7 +170,7 @@ func (t *Test) progress() {
 		return
 	}
 
-	fmt.Fprint(t.ctx.params.Writer, ".")
+	fmt.Fprint(t.writer(), ".")
 }
 
 // log takes out a read lock and logs a message to the Test's internal buffer.
@@ -182,7 +182,7 @@ func (t *Test) log(prefix string, a ...interface{}) {
 
 	b := t.logBuf
 	if b == nil {
-		b = t.ctx.params.Writer
+		b = t.writer()
 	}
 
 	if t.ctx.timestamp() {
@@ -209,7 +209,7 @@ func (t *Test) logf(format string, a ...interface{}) {
 
 	b := t.logBuf
 	if b == nil {
-		b = t.ctx.params.Writer
+		b = t.writer()
 	}
 
 	if t.ctx.timestamp() {
@@ -230,10 +230,10 @@ func (t *Test) flush() {
 	}
 
 	// Terminate progress so far.
-	fmt.Fprintln(t.ctx.params.Writer)
+	fmt.Fprintln(t.writer())
 
 	// Flush internal buffer to user-specified writer.
-	if _, err := io.Copy(t.ctx.params.Writer, t.logBuf); err != nil {
+	if _, err := io.Copy(t.writer(), t.logBuf); err != nil {
 		panic(err)
 	}
 
@@ -241,10 +241,44 @@ func (t *Test) flush() {
 	t.logBuf = nil
 }
 
+// writer returns the writer the Test's unbuffered output is sent to. This is
+// the user-specified writer, unless the Test's output is held back until it
+// completes.
+func (t *Test) writer() io.ReadWriter {
+	if t.outBuf != nil {
+		return t.outBuf
+	}
+	return t.ctx.params.Writer
+}
+
+// flushOutput sends the held back output of a completed Test to the
+// user-specified writer.
+func (t *Test) flushOutput() {
+	if t.outBuf == nil {
+		return
+	}
+
+	t.ctx.outputMu.Lock()
+	defer t.ctx.outputMu.Unlock()
+
+	if _, err := io.Copy(t.ctx.params.Writer, t.outBuf); err != nil {
+		panic(err)
+	}
+}
+
+// header logs an unindented, formatted message marking the start of a Test.
+// Headers are not internally buffered.
+func (t *Test) header(format string, a ...interface{}) {
+	if t.ctx.timestamp() {
+		fmt.Fprint(t.writer(), timestamp())
+	}
+	fmt.Fprintf(t.writer(), format+"\n", a...)
+}
+
 // Headerf prints a formatted, indented header inside the test log scope.
 // Headers are not internally buffered.
 func (t *Test) Headerf(format string, a ...interface{}) {
-	t.ctx.Headerf(testPrefix+format, a...)
+	fmt.Fprintf(t.writer(), "\n"+testPrefix+format+"\n", a...)
 }
 
 // Log logs a message.
@@ -359,25 +393,25 @@ func (a *Action) Infof(format string, s ...interface{}) {
 
 // Fail must be called when the Action is unsuccessful.
 func (a *Action) Fail(s ...interface{}) {
-	a.fail()
+	a.fail(fmt.Sprint(s...))
 	a.test.Fail(s...)
 }
 
 // Failf must be called when the Action is unsuccessful.
 func (a *Action) Failf(format string, s ...interface{}) {
-	a.fail()
+	a.fail(fmt.Sprintf(format, s...))
 	a.test.Failf(format, s...)
 }
 
 // Fatal must be called when an irrecoverable error was encountered during the Action.
 func (a *Action) Fatal(s ...interface{}) {
-	a.fail()
+	a.fail(fmt.Sprint(s...))
 	a.test.Fatal(s...)
 }
 
 // Fatalf must be called when an irrecoverable error was encountered during the Action.
 func (a *Action) Fatalf(format string, s ...interface{}) {
-	a.fail()
+	a.fail(fmt.Sprintf(format, s...))
 	a.test.Fatalf(format, s...)
 }
 
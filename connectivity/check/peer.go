@@ -310,6 +310,120 @@ func (s NodeportService) FlowFilters() []*flow.FlowFilter {
 	return s.Service.FlowFilters()
 }
 
+func (s Service) ToExternalIPService() ExternalIPService {
+	return ExternalIPService{
+		Service: s,
+	}
+}
+
+// ExternalIPService wraps a Service and exposes it through its external IPs, acting as a peer in a connectivity test.
+// It implements interface TestPeer.
+type ExternalIPService struct {
+	Service Service
+}
+
+// Name returns name of the wrapped service.
+func (s ExternalIPService) Name() string {
+	return s.Service.Name()
+}
+
+// Scheme returns the scheme of the wrapped service.
+func (s ExternalIPService) Scheme() string {
+	return s.Service.Scheme()
+}
+
+// Path returns the path of the wrapped service.
+func (s ExternalIPService) Path() string {
+	return s.Service.Path()
+}
+
+// Address returns the first external IP of the wrapped Service of the given family.
+func (s ExternalIPService) Address(family IPFamily) string {
+	for _, address := range s.Service.Service.Spec.ExternalIPs {
+		if family == IPFamilyAny || GetIPFamily(address) == family {
+			return address
+		}
+	}
+
+	return ""
+}
+
+// Port returns the first port of the wrapped Service.
+func (s ExternalIPService) Port() uint32 {
+	return s.Service.Port()
+}
+
+// HasLabel checks if given label exists and value matches.
+func (s ExternalIPService) HasLabel(name, value string) bool {
+	return s.Service.HasLabel(name, value)
+}
+
+// Labels returns the copy of service labels
+func (s ExternalIPService) Labels() map[string]string {
+	return s.Service.Labels()
+}
+
+func (s ExternalIPService) FlowFilters() []*flow.FlowFilter {
+	return s.Service.FlowFilters()
+}
+
+func (s Service) ToLoadBalancerService() LoadBalancerService {
+	return LoadBalancerService{
+		Service: s,
+	}
+}
+
+// LoadBalancerService wraps a Service and exposes it through its LoadBalancer IPs, acting as a peer in a connectivity test.
+// It implements interface TestPeer.
+type LoadBalancerService struct {
+	Service Service
+}
+
+// Name returns name of the wrapped service.
+func (s LoadBalancerService) Name() string {
+	return s.Service.Name()
+}
+
+// Scheme returns the scheme of the wrapped service.
+func (s LoadBalancerService) Scheme() string {
+	return s.Service.Scheme()
+}
+
+// Path returns the path of the wrapped service.
+func (s LoadBalancerService) Path() string {
+	return s.Service.Path()
+}
+
+// Address returns the first LoadBalancer IP of the wrapped Service of the given family.
+func (s LoadBalancerService) Address(family IPFamily) string {
+	for _, ingress := range s.Service.Service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" && (family == IPFamilyAny || GetIPFamily(ingress.IP) == family) {
+			return ingress.IP
+		}
+	}
+
+	return ""
+}
+
+// Port returns the first port of the wrapped Service.
+func (s LoadBalancerService) Port() uint32 {
+	return s.Service.Port()
+}
+
+// HasLabel checks if given label exists and value matches.
+func (s LoadBalancerService) HasLabel(name, value string) bool {
+	return s.Service.HasLabel(name, value)
+}
+
+// Labels returns the copy of service labels
+func (s LoadBalancerService) Labels() map[string]string {
+	return s.Service.Labels()
+}
+
+func (s LoadBalancerService) FlowFilters() []*flow.FlowFilter {
+	return s.Service.FlowFilters()
+}
+
 // ExternalWorkload is an external workload acting as a peer in a
 // connectivity test. It implements interface TestPeer.
 type ExternalWorkload struct {
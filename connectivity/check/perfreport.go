@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package check
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// PerfReport holds the individual samples of a network performance test run,
+// as written to the file given via --perf-report.
+type PerfReport struct {
+	CLIVersion    string             `json:"cliVersion"`
+	CiliumVersion string             `json:"ciliumVersion"`
+	Results       []PerfReportResult `json:"results"`
+}
+
+// PerfReportResult holds the samples of a single performance test executed
+// from a given client pod.
+type PerfReportResult struct {
+	Test            string    `json:"test"`
+	Scenario        string    `json:"scenario"`
+	Target          string    `json:"target"`
+	Pod             string    `json:"pod"`
+	SourceNode      string    `json:"sourceNode,omitempty"`
+	DestinationNode string    `json:"destinationNode,omitempty"`
+	Metric          string    `json:"metric"`
+	Duration        float64   `json:"durationSeconds"`
+	Values          []float64 `json:"values"`
+}
+
+// LoadPerfReport reads a PerfReport previously written to file.
+func LoadPerfReport(path string) (*PerfReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var report PerfReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parsing performance report %s: %w", path, err)
+	}
+
+	return &report, nil
+}
+
+func (ct *ConnectivityTest) writePerfReport() error {
+	if ct.Params().PerfReportFile == "" || len(ct.PerfResults) == 0 {
+		return nil
+	}
+
+	f, err := os.Create(ct.Params().PerfReportFile)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(ct.perfReport()); err != nil {
+		if e := f.Close(); e != nil {
+			return errors.Join(err, e)
+		}
+		return err
+	}
+
+	return f.Close()
+}
+
+// perfReport builds the PerfReport of all the collected PerfResults.
+func (ct *ConnectivityTest) perfReport() *PerfReport {
+	report := &PerfReport{
+		CLIVersion:    ct.version,
+		CiliumVersion: ct.CiliumVersion.String(),
+		Results:       make([]PerfReportResult, 0, len(ct.PerfResults)),
+	}
+
+	for _, p := range ct.sortedPerfTests() {
+		r := ct.PerfResults[p]
+		report.Results = append(report.Results, PerfReportResult{
+			Test:            p.Test,
+			Scenario:        r.Scenario,
+			Target:          r.Target,
+			Pod:             p.Pod,
+			SourceNode:      r.SourceNode,
+			DestinationNode: r.DestinationNode,
+			Metric:          r.Metric,
+			Duration:        r.Duration.Seconds(),
+			Values:          r.Values,
+		})
+	}
+
+	return report
+}
+
+// sortedPerfTests returns the keys of the collected PerfResults in a stable
+// order.
+func (ct *ConnectivityTest) sortedPerfTests() []PerfTests {
+	keys := make([]PerfTests, 0, len(ct.PerfResults))
+	for k := range ct.PerfResults {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Test != b.Test {
+			return a.Test < b.Test
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		if a.Pod != b.Pod {
+			return a.Pod < b.Pod
+		}
+		return a.DestinationNode < b.DestinationNode
+	})
+
+	return keys
+}
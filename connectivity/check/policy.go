@@ -118,6 +118,31 @@ func updateOrCreateCNP(ctx context.Context, client *k8s.Client, cnp *ciliumv2.Ci
 	return mod, err
 }
 
+func updateOrCreateCCNP(ctx context.Context, client *k8s.Client, ccnp *ciliumv2.CiliumClusterwideNetworkPolicy) (bool, error) {
+	mod := false
+
+	if kccnp, err := client.GetCiliumClusterwideNetworkPolicy(ctx, ccnp.Name, metav1.GetOptions{}); err == nil {
+		// Check if the local CCNP's Spec or Specs differ from the remote version.
+		if !kccnp.Spec.DeepEqual(ccnp.Spec) ||
+			!kccnp.Specs.DeepEqual(&ccnp.Specs) {
+			mod = true
+		}
+
+		kccnp.ObjectMeta.Labels = ccnp.ObjectMeta.Labels
+		kccnp.Spec = ccnp.Spec
+		kccnp.Specs = ccnp.Specs
+		kccnp.Status = ciliumv2.CiliumNetworkPolicyStatus{}
+
+		_, err = client.UpdateCiliumClusterwideNetworkPolicy(ctx, kccnp, metav1.UpdateOptions{})
+		return mod, err
+	}
+
+	// Creating, so a resource will definitely be modified.
+	mod = true
+	_, err := client.CreateCiliumClusterwideNetworkPolicy(ctx, ccnp, metav1.CreateOptions{})
+	return mod, err
+}
+
 // createOrUpdateKNP creates the KNP and updates it if it already exists.
 // NB: mod holds the information regarding the resource creation.
 func createOrUpdateKNP(ctx context.Context, client *k8s.Client, knp *networkingv1.NetworkPolicy) (bool, error) {
@@ -199,6 +224,15 @@ func deleteCNP(ctx context.Context, client *k8s.Client, cnp *ciliumv2.CiliumNetw
 	return nil
 }
 
+// deleteCCNP deletes a CiliumClusterwideNetworkPolicy from the cluster.
+func deleteCCNP(ctx context.Context, client *k8s.Client, ccnp *ciliumv2.CiliumClusterwideNetworkPolicy) error {
+	if err := client.DeleteCiliumClusterwideNetworkPolicy(ctx, ccnp.Name, metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("%s/%s policy delete failed: %w", client.ClusterName(), ccnp.Name, err)
+	}
+
+	return nil
+}
+
 // deleteKNP deletes a Kubernetes NetworkPolicy from the cluster.
 func deleteKNP(ctx context.Context, client *k8s.Client, knp *networkingv1.NetworkPolicy) error {
 	if err := client.DeleteKubernetesNetworkPolicy(ctx, knp.Namespace, knp.Name, metav1.DeleteOptions{}); err != nil {
@@ -398,6 +432,25 @@ func (t *Test) addCNPs(cnps ...*ciliumv2.CiliumNetworkPolicy) error {
 	return nil
 }
 
+// addCCNPs adds one or more CiliumClusterwideNetworkPolicy resources to the Test.
+func (t *Test) addCCNPs(ccnps ...*ciliumv2.CiliumClusterwideNetworkPolicy) error {
+	for _, p := range ccnps {
+		if p == nil {
+			return errors.New("cannot add nil CiliumClusterwideNetworkPolicy to test")
+		}
+		if p.Name == "" {
+			return fmt.Errorf("adding CiliumClusterwideNetworkPolicy with empty name to test: %v", p)
+		}
+		if _, ok := t.ccnps[p.Name]; ok {
+			return fmt.Errorf("CiliumClusterwideNetworkPolicy with name %s already in test scope", p.Name)
+		}
+
+		t.ccnps[p.Name] = p
+	}
+
+	return nil
+}
+
 // addKNPs adds one or more K8S NetworkPolicy resources to the Test.
 func (t *Test) addKNPs(policies ...*networkingv1.NetworkPolicy) error {
 	for _, p := range policies {
@@ -446,7 +499,7 @@ func sumMap(m map[string]int) int {
 
 // applyPolicies applies all the Test's registered network policies.
 func (t *Test) applyPolicies(ctx context.Context) error {
-	if len(t.cnps) == 0 && len(t.knps) == 0 && len(t.cegps) == 0 {
+	if len(t.cnps) == 0 && len(t.ccnps) == 0 && len(t.knps) == 0 && len(t.cegps) == 0 {
 		return nil
 	}
 
@@ -476,6 +529,20 @@ func (t *Test) applyPolicies(ctx context.Context) error {
 		}
 	}
 
+	// Apply all given CiliumClusterwideNetworkPolicies.
+	for _, ccnp := range t.ccnps {
+		for _, client := range t.Context().clients.clients() {
+			t.Infof("📜 Applying CiliumClusterwideNetworkPolicy '%s'..", ccnp.Name)
+			changed, err := updateOrCreateCCNP(ctx, client, ccnp)
+			if err != nil {
+				return fmt.Errorf("policy application failed: %w", err)
+			}
+			if changed {
+				revDeltas[client.ClusterName()]++
+			}
+		}
+	}
+
 	// Apply all given Kubernetes Network Policies.
 	for _, knp := range t.knps {
 		for _, client := range t.Context().clients.clients() {
@@ -531,6 +598,9 @@ func (t *Test) applyPolicies(ctx context.Context) error {
 	if len(t.cnps) > 0 {
 		t.Debugf("📜 Successfully applied %d CiliumNetworkPolicies", len(t.cnps))
 	}
+	if len(t.ccnps) > 0 {
+		t.Debugf("📜 Successfully applied %d CiliumClusterwideNetworkPolicies", len(t.ccnps))
+	}
 	if len(t.knps) > 0 {
 		t.Debugf("📜 Successfully applied %d K8S NetworkPolicies", len(t.knps))
 	}
@@ -543,7 +613,7 @@ func (t *Test) applyPolicies(ctx context.Context) error {
 
 // deletePolicies deletes a given set of network policies from the cluster.
 func (t *Test) deletePolicies(ctx context.Context) error {
-	if len(t.cnps) == 0 && len(t.knps) == 0 && len(t.cegps) == 0 {
+	if len(t.cnps) == 0 && len(t.ccnps) == 0 && len(t.knps) == 0 && len(t.cegps) == 0 {
 		return nil
 	}
 
@@ -568,6 +638,17 @@ func (t *Test) deletePolicies(ctx context.Context) error {
 		}
 	}
 
+	// Delete all the Test's CCNPs from all clients.
+	for _, ccnp := range t.ccnps {
+		t.Infof("📜 Deleting CiliumClusterwideNetworkPolicy '%s'..", ccnp.Name)
+		for _, client := range t.Context().clients.clients() {
+			if err := deleteCCNP(ctx, client, ccnp); err != nil {
+				return fmt.Errorf("deleting CiliumClusterwideNetworkPolicy: %w", err)
+			}
+			revDeltas[client.ClusterName()]++
+		}
+	}
+
 	// Delete all the Test's KNPs from all clients.
 	for _, knp := range t.knps {
 		t.Infof("📜 Deleting K8S NetworkPolicy '%s' from namespace '%s'..", knp.Name, knp.Namespace)
@@ -589,7 +670,7 @@ func (t *Test) deletePolicies(ctx context.Context) error {
 		}
 	}
 
-	if len(t.cnps) != 0 || len(t.knps) != 0 {
+	if len(t.cnps) != 0 || len(t.ccnps) != 0 || len(t.knps) != 0 {
 		// Wait for policies to be deleted on all Cilium nodes.
 		if err := t.waitCiliumPolicyRevisions(ctx, revs, revDeltas); err != nil {
 			return fmt.Errorf("timed out removing policies on Cilium agents: %w", err)
@@ -600,6 +681,10 @@ func (t *Test) deletePolicies(ctx context.Context) error {
 		t.Debugf("📜 Successfully deleted %d CiliumNetworkPolicies", len(t.cnps))
 	}
 
+	if len(t.ccnps) > 0 {
+		t.Debugf("📜 Successfully deleted %d CiliumClusterwideNetworkPolicies", len(t.ccnps))
+	}
+
 	if len(t.knps) > 0 {
 		t.Debugf("📜 Successfully deleted %d K8S NetworkPolicy", len(t.knps))
 	}
@@ -652,6 +737,36 @@ func parseCiliumPolicyYAML(policy string) (cnps []*ciliumv2.CiliumNetworkPolicy,
 	return cnps, nil
 }
 
+// parseCiliumClusterwidePolicyYAML decodes policy yaml into a slice of
+// CiliumClusterwideNetworkPolicies.
+func parseCiliumClusterwidePolicyYAML(policy string) (ccnps []*ciliumv2.CiliumClusterwideNetworkPolicy, err error) {
+	if policy == "" {
+		return nil, nil
+	}
+
+	yamls := strings.Split(policy, "\n---")
+
+	for _, yaml := range yamls {
+		if strings.TrimSpace(yaml) == "" {
+			continue
+		}
+
+		obj, kind, err := serializer.NewCodecFactory(scheme.Scheme, serializer.EnableStrict).UniversalDeserializer().Decode([]byte(yaml), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("decoding policy yaml: %s\nerror: %w", yaml, err)
+		}
+
+		switch policy := obj.(type) {
+		case *ciliumv2.CiliumClusterwideNetworkPolicy:
+			ccnps = append(ccnps, policy)
+		default:
+			return nil, fmt.Errorf("unknown policy type '%s' in: %s", kind.Kind, yaml)
+		}
+	}
+
+	return ccnps, nil
+}
+
 // parseK8SPolicyYAML decodes policy yaml into a slice of K8S NetworkPolicies.
 func parseK8SPolicyYAML(policy string) (policies []*networkingv1.NetworkPolicy, err error) {
 	if policy == "" {
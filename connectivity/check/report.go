@@ -0,0 +1,323 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package check
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	reportStatusPassed  = "passed"
+	reportStatusFailed  = "failed"
+	reportStatusSkipped = "skipped"
+)
+
+// JSONReport is the machine-readable report of a connectivity test run, as
+// written to the file given via --report-json.
+type JSONReport struct {
+	CLIVersion    string           `json:"cliVersion"`
+	CiliumVersion string           `json:"ciliumVersion"`
+	Summary       JSONReportCounts `json:"summary"`
+	Tests         []JSONReportTest `json:"tests"`
+}
+
+// JSONReportCounts summarizes the results of a connectivity test run.
+type JSONReportCounts struct {
+	Tests            int `json:"tests"`
+	FailedTests      int `json:"failedTests"`
+	SkippedTests     int `json:"skippedTests"`
+	Actions          int `json:"actions"`
+	FailedActions    int `json:"failedActions"`
+	SkippedScenarios int `json:"skippedScenarios"`
+}
+
+// JSONReportTest is the report of a single Test.
+type JSONReportTest struct {
+	Name       string               `json:"name"`
+	Status     string               `json:"status"`
+	SkipReason string               `json:"skipReason,omitempty"`
+	StartTime  *time.Time           `json:"startTime,omitempty"`
+	EndTime    *time.Time           `json:"endTime,omitempty"`
+	Duration   float64              `json:"durationSeconds"`
+	Scenarios  []JSONReportScenario `json:"scenarios"`
+}
+
+// JSONReportScenario is the report of a single Scenario within a Test.
+type JSONReportScenario struct {
+	Name       string             `json:"name"`
+	Status     string             `json:"status"`
+	SkipReason string             `json:"skipReason,omitempty"`
+	Actions    []JSONReportAction `json:"actions"`
+}
+
+// JSONReportAction is the report of a single Action within a Scenario.
+type JSONReportAction struct {
+	Name        string                 `json:"name"`
+	Status      string                 `json:"status"`
+	Source      *JSONReportPeer        `json:"source,omitempty"`
+	Destination *JSONReportPeer        `json:"destination,omitempty"`
+	IPFamily    string                 `json:"ipFamily"`
+	Command     []string               `json:"command,omitempty"`
+	ExitCode    *int                   `json:"exitCode,omitempty"`
+	Expected    JSONReportResults      `json:"expected"`
+	Actual      *JSONReportResults     `json:"actual,omitempty"`
+	Flows       []JSONReportFlowResult `json:"flows,omitempty"`
+	Metrics     []JSONReportMetric     `json:"metrics,omitempty"`
+	Failures    []string               `json:"failures,omitempty"`
+	StartTime   time.Time              `json:"startTime"`
+	EndTime     *time.Time             `json:"endTime,omitempty"`
+	Duration    float64                `json:"durationSeconds"`
+}
+
+// JSONReportPeer describes the source or destination peer of an Action.
+type JSONReportPeer struct {
+	Name    string            `json:"name"`
+	Address string            `json:"address"`
+	Port    uint32            `json:"port,omitempty"`
+	Node    string            `json:"node,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// JSONReportResults holds the egress and ingress results of an Action, as
+// well as the resulting exit code, either expected or actually observed.
+type JSONReportResults struct {
+	Egress   string `json:"egress"`
+	Ingress  string `json:"ingress"`
+	ExitCode string `json:"exitCode"`
+}
+
+// JSONReportFlowResult is the outcome of the flow validation for a peer.
+type JSONReportFlowResult struct {
+	Peer       string `json:"peer"`
+	Success    bool   `json:"success"`
+	FirstMatch int    `json:"firstMatch"`
+	LastMatch  int    `json:"lastMatch"`
+	Matched    int    `json:"matched"`
+	Failures   int    `json:"failures"`
+}
+
+// JSONReportMetric is the outcome of a metrics assertion on a node.
+type JSONReportMetric struct {
+	Source  string `json:"source"`
+	Node    string `json:"node"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (ct *ConnectivityTest) writeJSONReport() error {
+	if ct.Params().JSONReportFile == "" {
+		return nil
+	}
+
+	f, err := os.Create(ct.Params().JSONReportFile)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(ct.jsonReport()); err != nil {
+		if e := f.Close(); e != nil {
+			return errors.Join(err, e)
+		}
+		return err
+	}
+
+	return f.Close()
+}
+
+// jsonReport builds the JSONReport of all the Tests registered to the
+// ConnectivityTest.
+func (ct *ConnectivityTest) jsonReport() *JSONReport {
+	report := &JSONReport{
+		CLIVersion:    ct.version,
+		CiliumVersion: ct.CiliumVersion.String(),
+		Summary: JSONReportCounts{
+			Tests:            len(ct.tests) - len(ct.skippedTests()),
+			FailedTests:      len(ct.failedTests()),
+			SkippedTests:     len(ct.skippedTests()),
+			Actions:          len(ct.actions()),
+			FailedActions:    len(ct.failedActions()),
+			SkippedScenarios: len(ct.skippedScenarios()),
+		},
+		Tests: make([]JSONReportTest, 0, len(ct.tests)),
+	}
+
+	for _, t := range ct.tests {
+		report.Tests = append(report.Tests, t.jsonReport())
+	}
+
+	return report
+}
+
+func (t *Test) jsonReport() JSONReportTest {
+	rt := JSONReportTest{
+		Name:      t.Name(),
+		Status:    reportStatusPassed,
+		Scenarios: make([]JSONReportScenario, 0, len(t.scenarioOrder)),
+	}
+
+	switch {
+	case t.skipped:
+		rt.Status = reportStatusSkipped
+		rt.SkipReason = t.skipReason
+	case t.failed:
+		rt.Status = reportStatusFailed
+	}
+
+	if !t.startTime.IsZero() {
+		rt.StartTime = &t.startTime
+		if !t.completionTime.IsZero() {
+			rt.EndTime = &t.completionTime
+			rt.Duration = t.completionTime.Sub(t.startTime).Seconds()
+		}
+	}
+
+	for _, s := range t.scenarioOrder {
+		rs := JSONReportScenario{
+			Name:    t.scenarioName(s),
+			Status:  reportStatusPassed,
+			Actions: make([]JSONReportAction, 0, len(t.scenarios[s])),
+		}
+
+		if reason, ok := t.scenarioSkipReasons[s]; ok {
+			rs.Status = reportStatusSkipped
+			rs.SkipReason = reason
+		} else if t.skipped {
+			rs.Status = reportStatusSkipped
+		}
+
+		for _, a := range t.scenarios[s] {
+			ra := a.jsonReport()
+			if ra.Status == reportStatusFailed {
+				rs.Status = reportStatusFailed
+			}
+			rs.Actions = append(rs.Actions, ra)
+		}
+
+		rt.Scenarios = append(rt.Scenarios, rs)
+	}
+
+	return rt
+}
+
+func jsonReportPeer(peer TestPeer, ipFam IPFamily) *JSONReportPeer {
+	rp := &JSONReportPeer{
+		Name:    peer.Name(),
+		Address: peer.Address(ipFam),
+		Port:    peer.Port(),
+		Labels:  peer.Labels(),
+	}
+
+	switch p := peer.(type) {
+	case Pod:
+		rp.Node = p.NodeName()
+	case *Pod:
+		rp.Node = p.NodeName()
+	}
+
+	return rp
+}
+
+func (a *Action) jsonReport() JSONReportAction {
+	ra := JSONReportAction{
+		Name:     a.name,
+		Status:   reportStatusPassed,
+		IPFamily: a.ipFam.String(),
+		Command:  a.cmd,
+		Expected: JSONReportResults{
+			Egress:   a.expEgress.String(),
+			Ingress:  a.expIngress.String(),
+			ExitCode: a.expectedExitCode().String(),
+		},
+		Failures:  a.failures,
+		StartTime: a.started,
+	}
+
+	if a.failed {
+		ra.Status = reportStatusFailed
+	}
+
+	if a.src != nil {
+		ra.Source = jsonReportPeer(a.src, a.ipFam)
+	}
+	if a.dst != nil {
+		ra.Destination = jsonReportPeer(a.dst, a.ipFam)
+	}
+
+	if a.cmd != nil {
+		code := int(a.exitCode)
+		ra.ExitCode = &code
+	}
+	ra.Actual = a.actualResults()
+
+	for peer, r := range a.flowResults {
+		ra.Flows = append(ra.Flows, JSONReportFlowResult{
+			Peer:       peer.Name(),
+			Success:    r.Failures == 0 && r.FirstMatch >= 0,
+			FirstMatch: r.FirstMatch,
+			LastMatch:  r.LastMatch,
+			Matched:    len(r.Matched),
+			Failures:   r.Failures,
+		})
+	}
+	sort.Slice(ra.Flows, func(i, j int) bool {
+		return ra.Flows[i].Peer < ra.Flows[j].Peer
+	})
+
+	for _, m := range a.metricsValidations {
+		rm := JSONReportMetric{
+			Source:  m.source,
+			Node:    m.node,
+			Success: m.err == nil,
+		}
+		if m.err != nil {
+			rm.Error = m.err.Error()
+		}
+		ra.Metrics = append(ra.Metrics, rm)
+	}
+
+	if !a.completed.IsZero() {
+		ra.EndTime = &a.completed
+		ra.Duration = a.completed.Sub(a.started).Seconds()
+	}
+
+	return ra
+}
+
+// actualResults returns the results observed for the Action, or nil if it
+// neither ran a command nor validated flows. The result of a direction is the
+// expected one if the flows of the corresponding peer, the source for egress
+// and the destination for ingress, were successfully validated against it.
+// Otherwise, it is derived from the exit code of the command, which doesn't
+// tell in which direction the traffic was dropped.
+func (a *Action) actualResults() *JSONReportResults {
+	if a.cmd == nil && len(a.flowResults) == 0 {
+		return nil
+	}
+
+	observed := Result{Drop: a.exitCode != 0, ExitCode: a.exitCode}
+	actual := func(peer TestPeer, expected Result) string {
+		if peer == nil {
+			return observed.String()
+		}
+		if r, ok := a.flowResults[peer]; ok && r.Failures == 0 && r.FirstMatch >= 0 {
+			return expected.String()
+		}
+		return observed.String()
+	}
+
+	res := &JSONReportResults{
+		Egress:  actual(a.src, a.expEgress),
+		Ingress: actual(a.dst, a.expIngress),
+	}
+	if a.cmd != nil {
+		res.ExitCode = a.exitCode.String()
+	}
+	return res
+}
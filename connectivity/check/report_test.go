@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package check
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type reportTestScenario struct{}
+
+func (s *reportTestScenario) Name() string { return "dummy" }
+
+func (s *reportTestScenario) Run(context.Context, *Test) {}
+
+func TestJSONReport(t *testing.T) {
+	ct := &ConnectivityTest{
+		params:    Parameters{Writer: &bytes.Buffer{}},
+		version:   "v0.0.0-test",
+		testNames: make(map[string]struct{}),
+	}
+
+	passed := ct.NewTest("passed")
+	failed := ct.NewTest("failed")
+	skipped := ct.NewTest("skipped")
+
+	s1, s2 := &reportTestScenario{}, &reportTestScenario{}
+	passed.WithScenarios(s1)
+	failed.WithScenarios(s2)
+	skipped.WithScenarios(&reportTestScenario{})
+
+	client := &Pod{Pod: &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cilium-test", Name: "client", Labels: map[string]string{"kind": "client"}},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+		Status:     corev1.PodStatus{PodIPs: []corev1.PodIP{{IP: "10.0.0.1"}}},
+	}}
+	echo := HTTPEndpoint("echo", "http://echo:8080/")
+
+	now := time.Now()
+	passed.startTime, passed.completionTime = now, now.Add(2*time.Second)
+
+	a := passed.NewAction(s1, "curl-0", client, echo, IPFamilyV4)
+	a.cmd = []string{"curl", "http://echo:8080/"}
+	a.flowResults[echo] = FlowRequirementResults{FirstMatch: 1, LastMatch: 3, Matched: MatchMap{1: true, 3: true}}
+	a.completed = a.started.Add(time.Second)
+
+	b := failed.NewAction(s2, "curl-0", client, echo, IPFamilyV4)
+	b.cmd = []string{"curl", "http://echo:8080/"}
+	b.exitCode = ExitCurlTimeout
+	b.fail("command failed")
+	failed.failed = true
+
+	ct.skip(skipped, "feature l7-proxy is disabled")
+
+	r := ct.jsonReport()
+	assert.Equal(t, "v0.0.0-test", r.CLIVersion)
+	assert.Equal(t, JSONReportCounts{Tests: 2, FailedTests: 1, SkippedTests: 1, Actions: 2, FailedActions: 1}, r.Summary)
+	require.Len(t, r.Tests, 3)
+
+	rt := r.Tests[0]
+	assert.Equal(t, reportStatusPassed, rt.Status)
+	assert.Equal(t, 2.0, rt.Duration)
+	require.Len(t, rt.Scenarios, 1)
+	assert.Equal(t, "passed/dummy", rt.Scenarios[0].Name)
+	require.Len(t, rt.Scenarios[0].Actions, 1)
+	ra := rt.Scenarios[0].Actions[0]
+	assert.Equal(t, "cilium-test/client", ra.Source.Name)
+	assert.Equal(t, "10.0.0.1", ra.Source.Address)
+	assert.Equal(t, "node-1", ra.Source.Node)
+	assert.Equal(t, uint32(8080), ra.Destination.Port)
+	assert.Equal(t, "ipv4", ra.IPFamily)
+	assert.Equal(t, 0, *ra.ExitCode)
+	assert.Equal(t, "Allow-exit(0)", ra.Expected.Egress)
+	// The ingress flows were validated, the egress result is derived from the
+	// exit code.
+	assert.Equal(t, &JSONReportResults{Egress: "Allow-exit(0)", Ingress: ra.Expected.Ingress, ExitCode: "0"}, ra.Actual)
+	assert.Equal(t, []JSONReportFlowResult{{Peer: "echo", Success: true, FirstMatch: 1, LastMatch: 3, Matched: 2}}, ra.Flows)
+	assert.Equal(t, 1.0, ra.Duration)
+
+	rt = r.Tests[1]
+	assert.Equal(t, reportStatusFailed, rt.Status)
+	assert.Equal(t, reportStatusFailed, rt.Scenarios[0].Status)
+	ra = rt.Scenarios[0].Actions[0]
+	assert.Equal(t, int(ExitCurlTimeout), *ra.ExitCode)
+	assert.Equal(t, &JSONReportResults{Egress: "Drop-exit(28)", Ingress: "Drop-exit(28)", ExitCode: "28"}, ra.Actual)
+	assert.Equal(t, []string{"command failed"}, ra.Failures)
+
+	rt = r.Tests[2]
+	assert.Equal(t, reportStatusSkipped, rt.Status)
+	assert.Equal(t, "feature l7-proxy is disabled", rt.SkipReason)
+	assert.Equal(t, reportStatusSkipped, rt.Scenarios[0].Status)
+}
+
+func TestPerfReport(t *testing.T) {
+	ct := &ConnectivityTest{
+		version: "v0.0.0-test",
+		PerfResults: map[PerfTests]PerfResult{
+			{Pod: "client-b", Test: "TCP_RR", Target: PerfTargetPod}: {
+				Scenario: "pod-net", Target: PerfTargetPod, Metric: "OP/s", Duration: 10 * time.Second, Values: []float64{1, 2},
+			},
+			{Pod: "client-a", Test: "TCP_RR", Target: PerfTargetNodeMatrix, DestinationNode: "node-2"}: {
+				Scenario: "pod-net", Target: PerfTargetNodeMatrix, SourceNode: "node-1", DestinationNode: "node-2", Metric: "OP/s", Values: []float64{3},
+			},
+			{Pod: "client-a", Test: "TCP_RR", Target: PerfTargetPod}: {
+				Scenario: "pod-net", Target: PerfTargetPod, Metric: "OP/s", Values: []float64{4},
+			},
+			{Pod: "client-a", Test: "TCP_RR_LATENCY_P99", Target: PerfTargetPod}: {
+				Scenario: "pod-net", Target: PerfTargetPod, Metric: "us", Values: []float64{5},
+			},
+		},
+	}
+
+	r := ct.perfReport()
+	assert.Equal(t, "v0.0.0-test", r.CLIVersion)
+	require.Len(t, r.Results, 4)
+
+	assert.Equal(t, PerfReportResult{Test: "TCP_RR", Scenario: "pod-net", Target: PerfTargetNodeMatrix, Pod: "client-a",
+		SourceNode: "node-1", DestinationNode: "node-2", Metric: "OP/s", Values: []float64{3}}, r.Results[0])
+	assert.Equal(t, "client-a", r.Results[1].Pod)
+	assert.Equal(t, PerfReportResult{Test: "TCP_RR", Scenario: "pod-net", Target: PerfTargetPod, Pod: "client-b",
+		Metric: "OP/s", Duration: 10, Values: []float64{1, 2}}, r.Results[2])
+	assert.Equal(t, "TCP_RR_LATENCY_P99", r.Results[3].Test)
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package check
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cilium/cilium-cli/k8s"
+)
+
+// deploySecondaryNamespace deploys an echo server and a client in the
+// secondary test namespace, for the cross-namespace tests. They use the same
+// names and labels as their counterparts in the test namespace, so that
+// policies only tell them apart by namespace.
+func (ct *ConnectivityTest) deploySecondaryNamespace(ctx context.Context) error {
+	ns := ct.params.SecondaryTestNamespace
+	client := ct.clients.src
+
+	_, err := client.GetNamespace(ctx, ns, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Creating namespace %s for connectivity check...", client.ClusterName(), ns)
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        ns,
+				Annotations: ct.params.NamespaceAnnotations,
+			},
+		}
+		_, err = client.CreateNamespace(ctx, namespace, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create namespace %s: %w", ns, err)
+		}
+	}
+
+	_, err = client.GetService(ctx, ns, echoSameNodeDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s/%s service...", client.ClusterName(), ns, echoSameNodeDeploymentName)
+		svc := newService(echoSameNodeDeploymentName, map[string]string{"name": echoSameNodeDeploymentName}, serviceLabels, "http", 8080)
+		_, err = client.CreateService(ctx, ns, svc, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create service %s/%s: %w", ns, echoSameNodeDeploymentName, err)
+		}
+	}
+
+	_, err = client.GetDeployment(ctx, ns, echoSameNodeDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s/%s deployment...", client.ClusterName(), ns, echoSameNodeDeploymentName)
+		containerPort := 8080
+		echoDeployment := newDeployment(deploymentParameters{
+			Name:           echoSameNodeDeploymentName,
+			Kind:           kindEchoName,
+			Port:           containerPort,
+			NamedPort:      "http-8080",
+			Image:          ct.params.JSONMockImage,
+			Labels:         map[string]string{"other": "echo"},
+			Annotations:    ct.params.DeploymentAnnotations.Match(echoSameNodeDeploymentName),
+			NodeSelector:   ct.params.NodeSelector,
+			ReadinessProbe: newLocalReadinessProbe(containerPort, "/"),
+		})
+		_, err = client.CreateServiceAccount(ctx, ns, k8s.NewServiceAccount(echoSameNodeDeploymentName), metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create service account %s/%s: %w", ns, echoSameNodeDeploymentName, err)
+		}
+		_, err = client.CreateDeployment(ctx, ns, echoDeployment, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create deployment %s/%s: %w", ns, echoSameNodeDeploymentName, err)
+		}
+	}
+
+	_, err = client.GetDeployment(ctx, ns, clientDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		ct.Logf("✨ [%s] Deploying %s/%s deployment...", client.ClusterName(), ns, clientDeploymentName)
+		clientDeployment := newDeployment(deploymentParameters{
+			Name:         clientDeploymentName,
+			Kind:         kindClientName,
+			NamedPort:    "http-8080",
+			Port:         8080,
+			Image:        ct.params.CurlImage,
+			Command:      []string{"/bin/ash", "-c", "sleep 10000000"},
+			Annotations:  ct.params.DeploymentAnnotations.Match(clientDeploymentName),
+			NodeSelector: ct.params.NodeSelector,
+		})
+		_, err = client.CreateServiceAccount(ctx, ns, k8s.NewServiceAccount(clientDeploymentName), metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create service account %s/%s: %w", ns, clientDeploymentName, err)
+		}
+		_, err = client.CreateDeployment(ctx, ns, clientDeployment, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to create deployment %s/%s: %w", ns, clientDeploymentName, err)
+		}
+	}
+
+	return nil
+}
+
+// validateSecondaryNamespace waits for the pods of the secondary test
+// namespace to be ready, and records them for the cross-namespace tests.
+func (ct *ConnectivityTest) validateSecondaryNamespace(ctx context.Context) error {
+	ns := ct.params.SecondaryTestNamespace
+	client := ct.clients.src
+
+	for _, name := range []string{echoSameNodeDeploymentName, clientDeploymentName} {
+		if err := WaitForDeployment(ctx, ct, client, ns, name); err != nil {
+			return err
+		}
+	}
+
+	clientPods, err := client.ListPods(ctx, ns, metav1.ListOptions{LabelSelector: "kind=" + kindClientName})
+	if err != nil {
+		return fmt.Errorf("unable to list client pods in namespace %s: %w", ns, err)
+	}
+	for _, pod := range clientPods.Items {
+		if err := WaitForCiliumEndpoint(ctx, ct, client, ns, pod.Name); err != nil {
+			return err
+		}
+		ct.secondaryClientPods[pod.Name] = Pod{
+			K8sClient: client,
+			Pod:       pod.DeepCopy(),
+		}
+	}
+
+	echoPods, err := client.ListPods(ctx, ns, metav1.ListOptions{LabelSelector: "kind=" + kindEchoName})
+	if err != nil {
+		return fmt.Errorf("unable to list echo pods in namespace %s: %w", ns, err)
+	}
+	for _, pod := range echoPods.Items {
+		if err := WaitForCiliumEndpoint(ctx, ct, client, ns, pod.Name); err != nil {
+			return err
+		}
+		ct.secondaryEchoPods[pod.Name] = Pod{
+			K8sClient: client,
+			Pod:       pod.DeepCopy(),
+			scheme:    "http",
+			port:      8080, // listen port of the echo server inside the container
+		}
+	}
+
+	return nil
+}
+
+// deleteSecondaryNamespace deletes the secondary test namespace, along with
+// everything deployed in it.
+func (ct *ConnectivityTest) deleteSecondaryNamespace(ctx context.Context, client *k8s.Client) {
+	ns := ct.params.SecondaryTestNamespace
+	_ = client.DeleteNamespace(ctx, ns, metav1.DeleteOptions{})
+
+	_, err := client.GetNamespace(ctx, ns, metav1.GetOptions{})
+	if err == nil {
+		ct.Logf("⌛ [%s] Waiting for namespace %s to disappear", client.ClusterName(), ns)
+		for err == nil {
+			time.Sleep(time.Second)
+			_, err = client.GetNamespace(ctx, ns, metav1.GetOptions{})
+		}
+	}
+}
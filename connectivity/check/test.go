@@ -18,6 +18,7 @@ import (
 	k8sConst "github.com/cilium/cilium/pkg/k8s/apis/cilium.io"
 	ciliumv2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
 	v2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	"github.com/cilium/cilium/pkg/policy/api"
 	"github.com/cilium/cilium/pkg/versioncheck"
 
 	"github.com/cilium/cilium-cli/defaults"
@@ -63,6 +64,9 @@ type Test struct {
 	// True if the Test is marked as skipped.
 	skipped bool
 
+	// Reason the Test was skipped for, if any.
+	skipReason string
+
 	// True if the Test is marked as failed.
 	failed bool
 
@@ -70,6 +74,9 @@ type Test struct {
 	// for this test to be run
 	requirements []FeatureRequirement
 
+	// True if the Test must not run concurrently with any other Test.
+	exclusive bool
+
 	// installIPRoutesFromOutsideToPodCIDRs indicates that the test runner needs
 	// to install podCIDR => nodeIP routes before running the test
 	installIPRoutesFromOutsideToPodCIDRs bool
@@ -81,13 +88,22 @@ type Test struct {
 	// Scenarios registered to this test.
 	scenarios map[Scenario][]*Action
 
+	// Scenarios registered to this test, in registration order.
+	scenarioOrder []Scenario
+
 	// Scenarios marked as skipped during execution.
 	// Needs to be stored as a list, these are implemented in another package.
 	scenariosSkipped []Scenario
 
+	// Reasons the Scenarios were skipped for.
+	scenarioSkipReasons map[Scenario]string
+
 	// Policies active during this test.
 	cnps map[string]*ciliumv2.CiliumNetworkPolicy
 
+	// Cilium Clusterwide Network Policies active during this test.
+	ccnps map[string]*ciliumv2.CiliumClusterwideNetworkPolicy
+
 	// Kubernetes Network Policies active during this test.
 	knps map[string]*networkingv1.NetworkPolicy
 
@@ -118,6 +134,10 @@ type Test struct {
 	warnBuf *bytes.Buffer
 	verbose bool
 
+	// Buffer holding all output of the Test until it completes. Only used
+	// when Tests are allowed to run concurrently.
+	outBuf *bytes.Buffer
+
 	// List of functions to be called when Run() returns.
 	finalizers []func() error
 }
@@ -191,6 +211,10 @@ func (t *Test) setup(ctx context.Context) error {
 // This list is kept for reporting purposes.
 func (t *Test) skip(s Scenario, reason string) {
 	t.scenariosSkipped = append(t.scenariosSkipped, s)
+	if t.scenarioSkipReasons == nil {
+		t.scenarioSkipReasons = make(map[Scenario]string)
+	}
+	t.scenarioSkipReasons[s] = reason
 	t.Logf("[-] Skipping Scenario [%s] (%s)", t.scenarioName(s), reason)
 }
 
@@ -289,7 +313,7 @@ func (t *Test) Run(ctx context.Context) error {
 		t.completionTime = time.Now()
 	}()
 
-	t.ctx.Logf("[=] Test [%s]", t.Name())
+	t.header("[=] Test [%s]", t.Name())
 
 	if err := t.setup(ctx); err != nil {
 		return fmt.Errorf("setting up test: %w", err)
@@ -301,8 +325,8 @@ func (t *Test) Run(ctx context.Context) error {
 		}
 	}
 
-	if t.logBuf != nil {
-		t.ctx.Timestamp()
+	if t.logBuf != nil && t.ctx.timestamp() {
+		fmt.Fprint(t.writer(), timestamp())
 	}
 
 	for s := range t.scenarios {
@@ -326,7 +350,7 @@ func (t *Test) Run(ctx context.Context) error {
 	}
 
 	if t.logBuf != nil {
-		fmt.Fprintln(t.ctx.params.Writer)
+		fmt.Fprintln(t.writer())
 	}
 
 	// Don't add any more code here, as Scenario.Run() can call Fatal() and
@@ -349,42 +373,10 @@ func (t *Test) WithCiliumPolicy(policy string) *Test {
 	for i := range pl {
 		pl[i].Namespace = t.ctx.params.TestNamespace
 		if pl[i].Spec != nil {
-			for _, k := range []string{
-				k8sConst.PodNamespaceLabel,
-				kubernetesSourcedLabelPrefix + k8sConst.PodNamespaceLabel,
-				anySourceLabelPrefix + k8sConst.PodNamespaceLabel,
-			} {
-				for _, e := range pl[i].Spec.Egress {
-					for _, es := range e.ToEndpoints {
-						if n, ok := es.MatchLabels[k]; ok && n == defaults.ConnectivityCheckNamespace {
-							es.MatchLabels[k] = t.ctx.params.TestNamespace
-						}
-					}
-				}
-				for _, e := range pl[i].Spec.Ingress {
-					for _, es := range e.FromEndpoints {
-						if n, ok := es.MatchLabels[k]; ok && n == defaults.ConnectivityCheckNamespace {
-							es.MatchLabels[k] = t.ctx.params.TestNamespace
-						}
-					}
-				}
-
-				for _, e := range pl[i].Spec.EgressDeny {
-					for _, es := range e.ToEndpoints {
-						if n, ok := es.MatchLabels[k]; ok && n == defaults.ConnectivityCheckNamespace {
-							es.MatchLabels[k] = t.ctx.params.TestNamespace
-						}
-					}
-				}
-
-				for _, e := range pl[i].Spec.IngressDeny {
-					for _, es := range e.FromEndpoints {
-						if n, ok := es.MatchLabels[k]; ok && n == defaults.ConnectivityCheckNamespace {
-							es.MatchLabels[k] = t.ctx.params.TestNamespace
-						}
-					}
-				}
-			}
+			t.setTestNamespaceInSelectors(pl[i].Spec)
+		}
+		for _, spec := range pl[i].Specs {
+			t.setTestNamespaceInSelectors(spec)
 		}
 	}
 
@@ -397,6 +389,84 @@ func (t *Test) WithCiliumPolicy(policy string) *Test {
 	return t
 }
 
+// WithCiliumClusterwidePolicy takes a string containing a YAML policy document
+// and adds the clusterwide polic(y)(ies) to the scope of the Test, to be applied
+// when the test starts running. This is notably used for host policies, which
+// select nodes by means of a nodeSelector. When calling this method, note that
+// the CNP enabled feature requirement is applied directly here.
+func (t *Test) WithCiliumClusterwidePolicy(policy string) *Test {
+	pl, err := parseCiliumClusterwidePolicyYAML(policy)
+	if err != nil {
+		t.Fatalf("Parsing clusterwide policy YAML: %s", err)
+	}
+
+	// Change the default test namespace as required.
+	for i := range pl {
+		if pl[i].Spec != nil {
+			t.setTestNamespaceInSelectors(pl[i].Spec)
+		}
+		for _, spec := range pl[i].Specs {
+			t.setTestNamespaceInSelectors(spec)
+		}
+	}
+
+	if err := t.addCCNPs(pl...); err != nil {
+		t.Fatalf("Adding CCNPs to policy context: %s", err)
+	}
+
+	t.WithFeatureRequirements(RequireFeatureEnabled(FeatureCNP))
+
+	return t
+}
+
+// testNamespace returns the actual namespace the given namespace of a policy
+// manifest refers to, if it is one of the default test namespaces.
+func (t *Test) testNamespace(ns string) (string, bool) {
+	switch {
+	case ns == defaults.ConnectivityCheckNamespace:
+		return t.ctx.params.TestNamespace, true
+	case ns == defaults.ConnectivityCheckSecondaryNamespace && t.ctx.params.SecondaryTestNamespace != "":
+		return t.ctx.params.SecondaryTestNamespace, true
+	}
+	return "", false
+}
+
+// setTestNamespaceInSelectors replaces the default test namespaces by the
+// actual ones in the endpoint selectors of the given rule.
+func (t *Test) setTestNamespaceInSelectors(rule *api.Rule) {
+	selectors := []api.EndpointSelector{rule.EndpointSelector}
+	for _, e := range rule.Egress {
+		selectors = append(selectors, e.ToEndpoints...)
+	}
+	for _, e := range rule.Ingress {
+		selectors = append(selectors, e.FromEndpoints...)
+	}
+	for _, e := range rule.EgressDeny {
+		selectors = append(selectors, e.ToEndpoints...)
+	}
+	for _, e := range rule.IngressDeny {
+		selectors = append(selectors, e.FromEndpoints...)
+	}
+
+	for _, k := range []string{
+		k8sConst.PodNamespaceLabel,
+		kubernetesSourcedLabelPrefix + k8sConst.PodNamespaceLabel,
+		anySourceLabelPrefix + k8sConst.PodNamespaceLabel,
+		k8sConst.PodNamespaceMetaNameLabel,
+		kubernetesSourcedLabelPrefix + k8sConst.PodNamespaceMetaNameLabel,
+		anySourceLabelPrefix + k8sConst.PodNamespaceMetaNameLabel,
+	} {
+		for _, es := range selectors {
+			if es.LabelSelector == nil {
+				continue
+			}
+			if n, ok := t.testNamespace(es.MatchLabels[k]); ok {
+				es.MatchLabels[k] = n
+			}
+		}
+	}
+}
+
 // WithK8SPolicy takes a string containing a YAML policy document and adds
 // the polic(y)(ies) to the scope of the Test, to be applied when the test
 // starts running. When calling this method, note that the KNP enabled feature
@@ -416,17 +486,18 @@ func (t *Test) WithK8SPolicy(policy string) *Test {
 				k8sConst.PodNamespaceLabel,
 				kubernetesSourcedLabelPrefix + k8sConst.PodNamespaceLabel,
 				anySourceLabelPrefix + k8sConst.PodNamespaceLabel,
+				corev1.LabelMetadataName,
 			} {
 				for _, e := range pl[i].Spec.Egress {
 					for _, es := range e.To {
 						if es.PodSelector != nil {
-							if n, ok := es.PodSelector.MatchLabels[k]; ok && n == defaults.ConnectivityCheckNamespace {
-								es.PodSelector.MatchLabels[k] = t.ctx.params.TestNamespace
+							if n, ok := t.testNamespace(es.PodSelector.MatchLabels[k]); ok {
+								es.PodSelector.MatchLabels[k] = n
 							}
 						}
 						if es.NamespaceSelector != nil {
-							if n, ok := es.NamespaceSelector.MatchLabels[k]; ok && n == defaults.ConnectivityCheckNamespace {
-								es.NamespaceSelector.MatchLabels[k] = t.ctx.params.TestNamespace
+							if n, ok := t.testNamespace(es.NamespaceSelector.MatchLabels[k]); ok {
+								es.NamespaceSelector.MatchLabels[k] = n
 							}
 						}
 					}
@@ -434,13 +505,13 @@ func (t *Test) WithK8SPolicy(policy string) *Test {
 				for _, e := range pl[i].Spec.Ingress {
 					for _, es := range e.From {
 						if es.PodSelector != nil {
-							if n, ok := es.PodSelector.MatchLabels[k]; ok && n == defaults.ConnectivityCheckNamespace {
-								es.PodSelector.MatchLabels[k] = t.ctx.params.TestNamespace
+							if n, ok := t.testNamespace(es.PodSelector.MatchLabels[k]); ok {
+								es.PodSelector.MatchLabels[k] = n
 							}
 						}
 						if es.NamespaceSelector != nil {
-							if n, ok := es.NamespaceSelector.MatchLabels[k]; ok && n == defaults.ConnectivityCheckNamespace {
-								es.NamespaceSelector.MatchLabels[k] = t.ctx.params.TestNamespace
+							if n, ok := t.testNamespace(es.NamespaceSelector.MatchLabels[k]); ok {
+								es.NamespaceSelector.MatchLabels[k] = n
 							}
 						}
 					}
@@ -539,6 +610,7 @@ func (t *Test) WithScenarios(sl ...Scenario) *Test {
 		}
 
 		t.scenarios[s] = make([]*Action, 0)
+		t.scenarioOrder = append(t.scenarioOrder, s)
 	}
 
 	return t
@@ -609,7 +681,11 @@ func (t *Test) WithSecret(secret *corev1.Secret) *Test {
 
 // WithCABundleSecret makes the secret `cabundle` with a CA bundle and adds it to the cluster
 func (t *Test) WithCABundleSecret() *Test {
-	if len(caBundle) == 0 {
+	bundle := caBundle
+	if len(t.ctx.externalTargetCA) > 0 {
+		bundle = t.ctx.externalTargetCA
+	}
+	if len(bundle) == 0 {
 		t.Fatalf("CA bundle is empty")
 	}
 
@@ -619,7 +695,7 @@ func (t *Test) WithCABundleSecret() *Test {
 			Namespace: t.ctx.params.TestNamespace,
 		},
 		Data: map[string][]byte{
-			"ca.crt": caBundle,
+			"ca.crt": bundle,
 		},
 	}
 
@@ -689,6 +765,24 @@ func (t *Test) WithCertificate(name, hostname string) *Test {
 	})
 }
 
+// WithExclusive marks the Test as having to run on its own, even if other
+// Tests are allowed to run concurrently. Use this for Tests that are sensitive
+// to traffic generated by other Tests.
+func (t *Test) WithExclusive() *Test {
+	t.exclusive = true
+	return t
+}
+
+// runsExclusively returns true if the Test can't run concurrently with other
+// Tests, as it was explicitly marked as such or because it alters the state of
+// the cluster, e.g. by applying policies.
+func (t *Test) runsExclusively() bool {
+	return t.exclusive ||
+		len(t.cnps) > 0 || len(t.ccnps) > 0 || len(t.knps) > 0 || len(t.cegps) > 0 ||
+		len(t.secrets) > 0 || len(t.before) > 0 ||
+		t.installIPRoutesFromOutsideToPodCIDRs
+}
+
 // SetupFunc is a callback meant to be called before running the test.
 // It performs additional setup needed to run tests.
 type SetupFunc func(ctx context.Context, t *Test, testCtx *ConnectivityTest) error
@@ -774,7 +868,7 @@ func (t *Test) ForEachIPFamily(do func(IPFamily)) {
 	// are any netpols installed (https://github.com/cilium/cilium/issues/23852
 	// and https://github.com/cilium/cilium/issues/23910).
 	if f, ok := t.Context().Feature(FeatureEndpointRoutes); ok &&
-		f.Enabled && (len(t.cnps) > 0 || len(t.knps) > 0) &&
+		f.Enabled && (len(t.cnps) > 0 || len(t.ccnps) > 0 || len(t.knps) > 0) &&
 		versioncheck.MustCompile("<1.14.0")(t.Context().CiliumVersion) {
 
 		ipFams = []IPFamily{IPFamilyV4}
@@ -1,8 +1,16 @@
 package check
 
 import (
+	"bytes"
+	"context"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestWithFeatureRequirements(t *testing.T) {
@@ -47,3 +55,189 @@ func TestWithFeatureRequirements(t *testing.T) {
 		})
 	}
 }
+
+type concurrencyTestScenario struct {
+	barrier *sync.WaitGroup
+	running *atomic.Int32
+	overlap *atomic.Bool
+}
+
+func (s *concurrencyTestScenario) Name() string { return "concurrency" }
+
+func (s *concurrencyTestScenario) Run(_ context.Context, t *Test) {
+	if s.running.Add(1) > 1 && t.exclusive {
+		s.overlap.Store(true)
+	}
+	defer s.running.Add(-1)
+
+	t.Info("output of", t.Name())
+
+	if s.barrier == nil {
+		return
+	}
+
+	// Wait for all Tests sharing the barrier to be running at the same time.
+	s.barrier.Done()
+	done := make(chan struct{})
+	go func() {
+		s.barrier.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for concurrent tests")
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	out := &bytes.Buffer{}
+	ct := &ConnectivityTest{
+		params:    Parameters{Writer: out, TestConcurrency: 2, Verbose: true},
+		testNames: make(map[string]struct{}),
+		outputMu:  &sync.Mutex{},
+	}
+
+	var (
+		barrier sync.WaitGroup
+		running atomic.Int32
+		overlap atomic.Bool
+	)
+	barrier.Add(2)
+
+	ct.NewTest("first").WithScenarios(&concurrencyTestScenario{barrier: &barrier, running: &running, overlap: &overlap})
+	ct.NewTest("second").WithScenarios(&concurrencyTestScenario{barrier: &barrier, running: &running, overlap: &overlap})
+	exclusive := ct.NewTest("exclusive").WithExclusive().
+		WithScenarios(&concurrencyTestScenario{running: &running, overlap: &overlap})
+
+	assert.False(t, ct.tests[0].runsExclusively())
+	assert.True(t, exclusive.runsExclusively())
+	assert.True(t, ct.NewTest("policy").WithCiliumPolicy(`
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: policy
+spec:
+  endpointSelector: {}
+`).runsExclusively())
+	ct.tests = ct.tests[:3]
+
+	assert.NoError(t, ct.Run(context.Background()))
+	assert.Empty(t, ct.failedTests())
+	assert.False(t, overlap.Load(), "exclusive test ran concurrently with other tests")
+
+	// The output of each Test must not be interleaved with others.
+	for _, name := range []string{"first", "second", "exclusive"} {
+		assert.Contains(t, out.String(), "[=] Test ["+name+"]\n  [-] Scenario ["+name+"/concurrency]\n  ℹ️  output of "+name+"\n")
+	}
+	assert.Equal(t, 3, strings.Count(out.String(), "[=] Test"))
+}
+
+func TestWithCiliumClusterwidePolicy(t *testing.T) {
+	ct := &ConnectivityTest{
+		params:    Parameters{TestNamespace: "custom-test"},
+		testNames: make(map[string]struct{}),
+	}
+
+	test := ct.NewTest("host-policy").WithCiliumClusterwidePolicy(`
+apiVersion: cilium.io/v2
+kind: CiliumClusterwideNetworkPolicy
+metadata:
+  name: host-policy
+spec:
+  nodeSelector: {}
+  ingressDeny:
+  - fromEndpoints:
+    - matchLabels:
+        io.kubernetes.pod.namespace: cilium-test
+        name: client
+`)
+
+	ccnp, ok := test.ccnps["host-policy"]
+	if assert.True(t, ok) {
+		assert.NotNil(t, ccnp.Spec.NodeSelector.LabelSelector)
+		assert.Equal(t, "custom-test",
+			ccnp.Spec.IngressDeny[0].FromEndpoints[0].MatchLabels["any.io.kubernetes.pod.namespace"])
+	}
+	assert.Contains(t, test.requirements, RequireFeatureEnabled(FeatureCNP))
+	assert.True(t, test.runsExclusively())
+}
+
+func TestWithPoliciesSecondaryNamespace(t *testing.T) {
+	ct := &ConnectivityTest{
+		params:    Parameters{TestNamespace: "custom-test", SecondaryTestNamespace: "custom-test-2"},
+		testNames: make(map[string]struct{}),
+	}
+
+	test := ct.NewTest("cross-namespace").WithCiliumPolicy(`
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: cnp
+spec:
+  endpointSelector:
+    matchLabels:
+      kind: echo
+  ingress:
+  - fromEndpoints:
+    - matchLabels:
+        k8s:io.kubernetes.pod.namespace: cilium-test-secondary
+  ingressDeny:
+  - fromEndpoints:
+    - matchLabels:
+        k8s:io.cilium.k8s.namespace.labels.kubernetes.io/metadata.name: cilium-test-secondary
+`).WithCiliumClusterwidePolicy(`
+apiVersion: cilium.io/v2
+kind: CiliumClusterwideNetworkPolicy
+metadata:
+  name: ccnp
+specs:
+- endpointSelector:
+    matchLabels:
+      k8s:io.kubernetes.pod.namespace: cilium-test-secondary
+  ingress:
+  - fromEndpoints:
+    - matchLabels:
+        k8s:io.kubernetes.pod.namespace: cilium-test
+`).WithK8SPolicy(`
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: knp
+spec:
+  podSelector: {}
+  ingress:
+  - from:
+    - namespaceSelector:
+        matchLabels:
+          kubernetes.io/metadata.name: cilium-test-secondary
+    - namespaceSelector:
+        matchLabels:
+          kubernetes.io/metadata.name: other
+`)
+
+	cnp := test.cnps["cnp"]
+	if assert.NotNil(t, cnp) {
+		assert.Equal(t, "custom-test", cnp.Namespace)
+		assert.Equal(t, "custom-test-2",
+			cnp.Spec.Ingress[0].FromEndpoints[0].MatchLabels["k8s.io.kubernetes.pod.namespace"])
+		assert.Equal(t, "custom-test-2",
+			cnp.Spec.IngressDeny[0].FromEndpoints[0].MatchLabels["k8s.io.cilium.k8s.namespace.labels.kubernetes.io/metadata.name"])
+	}
+
+	ccnp := test.ccnps["ccnp"]
+	if assert.NotNil(t, ccnp) && assert.Len(t, ccnp.Specs, 1) {
+		assert.Equal(t, "custom-test-2",
+			ccnp.Specs[0].EndpointSelector.MatchLabels["k8s.io.kubernetes.pod.namespace"])
+		assert.Equal(t, "custom-test",
+			ccnp.Specs[0].Ingress[0].FromEndpoints[0].MatchLabels["k8s.io.kubernetes.pod.namespace"])
+	}
+
+	knp := test.knps["knp"]
+	if assert.NotNil(t, knp) {
+		assert.Equal(t, "custom-test-2",
+			knp.Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"])
+		assert.Equal(t, "other",
+			knp.Spec.Ingress[0].From[1].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"])
+	}
+}
@@ -51,6 +51,32 @@ func WaitForDeployment(ctx context.Context, log Logger, client *k8s.Client, name
 	}
 }
 
+// WaitForDaemonSet waits until all pods of the specified daemonset are ready.
+func WaitForDaemonSet(ctx context.Context, log Logger, client *k8s.Client, namespace string, name string) error {
+	log.Logf("⌛ [%s] Waiting for daemonset %s/%s to become ready...", client.ClusterName(), namespace, name)
+
+	ctx, cancel := context.WithTimeout(ctx, LongTimeout)
+	defer cancel()
+	for {
+		ds, err := client.GetDaemonSet(ctx, namespace, name, metav1.GetOptions{})
+		if err == nil {
+			if ds.Status.DesiredNumberScheduled > 0 && ds.Status.NumberReady == ds.Status.DesiredNumberScheduled {
+				return nil
+			}
+			err = fmt.Errorf("%d out of %d pods are ready", ds.Status.NumberReady, ds.Status.DesiredNumberScheduled)
+		}
+
+		log.Debugf("[%s] DaemonSet %s/%s is not yet ready: %s", client.ClusterName(), namespace, name, err)
+
+		select {
+		case <-time.After(PollInterval):
+		case <-ctx.Done():
+			return fmt.Errorf("timeout reached waiting for daemonset %s/%s to become ready (last error: %w)",
+				namespace, name, err)
+		}
+	}
+}
+
 // WaitForCiliumEndpoint waits until the specified cilium endpoint gets created.
 func WaitForCiliumEndpoint(ctx context.Context, log Logger, client *k8s.Client, namespace, name string) error {
 	log.Logf("⌛ [%s] Waiting for CiliumEndpoint for pod %s/%s to appear...", client.ClusterName(), namespace, name)
@@ -0,0 +1,337 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package matrix
+
+import (
+	"net/netip"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Tags categorizing the cases, used to include or exclude cases from a run.
+const (
+	TagBaseline          = "baseline"
+	TagIngress           = "ingress"
+	TagEgress            = "egress"
+	TagDenyAll           = "deny-all"
+	TagAllowAll          = "allow-all"
+	TagPodSelector       = "pod-selector"
+	TagNamespaceSelector = "namespace-selector"
+	TagMatchExpressions  = "match-expressions"
+	TagIPBlock           = "ip-block"
+	TagIPBlockExcept     = "ip-block-except"
+	TagPort              = "port"
+	TagNamedPort         = "named-port"
+	TagPortRange         = "port-range"
+	TagProtocol          = "protocol"
+	TagMultiPeer         = "multi-peer"
+	TagMultiPolicy       = "multi-policy"
+)
+
+// Case is a set of NetworkPolicies applied together, and against which all
+// pod pairs are probed.
+type Case struct {
+	Name     string
+	Tags     []string
+	Policies []*networkingv1.NetworkPolicy
+}
+
+func (c *Case) hasTag(tags []string) bool {
+	for _, t := range tags {
+		for _, ct := range c.Tags {
+			if t == ct {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// peer is a generated NetworkPolicy peer. The peers of a rule are ORed.
+type peer struct {
+	name  string
+	tags  []string
+	peers []networkingv1.NetworkPolicyPeer
+}
+
+// portSpec is a generated NetworkPolicy port specification.
+type portSpec struct {
+	name  string
+	tags  []string
+	ports []networkingv1.NetworkPolicyPort
+}
+
+func protocol(p corev1.Protocol) *corev1.Protocol {
+	return &p
+}
+
+func port(p intstr.IntOrString) *intstr.IntOrString {
+	return &p
+}
+
+func int32ptr(i int32) *int32 {
+	return &i
+}
+
+func selector(key, value string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: map[string]string{key: value}}
+}
+
+// hostPrefix returns the single address prefix of the IP, along with the
+// prefix covering all addresses of its family.
+func hostPrefix(ip string) (string, string) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", ""
+	}
+	all := "0.0.0.0/0"
+	if addr.Is6() {
+		all = "::/0"
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()).String(), all
+}
+
+// peers generates the peers the cases are built from, relative to the
+// namespace x the policies are applied in. The ipBlocks target the IP of pod
+// y/b.
+func (m *Matrix) peers() []peer {
+	ns := m.namespaces
+	peers := []peer{
+		{
+			name:  "pod-b",
+			tags:  []string{TagPodSelector},
+			peers: []networkingv1.NetworkPolicyPeer{{PodSelector: selector(podLabel, "b")}},
+		},
+		{
+			name:  "all-pods-in-y",
+			tags:  []string{TagNamespaceSelector},
+			peers: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: selector(namespaceLabel, "y")}},
+		},
+		{
+			name: "all-namespaces-but-x",
+			tags: []string{TagNamespaceSelector, TagMatchExpressions},
+			peers: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      namespaceLabel,
+					Operator: metav1.LabelSelectorOpNotIn,
+					Values:   []string{"x"},
+				}},
+			}}},
+		},
+		{
+			name: "pod-c-in-y",
+			tags: []string{TagNamespaceSelector, TagPodSelector},
+			peers: []networkingv1.NetworkPolicyPeer{{
+				NamespaceSelector: selector(metadataNameLabel, ns["y"]),
+				PodSelector:       selector(podLabel, "c"),
+			}},
+		},
+		{
+			name: "pod-c-in-all-namespaces",
+			tags: []string{TagNamespaceSelector, TagPodSelector},
+			peers: []networkingv1.NetworkPolicyPeer{{
+				NamespaceSelector: &metav1.LabelSelector{},
+				PodSelector:       selector(podLabel, "c"),
+			}},
+		},
+		{
+			name: "pod-b-or-all-pods-in-z",
+			tags: []string{TagPodSelector, TagNamespaceSelector, TagMultiPeer},
+			peers: []networkingv1.NetworkPolicyPeer{
+				{PodSelector: selector(podLabel, "b")},
+				{NamespaceSelector: selector(namespaceLabel, "z")},
+			},
+		},
+	}
+
+	if cidr, all := hostPrefix(m.pod("y", "b").ip); cidr != "" {
+		peers = append(peers,
+			peer{
+				name:  "ip-block-pod-y-b",
+				tags:  []string{TagIPBlock},
+				peers: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: cidr}}},
+			},
+			peer{
+				name:  "ip-block-all-except-pod-y-b",
+				tags:  []string{TagIPBlock, TagIPBlockExcept},
+				peers: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: all, Except: []string{cidr}}}},
+			},
+		)
+	}
+	return peers
+}
+
+// portSpecs generates the port specifications the cases are built from.
+func portSpecs() []portSpec {
+	return []portSpec{
+		{
+			name:  "port-80-tcp",
+			tags:  []string{TagPort},
+			ports: []networkingv1.NetworkPolicyPort{{Protocol: protocol(corev1.ProtocolTCP), Port: port(intstr.FromInt(80))}},
+		},
+		{
+			name:  "port-81-default-protocol",
+			tags:  []string{TagPort},
+			ports: []networkingv1.NetworkPolicyPort{{Port: port(intstr.FromInt(81))}},
+		},
+		{
+			name:  "named-port-81-udp",
+			tags:  []string{TagNamedPort},
+			ports: []networkingv1.NetworkPolicyPort{{Protocol: protocol(corev1.ProtocolUDP), Port: port(intstr.FromString(portName(81, corev1.ProtocolUDP)))}},
+		},
+		{
+			name:  "named-port-protocol-mismatch",
+			tags:  []string{TagNamedPort, TagProtocol},
+			ports: []networkingv1.NetworkPolicyPort{{Protocol: protocol(corev1.ProtocolTCP), Port: port(intstr.FromString(portName(80, corev1.ProtocolUDP)))}},
+		},
+		{
+			name:  "port-range-79-80-tcp",
+			tags:  []string{TagPortRange},
+			ports: []networkingv1.NetworkPolicyPort{{Protocol: protocol(corev1.ProtocolTCP), Port: port(intstr.FromInt(79)), EndPort: int32ptr(80)}},
+		},
+		{
+			name:  "port-range-80-81-udp",
+			tags:  []string{TagPortRange},
+			ports: []networkingv1.NetworkPolicyPort{{Protocol: protocol(corev1.ProtocolUDP), Port: port(intstr.FromInt(80)), EndPort: int32ptr(81)}},
+		},
+		{
+			name:  "all-udp-ports",
+			tags:  []string{TagProtocol},
+			ports: []networkingv1.NetworkPolicyPort{{Protocol: protocol(corev1.ProtocolUDP)}},
+		},
+		{
+			name: "port-80-tcp-or-81-udp",
+			tags: []string{TagPort, TagProtocol},
+			ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: protocol(corev1.ProtocolTCP), Port: port(intstr.FromInt(80))},
+				{Protocol: protocol(corev1.ProtocolUDP), Port: port(intstr.FromInt(81))},
+			},
+		},
+	}
+}
+
+// newPolicy returns a NetworkPolicy in namespace x, selecting the pods
+// matched by podSelector, or all pods of the namespace if it is nil.
+func (m *Matrix) newPolicy(name string, podSelector *metav1.LabelSelector, types ...networkingv1.PolicyType) *networkingv1.NetworkPolicy {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: m.namespaces["x"],
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: types,
+		},
+	}
+	if podSelector != nil {
+		np.Spec.PodSelector = *podSelector
+	}
+	return np
+}
+
+// directionCase returns a case with a single policy selecting pod x/a, with
+// a rule for the given peers and ports in the given direction.
+func (m *Matrix) directionCase(direction networkingv1.PolicyType, name string, tags []string, peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort) *Case {
+	dir := strings.ToLower(string(direction))
+	np := m.newPolicy(dir+"-"+name, selector(podLabel, "a"), direction)
+	if direction == networkingv1.PolicyTypeIngress {
+		np.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{From: peers, Ports: ports}}
+	} else {
+		np.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{{To: peers, Ports: ports}}
+	}
+	return &Case{
+		Name:     np.Name,
+		Tags:     append([]string{dir}, tags...),
+		Policies: []*networkingv1.NetworkPolicy{np},
+	}
+}
+
+// generateCases generates the family of cases: default deny and allow
+// policies, and policies combining each peer and port specification with
+// the most permissive counterpart, in both directions, along with cases
+// combining several policies and both directions.
+func (m *Matrix) generateCases() []*Case {
+	cases := []*Case{{Name: "no-policies", Tags: []string{TagBaseline}}}
+
+	for _, direction := range []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress} {
+		dir := strings.ToLower(string(direction))
+
+		deny := m.newPolicy(dir+"-deny-all", nil, direction)
+		cases = append(cases, &Case{
+			Name:     deny.Name,
+			Tags:     []string{dir, TagDenyAll},
+			Policies: []*networkingv1.NetworkPolicy{deny},
+		})
+
+		allow := m.newPolicy(dir+"-allow-all", nil, direction)
+		if direction == networkingv1.PolicyTypeIngress {
+			allow.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{}}
+		} else {
+			allow.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{{}}
+		}
+		cases = append(cases, &Case{
+			Name:     allow.Name,
+			Tags:     []string{dir, TagAllowAll},
+			Policies: []*networkingv1.NetworkPolicy{deny.DeepCopy(), allow},
+		})
+
+		for _, p := range m.peers() {
+			cases = append(cases, m.directionCase(direction, p.name, p.tags, p.peers, nil))
+		}
+		for _, p := range portSpecs() {
+			cases = append(cases, m.directionCase(direction, p.name, p.tags, nil, p.ports))
+		}
+	}
+
+	// Policies are additive: a second policy selecting the same pod allows
+	// the union of the traffic allowed by both.
+	first := m.directionCase(networkingv1.PolicyTypeIngress, "pod-b-port-80-tcp", nil,
+		[]networkingv1.NetworkPolicyPeer{{PodSelector: selector(podLabel, "b")}},
+		[]networkingv1.NetworkPolicyPort{{Protocol: protocol(corev1.ProtocolTCP), Port: port(intstr.FromInt(80))}})
+	second := m.directionCase(networkingv1.PolicyTypeIngress, "all-pods-in-y-port-81-udp", nil,
+		[]networkingv1.NetworkPolicyPeer{{NamespaceSelector: selector(namespaceLabel, "y")}},
+		[]networkingv1.NetworkPolicyPort{{Protocol: protocol(corev1.ProtocolUDP), Port: port(intstr.FromInt(81))}})
+	cases = append(cases, &Case{
+		Name:     "ingress-multi-policy",
+		Tags:     []string{TagIngress, TagPodSelector, TagNamespaceSelector, TagPort, TagMultiPolicy},
+		Policies: append(first.Policies, second.Policies...),
+	})
+
+	// Egress and ingress rules in the same policy, both applying to traffic
+	// between the pods of namespace x.
+	both := m.newPolicy("ingress-egress-pod-b", nil, networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress)
+	both.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{
+		From: []networkingv1.NetworkPolicyPeer{{PodSelector: selector(podLabel, "b")}},
+	}}
+	both.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{{
+		To:    []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+		Ports: []networkingv1.NetworkPolicyPort{{Protocol: protocol(corev1.ProtocolTCP), Port: port(intstr.FromString(portName(80, corev1.ProtocolTCP)))}},
+	}}
+	cases = append(cases, &Case{
+		Name:     both.Name,
+		Tags:     []string{TagIngress, TagEgress, TagPodSelector, TagNamespaceSelector, TagNamedPort},
+		Policies: []*networkingv1.NetworkPolicy{both},
+	})
+
+	return cases
+}
+
+// filterCases returns the cases which have any of the included tags, if
+// any, and none of the excluded tags.
+func filterCases(cases []*Case, include, exclude []string) []*Case {
+	var filtered []*Case
+	for _, c := range cases {
+		if len(include) > 0 && !c.hasTag(include) {
+			continue
+		}
+		if c.hasTag(exclude) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered
+}
@@ -0,0 +1,473 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// podLabel and namespaceLabel are the labels of the pods and namespaces
+	// the generated policies select on.
+	podLabel       = "pod"
+	namespaceLabel = "ns"
+	// metadataNameLabel is set on all namespaces by Kubernetes.
+	metadataNameLabel = "kubernetes.io/metadata.name"
+)
+
+var (
+	// namespaceNames and podNames are the short names of the namespaces and
+	// of the pods deployed in each of them.
+	namespaceNames = []string{"x", "y", "z"}
+	podNames       = []string{"a", "b", "c"}
+
+	// probes are the ports and protocols every pod serves, and probes.
+	probes = []probe{
+		{port: 80, protocol: corev1.ProtocolTCP},
+		{port: 81, protocol: corev1.ProtocolTCP},
+		{port: 80, protocol: corev1.ProtocolUDP},
+		{port: 81, protocol: corev1.ProtocolUDP},
+	}
+)
+
+type k8sMatrixImplementation interface {
+	GetNamespace(ctx context.Context, namespace string, options metav1.GetOptions) (*corev1.Namespace, error)
+	CreateNamespace(ctx context.Context, namespace *corev1.Namespace, opts metav1.CreateOptions) (*corev1.Namespace, error)
+	DeleteNamespace(ctx context.Context, namespace string, opts metav1.DeleteOptions) error
+	GetPod(ctx context.Context, namespace, name string, opts metav1.GetOptions) (*corev1.Pod, error)
+	CreatePod(ctx context.Context, namespace string, pod *corev1.Pod, opts metav1.CreateOptions) (*corev1.Pod, error)
+	ExecInPodWithStderr(ctx context.Context, namespace, pod, container string, command []string) (bytes.Buffer, bytes.Buffer, error)
+	ListKubernetesNetworkPolicies(ctx context.Context, namespace string, opts metav1.ListOptions) (*networkingv1.NetworkPolicyList, error)
+	CreateKubernetesNetworkPolicy(ctx context.Context, policy *networkingv1.NetworkPolicy, opts metav1.CreateOptions) (*networkingv1.NetworkPolicy, error)
+	DeleteKubernetesNetworkPolicy(ctx context.Context, namespace, name string, opts metav1.DeleteOptions) error
+}
+
+// Parameters of a NetworkPolicy conformance matrix run.
+type Parameters struct {
+	// NamespacePrefix is prepended to the names of the x, y and z
+	// namespaces the pods are deployed in.
+	NamespacePrefix string
+	// Image serving and probing the ports, agnhost.
+	Image string
+
+	// IncludeTags restricts the cases run to those with any of the tags.
+	IncludeTags []string
+	// ExcludeTags skips the cases with any of the tags.
+	ExcludeTags []string
+	// IncludeIPBlock runs the cases tagged ip-block, which select pods by
+	// their IP with ipBlocks. They are skipped by default, as Cilium only
+	// matches ipBlocks against addresses outside of the cluster.
+	IncludeIPBlock bool
+
+	// PolicyWait is how long to wait for the policies of a case to be
+	// enforced before probing.
+	PolicyWait   time.Duration
+	ProbeTimeout time.Duration
+	// Parallel is the number of probes run concurrently.
+	Parallel int
+	// Retries is how many times probes not matching the expected verdict
+	// are retried, to rule out transient failures.
+	Retries int
+	// Cleanup deletes the namespaces once done.
+	Cleanup bool
+
+	Writer io.Writer
+}
+
+// pod is a pod of the matrix, serving all the probes.
+type pod struct {
+	namespace string
+	// short is the short name of the pod, as namespace/pod, e.g. x/a.
+	short           string
+	name            string
+	labels          map[string]string
+	namespaceLabels map[string]string
+	ports           []corev1.ContainerPort
+	ip              string
+}
+
+func (p *pod) namedPort(name string) (corev1.ContainerPort, bool) {
+	for _, cp := range p.ports {
+		if cp.Name == name {
+			return cp, true
+		}
+	}
+	return corev1.ContainerPort{}, false
+}
+
+// probe is a port and protocol pod pairs are probed on.
+type probe struct {
+	port     int32
+	protocol corev1.Protocol
+}
+
+func (p probe) String() string {
+	return fmt.Sprintf("%d/%s", p.port, p.protocol)
+}
+
+// portName returns the name of the container port serving the port.
+func portName(port int32, protocol corev1.Protocol) string {
+	return fmt.Sprintf("serve-%d-%s", port, strings.ToLower(string(protocol)))
+}
+
+func containerName(p probe) string {
+	return fmt.Sprintf("cont-%d-%s", p.port, strings.ToLower(string(p.protocol)))
+}
+
+// Matrix probes all pod pairs of several namespaces on several ports and
+// protocols against a generated family of NetworkPolicies, and compares the
+// verdicts with those expected from the NetworkPolicy specification.
+type Matrix struct {
+	client k8sMatrixImplementation
+	params Parameters
+
+	// namespaces maps the short names of the namespaces to their names.
+	namespaces map[string]string
+	pods       []*pod
+}
+
+// NewMatrix returns a Matrix.
+func NewMatrix(client k8sMatrixImplementation, p Parameters) (*Matrix, error) {
+	if p.Parallel < 1 {
+		return nil, fmt.Errorf("parallel must be at least 1")
+	}
+
+	m := &Matrix{
+		client:     client,
+		params:     p,
+		namespaces: map[string]string{},
+	}
+	for _, ns := range namespaceNames {
+		m.namespaces[ns] = p.NamespacePrefix + "-" + ns
+		for _, name := range podNames {
+			m.pods = append(m.pods, m.newPod(ns, name))
+		}
+	}
+	return m, nil
+}
+
+func (m *Matrix) newPod(ns, name string) *pod {
+	p := &pod{
+		namespace: m.namespaces[ns],
+		short:     ns + "/" + name,
+		name:      name,
+		labels:    map[string]string{podLabel: name},
+		namespaceLabels: map[string]string{
+			namespaceLabel:    ns,
+			metadataNameLabel: m.namespaces[ns],
+		},
+	}
+	for _, pr := range probes {
+		p.ports = append(p.ports, corev1.ContainerPort{
+			Name:          portName(pr.port, pr.protocol),
+			ContainerPort: pr.port,
+			Protocol:      pr.protocol,
+		})
+	}
+	return p
+}
+
+func (m *Matrix) pod(ns, name string) *pod {
+	for _, p := range m.pods {
+		if p.short == ns+"/"+name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (m *Matrix) logf(format string, a ...interface{}) {
+	fmt.Fprintf(m.params.Writer, format+"\n", a...)
+}
+
+// Cases returns the cases run, once the pods are deployed.
+func (m *Matrix) Cases() []*Case {
+	exclude := m.params.ExcludeTags
+	if !m.params.IncludeIPBlock {
+		exclude = append(slices.Clone(exclude), TagIPBlock)
+	}
+	return filterCases(m.generateCases(), m.params.IncludeTags, exclude)
+}
+
+// Run deploys the pods, runs all cases and reports the truth table of each.
+// It returns an error if the verdicts of any case differ from the expected
+// ones.
+func (m *Matrix) Run(ctx context.Context) error {
+	if m.params.Cleanup {
+		defer m.cleanup(ctx)
+	}
+
+	m.logf("✨ Deploying %d pods in namespaces %s...", len(m.pods), strings.Join(m.namespaceList(), ", "))
+	if err := m.deploy(ctx); err != nil {
+		return err
+	}
+
+	cases := m.Cases()
+	if len(cases) == 0 {
+		return fmt.Errorf("no cases left to run after filtering by tags")
+	}
+	m.logf("ℹ️  Running %d cases, probing %d pod pairs on %d ports each", len(cases), len(m.pods)*(len(m.pods)-1), len(probes))
+	m.logf("%s", legend)
+
+	var results []*caseResult
+	for i, c := range cases {
+		m.logf("\n📋 [%d/%d] %s (%s)", i+1, len(cases), c.Name, strings.Join(c.Tags, ", "))
+		res, err := m.runCase(ctx, c)
+		if err != nil {
+			return fmt.Errorf("unable to run case %s: %w", c.Name, err)
+		}
+		m.printTruthTable(res)
+		results = append(results, res)
+	}
+
+	m.logf("")
+	failed := m.printSummary(results)
+	if failed > 0 {
+		return fmt.Errorf("%d/%d cases had unexpected verdicts", failed, len(results))
+	}
+	return nil
+}
+
+func (m *Matrix) namespaceList() []string {
+	var namespaces []string
+	for _, ns := range namespaceNames {
+		namespaces = append(namespaces, m.namespaces[ns])
+	}
+	return namespaces
+}
+
+// deploy creates the namespaces and pods if they do not exist yet, and waits
+// for the pods to be running.
+func (m *Matrix) deploy(ctx context.Context) error {
+	for _, ns := range namespaceNames {
+		name := m.namespaces[ns]
+		if existing, err := m.client.GetNamespace(ctx, name, metav1.GetOptions{}); err == nil {
+			// The expected verdicts rely on the labels of the namespaces.
+			if existing.Labels[namespaceLabel] != ns {
+				return fmt.Errorf("namespace %s already exists without label %s=%s", name, namespaceLabel, ns)
+			}
+			continue
+		}
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{namespaceLabel: ns},
+			},
+		}
+		if _, err := m.client.CreateNamespace(ctx, namespace, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create namespace %s: %w", name, err)
+		}
+	}
+
+	for _, p := range m.pods {
+		if _, err := m.client.GetPod(ctx, p.namespace, p.name, metav1.GetOptions{}); err == nil {
+			continue
+		}
+		if _, err := m.client.CreatePod(ctx, p.namespace, m.podSpec(p), metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create pod %s/%s: %w", p.namespace, p.name, err)
+		}
+	}
+
+	for _, p := range m.pods {
+		err := wait.PollUntilContextTimeout(ctx, time.Second, 5*time.Minute, true, func(ctx context.Context) (bool, error) {
+			pod, err := m.client.GetPod(ctx, p.namespace, p.name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+				return false, nil
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				if !status.Ready {
+					return false, nil
+				}
+			}
+			p.ip = pod.Status.PodIP
+			return true, nil
+		})
+		if err != nil {
+			return fmt.Errorf("pod %s/%s never became ready: %w", p.namespace, p.name, err)
+		}
+	}
+	return nil
+}
+
+// podSpec returns a pod running one agnhost container per probe, each
+// serving a single port and protocol on a named container port.
+func (m *Matrix) podSpec(p *pod) *corev1.Pod {
+	spec := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   p.name,
+			Labels: p.labels,
+		},
+		Spec: corev1.PodSpec{
+			TerminationGracePeriodSeconds: new(int64),
+		},
+	}
+	for i, pr := range probes {
+		spec.Spec.Containers = append(spec.Spec.Containers, corev1.Container{
+			Name:            containerName(pr),
+			Image:           m.params.Image,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command: []string{"/agnhost", "serve-hostname", "--" + strings.ToLower(string(pr.protocol)),
+				"--http=false", "--port", strconv.Itoa(int(pr.port))},
+			Ports: []corev1.ContainerPort{p.ports[i]},
+		})
+	}
+	return spec
+}
+
+func (m *Matrix) cleanup(ctx context.Context) {
+	for _, ns := range m.namespaceList() {
+		if err := m.client.DeleteNamespace(ctx, ns, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			m.logf("⚠️  Unable to delete namespace %s: %s", ns, err)
+		}
+	}
+}
+
+// deletePolicies deletes all NetworkPolicies of the namespaces, including
+// those left behind by an interrupted run.
+func (m *Matrix) deletePolicies(ctx context.Context) error {
+	for _, ns := range m.namespaceList() {
+		list, err := m.client.ListKubernetesNetworkPolicies(ctx, ns, metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to list NetworkPolicies in namespace %s: %w", ns, err)
+		}
+		for _, np := range list.Items {
+			if err := m.client.DeleteKubernetesNetworkPolicy(ctx, ns, np.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+				return fmt.Errorf("unable to delete NetworkPolicy %s/%s: %w", ns, np.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// verdict is the outcome of a probe, compared with the expected one.
+type verdict struct {
+	expected bool
+	actual   bool
+}
+
+func (v verdict) matches() bool {
+	return v.expected == v.actual
+}
+
+type pair struct {
+	src, dst *pod
+	probe    probe
+}
+
+type caseResult struct {
+	c          *Case
+	verdicts   map[pair]verdict
+	mismatches int
+}
+
+// runCase applies the policies of the case, probes all pod pairs, and
+// deletes the policies again.
+func (m *Matrix) runCase(ctx context.Context, c *Case) (*caseResult, error) {
+	if err := m.deletePolicies(ctx); err != nil {
+		return nil, err
+	}
+	for _, np := range c.Policies {
+		if _, err := m.client.CreateKubernetesNetworkPolicy(ctx, np, metav1.CreateOptions{}); err != nil {
+			return nil, fmt.Errorf("unable to create NetworkPolicy %s/%s: %w", np.Namespace, np.Name, err)
+		}
+	}
+	if len(c.Policies) > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(m.params.PolicyWait):
+		}
+	}
+
+	res := &caseResult{c: c, verdicts: map[pair]verdict{}}
+	var pairs []pair
+	for _, src := range m.pods {
+		for _, dst := range m.pods {
+			if src == dst {
+				continue
+			}
+			for _, pr := range probes {
+				pr := pair{src: src, dst: dst, probe: pr}
+				res.verdicts[pr] = verdict{expected: expected(c.Policies, src, dst, pr.probe)}
+				pairs = append(pairs, pr)
+			}
+		}
+	}
+
+	for attempt := 0; attempt <= m.params.Retries && len(pairs) > 0; attempt++ {
+		for pr, connected := range m.probeAll(ctx, pairs) {
+			v := res.verdicts[pr]
+			v.actual = connected
+			res.verdicts[pr] = v
+		}
+		// Only retry the probes which did not match the expected verdict.
+		var retry []pair
+		for _, pr := range pairs {
+			if !res.verdicts[pr].matches() {
+				retry = append(retry, pr)
+			}
+		}
+		pairs = retry
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	res.mismatches = len(pairs)
+
+	return res, m.deletePolicies(ctx)
+}
+
+// probeAll runs the probes concurrently, and returns whether each of them
+// connected.
+func (m *Matrix) probeAll(ctx context.Context, pairs []pair) map[pair]bool {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[pair]bool, len(pairs))
+		sem     = make(chan struct{}, m.params.Parallel)
+	)
+	for _, pr := range pairs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(pr pair) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			connected := m.connect(ctx, pr)
+			mu.Lock()
+			results[pr] = connected
+			mu.Unlock()
+		}(pr)
+	}
+	wg.Wait()
+	return results
+}
+
+// connect probes the destination from the source with agnhost connect, which
+// fails if the connection is refused or times out.
+func (m *Matrix) connect(ctx context.Context, pr pair) bool {
+	cmd := []string{"/agnhost", "connect", net.JoinHostPort(pr.dst.ip, strconv.Itoa(int(pr.probe.port))),
+		"--timeout=" + m.params.ProbeTimeout.String(), "--protocol=" + strings.ToLower(string(pr.probe.protocol))}
+	_, _, err := m.client.ExecInPodWithStderr(ctx, pr.src.namespace, pr.src.name, containerName(probes[0]), cmd)
+	return err == nil
+}
@@ -0,0 +1,335 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeClient simulates a cluster enforcing NetworkPolicies with the given
+// function.
+type fakeClient struct {
+	mu         sync.Mutex
+	namespaces map[string]*corev1.Namespace
+	pods       map[string]*corev1.Pod
+	policies   map[string]*networkingv1.NetworkPolicy
+	matrix     *Matrix
+	enforce    func(policies []*networkingv1.NetworkPolicy, src, dst *pod, p probe) bool
+	execs      int
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		namespaces: map[string]*corev1.Namespace{},
+		pods:       map[string]*corev1.Pod{},
+		policies:   map[string]*networkingv1.NetworkPolicy{},
+		enforce:    expected,
+	}
+}
+
+func (c *fakeClient) GetNamespace(_ context.Context, namespace string, _ metav1.GetOptions) (*corev1.Namespace, error) {
+	if ns, ok := c.namespaces[namespace]; ok {
+		return ns, nil
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, namespace)
+}
+
+func (c *fakeClient) CreateNamespace(_ context.Context, namespace *corev1.Namespace, _ metav1.CreateOptions) (*corev1.Namespace, error) {
+	c.namespaces[namespace.Name] = namespace
+	return namespace, nil
+}
+
+func (c *fakeClient) DeleteNamespace(_ context.Context, namespace string, _ metav1.DeleteOptions) error {
+	delete(c.namespaces, namespace)
+	return nil
+}
+
+func (c *fakeClient) GetPod(_ context.Context, namespace, name string, _ metav1.GetOptions) (*corev1.Pod, error) {
+	if pod, ok := c.pods[namespace+"/"+name]; ok {
+		return pod, nil
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "pods"}, name)
+}
+
+func (c *fakeClient) CreatePod(_ context.Context, namespace string, pod *corev1.Pod, _ metav1.CreateOptions) (*corev1.Pod, error) {
+	pod = pod.DeepCopy()
+	pod.Namespace = namespace
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.PodIP = fmt.Sprintf("10.0.%d.%d", len(c.pods)/3, len(c.pods)%3+10)
+	c.pods[namespace+"/"+pod.Name] = pod
+	return pod, nil
+}
+
+func (c *fakeClient) ExecInPodWithStderr(_ context.Context, namespace, name, _ string, command []string) (bytes.Buffer, bytes.Buffer, error) {
+	c.mu.Lock()
+	c.execs++
+	var policies []*networkingv1.NetworkPolicy
+	for _, np := range c.policies {
+		policies = append(policies, np)
+	}
+	c.mu.Unlock()
+
+	host, portStr, _ := net.SplitHostPort(command[2])
+	port, _ := strconv.Atoi(portStr)
+	pr := probe{port: int32(port), protocol: corev1.Protocol(strings.ToUpper(strings.TrimPrefix(command[4], "--protocol=")))}
+	var src, dst *pod
+	for _, p := range c.matrix.pods {
+		if p.namespace == namespace && p.name == name {
+			src = p
+		}
+		if p.ip == host {
+			dst = p
+		}
+	}
+	if c.enforce(policies, src, dst, pr) {
+		return *bytes.NewBufferString(dst.name), bytes.Buffer{}, nil
+	}
+	return bytes.Buffer{}, *bytes.NewBufferString("TIMEOUT"), errors.New("command terminated with exit code 1")
+}
+
+func (c *fakeClient) ListKubernetesNetworkPolicies(_ context.Context, namespace string, _ metav1.ListOptions) (*networkingv1.NetworkPolicyList, error) {
+	list := &networkingv1.NetworkPolicyList{}
+	for _, np := range c.policies {
+		if np.Namespace == namespace {
+			list.Items = append(list.Items, *np)
+		}
+	}
+	return list, nil
+}
+
+func (c *fakeClient) CreateKubernetesNetworkPolicy(_ context.Context, policy *networkingv1.NetworkPolicy, _ metav1.CreateOptions) (*networkingv1.NetworkPolicy, error) {
+	c.policies[policy.Namespace+"/"+policy.Name] = policy
+	return policy, nil
+}
+
+func (c *fakeClient) DeleteKubernetesNetworkPolicy(_ context.Context, namespace, name string, _ metav1.DeleteOptions) error {
+	delete(c.policies, namespace+"/"+name)
+	return nil
+}
+
+func newTestMatrix(t *testing.T, client *fakeClient, p Parameters) (*Matrix, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	p.NamespacePrefix = "knp"
+	p.Parallel = 4
+	p.Writer = &out
+	m, err := NewMatrix(client, p)
+	require.NoError(t, err)
+	client.matrix = m
+	return m, &out
+}
+
+// deployedMatrix returns a Matrix whose pods have IPs, without running it.
+func deployedMatrix(t *testing.T) *Matrix {
+	t.Helper()
+	m, _ := newTestMatrix(t, newFakeClient(), Parameters{})
+	require.NoError(t, m.deploy(context.Background()))
+	return m
+}
+
+func findCase(t *testing.T, m *Matrix, name string) *Case {
+	t.Helper()
+	for _, c := range m.generateCases() {
+		if c.Name == name {
+			return c
+		}
+	}
+	require.Failf(t, "case not found", "%s", name)
+	return nil
+}
+
+var (
+	tcp80 = probe{port: 80, protocol: corev1.ProtocolTCP}
+	tcp81 = probe{port: 81, protocol: corev1.ProtocolTCP}
+	udp80 = probe{port: 80, protocol: corev1.ProtocolUDP}
+	udp81 = probe{port: 81, protocol: corev1.ProtocolUDP}
+)
+
+func TestGenerateCases(t *testing.T) {
+	m := deployedMatrix(t)
+	cases := m.generateCases()
+
+	names := map[string]struct{}{}
+	for _, c := range cases {
+		assert.NotContains(t, names, c.Name)
+		names[c.Name] = struct{}{}
+		assert.NotEmpty(t, c.Tags, c.Name)
+		for _, np := range c.Policies {
+			assert.Equal(t, "knp-x", np.Namespace, c.Name)
+			assert.NotEmpty(t, np.Spec.PolicyTypes, c.Name)
+		}
+	}
+	assert.Contains(t, names, "ingress-ip-block-all-except-pod-y-b")
+	assert.Contains(t, names, "egress-named-port-81-udp")
+	assert.Contains(t, names, "egress-port-range-80-81-udp")
+
+	filtered := filterCases(cases, []string{TagIPBlock, TagNamedPort}, []string{TagEgress})
+	require.NotEmpty(t, filtered)
+	for _, c := range filtered {
+		assert.True(t, c.hasTag([]string{TagIPBlock, TagNamedPort}), c.Name)
+		assert.False(t, c.hasTag([]string{TagEgress}), c.Name)
+	}
+	assert.Len(t, filterCases(cases, nil, nil), len(cases))
+}
+
+func TestCasesIPBlock(t *testing.T) {
+	m := deployedMatrix(t)
+	for _, c := range m.Cases() {
+		assert.False(t, c.hasTag([]string{TagIPBlock}), c.Name)
+	}
+
+	m.params.IncludeIPBlock = true
+	assert.Len(t, m.Cases(), len(m.generateCases()))
+}
+
+func TestExpected(t *testing.T) {
+	m := deployedMatrix(t)
+	xa, xb, xc := m.pod("x", "a"), m.pod("x", "b"), m.pod("x", "c")
+	ya, yb, yc := m.pod("y", "a"), m.pod("y", "b"), m.pod("y", "c")
+	za := m.pod("z", "a")
+
+	assert.True(t, expected(nil, xa, xb, tcp80))
+
+	deny := findCase(t, m, "ingress-deny-all").Policies
+	assert.False(t, expected(deny, ya, xb, tcp80))
+	assert.True(t, expected(deny, xb, ya, tcp80), "egress is not isolated")
+	assert.True(t, expected(findCase(t, m, "ingress-allow-all").Policies, ya, xb, udp81), "policies are additive")
+
+	podB := findCase(t, m, "ingress-pod-b").Policies
+	assert.True(t, expected(podB, xb, xa, udp80))
+	assert.False(t, expected(podB, yb, xa, udp80), "pod selectors without namespace selector are namespaced")
+	assert.False(t, expected(podB, xc, xa, udp80))
+	assert.True(t, expected(podB, xc, xb, udp80), "only pod a is selected")
+
+	notX := findCase(t, m, "egress-all-namespaces-but-x").Policies
+	assert.True(t, expected(notX, xa, ya, tcp80))
+	assert.True(t, expected(notX, xa, za, tcp80))
+	assert.False(t, expected(notX, xa, xb, tcp80))
+
+	cInY := findCase(t, m, "ingress-pod-c-in-y").Policies
+	assert.True(t, expected(cInY, yc, xa, tcp80))
+	assert.False(t, expected(cInY, yb, xa, tcp80))
+
+	except := findCase(t, m, "egress-ip-block-all-except-pod-y-b").Policies
+	assert.True(t, expected(except, xa, ya, tcp80))
+	assert.False(t, expected(except, xa, yb, tcp80))
+	ipBlock := findCase(t, m, "ingress-ip-block-pod-y-b").Policies
+	assert.True(t, expected(ipBlock, yb, xa, tcp80))
+	assert.False(t, expected(ipBlock, yc, xa, tcp80))
+
+	defaultProtocol := findCase(t, m, "ingress-port-81-default-protocol").Policies
+	assert.True(t, expected(defaultProtocol, yb, xa, tcp81))
+	assert.False(t, expected(defaultProtocol, yb, xa, udp81))
+
+	named := findCase(t, m, "egress-named-port-81-udp").Policies
+	assert.True(t, expected(named, xa, yb, udp81))
+	assert.False(t, expected(named, xa, yb, udp80))
+	assert.False(t, expected(named, xa, yb, tcp81))
+	mismatch := findCase(t, m, "ingress-named-port-protocol-mismatch").Policies
+	for _, pr := range probes {
+		assert.False(t, expected(mismatch, yb, xa, pr), pr.String())
+	}
+
+	tcpRange := findCase(t, m, "ingress-port-range-79-80-tcp").Policies
+	assert.True(t, expected(tcpRange, yb, xa, tcp80))
+	assert.False(t, expected(tcpRange, yb, xa, tcp81))
+	udpRange := findCase(t, m, "ingress-port-range-80-81-udp").Policies
+	assert.True(t, expected(udpRange, yb, xa, udp80))
+	assert.True(t, expected(udpRange, yb, xa, udp81))
+	assert.False(t, expected(udpRange, yb, xa, tcp80))
+
+	multiPolicy := findCase(t, m, "ingress-multi-policy").Policies
+	assert.True(t, expected(multiPolicy, xb, xa, tcp80))
+	assert.False(t, expected(multiPolicy, xb, xa, udp81))
+	assert.True(t, expected(multiPolicy, yc, xa, udp81))
+
+	both := findCase(t, m, "ingress-egress-pod-b").Policies
+	assert.True(t, expected(both, xb, xc, tcp80), "allowed on egress of x/b and ingress of x/c")
+	assert.False(t, expected(both, xb, xc, tcp81), "denied on egress of x/b")
+	assert.False(t, expected(both, xc, xb, tcp80), "denied on ingress of x/b")
+	assert.True(t, expected(both, xb, ya, tcp80))
+}
+
+func TestMatrixRun(t *testing.T) {
+	client := newFakeClient()
+	m, out := newTestMatrix(t, client, Parameters{IncludeTags: []string{TagBaseline, TagNamedPort}, Cleanup: true})
+	require.NoError(t, m.Run(context.Background()))
+
+	assert.Contains(t, out.String(), "[1/6] no-policies (baseline)")
+	assert.Contains(t, out.String(), "   x/b  xxx.        ....  ....")
+	assert.NotContains(t, out.String(), "FAIL")
+	assert.Empty(t, client.policies, "policies are deleted after each case")
+	assert.Empty(t, client.namespaces, "namespaces are deleted on cleanup")
+	assert.Equal(t, 6*9*8*len(probes), client.execs)
+}
+
+func TestMatrixRunMismatch(t *testing.T) {
+	// Simulate an implementation which never selects pods with ipBlocks.
+	client := newFakeClient()
+	client.enforce = func(policies []*networkingv1.NetworkPolicy, src, dst *pod, p probe) bool {
+		var filtered []*networkingv1.NetworkPolicy
+		for _, np := range policies {
+			np = np.DeepCopy()
+			for i := range np.Spec.Egress {
+				for j := range np.Spec.Egress[i].To {
+					if np.Spec.Egress[i].To[j].IPBlock != nil {
+						np.Spec.Egress[i].To[j].IPBlock = &networkingv1.IPBlock{CIDR: "192.0.2.0/24"}
+					}
+				}
+			}
+			filtered = append(filtered, np)
+		}
+		return expected(filtered, src, dst, p)
+	}
+	m, out := newTestMatrix(t, client, Parameters{IncludeTags: []string{TagIPBlock}, ExcludeTags: []string{TagIngress}, IncludeIPBlock: true, Retries: 1})
+	err := m.Run(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, "2/2 cases had unexpected verdicts", err.Error())
+
+	// Only the probes to pod y/b are allowed by the ipBlock.
+	assert.Contains(t, out.String(), "❌ 4/288 probes did not match the expected verdict")
+	// All probes except those to pod y/b are allowed by the ipBlock.
+	assert.Contains(t, out.String(), "❌ 28/288 probes did not match the expected verdict")
+	assert.Contains(t, out.String(), "   x/a        ----  ----  ----  xxxx  ----  ----  ----  ----")
+	var summary []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, "egress-ip-block-pod-y-b ") {
+			summary = strings.Fields(line)
+		}
+	}
+	assert.Equal(t, []string{"egress-ip-block-pod-y-b", "egress,ip-block", "288", "4", "FAIL"}, summary)
+	// The mismatching probes of both cases are retried once.
+	assert.Equal(t, 2*(9*8*len(probes))+4+28, client.execs)
+	assert.Len(t, client.namespaces, 3, "namespaces are kept without cleanup")
+
+	m, _ = newTestMatrix(t, newFakeClient(), Parameters{ExcludeTags: []string{TagBaseline, TagIngress, TagEgress}})
+	assert.Error(t, m.Run(context.Background()), "no cases left")
+}
+
+func TestMatrixExistingNamespace(t *testing.T) {
+	client := newFakeClient()
+	client.namespaces["knp-y"] = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "knp-y"}}
+	m, _ := newTestMatrix(t, client, Parameters{PolicyWait: time.Millisecond})
+	assert.Error(t, m.Run(context.Background()))
+
+	_, err := NewMatrix(client, Parameters{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package matrix
+
+import (
+	"net/netip"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+)
+
+// The expected verdicts are computed following the semantics of the
+// Kubernetes NetworkPolicy specification, independently of how Cilium
+// implements them.
+
+// expected returns whether the policies allow the probe from src to dst.
+// Traffic must be allowed both on egress of the source and on ingress of the
+// destination.
+func expected(policies []*networkingv1.NetworkPolicy, src, dst *pod, p probe) bool {
+	return allowed(policies, networkingv1.PolicyTypeEgress, src, dst, dst, p) &&
+		allowed(policies, networkingv1.PolicyTypeIngress, dst, src, dst, p)
+}
+
+// allowed returns whether the policies selecting the subject for the given
+// policy type allow traffic with the peer. Subjects no policy selects for
+// the policy type are not isolated, and allow all traffic.
+func allowed(policies []*networkingv1.NetworkPolicy, policyType networkingv1.PolicyType, subject, peer, dst *pod, p probe) bool {
+	isolated := false
+	for _, np := range policies {
+		if np.Namespace != subject.namespace || !selectorMatches(&np.Spec.PodSelector, subject.labels) || !hasPolicyType(np, policyType) {
+			continue
+		}
+		isolated = true
+
+		if policyType == networkingv1.PolicyTypeIngress {
+			for _, rule := range np.Spec.Ingress {
+				if peersMatch(np, rule.From, peer) && portsMatch(rule.Ports, dst, p) {
+					return true
+				}
+			}
+		} else {
+			for _, rule := range np.Spec.Egress {
+				if peersMatch(np, rule.To, peer) && portsMatch(rule.Ports, dst, p) {
+					return true
+				}
+			}
+		}
+	}
+	return !isolated
+}
+
+// hasPolicyType returns whether the policy applies to the policy type.
+// Policies without policy types apply to ingress, and to egress if they have
+// egress rules.
+func hasPolicyType(np *networkingv1.NetworkPolicy, policyType networkingv1.PolicyType) bool {
+	if len(np.Spec.PolicyTypes) == 0 {
+		return policyType == networkingv1.PolicyTypeIngress || len(np.Spec.Egress) > 0
+	}
+	for _, t := range np.Spec.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+func peersMatch(np *networkingv1.NetworkPolicy, peers []networkingv1.NetworkPolicyPeer, peer *pod) bool {
+	if len(peers) == 0 {
+		return true
+	}
+	for _, p := range peers {
+		if p.IPBlock != nil {
+			if ipBlockMatches(p.IPBlock, peer.ip) {
+				return true
+			}
+			continue
+		}
+		if p.NamespaceSelector == nil {
+			if peer.namespace != np.Namespace {
+				continue
+			}
+		} else if !selectorMatches(p.NamespaceSelector, peer.namespaceLabels) {
+			continue
+		}
+		if p.PodSelector != nil && !selectorMatches(p.PodSelector, peer.labels) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func ipBlockMatches(b *networkingv1.IPBlock, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	prefix, err := netip.ParsePrefix(b.CIDR)
+	if err != nil || !prefix.Contains(addr) {
+		return false
+	}
+	for _, except := range b.Except {
+		if p, err := netip.ParsePrefix(except); err == nil && p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// portsMatch returns whether the ports allow the probe. Named ports are
+// resolved against the container ports of the destination pod, and only
+// match if the protocol of the named port matches as well.
+func portsMatch(ports []networkingv1.NetworkPolicyPort, dst *pod, p probe) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, np := range ports {
+		proto := corev1.ProtocolTCP
+		if np.Protocol != nil {
+			proto = *np.Protocol
+		}
+		if proto != p.protocol {
+			continue
+		}
+		if np.Port == nil {
+			return true
+		}
+		start := np.Port.IntVal
+		if np.Port.StrVal != "" {
+			cp, ok := dst.namedPort(np.Port.StrVal)
+			if !ok || cp.Protocol != proto {
+				continue
+			}
+			start = cp.ContainerPort
+		}
+		end := start
+		if np.EndPort != nil && np.Port.StrVal == "" {
+			end = *np.EndPort
+		}
+		if p.port >= start && p.port <= end {
+			return true
+		}
+	}
+	return false
+}
+
+func selectorMatches(sel *metav1.LabelSelector, lbls map[string]string) bool {
+	s, err := metav1.LabelSelectorAsSelector(sel)
+	if err != nil {
+		return false
+	}
+	return s.Matches(k8slabels.Set(lbls))
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package matrix
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// legend explains the cells of the truth tables. Each cell holds one
+// character per probe, in the order of the probes.
+var legend = func() string {
+	var ports []string
+	for _, pr := range probes {
+		ports = append(ports, pr.String())
+	}
+	return fmt.Sprintf(`ℹ️  Truth tables show one character per port, in the order %s:
+   .  connected, as expected
+   x  blocked, as expected
+   +  connected, but expected to be blocked
+   -  blocked, but expected to connect`, strings.Join(ports, ", "))
+}()
+
+func (v verdict) String() string {
+	switch {
+	case v.actual && v.expected:
+		return "."
+	case !v.actual && !v.expected:
+		return "x"
+	case v.actual:
+		return "+"
+	default:
+		return "-"
+	}
+}
+
+// printTruthTable prints the verdicts of the case, with the sources as rows
+// and the destinations as columns.
+func (m *Matrix) printTruthTable(res *caseResult) {
+	tw := tabwriter.NewWriter(m.params.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "   ")
+	for _, dst := range m.pods {
+		fmt.Fprintf(tw, "\t%s", dst.short)
+	}
+	fmt.Fprintln(tw)
+
+	for _, src := range m.pods {
+		fmt.Fprintf(tw, "   %s", src.short)
+		for _, dst := range m.pods {
+			var cell strings.Builder
+			for _, pr := range probes {
+				v, ok := res.verdicts[pair{src: src, dst: dst, probe: pr}]
+				if ok {
+					cell.WriteString(v.String())
+				}
+			}
+			fmt.Fprintf(tw, "\t%s", cell.String())
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+
+	if res.mismatches > 0 {
+		m.logf("❌ %d/%d probes did not match the expected verdict", res.mismatches, len(res.verdicts))
+	} else {
+		m.logf("✅ All %d probes matched the expected verdict", len(res.verdicts))
+	}
+}
+
+// printSummary prints the result of every case, and returns the number of
+// cases which failed.
+func (m *Matrix) printSummary(results []*caseResult) int {
+	failed := 0
+	tw := tabwriter.NewWriter(m.params.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CASE\tTAGS\tPROBES\tMISMATCHES\tRESULT")
+	for _, res := range results {
+		result := "ok"
+		if res.mismatches > 0 {
+			result = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", res.c.Name, strings.Join(res.c.Tags, ","), len(res.verdicts), res.mismatches, result)
+	}
+	tw.Flush()
+	return failed
+}
@@ -0,0 +1,327 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package perf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/cilium/cilium-cli/connectivity/check"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+
+	// PlacementSameNode is the placement of results between a client and a
+	// server running on the same node.
+	PlacementSameNode = "same-node"
+	// PlacementCrossNode is the placement of results between a client and a
+	// server running on different nodes.
+	PlacementCrossNode = "cross-node"
+)
+
+// CompareParameters configures how two performance reports are compared.
+type CompareParameters struct {
+	// Threshold is the relative change, in percent, a result needs to get
+	// worse by to be considered a regression.
+	Threshold float64
+	// Significance is the p-value below which a change is considered
+	// statistically significant.
+	Significance float64
+	// Output is the output format, one of OutputText or OutputJSON.
+	Output string
+}
+
+// Stats summarizes the samples of a performance test.
+type Stats struct {
+	Samples int     `json:"samples"`
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"stddev"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+}
+
+// Comparison is the comparison of a performance test across two reports.
+type Comparison struct {
+	Test     string `json:"test"`
+	Scenario string `json:"scenario"`
+	Target   string `json:"target"`
+	// Placement is one of PlacementSameNode and PlacementCrossNode, or empty
+	// if the nodes of the client and server are unknown.
+	Placement string `json:"placement,omitempty"`
+	Metric    string `json:"metric"`
+	Old       *Stats `json:"old,omitempty"`
+	New       *Stats `json:"new,omitempty"`
+	// Change is the relative change of the mean, in percent.
+	Change float64 `json:"changePercent"`
+	// PValue is the p-value of Welch's t-test on both sets of samples. It is
+	// nil if either set has less than two samples.
+	PValue     *float64 `json:"pValue,omitempty"`
+	Regression bool     `json:"regression"`
+}
+
+type resultKey struct {
+	test      string
+	scenario  string
+	target    string
+	placement string
+}
+
+// NewStats computes the statistics of the given samples.
+func NewStats(values []float64) Stats {
+	s := Stats{Samples: len(values)}
+	if len(values) == 0 {
+		return s
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	s.Mean = sum / float64(len(sorted))
+
+	if len(sorted) > 1 {
+		var sq float64
+		for _, v := range sorted {
+			sq += (v - s.Mean) * (v - s.Mean)
+		}
+		s.StdDev = math.Sqrt(sq / float64(len(sorted)-1))
+	}
+
+	s.Min = sorted[0]
+	s.Max = sorted[len(sorted)-1]
+	s.P50 = percentile(sorted, 50)
+	s.P90 = percentile(sorted, 90)
+	s.P99 = percentile(sorted, 99)
+
+	return s
+}
+
+// percentile returns the p-th percentile of the sorted values, interpolating
+// linearly between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+// lowerIsBetter returns true if lower values of the metric are better, as it
+// is the case for latencies.
+func lowerIsBetter(metric string) bool {
+	switch metric {
+	case "us", "ms":
+		return true
+	}
+	return false
+}
+
+// placement returns whether the client and server of a result ran on the same
+// node or on different nodes, or an empty string if that is unknown.
+func placement(res check.PerfReportResult) string {
+	switch {
+	case res.SourceNode == "" || res.DestinationNode == "":
+		return ""
+	case res.SourceNode == res.DestinationNode:
+		return PlacementSameNode
+	default:
+		return PlacementCrossNode
+	}
+}
+
+// groupResults merges the samples of all the pods which ran a given test in a
+// given scenario against a given target. Same-node and cross-node results
+// are kept apart, as their performance differs regardless of any change.
+func groupResults(r *check.PerfReport) (map[resultKey][]float64, map[resultKey]string) {
+	values := make(map[resultKey][]float64)
+	metrics := make(map[resultKey]string)
+	for _, res := range r.Results {
+		k := resultKey{test: res.Test, scenario: res.Scenario, target: res.Target, placement: placement(res)}
+		values[k] = append(values[k], res.Values...)
+		metrics[k] = res.Metric
+	}
+	return values, metrics
+}
+
+// Compare compares the results of the old and new performance reports,
+// per test, scenario, target and placement.
+func Compare(oldReport, newReport *check.PerfReport, params CompareParameters) []Comparison {
+	oldValues, oldMetrics := groupResults(oldReport)
+	newValues, newMetrics := groupResults(newReport)
+
+	keys := make(map[resultKey]struct{})
+	for k := range oldValues {
+		keys[k] = struct{}{}
+	}
+	for k := range newValues {
+		keys[k] = struct{}{}
+	}
+
+	comparisons := make([]Comparison, 0, len(keys))
+	for k := range keys {
+		c := Comparison{Test: k.test, Scenario: k.scenario, Target: k.target, Placement: k.placement, Metric: newMetrics[k]}
+		if c.Metric == "" {
+			c.Metric = oldMetrics[k]
+		}
+
+		ov, okOld := oldValues[k]
+		nv, okNew := newValues[k]
+		if okOld {
+			s := NewStats(ov)
+			c.Old = &s
+		}
+		if okNew {
+			s := NewStats(nv)
+			c.New = &s
+		}
+
+		if okOld && okNew {
+			if c.Old.Mean != 0 {
+				c.Change = (c.New.Mean - c.Old.Mean) / c.Old.Mean * 100
+			}
+			if p, ok := welchTTest(ov, nv); ok {
+				c.PValue = &p
+			}
+
+			worse := -c.Change
+			if lowerIsBetter(c.Metric) {
+				worse = c.Change
+			}
+			// Without enough samples to assess significance, rely on the
+			// threshold only.
+			significant := c.PValue == nil || *c.PValue < params.Significance
+			c.Regression = worse > params.Threshold && significant
+		}
+
+		comparisons = append(comparisons, c)
+	}
+
+	sort.Slice(comparisons, func(i, j int) bool {
+		a, b := comparisons[i], comparisons[j]
+		if a.Test != b.Test {
+			return a.Test < b.Test
+		}
+		if a.Scenario != b.Scenario {
+			return a.Scenario < b.Scenario
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Placement < b.Placement
+	})
+
+	return comparisons
+}
+
+// Regressions returns the number of regressions in the comparisons.
+func Regressions(comparisons []Comparison) int {
+	var n int
+	for _, c := range comparisons {
+		if c.Regression {
+			n++
+		}
+	}
+	return n
+}
+
+// CompareFiles compares the performance reports stored in the given files and
+// writes the result to w. It returns an error if any regression is detected.
+func CompareFiles(w io.Writer, oldPath, newPath string, params CompareParameters) error {
+	oldReport, err := check.LoadPerfReport(oldPath)
+	if err != nil {
+		return err
+	}
+	newReport, err := check.LoadPerfReport(newPath)
+	if err != nil {
+		return err
+	}
+
+	comparisons := Compare(oldReport, newReport, params)
+
+	switch params.Output {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(comparisons); err != nil {
+			return err
+		}
+	case OutputText, "":
+		if err := writeComparisons(w, oldReport, newReport, comparisons); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid output format %q", params.Output)
+	}
+
+	if n := Regressions(comparisons); n > 0 {
+		return fmt.Errorf("%d performance regressions detected", n)
+	}
+
+	return nil
+}
+
+func formatStats(s *Stats) []interface{} {
+	if s == nil {
+		return []interface{}{"-", "-", "-", "-", "-"}
+	}
+	return []interface{}{
+		fmt.Sprintf("%.2f", s.Mean), fmt.Sprintf("%.2f", s.StdDev),
+		fmt.Sprintf("%.2f", s.P50), fmt.Sprintf("%.2f", s.P90), fmt.Sprintf("%.2f", s.P99),
+	}
+}
+
+func writeComparisons(w io.Writer, oldReport, newReport *check.PerfReport, comparisons []Comparison) error {
+	fmt.Fprintf(w, "Old: Cilium %s (cilium-cli %s)\n", oldReport.CiliumVersion, oldReport.CLIVersion)
+	fmt.Fprintf(w, "New: Cilium %s (cilium-cli %s)\n\n", newReport.CiliumVersion, newReport.CLIVersion)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TEST\tSCENARIO\tTARGET\tPLACEMENT\tMETRIC\tOLD MEAN\tOLD STDDEV\tOLD P50\tOLD P90\tOLD P99\tNEW MEAN\tNEW STDDEV\tNEW P50\tNEW P90\tNEW P99\tCHANGE\tP-VALUE\tRESULT")
+	for _, c := range comparisons {
+		placement := c.Placement
+		if placement == "" {
+			placement = "-"
+		}
+		row := []interface{}{c.Test, c.Scenario, c.Target, placement, c.Metric}
+		row = append(row, formatStats(c.Old)...)
+		row = append(row, formatStats(c.New)...)
+
+		change, pvalue, result := "-", "-", "ok"
+		switch {
+		case c.Old == nil:
+			result = "new"
+		case c.New == nil:
+			result = "removed"
+		default:
+			change = fmt.Sprintf("%+.2f%%", c.Change)
+			if c.PValue != nil {
+				pvalue = fmt.Sprintf("%.4f", *c.PValue)
+			}
+			if c.Regression {
+				result = "REGRESSION"
+			}
+		}
+		row = append(row, change, pvalue, result)
+
+		for i, v := range row {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, v)
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
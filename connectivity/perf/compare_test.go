@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package perf
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/cilium-cli/connectivity/check"
+)
+
+func TestNewStats(t *testing.T) {
+	s := NewStats([]float64{5, 1, 4, 2, 3})
+	assert.Equal(t, 5, s.Samples)
+	assert.Equal(t, 3.0, s.Mean)
+	assert.InDelta(t, 1.5811, s.StdDev, 1e-4)
+	assert.Equal(t, 1.0, s.Min)
+	assert.Equal(t, 5.0, s.Max)
+	assert.Equal(t, 3.0, s.P50)
+	assert.InDelta(t, 4.6, s.P90, 1e-9)
+	assert.InDelta(t, 4.96, s.P99, 1e-9)
+
+	s = NewStats([]float64{42})
+	assert.Equal(t, Stats{Samples: 1, Mean: 42, Min: 42, Max: 42, P50: 42, P90: 42, P99: 42}, s)
+
+	assert.Equal(t, Stats{}, NewStats(nil))
+}
+
+func TestWelchTTest(t *testing.T) {
+	p, ok := welchTTest([]float64{1, 2, 3, 4, 5}, []float64{3, 4, 5, 6, 7})
+	require.True(t, ok)
+	assert.InDelta(t, 0.0805, p, 1e-4)
+
+	p, ok = welchTTest([]float64{10, 11, 10.5, 10.2}, []float64{10, 11, 10.5, 10.2})
+	require.True(t, ok)
+	assert.InDelta(t, 1, p, 1e-9)
+
+	_, ok = welchTTest([]float64{1}, []float64{1, 2})
+	assert.False(t, ok)
+}
+
+func TestCompare(t *testing.T) {
+	oldReport := &check.PerfReport{Results: []check.PerfReportResult{
+		{Test: "TCP_RR", Scenario: "pod-net", Target: "pod", Pod: "client-a", SourceNode: "node-1", DestinationNode: "node-1", Metric: "OP/s", Values: []float64{1000, 1010, 990}},
+		{Test: "TCP_RR", Scenario: "pod-net", Target: "pod", Pod: "client-b", SourceNode: "node-2", DestinationNode: "node-1", Metric: "OP/s", Values: []float64{600, 605, 595}},
+		{Test: "TCP_STREAM", Scenario: "pod-net", Target: "pod", Pod: "client-a", SourceNode: "node-1", DestinationNode: "node-1", Metric: "Mb/s", Values: []float64{9000, 9100, 8900}},
+		{Test: "UDP_RR", Scenario: "host-net", Pod: "client-a", Metric: "OP/s", Values: []float64{500}},
+	}}
+
+	newReport := &check.PerfReport{Results: []check.PerfReportResult{
+		{Test: "TCP_RR", Scenario: "pod-net", Target: "pod", Pod: "client-c", SourceNode: "node-3", DestinationNode: "node-3", Metric: "OP/s", Values: []float64{800, 810, 790, 805, 795, 800}},
+		{Test: "TCP_RR", Scenario: "pod-net", Target: "pod", Pod: "client-d", SourceNode: "node-4", DestinationNode: "node-3", Metric: "OP/s", Values: []float64{600, 598, 602}},
+		{Test: "TCP_STREAM", Scenario: "pod-net", Target: "pod", Pod: "client-c", SourceNode: "node-3", DestinationNode: "node-3", Metric: "Mb/s", Values: []float64{9050, 8950, 9000}},
+		{Test: "UDP_RR", Scenario: "host-net", Pod: "client-c", Metric: "OP/s", Values: []float64{400}},
+		{Test: "UDP_STREAM", Scenario: "pod-net", Target: "pod", Pod: "client-c", SourceNode: "node-3", DestinationNode: "node-3", Metric: "Mb/s", Values: []float64{1000}},
+		{Test: "TCP_RR", Scenario: "pod-net", Target: "cluster-ip", Pod: "client-c", SourceNode: "node-3", DestinationNode: "node-3", Metric: "OP/s", Values: []float64{700}},
+	}}
+
+	comparisons := Compare(oldReport, newReport, CompareParameters{Threshold: 5, Significance: 0.05})
+	require.Len(t, comparisons, 6)
+
+	byName := make(map[string]Comparison)
+	for _, c := range comparisons {
+		byName[c.Test+"/"+c.Scenario+"/"+c.Target+"/"+c.Placement] = c
+	}
+
+	c := byName["TCP_RR/pod-net/pod/same-node"]
+	assert.Equal(t, 3, c.Old.Samples)
+	assert.Equal(t, 6, c.New.Samples)
+	assert.InDelta(t, -20, c.Change, 0.01)
+	require.NotNil(t, c.PValue)
+	assert.Less(t, *c.PValue, 0.05)
+	assert.True(t, c.Regression)
+
+	// Cross-node results are compared separately from same-node ones, such
+	// that a different mix of both doesn't show up as a change.
+	c = byName["TCP_RR/pod-net/pod/cross-node"]
+	assert.InDelta(t, 0, c.Change, 0.01)
+	assert.False(t, c.Regression)
+
+	c = byName["TCP_STREAM/pod-net/pod/same-node"]
+	assert.False(t, c.Regression)
+
+	// Single samples are compared against the threshold only.
+	c = byName["UDP_RR/host-net//"]
+	assert.Nil(t, c.PValue)
+	assert.True(t, c.Regression)
+
+	c = byName["UDP_STREAM/pod-net/pod/same-node"]
+	assert.Nil(t, c.Old)
+	assert.False(t, c.Regression)
+
+	// Results against different targets are compared separately.
+	c = byName["TCP_RR/pod-net/cluster-ip/same-node"]
+	assert.Nil(t, c.Old)
+	assert.Equal(t, 700.0, c.New.Mean)
+
+	assert.Equal(t, 2, Regressions(comparisons))
+}
+
+func TestCompareLatency(t *testing.T) {
+	oldReport := &check.PerfReport{Results: []check.PerfReportResult{
+		{Test: "TCP_RR_LATENCY_P50", Scenario: "pod-net", Target: "pod", Pod: "client-a", SourceNode: "node-1", DestinationNode: "node-2", Metric: "us", Values: []float64{50, 51, 49}},
+		{Test: "TCP_RR_LATENCY_P99", Scenario: "pod-net", Target: "pod", Pod: "client-a", SourceNode: "node-1", DestinationNode: "node-2", Metric: "us", Values: []float64{200, 205, 195}},
+	}}
+	newReport := &check.PerfReport{Results: []check.PerfReportResult{
+		{Test: "TCP_RR_LATENCY_P50", Scenario: "pod-net", Target: "pod", Pod: "client-a", SourceNode: "node-1", DestinationNode: "node-2", Metric: "us", Values: []float64{60, 61, 59}},
+		{Test: "TCP_RR_LATENCY_P99", Scenario: "pod-net", Target: "pod", Pod: "client-a", SourceNode: "node-1", DestinationNode: "node-2", Metric: "us", Values: []float64{150, 155, 145}},
+	}}
+
+	comparisons := Compare(oldReport, newReport, CompareParameters{Threshold: 5, Significance: 0.05})
+	require.Len(t, comparisons, 2)
+
+	// Higher latencies are regressions, lower ones are improvements.
+	assert.Equal(t, "TCP_RR_LATENCY_P50", comparisons[0].Test)
+	assert.InDelta(t, 20, comparisons[0].Change, 0.01)
+	assert.True(t, comparisons[0].Regression)
+	assert.Equal(t, "TCP_RR_LATENCY_P99", comparisons[1].Test)
+	assert.InDelta(t, -25, comparisons[1].Change, 0.01)
+	assert.False(t, comparisons[1].Regression)
+
+	assert.Equal(t, 1, Regressions(comparisons))
+}
+
+func TestCompareFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, r *check.PerfReport) string {
+		data, err := json.Marshal(r)
+		require.NoError(t, err)
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		return path
+	}
+
+	oldPath := write("old.json", &check.PerfReport{CiliumVersion: "1.14.0", Results: []check.PerfReportResult{
+		{Test: "TCP_RR", Scenario: "pod-net", Metric: "OP/s", Values: []float64{1000, 1010}},
+	}})
+	newPath := write("new.json", &check.PerfReport{CiliumVersion: "1.15.0", Results: []check.PerfReportResult{
+		{Test: "TCP_RR", Scenario: "pod-net", Metric: "OP/s", Values: []float64{1005, 1000}},
+	}})
+
+	var out bytes.Buffer
+	require.NoError(t, CompareFiles(&out, oldPath, newPath, CompareParameters{Threshold: 5, Significance: 0.05}))
+	assert.Contains(t, out.String(), "Old: Cilium 1.14.0")
+	assert.Contains(t, out.String(), "TCP_RR")
+
+	out.Reset()
+	assert.Error(t, CompareFiles(&out, oldPath, newPath, CompareParameters{Output: "yaml"}))
+	assert.Error(t, CompareFiles(&out, oldPath, filepath.Join(dir, "missing.json"), CompareParameters{}))
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cilium/cilium/api/v1/flow"
+	"github.com/cilium/cilium/api/v1/observer"
+	monitorAPI "github.com/cilium/cilium/pkg/monitor/api"
+	hubprinter "github.com/cilium/hubble/pkg/printer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// reportFlows retrieves the flows of the source pod observed by Hubble while
+// the probe ran, prints those related to the destination and reports whether
+// and where the traffic was dropped.
+func (p *Prober) reportFlows(ctx context.Context, pod *corev1.Pod, dst *target, start, end time.Time) error {
+	// Give Hubble some time to observe the last flows of the probe.
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(p.params.FlowWait):
+	}
+
+	name := pod.Namespace + "/" + pod.Name
+	req := &observer.GetFlowsRequest{
+		Whitelist: []*flow.FlowFilter{
+			{SourcePod: []string{name}},
+			{DestinationPod: []string{name}},
+		},
+		Since: timestamppb.New(start.Add(-time.Second)),
+		Until: timestamppb.New(end.Add(p.params.FlowWait)),
+	}
+	b, err := p.observer.GetFlows(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	var flows []*flow.Flow
+	for {
+		res, err := b.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if f := res.GetFlow(); f != nil && dst.matches(f) {
+			flows = append(flows, f)
+		}
+	}
+
+	p.printFlows(flows)
+	p.logf("%s", verdict(flows))
+	return nil
+}
+
+// matches returns whether the flow relates to the destination. All flows are
+// considered related when the IPs of the destination are unknown.
+func (t *target) matches(f *flow.Flow) bool {
+	if t.service != nil {
+		for _, svc := range []*flow.Service{f.GetDestinationService(), f.GetSourceService()} {
+			if svc.GetName() == t.service.Name && svc.GetNamespace() == t.service.Namespace {
+				return true
+			}
+		}
+	}
+	if len(t.ips) == 0 {
+		return true
+	}
+	for _, ip := range t.ips {
+		if f.GetIP().GetSource() == ip || f.GetIP().GetDestination() == ip {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Prober) printFlows(flows []*flow.Flow) {
+	if len(flows) == 0 {
+		p.logf("📄 No related flows observed by Hubble")
+		return
+	}
+
+	p.logf("📄 Flows observed by Hubble:")
+	printer := hubprinter.New(hubprinter.Compact(), hubprinter.WithIPTranslation())
+	defer printer.Close()
+
+	for index, f := range flows {
+		src, dst := printer.GetHostNames(f)
+
+		ts := "N/A"
+		if t := f.GetTime(); t != nil && t.IsValid() {
+			ts = t.AsTime().Format(time.StampMilli)
+		}
+
+		//nolint:staticcheck // Summary is deprecated but there is no real alternative yet
+		//lint:ignore SA1019 Summary is deprecated but there is no real alternative yet
+		p.logf("   [%d] %s %s: %s -> %s %s %s %s %s (%s)", index, ts, f.GetNodeName(), src, dst, hubprinter.GetFlowType(f), f.Verdict.String(), f.TrafficDirection, f.DropReasonDesc, f.Summary)
+	}
+}
+
+// verdict summarizes the flows observed for the probe, reporting the first
+// drop if any, and the policy verdicts otherwise.
+func verdict(flows []*flow.Flow) string {
+	if len(flows) == 0 {
+		return "❓ Unable to tell whether the traffic was dropped without flows"
+	}
+
+	for _, f := range flows {
+		if f.GetVerdict() != flow.Verdict_DROPPED {
+			continue
+		}
+		return fmt.Sprintf("⛔ Traffic dropped on node %s (%s): %s%s", f.GetNodeName(),
+			directionString(f.GetTrafficDirection()), f.GetDropReasonDesc(), dropReasonHint(f.GetDropReasonDesc()))
+	}
+
+	for _, f := range flows {
+		if f.GetEventType().GetType() != monitorAPI.MessageTypePolicyVerdict {
+			continue
+		}
+		return fmt.Sprintf("✅ Traffic allowed by %s policy on node %s (%s match)", directionString(f.GetTrafficDirection()),
+			f.GetNodeName(), monitorAPI.PolicyMatchType(f.GetPolicyMatchType()))
+	}
+	return "✅ No drop observed"
+}
+
+func directionString(d flow.TrafficDirection) string {
+	switch d {
+	case flow.TrafficDirection_INGRESS:
+		return "ingress"
+	case flow.TrafficDirection_EGRESS:
+		return "egress"
+	}
+	return "unknown direction"
+}
+
+// dropReasonHint explains the policy related drop reasons.
+func dropReasonHint(reason flow.DropReason) string {
+	switch reason {
+	case flow.DropReason_POLICY_DENIED:
+		return ", no policy rule allows this traffic"
+	case flow.DropReason_POLICY_DENY:
+		return ", denied by a deny policy rule"
+	case flow.DropReason_AUTH_REQUIRED:
+		return ", mutual authentication is required by policy"
+	}
+	return ""
+}
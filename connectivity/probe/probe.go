@@ -0,0 +1,425 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package probe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cilium/cilium/api/v1/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	ProtocolHTTP = "http"
+	ProtocolTCP  = "tcp"
+	ProtocolUDP  = "udp"
+	ProtocolICMP = "icmp"
+
+	// dnsProbeName is the name queried by UDP probes.
+	dnsProbeName = "kubernetes.default.svc.cluster.local"
+
+	// ephemeralContainerLifetime bounds how long an injected ephemeral
+	// container keeps running, as ephemeral containers cannot be removed.
+	ephemeralContainerLifetime = time.Hour
+)
+
+// Protocols lists the protocols supported by the probe.
+var Protocols = []string{ProtocolHTTP, ProtocolTCP, ProtocolUDP, ProtocolICMP}
+
+type k8sProbeImplementation interface {
+	GetPod(ctx context.Context, namespace, name string, opts metav1.GetOptions) (*corev1.Pod, error)
+	GetService(ctx context.Context, namespace, name string, opts metav1.GetOptions) (*corev1.Service, error)
+	ExecInPodWithStderr(ctx context.Context, namespace, pod, container string, command []string) (bytes.Buffer, bytes.Buffer, error)
+	CreateEphemeralContainer(ctx context.Context, pod *corev1.Pod, ec *corev1.EphemeralContainer) (*corev1.Pod, error)
+}
+
+// Parameters of a reachability probe.
+type Parameters struct {
+	// From is the source pod, as [namespace/]pod.
+	From string
+	// To is the destination, as [namespace/]service[:port],
+	// [namespace/]pod[:port], ip[:port] or hostname[:port].
+	To string
+	// Protocol is one of Protocols.
+	Protocol string
+	// Container of the source pod to run the probe in. Defaults to the first
+	// container of the pod.
+	Container string
+	// Image of the ephemeral container injected when the source pod lacks
+	// the tools needed by the probe.
+	Image string
+	// Ephemeral forces the probe to run in an ephemeral container.
+	Ephemeral bool
+
+	ConnectTimeout time.Duration
+	RequestTimeout time.Duration
+
+	Hubble       bool
+	HubbleServer string
+	// FlowWait is how long to wait for the flows of the probe to be
+	// reported by Hubble.
+	FlowWait time.Duration
+
+	Writer io.Writer
+}
+
+// target is the resolved destination of a probe.
+type target struct {
+	// name is the destination as given by the user.
+	name string
+	// address is the IP or hostname the probe connects to.
+	address string
+	port    int
+	// service is set when the destination is a Service.
+	service *corev1.Service
+	// ips are the IPs of the destination, if known.
+	ips []string
+}
+
+// Prober runs reachability probes between arbitrary workloads.
+type Prober struct {
+	client   k8sProbeImplementation
+	observer observer.ObserverClient
+	params   Parameters
+}
+
+// NewProber returns a Prober.
+func NewProber(client k8sProbeImplementation, p Parameters) (*Prober, error) {
+	if p.Protocol == "" {
+		p.Protocol = ProtocolHTTP
+	}
+	if !isValidProtocol(p.Protocol) {
+		return nil, fmt.Errorf("unsupported protocol %q, must be one of: %s", p.Protocol, strings.Join(Protocols, ", "))
+	}
+	if p.From == "" || p.To == "" {
+		return nil, fmt.Errorf("both source and destination must be specified")
+	}
+	return &Prober{
+		client: client,
+		params: p,
+	}, nil
+}
+
+func isValidProtocol(protocol string) bool {
+	for _, p := range Protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// enableHubbleClient connects to Hubble Relay. Flows are not correlated with
+// the result of the probe if Relay is unavailable.
+func (p *Prober) enableHubbleClient(ctx context.Context) {
+	dialCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	c, err := grpc.DialContext(dialCtx, p.params.HubbleServer, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err == nil {
+		client := observer.NewObserverClient(c)
+		if _, err = client.ServerStatus(ctx, &observer.ServerStatusRequest{}); err == nil {
+			p.observer = client
+			return
+		}
+	}
+
+	p.logf("⚠️  Unable to contact Hubble Relay, flows will not be reported: %s", err)
+	p.logf(`ℹ️  Expose Relay locally with:
+   cilium hubble enable
+   cilium hubble port-forward&`)
+}
+
+func (p *Prober) logf(format string, a ...interface{}) {
+	fmt.Fprintf(p.params.Writer, format+"\n", a...)
+}
+
+// Run runs the probe and reports its result, along with the related flows.
+// It returns an error if the destination could not be reached.
+func (p *Prober) Run(ctx context.Context) error {
+	namespace, name := splitNamespacedName(p.params.From, corev1.NamespaceDefault)
+	pod, err := p.client.GetPod(ctx, namespace, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get source pod %s/%s: %w", namespace, name, err)
+	}
+
+	dst, err := p.resolveTarget(ctx, pod.Namespace, p.params.To)
+	if err != nil {
+		return err
+	}
+
+	cmd := p.command(dst)
+	container := p.params.Container
+	if container == "" {
+		container = pod.Spec.Containers[0].Name
+	}
+
+	p.logf("🔍 Probing %s (%s) from %s/%s over %s...", dst.name, dst.endpoint(p.params.Protocol), pod.Namespace, pod.Name, p.params.Protocol)
+
+	if p.params.Hubble {
+		p.enableHubbleClient(ctx)
+	}
+
+	start := time.Now()
+	var stdout, stderr bytes.Buffer
+	if !p.params.Ephemeral {
+		stdout, stderr, err = p.client.ExecInPodWithStderr(ctx, pod.Namespace, pod.Name, container, cmd)
+	}
+	if p.params.Ephemeral || isMissingExecutable(err, stderr.String()) {
+		if !p.params.Ephemeral {
+			p.logf("ℹ️  Container %s lacks %s, running the probe in an ephemeral container", container, cmd[0])
+		}
+		var ephemeral string
+		pod, ephemeral, err = p.ensureEphemeralContainer(ctx, pod, container)
+		if err != nil {
+			return err
+		}
+		start = time.Now()
+		stdout, stderr, err = p.client.ExecInPodWithStderr(ctx, pod.Namespace, pod.Name, ephemeral, cmd)
+	}
+	end := time.Now()
+
+	probeErr := err
+	if probeErr != nil {
+		p.logf("❌ %s failed: %s", strings.Join(cmd, " "), commandError(err, stderr.String()))
+	} else {
+		p.logf("✅ %s succeeded", cmd[0])
+		if out := strings.TrimSpace(stdout.String()); out != "" && p.params.Protocol == ProtocolHTTP {
+			p.logf("   %s", out)
+		}
+	}
+
+	if p.observer != nil {
+		if err := p.reportFlows(ctx, pod, dst, start, end); err != nil {
+			p.logf("⚠️  Unable to retrieve flows from Hubble: %s", err)
+		}
+	}
+
+	if probeErr != nil {
+		return fmt.Errorf("%s is not reachable from %s/%s", dst.name, pod.Namespace, pod.Name)
+	}
+	return nil
+}
+
+// resolveTarget resolves the destination of the probe. Names are looked up as
+// Services first and Pods second, in the namespace of the source pod unless
+// specified. Names which are neither are used as hostnames.
+func (p *Prober) resolveTarget(ctx context.Context, srcNamespace, to string) (*target, error) {
+	host, port, err := splitHostPort(to, p.defaultPort())
+	if err != nil {
+		return nil, err
+	}
+	if port == 0 && p.params.Protocol != ProtocolICMP {
+		return nil, fmt.Errorf("destination %q must include a port for protocol %s", to, p.params.Protocol)
+	}
+	dst := &target{name: to, address: host, port: port}
+
+	if ip := net.ParseIP(host); ip != nil {
+		dst.ips = []string{ip.String()}
+		return dst, nil
+	}
+
+	namespace, name := splitNamespacedName(host, srcNamespace)
+	explicit := strings.Contains(host, "/")
+	if !explicit && strings.Contains(name, ".") {
+		// Not a valid Service or Pod name, use as hostname.
+		return dst, nil
+	}
+
+	if svc, err := p.client.GetService(ctx, namespace, name, metav1.GetOptions{}); err == nil {
+		dst.service = svc
+		if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+			dst.address = fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace)
+		} else {
+			dst.address = svc.Spec.ClusterIP
+			dst.ips = svc.Spec.ClusterIPs
+		}
+		return dst, nil
+	}
+
+	if pod, err := p.client.GetPod(ctx, namespace, name, metav1.GetOptions{}); err == nil {
+		if pod.Status.PodIP == "" {
+			return nil, fmt.Errorf("destination pod %s/%s has no IP", namespace, name)
+		}
+		dst.address = pod.Status.PodIP
+		for _, ip := range pod.Status.PodIPs {
+			dst.ips = append(dst.ips, ip.IP)
+		}
+		return dst, nil
+	}
+
+	if explicit {
+		return nil, fmt.Errorf("no service or pod %s/%s found", namespace, name)
+	}
+	return dst, nil
+}
+
+func (p *Prober) defaultPort() int {
+	switch p.params.Protocol {
+	case ProtocolHTTP:
+		return 80
+	case ProtocolUDP:
+		return 53
+	}
+	return 0
+}
+
+// endpoint returns the address and, when relevant, port probed for protocol.
+func (t *target) endpoint(protocol string) string {
+	if protocol == ProtocolICMP {
+		return t.address
+	}
+	return net.JoinHostPort(t.address, strconv.Itoa(t.port))
+}
+
+// command returns the command probing the destination, mimicking the curl,
+// ping and dig commands used by the connectivity tests.
+func (p *Prober) command(dst *target) []string {
+	connectTimeout := p.params.ConnectTimeout.Seconds()
+	requestTimeout := p.params.RequestTimeout.Seconds()
+	port := strconv.Itoa(dst.port)
+
+	switch p.params.Protocol {
+	case ProtocolTCP:
+		cmd := []string{"nc", "-z"}
+		if connectTimeout > 0.0 {
+			cmd = append(cmd, "-w", seconds(connectTimeout))
+		}
+		return append(cmd, dst.address, port)
+
+	case ProtocolUDP:
+		cmd := []string{"dig", "+tries=1"}
+		if requestTimeout > 0.0 {
+			cmd = append(cmd, "+time="+seconds(requestTimeout))
+		}
+		return append(cmd, "-p", port, "@"+dst.address, dnsProbeName)
+
+	case ProtocolICMP:
+		cmd := []string{"ping", "-c", "1"}
+		if strings.Contains(dst.address, ":") {
+			cmd = append(cmd, "-6")
+		}
+		if connectTimeout > 0.0 {
+			cmd = append(cmd, "-W", seconds(connectTimeout))
+		}
+		return append(cmd, dst.address)
+	}
+
+	cmd := []string{"curl",
+		"-w", "%{local_ip}:%{local_port} -> %{remote_ip}:%{remote_port} = %{response_code}",
+		"--silent", "--fail", "--show-error",
+		"--output", "/dev/null",
+	}
+	if connectTimeout > 0.0 {
+		cmd = append(cmd, "--connect-timeout", strconv.FormatFloat(connectTimeout, 'f', -1, 64))
+	}
+	if requestTimeout > 0.0 {
+		cmd = append(cmd, "--max-time", strconv.FormatFloat(requestTimeout, 'f', -1, 64))
+	}
+	return append(cmd, fmt.Sprintf("http://%s/", net.JoinHostPort(dst.address, port)))
+}
+
+// seconds formats a duration in seconds as an integer, as expected by nc, dig
+// and ping.
+func seconds(s float64) string {
+	return strconv.Itoa(int(math.Ceil(s)))
+}
+
+// ensureEphemeralContainer injects an ephemeral container running the probe
+// image in the pod, targeting the given container, and waits for it to run.
+func (p *Prober) ensureEphemeralContainer(ctx context.Context, pod *corev1.Pod, container string) (*corev1.Pod, string, error) {
+	ec := &corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:    fmt.Sprintf("cilium-probe-%d", time.Now().Unix()),
+			Image:   p.params.Image,
+			Command: []string{"sleep", strconv.Itoa(int(ephemeralContainerLifetime.Seconds()))},
+		},
+		TargetContainerName: container,
+	}
+
+	namespace, name := pod.Namespace, pod.Name
+	pod, err := p.client.CreateEphemeralContainer(ctx, pod, ec)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to create ephemeral container in pod %s/%s: %w", namespace, name, err)
+	}
+
+	err = wait.PollUntilContextTimeout(ctx, time.Second, time.Minute, true, func(ctx context.Context) (bool, error) {
+		var err error
+		pod, err = p.client.GetPod(ctx, namespace, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, status := range pod.Status.EphemeralContainerStatuses {
+			if status.Name == ec.Name {
+				return status.State.Running != nil, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("ephemeral container %s in pod %s/%s never reached running state: %w", ec.Name, namespace, name, err)
+	}
+	return pod, ec.Name, nil
+}
+
+// isMissingExecutable returns whether the probe command failed because the
+// executable is not available in the container.
+func isMissingExecutable(err error, stderr string) bool {
+	if err == nil {
+		return false
+	}
+	for _, msg := range []string{err.Error(), stderr} {
+		if strings.Contains(msg, "executable file not found") ||
+			strings.Contains(msg, "no such file or directory") {
+			return true
+		}
+	}
+	return false
+}
+
+func commandError(err error, stderr string) string {
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		return stderr
+	}
+	return err.Error()
+}
+
+// splitNamespacedName splits namespace/name, using the given default
+// namespace if unspecified.
+func splitNamespacedName(s, defaultNamespace string) (string, string) {
+	if namespace, name, ok := strings.Cut(s, "/"); ok {
+		return namespace, name
+	}
+	return defaultNamespace, s
+}
+
+// splitHostPort splits host[:port], using the given default port if
+// unspecified. Bare IPv6 addresses are accepted as hosts.
+func splitHostPort(s string, defaultPort int) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		host = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+		if strings.Count(s, ":") == 1 || (strings.Contains(s, "[") && net.ParseIP(host) == nil) {
+			return "", 0, fmt.Errorf("invalid destination %q: %w", s, err)
+		}
+		return host, defaultPort, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > math.MaxUint16 {
+		return "", 0, fmt.Errorf("invalid port in destination %q", s)
+	}
+	return host, port, nil
+}
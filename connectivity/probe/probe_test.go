@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package probe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cilium/cilium/api/v1/flow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeClient struct {
+	pods     map[string]*corev1.Pod
+	services map[string]*corev1.Service
+	// tools are the executables available in the pod containers.
+	tools []string
+
+	execs []string
+}
+
+func (c *fakeClient) GetPod(_ context.Context, namespace, name string, _ metav1.GetOptions) (*corev1.Pod, error) {
+	if pod, ok := c.pods[namespace+"/"+name]; ok {
+		return pod, nil
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "pods"}, name)
+}
+
+func (c *fakeClient) GetService(_ context.Context, namespace, name string, _ metav1.GetOptions) (*corev1.Service, error) {
+	if svc, ok := c.services[namespace+"/"+name]; ok {
+		return svc, nil
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "services"}, name)
+}
+
+func (c *fakeClient) ExecInPodWithStderr(_ context.Context, _, _, container string, command []string) (bytes.Buffer, bytes.Buffer, error) {
+	c.execs = append(c.execs, container)
+	for _, tool := range c.tools {
+		if tool == command[0] {
+			return *bytes.NewBufferString("ok"), bytes.Buffer{}, nil
+		}
+	}
+	return bytes.Buffer{}, bytes.Buffer{}, errors.New(`exec: "` + command[0] + `": executable file not found in $PATH`)
+}
+
+func (c *fakeClient) CreateEphemeralContainer(_ context.Context, pod *corev1.Pod, ec *corev1.EphemeralContainer) (*corev1.Pod, error) {
+	pod = pod.DeepCopy()
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, *ec)
+	pod.Status.EphemeralContainerStatuses = append(pod.Status.EphemeralContainerStatuses, corev1.ContainerStatus{
+		Name:  ec.Name,
+		State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+	})
+	c.pods[pod.Namespace+"/"+pod.Name] = pod
+	c.tools = append(c.tools, "curl", "nc", "dig", "ping")
+	return pod, nil
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		pods: map[string]*corev1.Pod{
+			"default/client": {
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "client"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			},
+			"backend/db-0": {
+				ObjectMeta: metav1.ObjectMeta{Namespace: "backend", Name: "db-0"},
+				Status: corev1.PodStatus{
+					PodIP:  "10.0.1.5",
+					PodIPs: []corev1.PodIP{{IP: "10.0.1.5"}, {IP: "fd00::5"}},
+				},
+			},
+		},
+		services: map[string]*corev1.Service{
+			"default/echo": {
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "echo"},
+				Spec:       corev1.ServiceSpec{ClusterIP: "10.96.0.10", ClusterIPs: []string{"10.96.0.10"}},
+			},
+			"backend/headless": {
+				ObjectMeta: metav1.ObjectMeta{Namespace: "backend", Name: "headless"},
+				Spec:       corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone},
+			},
+		},
+	}
+}
+
+func TestSplitHostPort(t *testing.T) {
+	tests := []struct {
+		in      string
+		host    string
+		port    int
+		wantErr bool
+	}{
+		{in: "echo:8080", host: "echo", port: 8080},
+		{in: "echo", host: "echo", port: 80},
+		{in: "ns/echo:443", host: "ns/echo", port: 443},
+		{in: "10.0.0.1:53", host: "10.0.0.1", port: 53},
+		{in: "[fd00::1]:53", host: "fd00::1", port: 53},
+		{in: "fd00::1", host: "fd00::1", port: 80},
+		{in: "echo:http", wantErr: true},
+		{in: "echo:70000", wantErr: true},
+	}
+	for _, tt := range tests {
+		host, port, err := splitHostPort(tt.in, 80)
+		if tt.wantErr {
+			assert.Error(t, err, tt.in)
+			continue
+		}
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.host, host, tt.in)
+		assert.Equal(t, tt.port, port, tt.in)
+	}
+}
+
+func TestResolveTarget(t *testing.T) {
+	p, err := NewProber(newFakeClient(), Parameters{From: "client", To: "echo", Protocol: ProtocolTCP})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	dst, err := p.resolveTarget(ctx, "default", "echo:8080")
+	require.NoError(t, err)
+	assert.Equal(t, "10.96.0.10", dst.address)
+	assert.Equal(t, 8080, dst.port)
+	assert.NotNil(t, dst.service)
+
+	dst, err = p.resolveTarget(ctx, "default", "backend/db-0:5432")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.1.5", dst.address)
+	assert.Equal(t, []string{"10.0.1.5", "fd00::5"}, dst.ips)
+
+	dst, err = p.resolveTarget(ctx, "default", "backend/headless:80")
+	require.NoError(t, err)
+	assert.Equal(t, "headless.backend.svc.cluster.local", dst.address)
+
+	dst, err = p.resolveTarget(ctx, "default", "one.one.one.one:443")
+	require.NoError(t, err)
+	assert.Equal(t, "one.one.one.one", dst.address)
+	assert.Empty(t, dst.ips)
+
+	_, err = p.resolveTarget(ctx, "default", "backend/missing:80")
+	assert.Error(t, err)
+
+	_, err = p.resolveTarget(ctx, "default", "echo")
+	assert.Error(t, err, "TCP probes require a port")
+}
+
+func TestCommand(t *testing.T) {
+	params := Parameters{From: "client", To: "echo", ConnectTimeout: 2 * time.Second, RequestTimeout: 1500 * time.Millisecond}
+	dst := &target{address: "10.96.0.10", port: 8080}
+
+	for protocol, want := range map[string][]string{
+		ProtocolHTTP: {"curl",
+			"-w", "%{local_ip}:%{local_port} -> %{remote_ip}:%{remote_port} = %{response_code}",
+			"--silent", "--fail", "--show-error", "--output", "/dev/null",
+			"--connect-timeout", "2", "--max-time", "1.5", "http://10.96.0.10:8080/"},
+		ProtocolTCP:  {"nc", "-z", "-w", "2", "10.96.0.10", "8080"},
+		ProtocolUDP:  {"dig", "+tries=1", "+time=2", "-p", "8080", "@10.96.0.10", dnsProbeName},
+		ProtocolICMP: {"ping", "-c", "1", "-W", "2", "10.96.0.10"},
+	} {
+		params.Protocol = protocol
+		p, err := NewProber(newFakeClient(), params)
+		require.NoError(t, err)
+		assert.Equal(t, want, p.command(dst), protocol)
+	}
+
+	_, err := NewProber(newFakeClient(), Parameters{From: "client", To: "echo", Protocol: "sctp"})
+	assert.Error(t, err)
+}
+
+func TestRunEphemeralFallback(t *testing.T) {
+	client := newFakeClient()
+	var out bytes.Buffer
+	p, err := NewProber(client, Parameters{From: "default/client", To: "echo:8080", Writer: &out})
+	require.NoError(t, err)
+
+	require.NoError(t, p.Run(context.Background()))
+	require.Len(t, client.execs, 2)
+	assert.Equal(t, "app", client.execs[0])
+	assert.Contains(t, client.execs[1], "cilium-probe-")
+	assert.Contains(t, out.String(), "ephemeral container")
+}
+
+func TestVerdict(t *testing.T) {
+	assert.Contains(t, verdict(nil), "Unable to tell")
+
+	dropped := []*flow.Flow{
+		{Verdict: flow.Verdict_FORWARDED, NodeName: "kind-worker", TrafficDirection: flow.TrafficDirection_EGRESS},
+		{
+			Verdict:          flow.Verdict_DROPPED,
+			NodeName:         "kind-worker2",
+			TrafficDirection: flow.TrafficDirection_INGRESS,
+			DropReasonDesc:   flow.DropReason_POLICY_DENIED,
+		},
+	}
+	assert.Equal(t, "⛔ Traffic dropped on node kind-worker2 (ingress): POLICY_DENIED, no policy rule allows this traffic", verdict(dropped))
+
+	allowed := []*flow.Flow{
+		{
+			Verdict:          flow.Verdict_FORWARDED,
+			NodeName:         "kind-worker",
+			TrafficDirection: flow.TrafficDirection_EGRESS,
+			EventType:        &flow.CiliumEventType{Type: 5},
+			PolicyMatchType:  2,
+		},
+	}
+	assert.Equal(t, "✅ Traffic allowed by egress policy on node kind-worker (L3-L4 match)", verdict(allowed))
+}
+
+func TestTargetMatches(t *testing.T) {
+	dst := &target{ips: []string{"10.0.1.5"}}
+	assert.True(t, dst.matches(&flow.Flow{IP: &flow.IP{Source: "10.0.0.2", Destination: "10.0.1.5"}}))
+	assert.True(t, dst.matches(&flow.Flow{IP: &flow.IP{Source: "10.0.1.5", Destination: "10.0.0.2"}}))
+	assert.False(t, dst.matches(&flow.Flow{IP: &flow.IP{Source: "10.0.0.2", Destination: "10.0.1.6"}}))
+
+	dst = &target{
+		ips:     []string{"10.96.0.10"},
+		service: &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "echo"}},
+	}
+	assert.True(t, dst.matches(&flow.Flow{
+		IP:                 &flow.IP{Source: "10.0.0.2", Destination: "10.0.1.7"},
+		DestinationService: &flow.Service{Namespace: "default", Name: "echo"},
+	}))
+
+	assert.True(t, (&target{}).matches(&flow.Flow{}))
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -46,6 +47,15 @@ var (
 	//go:embed manifests/allow-host-entity.yaml
 	allowHostEntityPolicyYAML string
 
+	//go:embed manifests/host-firewall-ingress.yaml
+	hostFirewallIngressPolicyYAML string
+
+	//go:embed manifests/host-firewall-ingress-port.yaml
+	hostFirewallIngressPortPolicyYAML string
+
+	//go:embed manifests/host-firewall-egress.yaml
+	hostFirewallEgressPolicyYAML string
+
 	//go:embed manifests/allow-all-except-world.yaml
 	allowAllExceptWorldPolicyYAML string
 
@@ -106,6 +116,24 @@ var (
 	//go:embed manifests/echo-ingress-from-other-client-deny.yaml
 	echoIngressFromOtherClientDenyPolicyYAML string
 
+	//go:embed manifests/echo-ingress-from-secondary-namespace.yaml
+	echoIngressFromSecondaryNamespacePolicyYAML string
+
+	//go:embed manifests/echo-ingress-from-secondary-namespace-knp.yaml
+	echoIngressFromSecondaryNamespacePolicyKNPYAML string
+
+	//go:embed manifests/echo-ingress-from-secondary-namespace-deny.yaml
+	echoIngressFromSecondaryNamespaceDenyPolicyYAML string
+
+	//go:embed manifests/echo-ingress-clusterwide-from-test-namespace.yaml
+	echoIngressClusterwideFromTestNamespacePolicyYAML string
+
+	//go:embed manifests/client-egress-to-secondary-namespace.yaml
+	clientEgressToSecondaryNamespacePolicyYAML string
+
+	//go:embed manifests/client-egress-to-secondary-namespace-knp.yaml
+	clientEgressToSecondaryNamespacePolicyKNPYAML string
+
 	//go:embed manifests/client-egress-to-entities-world.yaml
 	clientEgressToEntitiesWorldPolicyYAML string
 
@@ -171,6 +199,16 @@ var (
 
 func Run(ctx context.Context, ct *check.ConnectivityTest, addExtraTests func(*check.ConnectivityTest) error,
 	addExtraSetup func(context.Context, *check.ConnectivityTest) error) error {
+	// Load the declarative test suite, if any, before deploying anything so
+	// that errors in the suite file are reported early.
+	var suite *suiteFile
+	if ct.Params().SuiteFile != "" {
+		var err error
+		if suite, err = loadSuiteFile(ct.Params().SuiteFile); err != nil {
+			return err
+		}
+	}
+
 	if err := ct.SetupAndValidate(ctx, addExtraSetup); err != nil {
 		return err
 	}
@@ -200,9 +238,19 @@ func Run(ctx context.Context, ct *check.ConnectivityTest, addExtraTests func(*ch
 
 	// Network Performance Test
 	if ct.Params().Perf {
-		ct.NewTest("network-perf").WithScenarios(
-			tests.NetperfPodtoPod(""),
-		)
+		perfScenarios := []check.Scenario{tests.NetperfPodtoPod("")}
+		if ct.Params().PerfNodeMatrix {
+			perfScenarios = append(perfScenarios, tests.NetperfNodeMatrix())
+		}
+		ct.NewTest("network-perf").WithScenarios(perfScenarios...)
+		return ct.Run(ctx)
+	}
+
+	// Declarative test suite loaded from file
+	if suite != nil {
+		if err := addSuiteFileTests(ct, suite); err != nil {
+			return err
+		}
 		return ct.Run(ctx)
 	}
 
@@ -261,6 +309,28 @@ func Run(ctx context.Context, ct *check.ConnectivityTest, addExtraTests func(*ch
 			tests.PodToLocalNodePort(),
 		)
 
+	// Check the session affinity and graceful termination of the services
+	// load-balanced by the kube-proxy replacement.
+	ct.NewTest("service-session-affinity").WithScenarios(tests.PodToServiceSessionAffinity())
+	// The graceful termination test deletes an echo-backends pod, which would
+	// disrupt the other tests using the echo-backends services.
+	ct.NewTest("service-graceful-termination").WithExclusive().WithScenarios(tests.PodToServiceGracefulTermination())
+
+	// Check the services exposed on external IPs, and the translation of
+	// services at connect time by socket-LB.
+	ct.NewTest("external-ip-service").
+		WithScenarios(
+			tests.PodToExternalIPService(),
+			tests.HostToExternalIPService(),
+		)
+	ct.NewTest("external-ip-service-from-outside").
+		WithScenarios(tests.OutsideToExternalIPService())
+	ct.NewTest("socket-lb").
+		WithScenarios(
+			tests.PodToServiceSocketLB(),
+			tests.PodToServiceSocketLBBypass(),
+		)
+
 	// Test with an allow-all-except-world (and unmanaged) policy.
 	ct.NewTest("allow-all-except-world").WithCiliumPolicy(allowAllExceptWorldPolicyYAML).
 		WithScenarios(
@@ -421,6 +491,51 @@ func Run(ctx context.Context, ct *check.ConnectivityTest, addExtraTests func(*ch
 			return check.ResultOK, check.ResultNone
 		})
 
+	// This host policy denies all ingress traffic from the client pods to the
+	// nodes.
+	ct.NewTest("host-firewall-ingress").
+		WithFeatureRequirements(check.RequireFeatureEnabled(check.FeatureHostFirewall)).
+		WithCiliumClusterwidePolicy(hostFirewallIngressPolicyYAML).
+		WithScenarios(
+			tests.PodToHost(),
+		).
+		WithExpectations(func(a *check.Action) (egress, ingress check.Result) {
+			if a.Source().HasLabel("name", "client") {
+				return check.ResultOK, check.ResultPolicyDenyIngressDrop
+			}
+			return check.ResultOK, check.ResultOK
+		})
+
+	// This host policy denies ingress traffic from the client pods to the
+	// Cilium health port of the nodes, while the other pods can still reach it.
+	ct.NewTest("host-firewall-ingress-port").
+		WithFeatureRequirements(check.RequireFeatureEnabled(check.FeatureHostFirewall)).
+		WithCiliumClusterwidePolicy(hostFirewallIngressPortPolicyYAML).
+		WithScenarios(
+			tests.PodToHostHealthPort(),
+		).
+		WithExpectations(func(a *check.Action) (egress, ingress check.Result) {
+			if a.Source().HasLabel("name", "client") {
+				return check.ResultDropCurlTimeout, check.ResultPolicyDenyIngressDrop
+			}
+			return check.ResultOK, check.ResultOK
+		})
+
+	// This host policy denies egress traffic from the nodes to the
+	// echo-other-node pods.
+	ct.NewTest("host-firewall-egress").
+		WithFeatureRequirements(check.RequireFeatureEnabled(check.FeatureHostFirewall)).
+		WithCiliumClusterwidePolicy(hostFirewallEgressPolicyYAML).
+		WithScenarios(
+			tests.HostToPod(),
+		).
+		WithExpectations(func(a *check.Action) (egress, ingress check.Result) {
+			if a.Destination().HasLabel("name", "echo-other-node") {
+				return check.ResultPolicyDenyEgressDrop, check.ResultNone
+			}
+			return check.ResultOK, check.ResultOK
+		})
+
 	// This policy allows ingress to echo only from client with a label 'other:client'.
 	echoIngressScenarios := []check.Scenario{tests.PodToPod()}
 	ct.NewTest("echo-ingress").WithCiliumPolicy(echoIngressFromOtherClientPolicyYAML).
@@ -695,6 +810,88 @@ func Run(ctx context.Context, ct *check.ConnectivityTest, addExtraTests func(*ch
 			return check.ResultOK, check.ResultOK
 		})
 
+	// Cross-namespace tests, with echo and client pods in a secondary test
+	// namespace. Client pods of each namespace contact the echo pods of the
+	// other namespace, and those of the test namespace their own echo pods.
+	if secondaryNS := ct.Params().SecondaryTestNamespace; secondaryNS != "" {
+		inSecondaryNS := func(p check.TestPeer) bool {
+			return strings.HasPrefix(p.Name(), secondaryNS+"/")
+		}
+		crossNamespaceScenarios := []check.Scenario{
+			tests.PodToPod(),
+			tests.PodToPodCrossNamespace(),
+		}
+
+		// This policy allows ingress to echo only from clients of the
+		// secondary test namespace.
+		ct.NewTest("echo-ingress-from-secondary-namespace").WithCiliumPolicy(echoIngressFromSecondaryNamespacePolicyYAML).
+			WithScenarios(crossNamespaceScenarios...).
+			WithExpectations(func(a *check.Action) (egress, ingress check.Result) {
+				if !inSecondaryNS(a.Destination()) && !inSecondaryNS(a.Source()) {
+					return check.ResultDropCurlTimeout, check.ResultDropCurlTimeout
+				}
+				return check.ResultOK, check.ResultOK
+			})
+
+		// This k8s policy allows ingress to echo only from clients of the
+		// secondary test namespace, selected by namespaceSelector.
+		ct.NewTest("echo-ingress-from-secondary-namespace-knp").WithK8SPolicy(echoIngressFromSecondaryNamespacePolicyKNPYAML).
+			WithScenarios(crossNamespaceScenarios...).
+			WithExpectations(func(a *check.Action) (egress, ingress check.Result) {
+				if !inSecondaryNS(a.Destination()) && !inSecondaryNS(a.Source()) {
+					return check.ResultDropCurlTimeout, check.ResultDropCurlTimeout
+				}
+				return check.ResultOK, check.ResultOK
+			})
+
+		// This policy denies ingress to echo from the pods of the secondary
+		// test namespace, selected by namespace label.
+		ct.NewTest("echo-ingress-from-secondary-namespace-deny").
+			WithCiliumPolicy(allowAllEgressPolicyYAML).                        // Allow all egress traffic
+			WithCiliumPolicy(allowAllIngressPolicyYAML).                       // Allow all ingress traffic
+			WithCiliumPolicy(echoIngressFromSecondaryNamespaceDenyPolicyYAML). // Deny secondary namespace contact echo
+			WithScenarios(crossNamespaceScenarios...).
+			WithExpectations(func(a *check.Action) (egress, ingress check.Result) {
+				if inSecondaryNS(a.Source()) {
+					return check.ResultDrop, check.ResultPolicyDenyIngressDrop
+				}
+				return check.ResultOK, check.ResultOK
+			})
+
+		// This policy allows egress from client only to echo of the secondary
+		// test namespace.
+		ct.NewTest("client-egress-to-secondary-namespace").WithCiliumPolicy(clientEgressToSecondaryNamespacePolicyYAML).
+			WithScenarios(crossNamespaceScenarios...).
+			WithExpectations(func(a *check.Action) (egress, ingress check.Result) {
+				if !inSecondaryNS(a.Source()) && !inSecondaryNS(a.Destination()) {
+					return check.ResultDropCurlTimeout, check.ResultNone
+				}
+				return check.ResultOK, check.ResultOK
+			})
+
+		// This k8s policy allows egress from client only to echo of the
+		// secondary test namespace, selected by namespaceSelector.
+		ct.NewTest("client-egress-to-secondary-namespace-knp").WithK8SPolicy(clientEgressToSecondaryNamespacePolicyKNPYAML).
+			WithScenarios(crossNamespaceScenarios...).
+			WithExpectations(func(a *check.Action) (egress, ingress check.Result) {
+				if !inSecondaryNS(a.Source()) && !inSecondaryNS(a.Destination()) {
+					return check.ResultDropCurlTimeout, check.ResultNone
+				}
+				return check.ResultOK, check.ResultOK
+			})
+
+		// This clusterwide policy allows ingress to echo of both test
+		// namespaces only from clients of the test namespace.
+		ct.NewTest("echo-ingress-clusterwide-from-test-namespace").WithCiliumClusterwidePolicy(echoIngressClusterwideFromTestNamespacePolicyYAML).
+			WithScenarios(crossNamespaceScenarios...).
+			WithExpectations(func(a *check.Action) (egress, ingress check.Result) {
+				if inSecondaryNS(a.Source()) {
+					return check.ResultDropCurlTimeout, check.ResultDropCurlTimeout
+				}
+				return check.ResultOK, check.ResultOK
+			})
+	}
+
 	// This policy denies L3 traffic to ExternalCIDR except ExternalIP/32
 	ct.NewTest("client-egress-to-cidr-deny").
 		WithCiliumPolicy(allowAllEgressPolicyYAML). // Allow all egress traffic
@@ -741,11 +938,13 @@ func Run(ctx context.Context, ct *check.ConnectivityTest, addExtraTests func(*ch
 		)
 
 	ct.NewTest("pod-to-pod-encryption").
+		WithExclusive().
 		WithFeatureRequirements(check.RequireFeatureEnabled(check.FeatureEncryptionPod)).
 		WithScenarios(
 			tests.PodToPodEncryption(),
 		)
 	ct.NewTest("node-to-node-encryption").
+		WithExclusive().
 		WithFeatureRequirements(check.RequireFeatureEnabled(check.FeatureEncryptionPod),
 			check.RequireFeatureEnabled(check.FeatureEncryptionNode)).
 		WithScenarios(
@@ -761,6 +960,15 @@ func Run(ctx context.Context, ct *check.ConnectivityTest, addExtraTests func(*ch
 			WithScenarios(
 				tests.EgressGateway(),
 			)
+
+		ct.NewTest("bgp-control-plane").
+			WithBGPPeeringPolicy().
+			WithFeatureRequirements(check.RequireFeatureEnabled(check.FeatureBGPControlPlane),
+				check.RequireFeatureEnabled(check.FeatureNodeWithoutCilium),
+				check.RequireFeatureEnabled(check.FeatureIPv4)).
+			WithScenarios(
+				tests.BGPAdvertisements(),
+			)
 	}
 
 	if versioncheck.MustCompile(">=1.14.0")(ct.CiliumVersion) {
@@ -1029,17 +1237,18 @@ func Run(ctx context.Context, ct *check.ConnectivityTest, addExtraTests func(*ch
 		WithFeatureRequirements(check.RequireFeatureEnabled(check.FeatureL7Proxy)).
 		WithScenarios(
 			tests.PodToWorld(tests.WithRetryDestPort(80)),
-			tests.PodToWorld2(), // resolves cilium.io
+			tests.PodToWorld2(), // resolves ExternalOtherTarget, cilium.io by default
 		).
 		WithExpectations(func(a *check.Action) (egress, ingress check.Result) {
-			if a.Destination().Address(check.IPFamilyAny) == "cilium.io" {
+			extOtherTarget := ct.Params().ExternalOtherTarget
+			if a.Destination().Address(check.IPFamilyAny) == extOtherTarget {
 				if a.Destination().Path() == "/" || a.Destination().Path() == "" {
 					egress = check.ResultDNSOK
 					egress.HTTP = check.HTTP{
 						Method: "GET",
-						URL:    "https://cilium.io",
+						URL:    "https://" + extOtherTarget,
 					}
-					// Expect packets for cilium.io / 104.198.14.52 to be dropped.
+					// Expect packets for ExternalOtherTarget to be dropped.
 					return check.ResultDropCurlTimeout, check.ResultNone
 				}
 				// Else expect HTTP drop by proxy
@@ -1071,7 +1280,7 @@ func Run(ctx context.Context, ct *check.ConnectivityTest, addExtraTests func(*ch
 	}
 
 	if ct.Params().IncludeUnsafeTests {
-		ct.NewTest("check-log-errors").WithScenarios(tests.NoErrorsInLogs(ct.CiliumVersion))
+		ct.NewTest("check-log-errors").WithExclusive().WithScenarios(tests.NoErrorsInLogs(ct.CiliumVersion))
 	}
 
 	return ct.Run(ctx)
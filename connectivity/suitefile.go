@@ -0,0 +1,388 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package connectivity
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/cilium/cilium-cli/connectivity/check"
+	"github.com/cilium/cilium-cli/connectivity/manifests/template"
+	"github.com/cilium/cilium-cli/connectivity/tests"
+)
+
+// suiteFile is a declarative connectivity test suite, as loaded from the file
+// passed via --suite-file.
+type suiteFile struct {
+	Tests []suiteTest `json:"tests"`
+}
+
+// suiteTest declares a single connectivity test.
+type suiteTest struct {
+	// Name of the test, must be unique within the suite.
+	Name string `json:"name"`
+
+	// CiliumVersion optionally limits the test to a Cilium version range,
+	// e.g. ">=1.14.0".
+	CiliumVersion string `json:"ciliumVersion,omitempty"`
+
+	// Requirements lists the features which need to match for the test to run.
+	Requirements []suiteRequirement `json:"requirements,omitempty"`
+
+	// CiliumPolicies and K8sPolicies are applied for the duration of the test.
+	CiliumPolicies []suitePolicy `json:"ciliumPolicies,omitempty"`
+	K8sPolicies    []suitePolicy `json:"k8sPolicies,omitempty"`
+
+	// Scenarios to run as part of the test.
+	Scenarios []suiteScenario `json:"scenarios"`
+
+	// Expectations are evaluated in order for every action, the first
+	// matching one determines the expected results. Actions not matching
+	// any expectation are expected to succeed.
+	Expectations []suiteExpectation `json:"expectations,omitempty"`
+}
+
+// suiteRequirement is the declarative form of a check.FeatureRequirement.
+// Exactly one of Enabled and Mode must be set.
+type suiteRequirement struct {
+	Feature string `json:"feature"`
+	Enabled *bool  `json:"enabled,omitempty"`
+	Mode    string `json:"mode,omitempty"`
+}
+
+// suitePolicy references a policy manifest, either as a file path (relative
+// to the suite file) or inline. The manifest is rendered as a template with
+// the connectivity test parameters, as the built-in manifests are.
+type suitePolicy struct {
+	File   string `json:"file,omitempty"`
+	Inline string `json:"inline,omitempty"`
+}
+
+// suiteScenario references a built-in scenario by name.
+type suiteScenario struct {
+	Name              string            `json:"name"`
+	SourceLabels      map[string]string `json:"sourceLabels,omitempty"`
+	DestinationLabels map[string]string `json:"destinationLabels,omitempty"`
+	Method            string            `json:"method,omitempty"`
+	Path              string            `json:"path,omitempty"`
+}
+
+// suiteExpectation maps the actions matching the given source and
+// destination labels (and optionally destination port) to the expected
+// egress and ingress results.
+type suiteExpectation struct {
+	Source      map[string]string `json:"source,omitempty"`
+	Destination map[string]string `json:"destination,omitempty"`
+	Port        uint32            `json:"port,omitempty"`
+	Egress      string            `json:"egress"`
+	Ingress     string            `json:"ingress"`
+}
+
+// suiteResults maps the result names usable in suite files to the
+// corresponding check.Result.
+var suiteResults = map[string]check.Result{
+	"ok":                          check.ResultOK,
+	"none":                        check.ResultNone,
+	"dns-ok":                      check.ResultDNSOK,
+	"dns-ok-drop-curl-timeout":    check.ResultDNSOKDropCurlTimeout,
+	"dns-ok-drop-curl-http-error": check.ResultDNSOKDropCurlHTTPError,
+	"curl-http-error":             check.ResultCurlHTTPError,
+	"drop":                        check.ResultDrop,
+	"drop-auth-required":          check.ResultDropAuthRequired,
+	"any-reason-egress-drop":      check.ResultAnyReasonEgressDrop,
+	"policy-deny-egress-drop":     check.ResultPolicyDenyEgressDrop,
+	"default-deny-egress-drop":    check.ResultDefaultDenyEgressDrop,
+	"any-reason-ingress-drop":     check.ResultIngressAnyReasonDrop,
+	"policy-deny-ingress-drop":    check.ResultPolicyDenyIngressDrop,
+	"default-deny-ingress-drop":   check.ResultDefaultDenyIngressDrop,
+	"drop-curl-timeout":           check.ResultDropCurlTimeout,
+	"drop-curl-http-error":        check.ResultDropCurlHTTPError,
+}
+
+// suiteScenarioFunc builds a scenario from its declarative form.
+type suiteScenarioFunc func(s suiteScenario) check.Scenario
+
+// withLabelOptions wraps scenarios accepting label options.
+func withLabelOptions(f func(opts ...tests.Option) check.Scenario) suiteScenarioFunc {
+	return func(s suiteScenario) check.Scenario {
+		var opts []tests.Option
+		if s.SourceLabels != nil {
+			opts = append(opts, tests.WithSourceLabelsOption(s.SourceLabels))
+		}
+		if s.DestinationLabels != nil {
+			opts = append(opts, tests.WithDestinationLabelsOption(s.DestinationLabels))
+		}
+		if s.Method != "" {
+			opts = append(opts, tests.WithMethod(s.Method))
+		}
+		if s.Path != "" {
+			opts = append(opts, tests.WithPath(s.Path))
+		}
+		return f(opts...)
+	}
+}
+
+// withoutOptions wraps scenarios which do not accept any options.
+func withoutOptions(f func() check.Scenario) suiteScenarioFunc {
+	return func(suiteScenario) check.Scenario {
+		return f()
+	}
+}
+
+// suiteScenarios lists the scenarios which can be referenced from suite
+// files, keyed by scenario name. Scenarios accepting label options are
+// listed in suiteLabelScenarios.
+var (
+	suiteLabelScenarios = map[string]suiteScenarioFunc{
+		"pod-to-pod":                 withLabelOptions(tests.PodToPod),
+		"pod-to-pod-cross-namespace": withLabelOptions(tests.PodToPodCrossNamespace),
+		"pod-to-pod-with-endpoints":  withLabelOptions(tests.PodToPodWithEndpoints),
+		"pod-to-service":             withLabelOptions(tests.PodToService),
+		"pod-to-ingress-service":     withLabelOptions(tests.PodToIngress),
+	}
+
+	suiteScenarios = map[string]suiteScenarioFunc{
+		"client-to-client":         withoutOptions(tests.ClientToClient),
+		"pod-to-host":              withoutOptions(tests.PodToHost),
+		"pod-to-hostport":          withoutOptions(tests.PodToHostPort),
+		"pod-to-world":             withoutOptions(func() check.Scenario { return tests.PodToWorld(tests.WithRetryAll()) }),
+		"pod-to-world-2":           withoutOptions(tests.PodToWorld2),
+		"pod-to-cidr":              withoutOptions(func() check.Scenario { return tests.PodToCIDR(tests.WithRetryAll()) }),
+		"pod-to-remote-nodeport":   withoutOptions(tests.PodToRemoteNodePort),
+		"pod-to-local-nodeport":    withoutOptions(tests.PodToLocalNodePort),
+		"pod-to-external-workload": withoutOptions(tests.PodToExternalWorkload),
+		"cilium-health":            withoutOptions(tests.CiliumHealth),
+	}
+)
+
+// loadSuiteFile reads and validates the suite file at path. Policy files are
+// read relative to the directory of the suite file, and their content is
+// stored inline in the returned suite.
+func loadSuiteFile(path string) (*suiteFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read suite file: %w", err)
+	}
+
+	suite, err := parseSuiteFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid suite file %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range suite.Tests {
+		t := &suite.Tests[i]
+		for _, pl := range [][]suitePolicy{t.CiliumPolicies, t.K8sPolicies} {
+			for j := range pl {
+				if pl[j].File == "" {
+					continue
+				}
+				file := pl[j].File
+				if !filepath.IsAbs(file) {
+					file = filepath.Join(dir, file)
+				}
+				policy, err := os.ReadFile(file)
+				if err != nil {
+					return nil, fmt.Errorf("test %s: unable to read policy: %w", t.Name, err)
+				}
+				pl[j].Inline = string(policy)
+			}
+		}
+	}
+
+	return suite, nil
+}
+
+// parseSuiteFile parses and validates the suite document in data.
+func parseSuiteFile(data []byte) (*suiteFile, error) {
+	suite := &suiteFile{}
+	if err := yaml.UnmarshalStrict(data, suite); err != nil {
+		return nil, err
+	}
+
+	if len(suite.Tests) == 0 {
+		return nil, fmt.Errorf("no tests defined")
+	}
+
+	names := make(map[string]struct{}, len(suite.Tests))
+	for i, t := range suite.Tests {
+		if t.Name == "" {
+			return nil, fmt.Errorf("test #%d: missing name", i)
+		}
+		if _, ok := names[t.Name]; ok {
+			return nil, fmt.Errorf("test %s: defined more than once", t.Name)
+		}
+		names[t.Name] = struct{}{}
+
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("test %s: %w", t.Name, err)
+		}
+	}
+
+	return suite, nil
+}
+
+func (t *suiteTest) validate() error {
+	for _, r := range t.Requirements {
+		if r.Feature == "" {
+			return fmt.Errorf("requirement without feature")
+		}
+		if !check.IsKnownFeature(check.Feature(r.Feature)) {
+			return fmt.Errorf("unknown feature %q, must be one of: %s", r.Feature, strings.Join(check.KnownFeatures(), ", "))
+		}
+		if (r.Enabled == nil) == (r.Mode == "") {
+			return fmt.Errorf("requirement %s: exactly one of enabled and mode must be set", r.Feature)
+		}
+	}
+
+	for _, pl := range [][]suitePolicy{t.CiliumPolicies, t.K8sPolicies} {
+		for _, p := range pl {
+			if (p.File == "") == (p.Inline == "") {
+				return fmt.Errorf("policy: exactly one of file and inline must be set")
+			}
+		}
+	}
+
+	if len(t.Scenarios) == 0 {
+		return fmt.Errorf("no scenarios defined")
+	}
+	for _, s := range t.Scenarios {
+		if _, ok := suiteLabelScenarios[s.Name]; ok {
+			continue
+		}
+		if _, ok := suiteScenarios[s.Name]; !ok {
+			return fmt.Errorf("unknown scenario %q, must be one of: %s", s.Name, strings.Join(suiteScenarioNames(), ", "))
+		}
+		if s.SourceLabels != nil || s.DestinationLabels != nil || s.Method != "" || s.Path != "" {
+			return fmt.Errorf("scenario %s does not support label, method or path options", s.Name)
+		}
+	}
+
+	for _, e := range t.Expectations {
+		for _, r := range []string{e.Egress, e.Ingress} {
+			if _, ok := suiteResults[r]; !ok {
+				return fmt.Errorf("unknown result %q, must be one of: %s", r, strings.Join(suiteResultNames(), ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// expectations returns the check.ExpectationsFunc implementing the test's
+// declared expectations, or nil if none were declared.
+func (t *suiteTest) expectations() check.ExpectationsFunc {
+	if len(t.Expectations) == 0 {
+		return nil
+	}
+
+	return func(a *check.Action) (egress, ingress check.Result) {
+		if e := t.matchExpectation(a.Source(), a.Destination()); e != nil {
+			return suiteResults[e.Egress], suiteResults[e.Ingress]
+		}
+		return check.ResultOK, check.ResultOK
+	}
+}
+
+// matchExpectation returns the first expectation matching the given source
+// and destination, nil if none does.
+func (t *suiteTest) matchExpectation(src, dst check.TestPeer) *suiteExpectation {
+	for i, e := range t.Expectations {
+		if e.Port != 0 && dst.Port() != e.Port {
+			continue
+		}
+		if hasAllLabels(src, e.Source) && hasAllLabels(dst, e.Destination) {
+			return &t.Expectations[i]
+		}
+	}
+	return nil
+}
+
+func hasAllLabels(peer check.TestPeer, labels map[string]string) bool {
+	for k, v := range labels {
+		if !peer.HasLabel(k, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func suiteScenarioNames() []string {
+	names := make([]string, 0, len(suiteScenarios)+len(suiteLabelScenarios))
+	for n := range suiteScenarios {
+		names = append(names, n)
+	}
+	for n := range suiteLabelScenarios {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func suiteResultNames() []string {
+	names := make([]string, 0, len(suiteResults))
+	for n := range suiteResults {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addSuiteFileTests registers the tests declared in suite with the
+// ConnectivityTest.
+func addSuiteFileTests(ct *check.ConnectivityTest, suite *suiteFile) error {
+	for i := range suite.Tests {
+		st := &suite.Tests[i]
+		t := ct.NewTest(st.Name)
+
+		if st.CiliumVersion != "" {
+			t.WithCiliumVersion(st.CiliumVersion)
+		}
+
+		for _, r := range st.Requirements {
+			switch {
+			case r.Mode != "":
+				t.WithFeatureRequirements(check.RequireFeatureMode(check.Feature(r.Feature), r.Mode))
+			case *r.Enabled:
+				t.WithFeatureRequirements(check.RequireFeatureEnabled(check.Feature(r.Feature)))
+			default:
+				t.WithFeatureRequirements(check.RequireFeatureDisabled(check.Feature(r.Feature)))
+			}
+		}
+
+		for _, p := range st.CiliumPolicies {
+			policy, err := template.Render(p.Inline, ct.Params())
+			if err != nil {
+				return fmt.Errorf("test %s: rendering policy: %w", st.Name, err)
+			}
+			t.WithCiliumPolicy(policy)
+		}
+		for _, p := range st.K8sPolicies {
+			policy, err := template.Render(p.Inline, ct.Params())
+			if err != nil {
+				return fmt.Errorf("test %s: rendering policy: %w", st.Name, err)
+			}
+			t.WithK8SPolicy(policy)
+		}
+
+		for _, s := range st.Scenarios {
+			if f, ok := suiteLabelScenarios[s.Name]; ok {
+				t.WithScenarios(f(s))
+			} else {
+				t.WithScenarios(suiteScenarios[s.Name](s))
+			}
+		}
+
+		if f := st.expectations(); f != nil {
+			t.WithExpectations(f)
+		}
+	}
+
+	return nil
+}
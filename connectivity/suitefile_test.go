@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package connectivity
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/cilium-cli/connectivity/check"
+)
+
+const testSuiteFile = `
+tests:
+- name: org-client-egress
+  requirements:
+  - feature: l7-proxy
+    enabled: true
+  - feature: encryption-pod
+    mode: ipsec
+  ciliumPolicies:
+  - file: policies/client-egress.yaml
+  scenarios:
+  - name: pod-to-pod
+    sourceLabels:
+      kind: client
+  - name: client-to-client
+  expectations:
+  - source:
+      other: client
+    destination:
+      kind: echo
+    port: 8080
+    egress: policy-deny-egress-drop
+    ingress: none
+  - destination:
+      kind: echo
+    egress: drop-curl-timeout
+    ingress: none
+`
+
+func TestLoadSuiteFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "policies"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "policies", "client-egress.yaml"), []byte("kind: CiliumNetworkPolicy"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "suite.yaml"), []byte(testSuiteFile), 0o644))
+
+	suite, err := loadSuiteFile(filepath.Join(dir, "suite.yaml"))
+	require.NoError(t, err)
+	require.Len(t, suite.Tests, 1)
+
+	st := suite.Tests[0]
+	assert.Equal(t, "org-client-egress", st.Name)
+	assert.Len(t, st.Requirements, 2)
+	assert.Equal(t, "kind: CiliumNetworkPolicy", st.CiliumPolicies[0].Inline)
+	assert.Len(t, st.Scenarios, 2)
+	assert.Equal(t, map[string]string{"kind": "client"}, st.Scenarios[0].SourceLabels)
+
+	_, err = loadSuiteFile(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestParseSuiteFileErrors(t *testing.T) {
+	for name, doc := range map[string]string{
+		"empty":           `tests: []`,
+		"unknown field":   `{tests: [{name: a, scenarios: [{name: pod-to-pod}], foo: bar}]}`,
+		"missing name":    `{tests: [{scenarios: [{name: pod-to-pod}]}]}`,
+		"duplicate name":  `{tests: [{name: a, scenarios: [{name: pod-to-pod}]}, {name: a, scenarios: [{name: pod-to-pod}]}]}`,
+		"no scenarios":    `{tests: [{name: a}]}`,
+		"bad scenario":    `{tests: [{name: a, scenarios: [{name: pod-to-moon}]}]}`,
+		"bad option":      `{tests: [{name: a, scenarios: [{name: pod-to-host, sourceLabels: {a: b}}]}]}`,
+		"bad result":      `{tests: [{name: a, scenarios: [{name: pod-to-pod}], expectations: [{egress: ok, ingress: maybe}]}]}`,
+		"bad requirement": `{tests: [{name: a, scenarios: [{name: pod-to-pod}], requirements: [{feature: tunnel}]}]}`,
+		"bad feature":     `{tests: [{name: a, scenarios: [{name: pod-to-pod}], requirements: [{feature: l7-proxi, enabled: true}]}]}`,
+		"bad policy":      `{tests: [{name: a, scenarios: [{name: pod-to-pod}], k8sPolicies: [{}]}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseSuiteFile([]byte(doc))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSuiteTestMatchExpectation(t *testing.T) {
+	suite, err := parseSuiteFile([]byte(testSuiteFile))
+	require.NoError(t, err)
+	st := suite.Tests[0]
+
+	client2 := check.HTTPEndpointWithLabels("client2", "http://client2", map[string]string{"other": "client"})
+	echo := check.HTTPEndpointWithLabels("echo", "http://echo:8080", map[string]string{"kind": "echo"})
+	echo9090 := check.HTTPEndpointWithLabels("echo", "http://echo:9090", map[string]string{"kind": "echo"})
+	world := check.HTTPEndpoint("world", "http://one.one.one.one")
+
+	e := st.matchExpectation(client2, echo)
+	require.NotNil(t, e)
+	assert.Equal(t, "policy-deny-egress-drop", e.Egress)
+
+	e = st.matchExpectation(client2, echo9090)
+	require.NotNil(t, e)
+	assert.Equal(t, "drop-curl-timeout", e.Egress)
+
+	assert.Nil(t, st.matchExpectation(client2, world))
+}
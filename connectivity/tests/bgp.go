@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cilium/cilium/pkg/node/addressing"
+	"golang.org/x/exp/slices"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cilium/cilium-cli/connectivity/check"
+	"github.com/cilium/cilium-cli/internal/utils"
+)
+
+// BGPAdvertisements is a test case which, given a CiliumBGPPeeringPolicy
+// peering all the Cilium nodes with a stand-in BGP peer running on a node
+// without Cilium, checks that:
+// - the BGP sessions of all the Cilium nodes get established
+// - the PodCIDRs of the nodes and the LoadBalancer IP of the echo-bgp service
+// are received by the peer, and installed in the kernel of its node
+// - traffic from the node of the peer, routed via the learned routes, reaches
+// the echo pods directly and through the LoadBalancer IP
+func BGPAdvertisements() check.Scenario {
+	return &bgpAdvertisements{}
+}
+
+type bgpAdvertisements struct{}
+
+func (s *bgpAdvertisements) Name() string {
+	return "bgp-advertisements"
+}
+
+func (s *bgpAdvertisements) Run(ctx context.Context, t *check.Test) {
+	ct := t.Context()
+
+	peer, ok := ct.BGPPeerPod()
+	if !ok {
+		t.Fatal("No BGP peer pod available")
+	}
+	client, ok := ct.HostNetNSPodsByNode()[peer.Pod.Spec.NodeName]
+	if !ok {
+		t.Fatalf("No host netns pod available on node %s of the BGP peer", peer.Pod.Spec.NodeName)
+	}
+
+	// The peer accepts sessions from any address, hence reports the nodes by
+	// the address they peer from, their InternalIP.
+	ciliumNodes, err := ct.K8sClient().ListCiliumNodes(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list CiliumNodes: %s", err)
+	}
+	nodeIPs := make(map[string]string)
+	for _, cn := range ciliumNodes.Items {
+		for _, addr := range cn.Spec.Addresses {
+			if addr.Type == addressing.NodeInternalIP && check.GetIPFamily(addr.IP) == check.IPFamilyV4 {
+				nodeIPs[cn.Name] = addr.IP
+				break
+			}
+		}
+	}
+
+	waitForBGPSessions(ctx, t, peer, nodeIPs)
+
+	// Expect the PodCIDRs to be routed via their node, and the LoadBalancer IP
+	// via any node.
+	expected := make(map[string]string)
+	for _, cn := range ciliumNodes.Items {
+		nodeIP, ok := nodeIPs[cn.Name]
+		if !ok {
+			continue
+		}
+		for _, cidr := range cn.Spec.IPAM.PodCIDRs {
+			if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Addr().Is4() {
+				expected[prefix.Masked().String()] = nodeIP
+			}
+		}
+	}
+	svc := waitForLoadBalancerIP(ctx, t, check.EchoBGPServiceName)
+	lb := svc.ToLoadBalancerService()
+	expected[lb.Address(check.IPFamilyV4)+"/32"] = ""
+
+	waitForBGPRoutes(ctx, t, peer, expected)
+
+	i := 0
+	for _, echo := range ct.EchoPods() {
+		echo := echo
+
+		t.NewAction(s, fmt.Sprintf("curl-echo-pod-%d", i), &client, echo, check.IPFamilyV4).Run(func(a *check.Action) {
+			a.ExecInPod(ctx, ct.CurlCommand(echo, check.IPFamilyV4))
+		})
+		i++
+	}
+
+	t.NewAction(s, "curl-echo-load-balancer", &client, lb, check.IPFamilyV4).Run(func(a *check.Action) {
+		a.ExecInPod(ctx, ct.CurlCommand(lb, check.IPFamilyV4))
+	})
+}
+
+// waitForBGPSessions waits for the BGP peer to have an established session
+// with each of the given nodes.
+func waitForBGPSessions(ctx context.Context, t *check.Test, peer check.Pod, nodeIPs map[string]string) {
+	w := utils.NewWaitObserver(ctx, utils.WaitParameters{Timeout: 2 * time.Minute})
+	defer w.Cancel()
+
+	for {
+		sessions, err := check.BGPPeerSessions(ctx, peer)
+		if err == nil {
+			var pending []string
+			for node, ip := range nodeIPs {
+				if state := sessions[ip]; state != "Established" {
+					pending = append(pending, fmt.Sprintf("%s (%s)", node, orUnknown(state)))
+				}
+			}
+			if len(pending) == 0 {
+				t.Debugf("BGP sessions established with %d nodes", len(nodeIPs))
+				return
+			}
+			sort.Strings(pending)
+			err = fmt.Errorf("BGP sessions not established with nodes %s", strings.Join(pending, ", "))
+		}
+
+		if err := w.Retry(err); err != nil {
+			t.Fatal("Failed to establish BGP sessions:", err)
+		}
+	}
+}
+
+// waitForBGPRoutes waits for the BGP peer to install routes to each of the
+// given prefixes, via the given next hop if not empty.
+func waitForBGPRoutes(ctx context.Context, t *check.Test, peer check.Pod, expected map[string]string) {
+	w := utils.NewWaitObserver(ctx, utils.WaitParameters{Timeout: time.Minute})
+	defer w.Cancel()
+
+	for {
+		routes, err := check.BGPPeerRoutes(ctx, peer)
+		if err == nil {
+			var missing []string
+			for prefix, nextHop := range expected {
+				nextHops, ok := routes[prefix]
+				if !ok || (nextHop != "" && !slices.Contains(nextHops, nextHop)) {
+					missing = append(missing, fmt.Sprintf("%s via %s", prefix, orUnknown(nextHop)))
+				}
+			}
+			if len(missing) == 0 {
+				t.Debugf("BGP routes installed for %d prefixes", len(expected))
+				return
+			}
+			sort.Strings(missing)
+			err = fmt.Errorf("BGP routes not installed: %s", strings.Join(missing, ", "))
+		}
+
+		if err := w.Retry(err); err != nil {
+			t.Fatal("Failed to receive BGP routes:", err)
+		}
+	}
+}
+
+// waitForLoadBalancerIP waits for the given service of the test namespace to
+// be allocated an IPv4 LoadBalancer IP.
+func waitForLoadBalancerIP(ctx context.Context, t *check.Test, name string) check.Service {
+	ct := t.Context()
+
+	w := utils.NewWaitObserver(ctx, utils.WaitParameters{Timeout: time.Minute})
+	defer w.Cancel()
+
+	for {
+		svc, err := ct.K8sClient().GetService(ctx, ct.Params().TestNamespace, name, metav1.GetOptions{})
+		if err == nil {
+			s := check.Service{Service: svc}
+			if s.ToLoadBalancerService().Address(check.IPFamilyV4) != "" {
+				return s
+			}
+			err = fmt.Errorf("service %s has no LoadBalancer IP", name)
+		}
+
+		if err := w.Retry(err); err != nil {
+			t.Fatal("Failed to get LoadBalancer IP:", err)
+		}
+	}
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
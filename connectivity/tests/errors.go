@@ -19,6 +19,13 @@ import (
 // logs. The error messages are defined in badLogMsgsWithExceptions, which key
 // is an error message, while values is a list of ignored messages.
 func NoErrorsInLogs(ciliumVersion semver.Version) check.Scenario {
+	return &noErrorsInLogs{ErrorLogMessages(ciliumVersion)}
+}
+
+// ErrorLogMessages returns the messages which must not appear in the
+// cilium-agent logs of the given Cilium version. The keys are the error
+// messages, the values the list of messages for which they are ignored.
+func ErrorLogMessages(ciliumVersion semver.Version) map[string][]string {
 	// Exceptions for level=error should only be added as a last resort, if the
 	// error cannot be fixed in Cilium or in the test.
 	errorLogExceptions := []string{"Error in delegate stream, restarting", failedToListCRDs, removeInexistentID}
@@ -26,7 +33,7 @@ func NoErrorsInLogs(ciliumVersion semver.Version) check.Scenario {
 		errorLogExceptions = append(errorLogExceptions, previouslyUsedCIDR)
 	}
 	// The list is adopted from cilium/cilium/test/helper/utils.go
-	var errorMsgsWithExceptions = map[string][]string{
+	return map[string][]string{
 		panicMessage:        nil,
 		deadLockHeader:      nil,
 		segmentationFault:   nil,
@@ -49,7 +56,6 @@ func NoErrorsInLogs(ciliumVersion semver.Version) check.Scenario {
 		"DATA RACE":         nil,
 		"level=error":       errorLogExceptions,
 	}
-	return &noErrorsInLogs{errorMsgsWithExceptions}
 }
 
 type noErrorsInLogs struct {
@@ -6,10 +6,16 @@ package tests
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
 
 	"github.com/cilium/cilium-cli/connectivity/check"
 )
 
+// ciliumHealthPort is the port the Cilium agents serve the node-to-node
+// health checks on, in the host network namespace of every node.
+const ciliumHealthPort = 4240
+
 // PodToHost sends an ICMP ping from all client Pods to all nodes
 // in the test context.
 func PodToHost() check.Scenario {
@@ -108,3 +114,120 @@ func (s *podToHostPort) Run(ctx context.Context, t *check.Test) {
 		}
 	}
 }
+
+// PodToHostHealthPort sends an HTTP request from all client Pods and all
+// host-netns Pods to the Cilium health port of all nodes in the test context.
+func PodToHostHealthPort() check.Scenario {
+	return &podToHostHealthPort{}
+}
+
+// podToHostHealthPort implements a ConditionalScenario.
+type podToHostHealthPort struct{}
+
+func (s *podToHostHealthPort) Name() string {
+	return "pod-to-host-health-port"
+}
+
+func (s *podToHostHealthPort) Requirements() []check.FeatureRequirement {
+	return []check.FeatureRequirement{
+		check.RequireFeatureEnabled(check.FeatureHealthChecking),
+	}
+}
+
+func (s *podToHostHealthPort) Run(ctx context.Context, t *check.Test) {
+	var i int
+	ct := t.Context()
+
+	var sources []check.Pod
+	for _, pod := range ct.ClientPods() {
+		sources = append(sources, pod)
+	}
+	for name := range ct.Nodes() {
+		if pod, ok := ct.HostNetNSPodsByNode()[name]; ok {
+			sources = append(sources, pod)
+		}
+	}
+
+	for _, pod := range sources {
+		pod := pod // copy to avoid memory aliasing when using reference
+
+		for _, node := range ct.Nodes() {
+			// The host-netns pod of the destination node observes the
+			// flows of the host, on which the ingress policy is enforced.
+			host, ok := ct.HostNetNSPodsByNode()[node.Name]
+			if !ok {
+				continue
+			}
+			// Traffic from a host to itself is not observed by Hubble.
+			if pod.Pod.Spec.HostNetwork && pod.Pod.Spec.NodeName == node.Name {
+				continue
+			}
+
+			t.ForEachIPFamily(func(ipFam check.IPFamily) {
+				for _, addr := range node.Status.Addresses {
+					if check.GetIPFamily(addr.Address) != ipFam {
+						continue
+					}
+
+					baseURL := fmt.Sprintf("http://%s/hello", net.JoinHostPort(addr.Address, strconv.Itoa(ciliumHealthPort)))
+					ep := check.HTTPEndpoint(fmt.Sprintf("%s-health", node.Name), baseURL)
+
+					t.NewAction(s, fmt.Sprintf("curl-%s-%d", ipFam, i), &pod, ep, ipFam).Run(func(a *check.Action) {
+						a.ExecInPod(ctx, ct.CurlCommand(ep, ipFam))
+
+						a.ValidateFlows(ctx, pod, a.GetEgressRequirements(check.FlowParameters{}))
+						a.ValidateFlows(ctx, host, a.GetIngressRequirements(check.FlowParameters{}))
+					})
+
+					i++
+				}
+			})
+		}
+	}
+}
+
+// HostToPod sends an HTTP request from all host-netns Pods to all echo Pods
+// running on other nodes.
+func HostToPod() check.Scenario {
+	return &hostToPod{}
+}
+
+// hostToPod implements a Scenario.
+type hostToPod struct{}
+
+func (s *hostToPod) Name() string {
+	return "host-to-pod"
+}
+
+func (s *hostToPod) Run(ctx context.Context, t *check.Test) {
+	var i int
+	ct := t.Context()
+
+	for name := range ct.Nodes() {
+		host, ok := ct.HostNetNSPodsByNode()[name]
+		if !ok {
+			continue
+		}
+
+		for _, echo := range ct.EchoPods() {
+			echo := echo // copy to avoid memory aliasing when using reference
+
+			// Traffic between a host and its local pods is not subject to
+			// the host egress policies.
+			if echo.Pod.Spec.NodeName == name {
+				continue
+			}
+
+			t.ForEachIPFamily(func(ipFam check.IPFamily) {
+				t.NewAction(s, fmt.Sprintf("curl-%s-%d", ipFam, i), &host, echo, ipFam).Run(func(a *check.Action) {
+					a.ExecInPod(ctx, ct.CurlCommand(echo, ipFam))
+
+					a.ValidateFlows(ctx, host, a.GetEgressRequirements(check.FlowParameters{}))
+					a.ValidateFlows(ctx, echo, a.GetIngressRequirements(check.FlowParameters{}))
+				})
+			})
+
+			i++
+		}
+	}
+}
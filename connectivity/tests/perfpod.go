@@ -31,6 +31,11 @@ type netPerfPodtoPod struct {
 
 var netPerfRegex = regexp.MustCompile(`\s+\d+\s+\d+\s+(\d+|\S+)\s+(\S+|\d+)\s+(\S+)+\s+(\S+)?`)
 
+// netperfLatencySelectors are the netperf output selectors used to collect
+// latency percentiles of request/response tests, in addition to the
+// throughput.
+var netperfLatencySelectors = []string{"THROUGHPUT", "P50_LATENCY", "P90_LATENCY", "P99_LATENCY"}
+
 func (s *netPerfPodtoPod) Name() string {
 	tn := "perf-pod-to-pod"
 	if s.name == "" {
@@ -40,51 +45,159 @@ func (s *netPerfPodtoPod) Name() string {
 }
 
 func (s *netPerfPodtoPod) Run(ctx context.Context, t *check.Test) {
-	samples := t.Context().Params().PerfSamples
-	duration := t.Context().Params().PerfDuration
-	crr := t.Context().Params().PerfCRR
 	for _, c := range t.Context().PerfClientPods() {
 		c := c
 		for _, server := range t.Context().PerfServerPod() {
-			var scenarioName string
-			if c.Pod.Spec.HostNetwork {
-				scenarioName = "host-net"
-			} else {
-				scenarioName = "pod-net"
-			}
 			action := t.NewAction(s, "netperf", &c, server, check.IPFamilyV4)
 			action.CollectFlows = false
 			action.Run(func(a *check.Action) {
-				if crr {
-					netperf(ctx, server.Pod.Status.PodIP, c.Pod.Name, "TCP_CRR", a, t.Context().PerfResults, 1, 30, scenarioName)
-				} else {
-					netperf(ctx, server.Pod.Status.PodIP, c.Pod.Name, "TCP_RR", a, t.Context().PerfResults, samples, duration, scenarioName)
-					netperf(ctx, server.Pod.Status.PodIP, c.Pod.Name, "TCP_STREAM", a, t.Context().PerfResults, samples, duration, scenarioName)
-					netperf(ctx, server.Pod.Status.PodIP, c.Pod.Name, "UDP_RR", a, t.Context().PerfResults, samples, duration, scenarioName)
-					netperf(ctx, server.Pod.Status.PodIP, c.Pod.Name, "UDP_STREAM", a, t.Context().PerfResults, samples, duration, scenarioName)
-				}
+				runNetperfTests(ctx, t, a, c, netperfTarget{
+					target:          check.PerfTargetPod,
+					host:            server.Pod.Status.PodIP,
+					destinationNode: server.NodeName(),
+				})
 			})
+
+			if !t.Context().Params().PerfService {
+				continue
+			}
+
+			for _, svc := range t.Context().PerfServices() {
+				controlNodePort, dataPort := check.PerfServicePorts(svc)
+
+				action := t.NewAction(s, "netperf-cluster-ip", &c, svc, check.IPFamilyV4)
+				action.CollectFlows = false
+				action.Run(func(a *check.Action) {
+					runNetperfTests(ctx, t, a, c, netperfTarget{
+						target:   check.PerfTargetClusterIP,
+						host:     svc.Service.Spec.ClusterIP,
+						dataPort: dataPort,
+						// The perf server is the only backend of the service.
+						destinationNode: server.NodeName(),
+					})
+				})
+
+				action = t.NewAction(s, "netperf-node-port", &c, server, check.IPFamilyV4)
+				action.CollectFlows = false
+				action.Run(func(a *check.Action) {
+					runNetperfTests(ctx, t, a, c, netperfTarget{
+						target:          check.PerfTargetNodePort,
+						host:            server.Pod.Status.HostIP,
+						controlPort:     controlNodePort,
+						dataPort:        dataPort,
+						destinationNode: server.NodeName(),
+					})
+				})
+			}
 		}
 	}
 }
 
-func netperf(ctx context.Context, sip string, podname string, test string, a *check.Action, result map[check.PerfTests]check.PerfResult, samples int, duration time.Duration, scenarioName string) {
-	// Define test about to be executed and from which pod
-	k := check.PerfTests{
-		Pod:  podname,
-		Test: test,
+// NetperfNodeMatrix runs the network performance tests between all pairs of
+// nodes of the cluster.
+func NetperfNodeMatrix() check.Scenario {
+	return &netPerfNodeMatrix{}
+}
+
+type netPerfNodeMatrix struct{}
+
+func (s *netPerfNodeMatrix) Name() string {
+	return "perf-node-matrix"
+}
+
+func (s *netPerfNodeMatrix) Run(ctx context.Context, t *check.Test) {
+	for _, c := range t.Context().PerfNodeMatrixPods() {
+		c := c
+		for _, server := range t.Context().PerfNodeMatrixPods() {
+			action := t.NewAction(s, fmt.Sprintf("netperf-%s", server.NodeName()), &c, server, check.IPFamilyV4)
+			action.CollectFlows = false
+			action.Run(func(a *check.Action) {
+				runNetperfTests(ctx, t, a, c, netperfTarget{
+					target:          check.PerfTargetNodeMatrix,
+					host:            server.Pod.Status.PodIP,
+					controlPort:     check.PerfNodeMatrixControlPort,
+					destinationNode: server.NodeName(),
+				})
+			})
+		}
 	}
+}
+
+// netperfTarget describes the server netperf connects to.
+type netperfTarget struct {
+	// target is the kind of target, one of the check.PerfTarget* constants.
+	target string
+	host   string
+	// controlPort is the port of the netserver control connection, the
+	// netperf default is used if zero.
+	controlPort int
+	// dataPort is the port netserver listens on for the data connection, a
+	// random port is picked if zero.
+	dataPort int
+	// destinationNode is the node of the server. Results are only told apart
+	// by destination node for the node matrix.
+	destinationNode string
+}
+
+// runNetperfTests runs the set of netperf tests selected by the user from
+// client c against the given target.
+func runNetperfTests(ctx context.Context, t *check.Test, a *check.Action, c check.Pod, target netperfTarget) {
+	params := t.Context().Params()
+
+	scenarioName := "pod-net"
+	if c.Pod.Spec.HostNetwork {
+		scenarioName = "host-net"
+	}
+
+	if params.PerfCRR {
+		netperf(ctx, target, c, "TCP_CRR", a, t.Context().PerfResults, 1, 30*time.Second, scenarioName, false)
+		return
+	}
+
+	for _, test := range []string{"TCP_RR", "TCP_STREAM", "UDP_RR", "UDP_STREAM"} {
+		latency := params.PerfLatency && strings.HasSuffix(test, "_RR")
+		netperf(ctx, target, c, test, a, t.Context().PerfResults, params.PerfSamples, params.PerfDuration, scenarioName, latency)
+	}
+}
+
+func netperf(ctx context.Context, target netperfTarget, c check.Pod, test string, a *check.Action, result map[check.PerfTests]check.PerfResult, samples int, duration time.Duration, scenarioName string, latency bool) {
 	metric := string("OP/s")
 	if strings.Contains(test, "STREAM") {
 		metric = "Mb/s"
 	}
 
-	exec := []string{"/usr/local/bin/netperf", "-H", sip, "-l", duration.String(), "-t", test, "--", "-R", "1", "-m", fmt.Sprintf("%d", messageSize)}
+	exec := []string{"/usr/local/bin/netperf", "-H", target.host, "-l", duration.String(), "-t", test}
+	if target.controlPort != 0 {
+		exec = append(exec, "-p", strconv.Itoa(target.controlPort))
+	}
+	exec = append(exec, "--", "-R", "1", "-m", fmt.Sprintf("%d", messageSize))
+	if target.dataPort != 0 {
+		exec = append(exec, "-P", fmt.Sprintf(",%d", target.dataPort))
+	}
+	if latency {
+		exec = append(exec, "-o", strings.Join(netperfLatencySelectors, ","))
+	}
+
 	//  recv socketsize		send socketsize 	msg size|okmsg	duration	value
 	values := []float64{}
+	// P50, P90 and P99 latencies in microseconds
+	latencies := [3][]float64{}
 	// Result data
 	for i := 0; i < samples; i++ {
 		a.ExecInPod(ctx, exec)
+
+		if latency {
+			v, err := parseNetperfSelectors(a.CmdOutput(), len(netperfLatencySelectors))
+			if err != nil {
+				a.Fatalf("Unable to parse netperf result: %s", err)
+			}
+			values = append(values, v[0])
+			for j := range latencies {
+				latencies[j] = append(latencies[j], v[j+1])
+			}
+			continue
+		}
+
 		d := netPerfRegex.FindStringSubmatch(a.CmdOutput())
 		if len(d) < 5 {
 			a.Fatal("Unable to process netperf result")
@@ -102,15 +215,57 @@ func netperf(ctx context.Context, sip string, podname string, test string, a *ch
 			a.Fatal("Unable to parse netperf result")
 		}
 	}
-	res := check.PerfResult{
-		Scenario: scenarioName,
-		Metric:   metric,
-		Duration: duration,
-		Values:   values,
-		Samples:  samples,
-		Avg:      listAvg(values),
+
+	storeResult := func(test, metric string, values []float64) {
+		// Define test executed and from which pod
+		k := check.PerfTests{
+			Pod:    c.Pod.Name,
+			Test:   test,
+			Target: target.target,
+		}
+		if target.target == check.PerfTargetNodeMatrix {
+			k.DestinationNode = target.destinationNode
+		}
+		result[k] = check.PerfResult{
+			Scenario:        scenarioName,
+			Target:          target.target,
+			SourceNode:      c.NodeName(),
+			DestinationNode: target.destinationNode,
+			Metric:          metric,
+			Duration:        duration,
+			Values:          values,
+			Samples:         samples,
+			Avg:             listAvg(values),
+		}
+	}
+
+	storeResult(test, metric, values)
+	if latency {
+		for j, p := range []string{"P50", "P90", "P99"} {
+			storeResult(fmt.Sprintf("%s_LATENCY_%s", test, p), "us", latencies[j])
+		}
 	}
-	result[k] = res
+}
+
+// parseNetperfSelectors parses the output of netperf run with n output
+// selectors, which ends with a line of comma-separated values.
+func parseNetperfSelectors(output string, n int) ([]float64, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := strings.Split(strings.TrimSpace(lines[len(lines)-1]), ",")
+	if len(fields) != n {
+		return nil, fmt.Errorf("expected %d values, got %q", n, lines[len(lines)-1])
+	}
+
+	values := make([]float64, 0, n)
+	for _, f := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	return values, nil
 }
 
 func listAvg(list []float64) float64 {
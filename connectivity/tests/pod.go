@@ -70,6 +70,66 @@ func (s *podToPod) Run(ctx context.Context, t *check.Test) {
 	}
 }
 
+// PodToPodCrossNamespace generates one HTTP request from each client pod of
+// the test namespace to each echo pod of the secondary test namespace, and
+// the other way around. The remote Pod is contacted directly, no DNS is
+// involved.
+func PodToPodCrossNamespace(opts ...Option) check.Scenario {
+	options := &labelsOption{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return &podToPodCrossNamespace{
+		sourceLabels:      options.sourceLabels,
+		destinationLabels: options.destinationLabels,
+	}
+}
+
+// podToPodCrossNamespace implements a Scenario.
+type podToPodCrossNamespace struct {
+	sourceLabels      map[string]string
+	destinationLabels map[string]string
+}
+
+func (s *podToPodCrossNamespace) Name() string {
+	return "pod-to-pod-cross-namespace"
+}
+
+func (s *podToPodCrossNamespace) Run(ctx context.Context, t *check.Test) {
+	var i int
+	ct := t.Context()
+
+	for _, pair := range []struct {
+		clients map[string]check.Pod
+		echoes  map[string]check.Pod
+	}{
+		{ct.ClientPods(), ct.SecondaryEchoPods()},
+		{ct.SecondaryClientPods(), ct.EchoPods()},
+	} {
+		for _, client := range pair.clients {
+			client := client // copy to avoid memory aliasing when using reference
+			if !hasAllLabels(client, s.sourceLabels) {
+				continue
+			}
+			for _, echo := range pair.echoes {
+				if !hasAllLabels(echo, s.destinationLabels) {
+					continue
+				}
+				t.ForEachIPFamily(func(ipFam check.IPFamily) {
+					t.NewAction(s, fmt.Sprintf("curl-%s-%d", ipFam, i), &client, echo, ipFam).Run(func(a *check.Action) {
+						a.ExecInPod(ctx, ct.CurlCommand(echo, ipFam))
+
+						a.ValidateFlows(ctx, client, a.GetEgressRequirements(check.FlowParameters{}))
+						a.ValidateFlows(ctx, echo, a.GetIngressRequirements(check.FlowParameters{}))
+					})
+				})
+
+				i++
+			}
+		}
+	}
+}
+
 func PodToPodWithEndpoints(opts ...Option) check.Scenario {
 	options := &labelsOption{}
 	for _, opt := range opts {
@@ -6,8 +6,14 @@ package tests
 import (
 	"context"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
+	"golang.org/x/exp/slices"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/cilium/cilium/pkg/versioncheck"
 
@@ -278,3 +284,461 @@ func (s *outsideToNodePort) Run(ctx context.Context, t *check.Test) {
 		}
 	}
 }
+
+// echoBackendsRequests is the number of requests sent to the echo backends
+// Services to find out which backends serve them.
+const echoBackendsRequests = 20
+
+// PodToServiceSessionAffinity sends a series of HTTP requests from all client
+// Pods to the echo backends Service with ClientIP session affinity, and checks
+// that they are all served by the same backend. As a control, it checks that
+// the same requests to the echo backends Service without session affinity are
+// served by several backends, as a single ready backend would otherwise pass.
+func PodToServiceSessionAffinity() check.Scenario {
+	return &podToServiceSessionAffinity{}
+}
+
+// podToServiceSessionAffinity implements a ConditionalScenario.
+type podToServiceSessionAffinity struct{}
+
+func (s *podToServiceSessionAffinity) Name() string {
+	return "pod-to-service-session-affinity"
+}
+
+func (s *podToServiceSessionAffinity) Requirements() []check.FeatureRequirement {
+	return []check.FeatureRequirement{
+		check.RequireFeatureEnabled(check.FeatureKPRSessionAffinity),
+	}
+}
+
+func (s *podToServiceSessionAffinity) Run(ctx context.Context, t *check.Test) {
+	var i int
+	ct := t.Context()
+
+	for _, pod := range ct.ClientPods() {
+		pod := pod // copy to avoid memory aliasing when using reference
+
+		for _, svc := range ct.EchoBackendsServices() {
+			affinity := svc.Service.Spec.SessionAffinity == corev1.ServiceAffinityClientIP
+
+			t.ForEachIPFamily(func(ipFam check.IPFamily) {
+				name := fmt.Sprintf("curl-%s-%d", ipFam, i)
+				if !affinity {
+					name = fmt.Sprintf("curl-spread-%s-%d", ipFam, i)
+				}
+				t.NewAction(s, name, &pod, svc, ipFam).Run(func(a *check.Action) {
+					a.ExecInPod(ctx, echoBackendsCommand(ct, svc, ipFam, echoBackendsRequests))
+
+					backends := strings.Fields(a.CmdOutput())
+					if len(backends) != echoBackendsRequests {
+						a.Failf("expected %d replies from %s, got %d: %q", echoBackendsRequests, svc.Name(), len(backends), a.CmdOutput())
+						return
+					}
+
+					distinct := make(map[string]struct{})
+					for _, backend := range backends {
+						distinct[backend] = struct{}{}
+					}
+					switch {
+					case affinity && len(distinct) > 1:
+						a.Failf("requests to %s were served by different backends despite ClientIP session affinity: %v", svc.Name(), backends)
+					case !affinity && len(distinct) < 2:
+						a.Failf("requests to %s were all served by backend %s without session affinity, which can't be told apart from session affinity", svc.Name(), backends[0])
+					}
+				})
+			})
+
+			i++
+		}
+	}
+}
+
+// PodToServiceGracefulTermination opens a connection from a client Pod to the
+// echo backends Service and terminates the backend serving it. It checks that
+// the established connection is still served by the terminating backend,
+// while new connections are steered to the other backends.
+func PodToServiceGracefulTermination() check.Scenario {
+	return &podToServiceGracefulTermination{}
+}
+
+// podToServiceGracefulTermination implements a ConditionalScenario.
+type podToServiceGracefulTermination struct{}
+
+func (s *podToServiceGracefulTermination) Name() string {
+	return "pod-to-service-graceful-termination"
+}
+
+func (s *podToServiceGracefulTermination) Requirements() []check.FeatureRequirement {
+	return []check.FeatureRequirement{
+		check.RequireFeatureEnabled(check.FeatureKPRGracefulTermination),
+	}
+}
+
+func (s *podToServiceGracefulTermination) Run(ctx context.Context, t *check.Test) {
+	var i int
+	ct := t.Context()
+
+	pod := ct.RandomClientPod()
+	if pod == nil {
+		t.Fatal("No client pod available")
+	}
+
+	for _, svc := range ct.EchoBackendsServices() {
+		if svc.Service.Spec.SessionAffinity == corev1.ServiceAffinityClientIP {
+			continue
+		}
+
+		t.NewAction(s, fmt.Sprintf("terminate-%d", i), pod, svc, check.IPFamilyAny).Run(func(a *check.Action) {
+			// Keep a connection open in the background, which sends a second
+			// request once /tmp/graceful-done exists.
+			a.ExecInPod(ctx, []string{"/bin/sh", "-c", fmt.Sprintf(gracefulConnectionScript,
+				svc.Address(check.IPFamilyAny), svc.Port())})
+			backends := parseHTTPResponseBodies(a.CmdOutput())
+			if len(backends) != 1 {
+				a.Fatalf("expected a reply from %s on the established connection, got %q", svc.Name(), a.CmdOutput())
+			}
+			terminating := backends[0]
+
+			a.Debugf("Terminating backend %s", terminating)
+			if err := ct.K8sClient().DeletePod(ctx, svc.Service.Namespace, terminating, metav1.DeleteOptions{}); err != nil {
+				a.Fatalf("Failed to delete backend pod %s: %s", terminating, err)
+			}
+
+			// New connections must be steered away from the terminating
+			// backend once the agents learn about its termination.
+			timeout := time.After(check.ShortTimeout)
+			for {
+				a.ExecInPod(ctx, echoBackendsCommand(ct, svc, check.IPFamilyAny, echoBackendsRequests))
+				backends := strings.Fields(a.CmdOutput())
+				if len(backends) == echoBackendsRequests && !slices.Contains(backends, terminating) {
+					break
+				}
+
+				select {
+				case <-timeout:
+					a.Fatalf("new connections to %s are still not steered away from terminating backend %s: %v", svc.Name(), terminating, backends)
+				case <-time.After(check.PollInterval):
+				}
+			}
+
+			// The established connection must still be served by the
+			// terminating backend.
+			a.ExecInPod(ctx, []string{"/bin/sh", "-c", gracefulConnectionDoneScript})
+			backends = parseHTTPResponseBodies(a.CmdOutput())
+			if len(backends) != 2 || backends[1] != terminating {
+				a.Failf("established connection to %s was not served by terminating backend %s until completion: %q", svc.Name(), terminating, a.CmdOutput())
+			}
+		})
+
+		i++
+	}
+}
+
+// gracefulConnectionScript opens a connection to the given address and port,
+// sending a first request right away and a second one once /tmp/graceful-done
+// exists. It outputs the reply to the first request.
+const gracefulConnectionScript = `rm -f /tmp/graceful-*
+{ printf 'GET /hostname HTTP/1.1\r\nHost: echo\r\n\r\n'; while [ ! -e /tmp/graceful-done ]; do sleep 1; done; printf 'GET /hostname HTTP/1.1\r\nHost: echo\r\nConnection: close\r\n\r\n'; } 2>/dev/null | nc %s %d >/tmp/graceful-out 2>&1 &
+echo $! >/tmp/graceful-pid
+sleep 2
+cat /tmp/graceful-out`
+
+// gracefulConnectionDoneScript triggers the second request on the connection
+// opened by gracefulConnectionScript, and outputs both replies once the
+// connection is closed.
+const gracefulConnectionDoneScript = `touch /tmp/graceful-done
+for i in $(seq 10); do kill -0 $(cat /tmp/graceful-pid) 2>/dev/null || break; sleep 1; done
+cat /tmp/graceful-out`
+
+// echoBackendsCommand returns a command sending the given number of requests
+// to an echo backends Service, outputting the name of the backend serving each
+// of them on a separate line.
+func echoBackendsCommand(ct *check.ConnectivityTest, svc check.Service, ipFam check.IPFamily, requests int) []string {
+	curl := []string{"curl", "--silent", "--fail", "--show-error"}
+	if connectTimeout := ct.Params().ConnectTimeout.Seconds(); connectTimeout > 0.0 {
+		curl = append(curl, "--connect-timeout", strconv.FormatFloat(connectTimeout, 'f', -1, 64))
+	}
+	if requestTimeout := ct.Params().RequestTimeout.Seconds(); requestTimeout > 0.0 {
+		curl = append(curl, "--max-time", strconv.FormatFloat(requestTimeout, 'f', -1, 64))
+	}
+	curl = append(curl, fmt.Sprintf("%s://%s/hostname", svc.Scheme(),
+		net.JoinHostPort(svc.Address(ipFam), strconv.FormatUint(uint64(svc.Port()), 10))))
+
+	return []string{"/bin/sh", "-c", fmt.Sprintf("for i in $(seq %d); do %s || exit 1; echo; done",
+		requests, strings.Join(curl, " "))}
+}
+
+// parseHTTPResponseBodies returns the bodies of the raw HTTP/1.1 responses in
+// the given output, without surrounding whitespace.
+func parseHTTPResponseBodies(out string) []string {
+	var bodies []string
+	for _, resp := range strings.Split(out, "HTTP/1.1 ")[1:] {
+		if _, body, ok := strings.Cut(resp, "\r\n\r\n"); ok {
+			bodies = append(bodies, strings.TrimSpace(body))
+		}
+	}
+	return bodies
+}
+
+// PodToExternalIPService sends an HTTP request from all client Pods to the
+// external IPs of all echo external IP Services.
+func PodToExternalIPService() check.Scenario {
+	return &podToExternalIPService{}
+}
+
+// podToExternalIPService implements a ConditionalScenario.
+type podToExternalIPService struct{}
+
+func (s *podToExternalIPService) Name() string {
+	return "pod-to-external-ip-service"
+}
+
+func (s *podToExternalIPService) Requirements() []check.FeatureRequirement {
+	return []check.FeatureRequirement{
+		check.RequireFeatureEnabled(check.FeatureKPRExternalIPs),
+	}
+}
+
+func (s *podToExternalIPService) Run(ctx context.Context, t *check.Test) {
+	var i int
+	ct := t.Context()
+
+	for _, pod := range ct.ClientPods() {
+		pod := pod // copy to avoid memory aliasing when using reference
+
+		for _, svc := range ct.EchoExternalIPServices() {
+			curlExternalIP(ctx, s, t, fmt.Sprintf("curl-%d", i), &pod, svc, true)
+			i++
+		}
+	}
+}
+
+// HostToExternalIPService sends an HTTP request from all host-netns Pods
+// running on Cilium nodes to the external IPs of all echo external IP Services.
+func HostToExternalIPService() check.Scenario {
+	return &hostToExternalIPService{}
+}
+
+// hostToExternalIPService implements a ConditionalScenario.
+type hostToExternalIPService struct{}
+
+func (s *hostToExternalIPService) Name() string {
+	return "host-to-external-ip-service"
+}
+
+func (s *hostToExternalIPService) Requirements() []check.FeatureRequirement {
+	return []check.FeatureRequirement{
+		check.RequireFeatureEnabled(check.FeatureKPRExternalIPs),
+	}
+}
+
+func (s *hostToExternalIPService) Run(ctx context.Context, t *check.Test) {
+	var i int
+	ct := t.Context()
+
+	for name := range ct.Nodes() {
+		pod, ok := ct.HostNetNSPodsByNode()[name]
+		if !ok {
+			continue
+		}
+
+		for _, svc := range ct.EchoExternalIPServices() {
+			curlExternalIP(ctx, s, t, fmt.Sprintf("curl-%d", i), &pod, svc, true)
+			i++
+		}
+	}
+}
+
+// OutsideToExternalIPService sends an HTTP request from a client pod running
+// on a node w/o Cilium to the external IPs of all echo external IP Services.
+func OutsideToExternalIPService() check.Scenario {
+	return &outsideToExternalIPService{}
+}
+
+// outsideToExternalIPService implements a ConditionalScenario.
+type outsideToExternalIPService struct{}
+
+func (s *outsideToExternalIPService) Name() string {
+	return "outside-to-external-ip-service"
+}
+
+func (s *outsideToExternalIPService) Requirements() []check.FeatureRequirement {
+	return []check.FeatureRequirement{
+		check.RequireFeatureEnabled(check.FeatureKPRExternalIPs),
+		check.RequireFeatureEnabled(check.FeatureNodeWithoutCilium),
+	}
+}
+
+func (s *outsideToExternalIPService) Run(ctx context.Context, t *check.Test) {
+	clientPod := t.Context().HostNetNSPodsByNode()[t.NodesWithoutCilium()[0]]
+	i := 0
+
+	// Like for NodePort services, the original client IP can only be
+	// observed when the kube-proxy replacement does the N/S LB.
+	_, validateFlows := t.Context().Feature(check.FeatureKPRNodePort)
+
+	for _, svc := range t.Context().EchoExternalIPServices() {
+		curlExternalIP(ctx, s, t, fmt.Sprintf("curl-%d", i), &clientPod, svc, validateFlows)
+		i++
+	}
+}
+
+func curlExternalIP(ctx context.Context, s check.Scenario, t *check.Test,
+	name string, pod *check.Pod, svc check.Service, validateFlows bool) {
+
+	ep := svc.ToExternalIPService()
+	backend, hasBackend := serviceBackend(t.Context(), svc)
+
+	t.ForEachIPFamily(func(ipFam check.IPFamily) {
+		if ep.Address(ipFam) == "" {
+			return
+		}
+
+		t.NewAction(s, fmt.Sprintf("%s-%s", name, ipFam), pod, ep, ipFam).Run(func(a *check.Action) {
+			a.ExecInPod(ctx, t.Context().CurlCommand(ep, ipFam))
+
+			if validateFlows {
+				p := check.FlowParameters{}
+				if hasBackend {
+					// The external IP may be translated to the backend
+					// before Hubble observes the request, e.g. by socket-LB.
+					p.AltDstIP = backend.Address(ipFam)
+					p.AltDstPort = backend.Port()
+				}
+				a.ValidateFlows(ctx, pod, a.GetEgressRequirements(p))
+			}
+		})
+	})
+}
+
+// PodToServiceSocketLB sends an HTTP request from all host-netns Pods running
+// on Cilium nodes, and all client Pods unless socket-LB is restricted to the
+// host namespace, to the ClusterIPs of all echo Services. As the Services are
+// translated to their backend at connect time, no flow towards the ClusterIPs
+// must be observed.
+func PodToServiceSocketLB() check.Scenario {
+	return &podToServiceSocketLB{}
+}
+
+// podToServiceSocketLB implements a ConditionalScenario.
+type podToServiceSocketLB struct{}
+
+func (s *podToServiceSocketLB) Name() string {
+	return "pod-to-service-socket-lb"
+}
+
+func (s *podToServiceSocketLB) Requirements() []check.FeatureRequirement {
+	return []check.FeatureRequirement{
+		check.RequireFeatureEnabled(check.FeatureKPRSocketLB),
+	}
+}
+
+func (s *podToServiceSocketLB) Run(ctx context.Context, t *check.Test) {
+	var i int
+	ct := t.Context()
+
+	var sources []check.Pod
+	for name := range ct.Nodes() {
+		if pod, ok := ct.HostNetNSPodsByNode()[name]; ok {
+			sources = append(sources, pod)
+		}
+	}
+	if f, ok := ct.Feature(check.FeatureKPRSocketLBHostnsOnly); !ok || !f.Enabled {
+		for _, pod := range ct.ClientPods() {
+			sources = append(sources, pod)
+		}
+	}
+
+	for _, pod := range sources {
+		pod := pod // copy to avoid memory aliasing when using reference
+
+		for _, svc := range ct.EchoServices() {
+			backend, ok := serviceBackend(ct, svc)
+			if !ok {
+				continue
+			}
+
+			t.ForEachIPFamily(func(ipFam check.IPFamily) {
+				clusterIP := svc.Address(ipFam)
+				if clusterIP == "" {
+					return
+				}
+
+				// The action targets the backend, as this is where the
+				// requests must be observed going.
+				ep := check.HTTPEndpoint(svc.Name(), fmt.Sprintf("%s://%s%s", svc.Scheme(),
+					net.JoinHostPort(clusterIP, strconv.FormatUint(uint64(svc.Port()), 10)), svc.Path()))
+				t.NewAction(s, fmt.Sprintf("curl-%s-%d", ipFam, i), &pod, backend, ipFam).Run(func(a *check.Action) {
+					a.ExecInPod(ctx, ct.CurlCommand(ep, ipFam))
+
+					a.ValidateFlows(ctx, pod, a.GetEgressRequirements(check.FlowParameters{
+						UntranslatedDstIP: clusterIP,
+					}))
+				})
+			})
+
+			i++
+		}
+	}
+}
+
+// PodToServiceSocketLBBypass sends an HTTP request from all client Pods to
+// the ClusterIPs of all echo Services, when socket-LB is restricted to the
+// host namespace. As socket-LB is bypassed in the Pods' namespace, the
+// requests must be observed going to the ClusterIPs.
+func PodToServiceSocketLBBypass() check.Scenario {
+	return &podToServiceSocketLBBypass{}
+}
+
+// podToServiceSocketLBBypass implements a ConditionalScenario.
+type podToServiceSocketLBBypass struct{}
+
+func (s *podToServiceSocketLBBypass) Name() string {
+	return "pod-to-service-socket-lb-bypass"
+}
+
+func (s *podToServiceSocketLBBypass) Requirements() []check.FeatureRequirement {
+	return []check.FeatureRequirement{
+		check.RequireFeatureEnabled(check.FeatureKPRSocketLBHostnsOnly),
+	}
+}
+
+func (s *podToServiceSocketLBBypass) Run(ctx context.Context, t *check.Test) {
+	var i int
+	ct := t.Context()
+
+	for _, pod := range ct.ClientPods() {
+		pod := pod // copy to avoid memory aliasing when using reference
+
+		for _, svc := range ct.EchoServices() {
+			t.ForEachIPFamily(func(ipFam check.IPFamily) {
+				if svc.Address(ipFam) == "" {
+					return
+				}
+
+				t.NewAction(s, fmt.Sprintf("curl-%s-%d", ipFam, i), &pod, svc, ipFam).Run(func(a *check.Action) {
+					a.ExecInPod(ctx, ct.CurlCommand(svc, ipFam))
+
+					a.ValidateFlows(ctx, pod, a.GetEgressRequirements(check.FlowParameters{}))
+				})
+			})
+
+			i++
+		}
+	}
+}
+
+// serviceBackend returns the echo Pod backing the given Service, if it has a
+// single one.
+func serviceBackend(ct *check.ConnectivityTest, svc check.Service) (check.Pod, bool) {
+	var backends []check.Pod
+	for _, echo := range ct.EchoPods() {
+		if len(svc.Service.Spec.Selector) > 0 && hasAllLabels(echo, svc.Service.Spec.Selector) {
+			backends = append(backends, echo)
+		}
+	}
+	if len(backends) != 1 {
+		return check.Pod{}, false
+	}
+	return backends[0], true
+}
@@ -6,6 +6,7 @@ package tests
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/cilium/cilium-cli/connectivity/check"
 )
@@ -71,8 +72,8 @@ func (s *podToWorld) Run(ctx context.Context, t *check.Test) {
 	}
 }
 
-// PodToWorld2 sends an HTTPS request to cilium.io from from random client
-// Pods.
+// PodToWorld2 sends an HTTPS request to ExternalOtherTarget, cilium.io by
+// default, from from random client Pods.
 func PodToWorld2() check.Scenario {
 	return &podToWorld2{}
 }
@@ -85,7 +86,9 @@ func (s *podToWorld2) Name() string {
 }
 
 func (s *podToWorld2) Run(ctx context.Context, t *check.Test) {
-	https := check.HTTPEndpoint("cilium-io-https", "https://cilium.io")
+	extTarget := t.Context().Params().ExternalOtherTarget
+	name := strings.ReplaceAll(extTarget, ".", "-")
+	https := check.HTTPEndpoint(name+"-https", "https://"+extTarget)
 
 	fp := check.FlowParameters{
 		DNSRequired: true,
@@ -99,7 +102,7 @@ func (s *podToWorld2) Run(ctx context.Context, t *check.Test) {
 		client := client // copy to avoid memory aliasing when using reference
 
 		// With https, over port 443.
-		t.NewAction(s, fmt.Sprintf("https-cilium-io-%d", i), &client, https, check.IPFamilyAny).Run(func(a *check.Action) {
+		t.NewAction(s, fmt.Sprintf("https-%s-%d", name, i), &client, https, check.IPFamilyAny).Run(func(a *check.Action) {
 			a.ExecInPod(ctx, ct.CurlCommand(https, check.IPFamilyAny))
 			a.ValidateFlows(ctx, client, a.GetEgressRequirements(fp))
 			a.ValidateMetrics(ctx, client, a.GetEgressMetricsRequirements())
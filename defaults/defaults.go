@@ -78,6 +78,9 @@ const (
 	SPIREAgentConfigMapName    = "spire-agent"
 
 	ConnectivityCheckNamespace = "cilium-test"
+	// ConnectivityCheckSecondaryNamespace is the name the policy manifests of
+	// the cross-namespace tests use to refer to the secondary test namespace.
+	ConnectivityCheckSecondaryNamespace = "cilium-test-secondary"
 
 	// renovate: datasource=docker
 	ConnectivityCheckAlpineCurlImage = "quay.io/cilium/alpine-curl:v1.7.0@sha256:ccd0ed9da1752bab88a807647ad3cec65d460d281ab88988b60d70148783e751"
@@ -86,7 +89,16 @@ const (
 	// renovate: datasource=docker
 	ConnectivityCheckJSONMockImage = "quay.io/cilium/json-mock:v1.3.5@sha256:d5dfd0044540cbe01ad6a1932cfb1913587f93cac4f145471ca04777f26342a4"
 	// renovate: datasource=docker
+	ConnectivityCheckAgnhostImage = "registry.k8s.io/e2e-test-images/agnhost:2.47"
+	// renovate: datasource=docker
 	ConnectivityDNSTestServerImage = "docker.io/coredns/coredns:1.11.1@sha256:1eeb4c7316bacb1d4c8ead65571cd92dd21e27359f0d4917f1a5822a73b75db1"
+	// renovate: datasource=docker
+	ConnectivityCheckFRRImage = "quay.io/frrouting/frr:9.0.1"
+
+	// ConnectivityCheckBGPLoadBalancerCIDR is the CIDR of the LoadBalancer IP
+	// pool of the BGP tests. It is taken from the benchmarking range
+	// (RFC 2544) to avoid overlapping with addresses in use.
+	ConnectivityCheckBGPLoadBalancerCIDR = "198.18.0.0/29"
 
 	ConfigMapName = "cilium-config"
 
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type k8sEncryptImplementation interface {
+	ListPods(ctx context.Context, namespace string, options metav1.ListOptions) (*corev1.PodList, error)
+	ExecInPod(ctx context.Context, namespace, pod, container string, command []string) (bytes.Buffer, error)
+	GetSecret(ctx context.Context, namespace, name string, opts metav1.GetOptions) (*corev1.Secret, error)
+	PatchSecret(ctx context.Context, namespace, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions) (*corev1.Secret, error)
+}
+
+// Parameters contains options for CLI
+type Parameters struct {
+	CiliumNamespace  string
+	AgentPodSelector string
+	NodeName         string
+	Writer           io.Writer
+	WaitDuration     time.Duration
+	Output           string
+}
+
+// Status is used to get and manage encryption state of cilium agents
+type Status struct {
+	client     k8sEncryptImplementation
+	params     Parameters
+	ciliumPods []*corev1.Pod
+
+	// now returns the current time, used to compute the age of the last
+	// WireGuard handshakes.
+	now func() time.Time
+
+	// pollInterval is the interval at which agents are polled while waiting
+	// for a new IPsec key to be used.
+	pollInterval time.Duration
+}
+
+// NewStatus returns new encrypt.Status struct
+func NewStatus(client k8sEncryptImplementation, p Parameters) *Status {
+	return &Status{
+		client: client,
+		params: p,
+		now:    time.Now,
+
+		pollInterval: 2 * time.Second,
+	}
+}
+
+// initTargetCiliumPods stores cilium agent pods in the status.ciliumPods.
+// If node selector option is specified then only that nodes' cilium-agent
+// pod is stored else all cilium-agents in the cluster are stored.
+func (s *Status) initTargetCiliumPods(ctx context.Context) error {
+	opts := metav1.ListOptions{LabelSelector: s.params.AgentPodSelector}
+	if s.params.NodeName != "" {
+		opts.FieldSelector = fmt.Sprintf("spec.nodeName=%s", s.params.NodeName)
+	}
+
+	ciliumPods, err := s.client.ListPods(ctx, s.params.CiliumNamespace, opts)
+	if err != nil {
+		return fmt.Errorf("unable to list Cilium pods: %w", err)
+	}
+	if len(ciliumPods.Items) == 0 {
+		return fmt.Errorf("no Cilium pods found matching %q", s.params.AgentPodSelector)
+	}
+
+	for _, ciliumPod := range ciliumPods.Items {
+		s.ciliumPods = append(s.ciliumPods, ciliumPod.DeepCopy())
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package encrypt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/cilium/cilium-cli/defaults"
+)
+
+// maxSPI is the largest SPI Cilium accepts for IPsec keys. SPIs wrap around
+// to 1 past it.
+const maxSPI = 15
+
+// ipsecKey is a parsed IPsec key, as stored in the keys secret:
+//
+//	<spi>[+] <aead-algo> <aead-key> <icv-len>
+//	<spi>[+] <auth-algo> <auth-key> <enc-algo> <enc-key>
+type ipsecKey struct {
+	spi    int64
+	suffix string
+	fields []string
+}
+
+func parseIPsecKey(key string) (*ipsecKey, error) {
+	fields := strings.Fields(key)
+	if len(fields) != 4 && len(fields) != 5 {
+		return nil, fmt.Errorf("unexpected number of fields in IPsec key: %d", len(fields))
+	}
+
+	spi, suffix := fields[0], ""
+	if strings.HasSuffix(spi, "+") {
+		spi, suffix = strings.TrimSuffix(spi, "+"), "+"
+	}
+	n, err := strconv.ParseInt(spi, 10, 64)
+	if err != nil || n < 1 || n > maxSPI {
+		return nil, fmt.Errorf("invalid IPsec key SPI %q", fields[0])
+	}
+
+	return &ipsecKey{spi: n, suffix: suffix, fields: fields[1:]}, nil
+}
+
+func (k *ipsecKey) String() string {
+	return strings.Join(append([]string{strconv.FormatInt(k.spi, 10) + k.suffix}, k.fields...), " ")
+}
+
+// rotate returns a new key using the next SPI, with the same algorithms and
+// key lengths, but freshly generated key material.
+func (k *ipsecKey) rotate() (*ipsecKey, error) {
+	next := &ipsecKey{
+		spi:    k.spi%maxSPI + 1,
+		suffix: k.suffix,
+		fields: append([]string{}, k.fields...),
+	}
+
+	// The key material is the second field, and the fourth one when the key
+	// uses separate authentication and encryption algorithms.
+	keyFields := []int{1}
+	if len(k.fields) == 4 {
+		keyFields = append(keyFields, 3)
+	}
+	for _, i := range keyFields {
+		prefix, material := "", next.fields[i]
+		if strings.HasPrefix(material, "0x") {
+			prefix, material = "0x", strings.TrimPrefix(material, "0x")
+		}
+		if len(material) == 0 || len(material)%2 != 0 {
+			return nil, fmt.Errorf("unexpected length of IPsec key material: %d", len(material))
+		}
+		random := make([]byte, len(material)/2)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("unable to generate random sequence for key: %w", err)
+		}
+		next.fields[i] = prefix + hex.EncodeToString(random)
+	}
+
+	return next, nil
+}
+
+// RotateKey replaces the IPsec key with a new one using the next SPI, and
+// waits until every agent uses it. XFRM error counters are watched during
+// the transition, and any increase is reported.
+func (s *Status) RotateKey(ctx context.Context) error {
+	ctx, cancelFn := context.WithTimeout(ctx, s.params.WaitDuration)
+	defer cancelFn()
+
+	err := s.initTargetCiliumPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	before, err := s.fetchEncryptStatusConcurrently(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkReadyForRotation(before); err != nil {
+		return err
+	}
+
+	secret, err := s.client.GetSecret(ctx, s.params.CiliumNamespace, defaults.EncryptionSecretName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get secret %s/%s: %w", s.params.CiliumNamespace, defaults.EncryptionSecretName, err)
+	}
+	key, err := parseIPsecKey(string(secret.Data["keys"]))
+	if err != nil {
+		return fmt.Errorf("unable to parse secret %s/%s: %w", s.params.CiliumNamespace, defaults.EncryptionSecretName, err)
+	}
+	newKey, err := key.rotate()
+	if err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"data": map[string][]byte{"keys": []byte(newKey.String())},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PatchSecret(ctx, s.params.CiliumNamespace, defaults.EncryptionSecretName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to patch secret %s/%s: %w", s.params.CiliumNamespace, defaults.EncryptionSecretName, err)
+	}
+	s.logf("🔑 Rotated IPsec key in secret %s/%s from SPI %d to SPI %d", s.params.CiliumNamespace, defaults.EncryptionSecretName, key.spi, newKey.spi)
+
+	s.logf("⌛ Waiting for %d nodes to use the new key...", len(before))
+	after, err := s.waitForKey(ctx, newKey.spi)
+	s.reportXfrmErrors(before, after)
+	if err != nil {
+		return err
+	}
+
+	s.logf("✅ All %d nodes use the new key with SPI %d", len(after), newKey.spi)
+	return nil
+}
+
+// checkReadyForRotation ensures all nodes use IPsec with the same single key,
+// as rotating a key while a previous rotation is still in progress would
+// drop the key some nodes still use.
+func checkReadyForRotation(res map[string]*NodeStatus) error {
+	var keyIDs string
+	for _, node := range sortedNodes(res) {
+		st := res[node]
+		if st.Mode != modeIPsec {
+			return fmt.Errorf("IPsec encryption is not enabled on node %s (mode: %s)", node, st.Mode)
+		}
+		ids := st.keyIDs()
+		if len(st.IPsec.KeyIDs) > 1 {
+			return fmt.Errorf("node %s uses several IPsec keys [%s], a key rotation is likely in progress", node, ids)
+		}
+		if keyIDs == "" {
+			keyIDs = ids
+		} else if ids != keyIDs {
+			return fmt.Errorf("node %s uses IPsec key [%s] while other nodes use [%s], a key rotation is likely in progress", node, ids, keyIDs)
+		}
+	}
+	return nil
+}
+
+// waitForKey polls the agents until all of them have XFRM states using the
+// given SPI, and returns their last status.
+func (s *Status) waitForKey(ctx context.Context, spi int64) (map[string]*NodeStatus, error) {
+	for {
+		res, err := s.fetchEncryptStatusConcurrently(ctx)
+		var pending []string
+		if err == nil {
+			for _, node := range sortedNodes(res) {
+				if !res[node].usesKey(spi) {
+					pending = append(pending, node)
+				}
+			}
+			if len(pending) == 0 {
+				return res, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return res, fmt.Errorf("timeout waiting for nodes to use the new key: %w", err)
+			}
+			return res, fmt.Errorf("timeout waiting for nodes to use the new key, pending nodes: %s", strings.Join(pending, ", "))
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+func (st *NodeStatus) usesKey(spi int64) bool {
+	if st.IPsec == nil {
+		return false
+	}
+	for _, id := range st.IPsec.KeyIDs {
+		if id == spi {
+			return true
+		}
+	}
+	return false
+}
+
+// reportXfrmErrors reports the XFRM error counters which increased on each
+// node between the two given statuses.
+func (s *Status) reportXfrmErrors(before, after map[string]*NodeStatus) {
+	increased := false
+	for _, node := range sortedNodes(after) {
+		prev, ok := before[node]
+		if !ok || prev.IPsec == nil || after[node].IPsec == nil {
+			continue
+		}
+
+		var errs []string
+		for name, v := range after[node].IPsec.XfrmErrors {
+			if prevV := prev.IPsec.XfrmErrors[name]; v > prevV {
+				errs = append(errs, fmt.Sprintf("%s +%d", name, v-prevV))
+			}
+		}
+		if len(errs) > 0 {
+			sort.Strings(errs)
+			s.logf("⚠️  XFRM errors increased on node %s during the rotation: %s", node, strings.Join(errs, ", "))
+			increased = true
+		}
+	}
+	if !increased && len(after) > 0 {
+		s.logf("✅ No XFRM error occurred during the rotation")
+	}
+}
+
+func sortedNodes(res map[string]*NodeStatus) []string {
+	nodes := make([]string, 0, len(res))
+	for node := range res {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+func (s *Status) logf(format string, a ...interface{}) {
+	fmt.Fprintf(s.params.Writer, format+"\n", a...)
+}
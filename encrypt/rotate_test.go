@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cilium/cilium-cli/defaults"
+)
+
+// ipsecNodeOutputs returns the outputs of the commands run in the agent of a
+// node using the given IPsec SPIs, with the given number of XFRM errors.
+func ipsecNodeOutputs(t *testing.T, xfrmErrors int, spis ...int) map[string]string {
+	var state strings.Builder
+	for _, spi := range spis {
+		fmt.Fprintf(&state, "src 10.0.0.1 dst 10.0.1.1\n\tproto esp spi 0x%08x reqid 1 mode tunnel\n", spi)
+	}
+	return map[string]string{
+		"cilium status -o json": statusJSON(t, &models.EncryptionStatus{Mode: modeIPsec}),
+		"ip xfrm state":         state.String(),
+		"ip xfrm policy":        xfrmPolicyOutput,
+		"cilium metrics list -ojson -pcilium_ipsec_xfrm_error": fmt.Sprintf(
+			`[{"name": "cilium_ipsec_xfrm_error", "labels": {"error": "no_state", "type": "inbound"}, "value": %d}]`, xfrmErrors),
+	}
+}
+
+func TestParseIPsecKey(t *testing.T) {
+	for _, key := range []string{
+		"3 rfc4106(gcm(aes)) 0123456789abcdef0123456789abcdef01234567 128",
+		"15+ rfc4106(gcm(aes)) 0123456789abcdef0123456789abcdef01234567 128",
+		"1 hmac(sha256) 0x0123456789abcdef0123456789abcdef cbc(aes) 0x0123456789abcdef0123456789abcdef",
+	} {
+		k, err := parseIPsecKey(key)
+		require.NoError(t, err)
+		require.Equal(t, key, k.String())
+
+		next, err := k.rotate()
+		require.NoError(t, err)
+		require.Equal(t, k.spi%maxSPI+1, next.spi)
+		require.Equal(t, k.suffix, next.suffix)
+
+		// Only the key material changes, keeping its length.
+		require.Len(t, next.fields, len(k.fields))
+		for i := range k.fields {
+			if i%2 == 1 {
+				require.NotEqual(t, k.fields[i], next.fields[i])
+				require.Len(t, next.fields[i], len(k.fields[i]))
+			} else {
+				require.Equal(t, k.fields[i], next.fields[i])
+			}
+		}
+	}
+
+	for _, key := range []string{
+		"",
+		"0 rfc4106(gcm(aes)) 0123 128",
+		"16 rfc4106(gcm(aes)) 0123 128",
+		"x rfc4106(gcm(aes)) 0123 128",
+		"3 rfc4106(gcm(aes)) 0123",
+	} {
+		_, err := parseIPsecKey(key)
+		require.Error(t, err, key)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	client := &fakeClient{
+		nodes: []string{"node-1", "node-2"},
+		outputs: map[string]map[string]string{
+			"node-1": ipsecNodeOutputs(t, 0, 3),
+			"node-2": ipsecNodeOutputs(t, 1, 3),
+		},
+		secret: &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: defaults.EncryptionSecretName},
+			Data:       map[string][]byte{"keys": []byte("3+ rfc4106(gcm(aes)) 0123456789abcdef0123456789abcdef01234567 128")},
+		},
+	}
+	// node-1 picks up the new key right away, while node-2 only does so on
+	// the next poll, after hitting XFRM errors.
+	polls := 0
+	client.onPatch = func() {
+		client.setOutputs("node-1", ipsecNodeOutputs(t, 0, 3, 4))
+	}
+
+	out := &bytes.Buffer{}
+	s := NewStatus(client, Parameters{CiliumNamespace: "kube-system", Writer: out, WaitDuration: time.Minute})
+	s.pollInterval = time.Millisecond
+	s.client = &pollingClient{fakeClient: client, onPoll: func() {
+		polls++
+		if polls == 2 {
+			client.setOutputs("node-2", ipsecNodeOutputs(t, 3, 3, 4))
+		}
+	}}
+	require.NoError(t, s.RotateKey(context.Background()))
+
+	require.Len(t, client.patches, 1)
+	var patch struct {
+		Data struct {
+			Keys []byte `json:"keys"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(client.patches[0]), &patch))
+	key, err := parseIPsecKey(string(patch.Data.Keys))
+	require.NoError(t, err)
+	require.Equal(t, int64(4), key.spi)
+	require.Equal(t, "+", key.suffix)
+
+	require.Contains(t, out.String(), "🔑 Rotated IPsec key in secret kube-system/cilium-ipsec-keys from SPI 3 to SPI 4")
+	require.Contains(t, out.String(), "⚠️  XFRM errors increased on node node-2 during the rotation: inbound/no_state +2")
+	require.Contains(t, out.String(), "✅ All 2 nodes use the new key with SPI 4")
+}
+
+func TestRotateKeyInProgress(t *testing.T) {
+	client := &fakeClient{
+		nodes: []string{"node-1", "node-2"},
+		outputs: map[string]map[string]string{
+			"node-1": ipsecNodeOutputs(t, 0, 3, 4),
+			"node-2": ipsecNodeOutputs(t, 0, 3),
+		},
+	}
+
+	s := NewStatus(client, Parameters{CiliumNamespace: "kube-system", Writer: &bytes.Buffer{}, WaitDuration: time.Minute})
+	require.ErrorContains(t, s.RotateKey(context.Background()), "node node-1 uses several IPsec keys [3,4]")
+	require.Empty(t, client.patches)
+
+	client.setOutputs("node-1", map[string]string{
+		"cilium status -o json": statusJSON(t, &models.EncryptionStatus{Mode: modeWireguard}),
+	})
+	s = NewStatus(client, Parameters{CiliumNamespace: "kube-system", Writer: &bytes.Buffer{}, WaitDuration: time.Minute})
+	require.ErrorContains(t, s.RotateKey(context.Background()), "IPsec encryption is not enabled on node node-1")
+	require.Empty(t, client.patches)
+}
+
+func TestRotateKeyTimeout(t *testing.T) {
+	client := &fakeClient{
+		nodes: []string{"node-1", "node-2"},
+		outputs: map[string]map[string]string{
+			"node-1": ipsecNodeOutputs(t, 0, 3),
+			"node-2": ipsecNodeOutputs(t, 0, 3),
+		},
+		secret: &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: defaults.EncryptionSecretName},
+			Data:       map[string][]byte{"keys": []byte("3 rfc4106(gcm(aes)) 0123456789abcdef0123456789abcdef01234567 128")},
+		},
+	}
+	client.onPatch = func() {
+		client.setOutputs("node-2", ipsecNodeOutputs(t, 0, 3, 4))
+	}
+
+	s := NewStatus(client, Parameters{CiliumNamespace: "kube-system", Writer: &bytes.Buffer{}, WaitDuration: 50 * time.Millisecond})
+	s.pollInterval = time.Millisecond
+	require.ErrorContains(t, s.RotateKey(context.Background()), "pending nodes: node-1")
+}
+
+// pollingClient calls onPoll before each round of status requests, i.e. each
+// time the status of the first node is requested.
+type pollingClient struct {
+	*fakeClient
+	onPoll func()
+}
+
+func (c *pollingClient) ExecInPod(ctx context.Context, namespace, pod, container string, command []string) (bytes.Buffer, error) {
+	if pod == "cilium-"+c.nodes[0] && strings.Join(command, " ") == "cilium status -o json" {
+		c.onPoll()
+	}
+	return c.fakeClient.ExecInPod(ctx, namespace, pod, container, command)
+}
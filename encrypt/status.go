@@ -0,0 +1,395 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package encrypt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cilium/cilium/api/v1/models"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/cilium/cilium-cli/defaults"
+	"github.com/cilium/cilium-cli/status"
+)
+
+const (
+	padding     = 3
+	minWidth    = 5
+	paddingChar = ' '
+
+	modeIPsec     = "IPsec"
+	modeWireguard = "Wireguard"
+)
+
+// NodeStatus is the encryption state of a single node.
+type NodeStatus struct {
+	Mode      string                  `json:"mode"`
+	Msg       string                  `json:"msg,omitempty"`
+	Wireguard *models.WireguardStatus `json:"wireguard,omitempty"`
+	IPsec     *IPsecStatus            `json:"ipsec,omitempty"`
+
+	// Inconsistencies lists how the node disagrees with the rest of the
+	// cluster.
+	Inconsistencies []string `json:"inconsistencies,omitempty"`
+}
+
+// IPsecStatus is the IPsec state of a single node.
+type IPsecStatus struct {
+	KeyIDs       []int64           `json:"key-ids"`
+	XfrmStates   int               `json:"xfrm-states"`
+	XfrmPolicies int               `json:"xfrm-policies"`
+	XfrmErrors   map[string]uint64 `json:"xfrm-errors,omitempty"`
+}
+
+type ciliumMetricsXfrmError struct {
+	Labels struct {
+		Error string `json:"error"`
+		Type  string `json:"type"`
+	} `json:"labels"`
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+var spiRegexp = regexp.MustCompile(`\bspi 0x([0-9a-fA-F]+)`)
+
+// GetEncryptStatus gets encryption state from all/specific cilium agent pods.
+func (s *Status) GetEncryptStatus(ctx context.Context) error {
+	ctx, cancelFn := context.WithTimeout(ctx, s.params.WaitDuration)
+	defer cancelFn()
+
+	err := s.initTargetCiliumPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.fetchEncryptStatusConcurrently(ctx)
+	flagInconsistencies(res)
+	if werr := s.writeStatus(res); werr != nil {
+		return werr
+	}
+
+	return err
+}
+
+func (s *Status) fetchEncryptStatusConcurrently(ctx context.Context) (map[string]*NodeStatus, error) {
+	allFetchedData := make(map[string]*NodeStatus)
+
+	// res contains data returned from cilium pod
+	type res struct {
+		nodeName string
+		data     *NodeStatus
+		err      error
+	}
+	resCh := make(chan res)
+
+	var wg sync.WaitGroup
+
+	// max number of concurrent go routines will be number of cilium agent pods
+	wg.Add(len(s.ciliumPods))
+
+	// concurrently fetch state from each cilium pod
+	for _, pod := range s.ciliumPods {
+		go func(ctx context.Context, pod *corev1.Pod) {
+			defer wg.Done()
+
+			st, err := s.fetchEncryptStatusFromPod(ctx, pod)
+			resCh <- res{
+				nodeName: pod.Spec.NodeName,
+				data:     st,
+				err:      err,
+			}
+		}(ctx, pod)
+	}
+
+	// close resCh when data from all nodes is collected
+	go func() {
+		wg.Wait()
+		close(resCh)
+	}()
+
+	// read from the channel till it is closed.
+	// on error, store error and continue to next node.
+	var err error
+	for fetchedData := range resCh {
+		if fetchedData.err != nil {
+			err = errors.Join(err, fetchedData.err)
+		} else {
+			allFetchedData[fetchedData.nodeName] = fetchedData.data
+		}
+	}
+
+	return allFetchedData, err
+}
+
+func (s *Status) execInPod(ctx context.Context, pod *corev1.Pod, cmd ...string) ([]byte, error) {
+	output, err := s.client.ExecInPod(ctx, pod.Namespace, pod.Name, defaults.AgentContainerName, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %q in %s: %w", strings.Join(cmd, " "), pod.Name, err)
+	}
+	return output.Bytes(), nil
+}
+
+func (s *Status) fetchEncryptStatusFromPod(ctx context.Context, pod *corev1.Pod) (*NodeStatus, error) {
+	output, err := s.execInPod(ctx, pod, "cilium", "status", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var sr models.StatusResponse
+	if err := json.Unmarshal(output, &sr); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal status from %s: %w", pod.Name, err)
+	}
+	if sr.Encryption == nil {
+		return nil, fmt.Errorf("no encryption status reported by %s", pod.Name)
+	}
+
+	st := &NodeStatus{
+		Mode:      sr.Encryption.Mode,
+		Msg:       sr.Encryption.Msg,
+		Wireguard: sr.Encryption.Wireguard,
+	}
+	if st.Mode != modeIPsec {
+		return st, nil
+	}
+
+	st.IPsec = &IPsecStatus{}
+	output, err = s.execInPod(ctx, pod, "ip", "xfrm", "state")
+	if err != nil {
+		return nil, err
+	}
+	st.IPsec.XfrmStates, st.IPsec.KeyIDs = parseXfrm(output)
+
+	output, err = s.execInPod(ctx, pod, "ip", "xfrm", "policy")
+	if err != nil {
+		return nil, err
+	}
+	st.IPsec.XfrmPolicies, _ = parseXfrm(output)
+
+	output, err = s.execInPod(ctx, pod, "cilium", "metrics", "list", "-ojson", "-pcilium_ipsec_xfrm_error")
+	if err != nil {
+		return nil, err
+	}
+	var xfrmMetrics []ciliumMetricsXfrmError
+	if err := json.Unmarshal(output, &xfrmMetrics); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal xfrm error metrics from %s: %w", pod.Name, err)
+	}
+	for _, m := range xfrmMetrics {
+		if m.Value == 0 {
+			continue
+		}
+		if st.IPsec.XfrmErrors == nil {
+			st.IPsec.XfrmErrors = make(map[string]uint64)
+		}
+		st.IPsec.XfrmErrors[m.Labels.Type+"/"+m.Labels.Error] = m.Value
+	}
+
+	return st, nil
+}
+
+// parseXfrm parses the output of 'ip xfrm state' or 'ip xfrm policy', and
+// returns the number of entries along with the sorted, deduplicated SPIs they
+// reference. Cilium uses the IPsec key ID as SPI.
+func parseXfrm(output []byte) (int, []int64) {
+	count := 0
+	spis := make(map[int64]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "src ") {
+			count++
+		}
+		if m := spiRegexp.FindStringSubmatch(line); m != nil {
+			if spi, err := strconv.ParseInt(m[1], 16, 64); err == nil {
+				spis[spi] = struct{}{}
+			}
+		}
+	}
+
+	keyIDs := make([]int64, 0, len(spis))
+	for spi := range spis {
+		keyIDs = append(keyIDs, spi)
+	}
+	sort.Slice(keyIDs, func(i, j int) bool { return keyIDs[i] < keyIDs[j] })
+	return count, keyIDs
+}
+
+// peerCount returns the number of WireGuard peers of the node, across all its
+// interfaces.
+func (st *NodeStatus) peerCount() int64 {
+	var count int64
+	if st.Wireguard != nil {
+		for _, iface := range st.Wireguard.Interfaces {
+			count += iface.PeerCount
+		}
+	}
+	return count
+}
+
+// oldestHandshake returns the time of the least recent handshake among the
+// WireGuard peers of the node, and false if the node has no peer.
+func (st *NodeStatus) oldestHandshake() (time.Time, bool) {
+	var oldest time.Time
+	found := false
+	if st.Wireguard != nil {
+		for _, iface := range st.Wireguard.Interfaces {
+			for _, peer := range iface.Peers {
+				t := time.Time(peer.LastHandshakeTime)
+				if !found || t.Before(oldest) {
+					oldest = t
+					found = true
+				}
+			}
+		}
+	}
+	return oldest, found
+}
+
+func (st *NodeStatus) keyIDs() string {
+	if st.IPsec == nil {
+		return ""
+	}
+	ids := make([]string, 0, len(st.IPsec.KeyIDs))
+	for _, id := range st.IPsec.KeyIDs {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(ids, ",")
+}
+
+func (st *NodeStatus) xfrmErrors() uint64 {
+	var count uint64
+	if st.IPsec != nil {
+		for _, v := range st.IPsec.XfrmErrors {
+			count += v
+		}
+	}
+	return count
+}
+
+// flagInconsistencies records on each node how its encryption mode, WireGuard
+// peer count and IPsec key IDs differ from those of most nodes in the
+// cluster.
+func flagInconsistencies(res map[string]*NodeStatus) {
+	modes := make(map[string]string)
+	for node, st := range res {
+		modes[node] = st.Mode
+	}
+	mode := majority(modes)
+
+	peerCounts := make(map[string]string)
+	keyIDs := make(map[string]string)
+	for node, st := range res {
+		if st.Mode != mode {
+			st.Inconsistencies = append(st.Inconsistencies,
+				fmt.Sprintf("encryption mode %s differs from %s on the rest of the cluster", st.Mode, mode))
+			continue
+		}
+		switch st.Mode {
+		case modeWireguard:
+			peerCounts[node] = strconv.FormatInt(st.peerCount(), 10)
+		case modeIPsec:
+			keyIDs[node] = st.keyIDs()
+		}
+	}
+
+	peerCount := majority(peerCounts)
+	for node, count := range peerCounts {
+		if count != peerCount {
+			res[node].Inconsistencies = append(res[node].Inconsistencies,
+				fmt.Sprintf("WireGuard peer count %s differs from %s on the rest of the cluster", count, peerCount))
+		}
+	}
+
+	keyID := majority(keyIDs)
+	for node, ids := range keyIDs {
+		if ids != keyID {
+			res[node].Inconsistencies = append(res[node].Inconsistencies,
+				fmt.Sprintf("IPsec key IDs [%s] differ from [%s] on the rest of the cluster", ids, keyID))
+		}
+	}
+}
+
+// majority returns the most common value, the smallest one in case of a tie.
+func majority(values map[string]string) string {
+	counts := make(map[string]int)
+	for _, v := range values {
+		counts[v]++
+	}
+
+	var best string
+	bestCount := 0
+	for v, c := range counts {
+		if c > bestCount || (c == bestCount && v < best) {
+			best, bestCount = v, c
+		}
+	}
+	return best
+}
+
+func (s *Status) writeStatus(res map[string]*NodeStatus) error {
+	if s.params.Output == status.OutputJSON {
+		jsonStatus, err := json.MarshalIndent(res, "", " ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(s.params.Writer, string(jsonStatus))
+	} else {
+		s.printSummary(s.params.Writer, res)
+	}
+
+	return nil
+}
+
+func (s *Status) printSummary(out io.Writer, res map[string]*NodeStatus) {
+	nodes := sortedNodes(res)
+
+	// tab writer with min width 5 and padding 3
+	w := tabwriter.NewWriter(out, minWidth, 0, padding, paddingChar, 0)
+	fmt.Fprintln(w, "Node\tMode\tPeers\tOldest Handshake\tKey IDs\tXFRM States\tXFRM Policies\tXFRM Errors")
+
+	for _, node := range nodes {
+		st := res[node]
+		peers, handshake := "-", "-"
+		if st.Mode == modeWireguard {
+			peers = strconv.FormatInt(st.peerCount(), 10)
+			if t, ok := st.oldestHandshake(); ok {
+				if t.IsZero() {
+					handshake = "never"
+				} else {
+					handshake = s.now().Sub(t).Round(time.Second).String() + " ago"
+				}
+			}
+		}
+		keyIDs, states, policies, xfrmErrors := "-", "-", "-", "-"
+		if st.IPsec != nil {
+			keyIDs = st.keyIDs()
+			states = strconv.Itoa(st.IPsec.XfrmStates)
+			policies = strconv.Itoa(st.IPsec.XfrmPolicies)
+			xfrmErrors = strconv.FormatUint(st.xfrmErrors(), 10)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", node, st.Mode, peers, handshake, keyIDs, states, policies, xfrmErrors)
+	}
+	w.Flush()
+
+	for _, node := range nodes {
+		st := res[node]
+		if st.Msg != "" {
+			fmt.Fprintf(out, "ℹ️  %s: %s\n", node, st.Msg)
+		}
+		for _, inconsistency := range st.Inconsistencies {
+			fmt.Fprintf(out, "⚠️  %s: %s\n", node, inconsistency)
+		}
+	}
+}
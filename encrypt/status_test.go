@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/go-openapi/strfmt"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/cilium/cilium-cli/status"
+)
+
+const xfrmStateOutput = `src 10.0.0.1 dst 10.0.1.1
+	proto esp spi 0x00000003 reqid 1 mode tunnel
+	replay-window 0
+	mark 0x3e00/0xff00 output-mark 0xe00/0xf00
+	aead rfc4106(gcm(aes)) 0x0102 128
+	anti-replay context: seq 0x0, oseq 0x2, bitmap 0x00000000
+	sel src 0.0.0.0/0 dst 0.0.0.0/0
+src 10.0.1.1 dst 10.0.0.1
+	proto esp spi 0x00000004 reqid 1 mode tunnel
+	replay-window 0
+	mark 0xd00/0xf00 output-mark 0xd00/0xf00
+	aead rfc4106(gcm(aes)) 0x0102 128
+	sel src 0.0.0.0/0 dst 0.0.0.0/0
+`
+
+const xfrmPolicyOutput = `src 10.0.0.0/24 dst 10.0.1.0/24
+	dir out priority 0
+	mark 0x3e00/0xff00
+	tmpl src 10.0.0.1 dst 10.0.1.1
+		proto esp spi 0x00000003 reqid 1 mode tunnel
+src 0.0.0.0/0 dst 10.0.0.0/24
+	dir in priority 0
+	mark 0xd00/0xf00
+	tmpl src 0.0.0.0 dst 10.0.0.1
+		proto esp reqid 1 mode tunnel
+src 0.0.0.0/0 dst 0.0.0.0/0
+	socket out priority 0
+`
+
+type fakeClient struct {
+	nodes []string
+
+	mu      sync.Mutex
+	outputs map[string]map[string]string // node -> command -> output
+
+	secret  *corev1.Secret
+	patches []string
+	onPatch func()
+}
+
+func (c *fakeClient) GetSecret(_ context.Context, namespace, name string, _ metav1.GetOptions) (*corev1.Secret, error) {
+	if c.secret == nil || c.secret.Namespace != namespace || c.secret.Name != name {
+		return nil, fmt.Errorf("secret %s/%s not found", namespace, name)
+	}
+	return c.secret, nil
+}
+
+func (c *fakeClient) PatchSecret(_ context.Context, _, _ string, _ types.PatchType, data []byte, _ metav1.PatchOptions) (*corev1.Secret, error) {
+	c.patches = append(c.patches, string(data))
+	if c.onPatch != nil {
+		c.onPatch()
+	}
+	return c.secret, nil
+}
+
+func (c *fakeClient) ListPods(_ context.Context, namespace string, options metav1.ListOptions) (*corev1.PodList, error) {
+	pods := &corev1.PodList{}
+	for _, node := range c.nodes {
+		if options.FieldSelector != "" && options.FieldSelector != "spec.nodeName="+node {
+			continue
+		}
+		pods.Items = append(pods.Items, corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "cilium-" + node},
+			Spec:       corev1.PodSpec{NodeName: node},
+		})
+	}
+	return pods, nil
+}
+
+func (c *fakeClient) ExecInPod(_ context.Context, _, pod, _ string, command []string) (bytes.Buffer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	node := strings.TrimPrefix(pod, "cilium-")
+	out, ok := c.outputs[node][strings.Join(command, " ")]
+	if !ok {
+		return bytes.Buffer{}, fmt.Errorf("command terminated with exit code 1")
+	}
+	return *bytes.NewBufferString(out), nil
+}
+
+func (c *fakeClient) setOutputs(node string, outputs map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.outputs[node] = outputs
+}
+
+func statusJSON(t *testing.T, enc *models.EncryptionStatus) string {
+	b, err := json.Marshal(models.StatusResponse{Encryption: enc})
+	require.NoError(t, err)
+	return string(b)
+}
+
+func wireguardStatus(handshake time.Time, peers int) *models.EncryptionStatus {
+	iface := &models.WireguardInterface{Name: "cilium_wg0", ListenPort: 51871, PeerCount: int64(peers)}
+	for i := 0; i < peers; i++ {
+		iface.Peers = append(iface.Peers, &models.WireguardPeer{
+			PublicKey:         fmt.Sprintf("key-%d", i),
+			LastHandshakeTime: strfmt.DateTime(handshake.Add(time.Duration(i) * time.Minute)),
+		})
+	}
+	return &models.EncryptionStatus{
+		Mode:      modeWireguard,
+		Wireguard: &models.WireguardStatus{Interfaces: []*models.WireguardInterface{iface}},
+	}
+}
+
+func TestParseXfrm(t *testing.T) {
+	count, keyIDs := parseXfrm([]byte(xfrmStateOutput))
+	require.Equal(t, 2, count)
+	require.Equal(t, []int64{3, 4}, keyIDs)
+
+	count, keyIDs = parseXfrm([]byte(xfrmPolicyOutput))
+	require.Equal(t, 3, count)
+	require.Equal(t, []int64{3}, keyIDs)
+
+	count, keyIDs = parseXfrm(nil)
+	require.Equal(t, 0, count)
+	require.Empty(t, keyIDs)
+}
+
+func TestFlagInconsistencies(t *testing.T) {
+	res := map[string]*NodeStatus{
+		"node-1": {Mode: modeIPsec, IPsec: &IPsecStatus{KeyIDs: []int64{3}}},
+		"node-2": {Mode: modeIPsec, IPsec: &IPsecStatus{KeyIDs: []int64{3}}},
+		"node-3": {Mode: modeIPsec, IPsec: &IPsecStatus{KeyIDs: []int64{3, 4}}},
+		"node-4": {Mode: "Disabled"},
+	}
+	flagInconsistencies(res)
+
+	require.Empty(t, res["node-1"].Inconsistencies)
+	require.Empty(t, res["node-2"].Inconsistencies)
+	require.Equal(t, []string{"IPsec key IDs [3,4] differ from [3] on the rest of the cluster"}, res["node-3"].Inconsistencies)
+	require.Equal(t, []string{"encryption mode Disabled differs from IPsec on the rest of the cluster"}, res["node-4"].Inconsistencies)
+}
+
+func TestMajority(t *testing.T) {
+	require.Equal(t, "", majority(nil))
+	require.Equal(t, "2", majority(map[string]string{"a": "2", "b": "2", "c": "1"}))
+	// Ties are broken deterministically.
+	require.Equal(t, "1", majority(map[string]string{"a": "2", "b": "1"}))
+}
+
+func TestGetEncryptStatusWireguard(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	client := &fakeClient{
+		nodes: []string{"node-1", "node-2", "node-3"},
+		outputs: map[string]map[string]string{
+			"node-1": {"cilium status -o json": statusJSON(t, wireguardStatus(now.Add(-5*time.Minute), 2))},
+			"node-2": {"cilium status -o json": statusJSON(t, wireguardStatus(now.Add(-2*time.Minute), 2))},
+			"node-3": {"cilium status -o json": statusJSON(t, wireguardStatus(time.Time{}, 1))},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	s := NewStatus(client, Parameters{Writer: out, WaitDuration: time.Minute, Output: status.OutputSummary})
+	s.now = func() time.Time { return now }
+	require.NoError(t, s.GetEncryptStatus(context.Background()))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 5)
+	require.Equal(t, []string{"node-1", "Wireguard", "2", "5m0s", "ago", "-", "-", "-", "-"}, strings.Fields(lines[1]))
+	require.Equal(t, []string{"node-2", "Wireguard", "2", "2m0s", "ago", "-", "-", "-", "-"}, strings.Fields(lines[2]))
+	require.Equal(t, []string{"node-3", "Wireguard", "1", "never", "-", "-", "-", "-"}, strings.Fields(lines[3]))
+	require.Equal(t, "⚠️  node-3: WireGuard peer count 1 differs from 2 on the rest of the cluster", lines[4])
+}
+
+func TestGetEncryptStatusIPsec(t *testing.T) {
+	ipsecOutputs := func(state string) map[string]string {
+		return map[string]string{
+			"cilium status -o json": statusJSON(t, &models.EncryptionStatus{Mode: modeIPsec}),
+			"ip xfrm state":         state,
+			"ip xfrm policy":        xfrmPolicyOutput,
+			"cilium metrics list -ojson -pcilium_ipsec_xfrm_error": `[
+				{"name": "cilium_ipsec_xfrm_error", "labels": {"error": "no_state", "type": "inbound"}, "value": 2},
+				{"name": "cilium_ipsec_xfrm_error", "labels": {"error": "state_protocol", "type": "inbound"}, "value": 0}
+			]`,
+		}
+	}
+	client := &fakeClient{
+		nodes: []string{"node-1", "node-2"},
+		outputs: map[string]map[string]string{
+			"node-1": ipsecOutputs(xfrmStateOutput),
+			"node-2": ipsecOutputs(xfrmStateOutput),
+		},
+	}
+
+	out := &bytes.Buffer{}
+	s := NewStatus(client, Parameters{Writer: out, WaitDuration: time.Minute, Output: status.OutputJSON})
+	require.NoError(t, s.GetEncryptStatus(context.Background()))
+
+	var res map[string]*NodeStatus
+	require.NoError(t, json.Unmarshal(out.Bytes(), &res))
+	require.Len(t, res, 2)
+	require.Equal(t, &NodeStatus{
+		Mode: modeIPsec,
+		IPsec: &IPsecStatus{
+			KeyIDs:       []int64{3, 4},
+			XfrmStates:   2,
+			XfrmPolicies: 3,
+			XfrmErrors:   map[string]uint64{"inbound/no_state": 2},
+		},
+	}, res["node-1"])
+
+	// Restricting to a single node only queries its agent.
+	out.Reset()
+	s = NewStatus(client, Parameters{Writer: out, NodeName: "node-2", WaitDuration: time.Minute, Output: status.OutputSummary})
+	require.NoError(t, s.GetEncryptStatus(context.Background()))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, []string{"node-2", "IPsec", "-", "-", "3,4", "2", "3", "2"}, strings.Fields(lines[1]))
+}
+
+func TestGetEncryptStatusError(t *testing.T) {
+	client := &fakeClient{
+		nodes: []string{"node-1", "node-2"},
+		outputs: map[string]map[string]string{
+			"node-1": {"cilium status -o json": statusJSON(t, &models.EncryptionStatus{Mode: "Disabled"})},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	s := NewStatus(client, Parameters{Writer: out, WaitDuration: time.Minute, Output: status.OutputSummary})
+	err := s.GetEncryptStatus(context.Background())
+	require.ErrorContains(t, err, "cilium-node-2")
+
+	// The status of the other nodes is still reported.
+	require.Contains(t, out.String(), "node-1")
+}
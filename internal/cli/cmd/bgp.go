@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"context"
+	"os"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -21,7 +22,11 @@ func newCmdBgp() *cobra.Command {
 		Long:  ``,
 	}
 
-	cmd.AddCommand(newCmdBgpPeers())
+	cmd.AddCommand(
+		newCmdBgpPeers(),
+		newCmdBgpRoutes(),
+		newCmdBgpValidate(),
+	)
 
 	return cmd
 }
@@ -53,3 +58,78 @@ func newCmdBgpPeers() *cobra.Command {
 
 	return cmd
 }
+
+func newCmdBgpRoutes() *cobra.Command {
+	params := bgp.Parameters{}
+
+	cmd := &cobra.Command{
+		Use:   "routes [available|advertised]",
+		Short: "Lists BGP routes",
+		Long: `This command lists the BGP routes from all nodes in the cluster.
+
+Available routes are the routes in the local RIB of each node (default), while advertised
+routes are the routes advertised to the peers, optionally restricted to a single peer.`,
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{bgp.RoutesAvailable, bgp.RoutesAdvertised},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params.CiliumNamespace = namespace
+
+			tableType := bgp.RoutesAvailable
+			if len(args) > 0 {
+				tableType = args[0]
+			}
+
+			s := bgp.NewStatus(k8sClient, params)
+			err := s.GetRoutes(context.Background(), tableType)
+			if err != nil {
+				fatalf("Unable to get BGP routes: %s", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&params.AgentPodSelector, "agent-pod-selector", defaults.AgentPodSelector, "Label on cilium-agent pods to select with")
+	cmd.Flags().StringVar(&params.NodeName, "node", "", "Node from which BGP routes will be fetched, omit to select all nodes")
+	cmd.Flags().StringVar(&params.PeerAddress, "peer", "", "Peer to which advertised routes will be fetched, omit to select all peers")
+	cmd.Flags().StringVar(&params.AFI, "afi", "ipv4", "Address family of the routes. One of: ipv4, ipv6")
+	cmd.Flags().StringVar(&params.SAFI, "safi", "unicast", "Subsequent address family of the routes")
+	cmd.Flags().DurationVar(&params.WaitDuration, "wait-duration", 1*time.Minute, "Maximum time to wait for result, default 1 minute")
+	cmd.Flags().StringVarP(&params.Output, "output", "o", status.OutputSummary, "Output format. One of: json, summary")
+
+	return cmd
+}
+
+func newCmdBgpValidate() *cobra.Command {
+	params := bgp.Parameters{}
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate BGP peering policies",
+		Long: `This command cross-checks the CiliumBGPPeeringPolicies against the cluster, looking for
+node selectors matching no node, nodes selected by several policies, duplicate or
+conflicting local ASNs, invalid neighbors and service selectors matching no LoadBalancer
+service. The peers intended by the policies are also compared with the live BGP sessions
+of the agents.
+
+The command fails if any error is found.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params.CiliumNamespace = namespace
+			params.Writer = os.Stdout
+
+			s := bgp.NewStatus(k8sClient, params)
+			err := s.Validate(context.Background())
+			if err != nil {
+				fatalf("Unable to validate BGP peering policies: %s", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&params.AgentPodSelector, "agent-pod-selector", defaults.AgentPodSelector, "Label on cilium-agent pods to select with")
+	cmd.Flags().DurationVar(&params.WaitDuration, "wait-duration", 1*time.Minute, "Maximum time to wait for result, default 1 minute")
+	cmd.Flags().StringVarP(&params.Output, "output", "o", status.OutputSummary, "Output format. One of: json, summary")
+
+	return cmd
+}
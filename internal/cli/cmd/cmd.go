@@ -13,6 +13,10 @@ import (
 	"github.com/cilium/cilium-cli/k8s"
 )
 
+// skipK8sClientAnnotation is the annotation of commands which don't require
+// the Kubernetes client, such as commands working on local files.
+const skipK8sClientAnnotation = "cilium.io/skip-k8s-client"
+
 var (
 	contextName string
 	namespace   string
@@ -39,6 +43,9 @@ func NewCiliumCommand(hooks Hooks) *cobra.Command {
 			case "completion", "help":
 				return nil
 			}
+			if _, ok := cmd.Annotations[skipK8sClientAnnotation]; ok {
+				return nil
+			}
 
 			c, err := k8s.NewClient(contextName, "", namespace)
 			if err != nil {
@@ -83,7 +90,9 @@ cilium connectivity test`,
 		newCmdConfig(),
 		newCmdConnectivity(hooks),
 		newCmdContext(),
+		newCmdEncrypt(),
 		newCmdHubble(),
+		newCmdPolicy(),
 		newCmdStatus(),
 		newCmdSysdump(hooks),
 		newCmdVersion(),
@@ -18,6 +18,9 @@ import (
 
 	"github.com/cilium/cilium-cli/connectivity"
 	"github.com/cilium/cilium-cli/connectivity/check"
+	"github.com/cilium/cilium-cli/connectivity/matrix"
+	"github.com/cilium/cilium-cli/connectivity/perf"
+	"github.com/cilium/cilium-cli/connectivity/probe"
 	"github.com/cilium/cilium-cli/defaults"
 	"github.com/cilium/cilium-cli/sysdump"
 )
@@ -32,6 +35,9 @@ func newCmdConnectivity(hooks Hooks) *cobra.Command {
 	}
 
 	cmd.AddCommand(newCmdConnectivityTest(hooks))
+	cmd.AddCommand(newCmdConnectivityPerf())
+	cmd.AddCommand(newCmdConnectivityProbe())
+	cmd.AddCommand(newCmdConnectivityMatrix())
 
 	return cmd
 }
@@ -113,10 +119,12 @@ func newCmdConnectivityTest(hooks Hooks) *cobra.Command {
 	cmd.Flags().BoolVar(&params.SingleNode, "single-node", false, "Limit to tests able to run on a single node")
 	cmd.Flags().BoolVar(&params.PrintFlows, "print-flows", false, "Print flow logs for each test")
 	cmd.Flags().DurationVar(&params.PostTestSleepDuration, "post-test-sleep", 0, "Wait time after each test before next test starts")
+	cmd.Flags().IntVar(&params.TestConcurrency, "test-concurrency", 1, "Number of tests to run concurrently. Tests applying policies or otherwise altering the cluster always run on their own")
 	cmd.Flags().BoolVar(&params.ForceDeploy, "force-deploy", false, "Force re-deploying test artifacts")
 	cmd.Flags().BoolVar(&params.Hubble, "hubble", true, "Automatically use Hubble for flow validation & troubleshooting")
 	cmd.Flags().StringVar(&params.HubbleServer, "hubble-server", "localhost:4245", "Address of the Hubble endpoint for flow validation")
 	cmd.Flags().StringVar(&params.TestNamespace, "test-namespace", defaults.ConnectivityCheckNamespace, "Namespace to perform the connectivity test in")
+	cmd.Flags().StringVar(&params.SecondaryTestNamespace, "secondary-test-namespace", "", "Deploy echo and client pods in this additional namespace, and run the cross-namespace policy tests against it")
 	cmd.Flags().StringVar(&params.AgentDaemonSetName, "agent-daemonset-name", defaults.AgentDaemonSetName, "Name of cilium agent daemonset")
 	cmd.Flags().StringVar(&params.AgentPodSelector, "agent-pod-selector", defaults.AgentPodSelector, "Label on cilium-agent pods to select with")
 	cmd.Flags().StringToStringVar(&params.NodeSelector, "node-selector", map[string]string{}, "Restrict connectivity test pods to nodes matching this label")
@@ -134,13 +142,18 @@ func newCmdConnectivityTest(hooks Hooks) *cobra.Command {
 	cmd.Flags().BoolVarP(&params.Timestamp, "timestamp", "t", false, "Show timestamp in messages")
 	cmd.Flags().BoolVarP(&params.PauseOnFail, "pause-on-fail", "p", false, "Pause execution on test failure")
 	cmd.Flags().StringVar(&params.ExternalTarget, "external-target", "one.one.one.one", "Domain name to use as external target in connectivity tests")
+	cmd.Flags().StringVar(&params.ExternalOtherTarget, "external-other-target", "cilium.io", "Other domain name to use as external target in connectivity tests")
 	cmd.Flags().StringVar(&params.ExternalTargetCANamespace, "external-target-ca-namespace", defaults.ConnectivityCheckNamespace, "Namespace of the CA secret for the external target. Used by client-egress-l7-tls test cases.")
 	cmd.Flags().StringVar(&params.ExternalTargetCAName, "external-target-ca-name", "cabundle", "Name of the CA secret for the external target. Used by client-egress-l7-tls test cases.")
 	cmd.Flags().StringVar(&params.ExternalCIDR, "external-cidr", "1.0.0.0/8", "CIDR to use as external target in connectivity tests")
+	cmd.Flags().StringVar(&params.BGPLoadBalancerCIDR, "bgp-lb-cidr", defaults.ConnectivityCheckBGPLoadBalancerCIDR, "IPv4 CIDR of the LoadBalancer IPs announced over BGP in the BGP tests")
 	cmd.Flags().StringVar(&params.ExternalIP, "external-ip", "1.1.1.1", "IP to use as external target in connectivity tests")
 	cmd.Flags().StringVar(&params.ExternalOtherIP, "external-other-ip", "1.0.0.1", "Other IP to use as external target in connectivity tests")
+	cmd.Flags().BoolVar(&params.Offline, "offline", false, "Deploy in-cluster stand-ins for the external targets, overriding --external-target, --external-other-target, --external-ip, --external-other-ip and --external-cidr, so that tests don't need Internet access. The external targets run on nodes without Cilium if there are two of them, and in the host network of Cilium nodes otherwise, in which case the tests relying on the world identity are less accurate")
 	cmd.Flags().StringVar(&params.JunitFile, "junit-file", "", "Generate junit report and write to file")
 	cmd.Flags().StringToStringVar(&params.JunitProperties, "junit-property", map[string]string{}, "Add key=value properties to the generated junit file")
+	cmd.Flags().StringVar(&params.JSONReportFile, "report-json", "", "Generate a JSON report of all tests, scenarios and actions and write it to file")
+	cmd.Flags().StringVar(&params.SuiteFile, "suite-file", "", "Run the tests declared in the given YAML file instead of the built-in test suite")
 	cmd.Flags().BoolVar(&params.SkipIPCacheCheck, "skip-ip-cache-check", true, "Skip IPCache check")
 	cmd.Flags().MarkHidden("skip-ip-cache-check")
 	cmd.Flags().BoolVar(&params.IncludeUnsafeTests, "include-unsafe-tests", false, "Include tests which can modify cluster nodes state")
@@ -157,11 +170,17 @@ func newCmdConnectivityTest(hooks Hooks) *cobra.Command {
 	cmd.Flags().IntVar(&params.PerfSamples, "perf-samples", 1, "Number of Performance samples to capture (how many times to run each test)")
 	cmd.Flags().BoolVar(&params.PerfCRR, "perf-crr", false, "Run Netperf CRR Test. --perf-samples and --perf-duration ignored")
 	cmd.Flags().BoolVar(&params.PerfHostNet, "host-net", false, "Use host networking during network performance tests")
+	cmd.Flags().BoolVar(&params.PerfLatency, "perf-latency", false, "Collect P50, P90 and P99 latencies of request/response Performance tests")
+	cmd.Flags().BoolVar(&params.PerfService, "perf-service", false, "Additionally run Performance tests against the ClusterIP and NodePort of a service in front of the server")
+	cmd.Flags().BoolVar(&params.PerfNodeMatrix, "perf-node-matrix", false, "Additionally run Performance tests between all pairs of nodes. Runtime grows quadratically with the number of nodes")
+	cmd.Flags().StringVar(&params.PerfReportFile, "perf-report", "", "Write the individual samples of the Performance tests to file, for use with 'cilium connectivity perf compare'")
 
 	cmd.Flags().StringVar(&params.CurlImage, "curl-image", defaults.ConnectivityCheckAlpineCurlImage, "Image path to use for curl")
 	cmd.Flags().StringVar(&params.PerformanceImage, "performance-image", defaults.ConnectivityPerformanceImage, "Image path to use for performance")
 	cmd.Flags().StringVar(&params.JSONMockImage, "json-mock-image", defaults.ConnectivityCheckJSONMockImage, "Image path to use for json mock")
+	cmd.Flags().StringVar(&params.AgnhostImage, "agnhost-image", defaults.ConnectivityCheckAgnhostImage, "Image path to use for the session affinity and graceful termination backends")
 	cmd.Flags().StringVar(&params.DNSTestServerImage, "dns-test-server-image", defaults.ConnectivityDNSTestServerImage, "Image path to use for CoreDNS")
+	cmd.Flags().StringVar(&params.FRRImage, "frr-image", defaults.ConnectivityCheckFRRImage, "Image path to use for the FRR stand-in BGP peer")
 
 	cmd.Flags().UintVar(&params.Retry, "retry", defaults.ConnectRetry, "Number of retries on connection failure to external targets")
 	cmd.Flags().DurationVar(&params.RetryDelay, "retry-delay", defaults.ConnectRetryDelay, "Delay between retries for external targets")
@@ -185,3 +204,142 @@ func newCmdConnectivityTest(hooks Hooks) *cobra.Command {
 
 	return cmd
 }
+
+func newCmdConnectivityPerf() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "perf",
+		Short: "Analyze network performance test results",
+		Long:  ``,
+	}
+
+	cmd.AddCommand(newCmdConnectivityPerfCompare())
+
+	return cmd
+}
+
+func newCmdConnectivityPerfCompare() *cobra.Command {
+	params := perf.CompareParameters{}
+
+	cmd := &cobra.Command{
+		Use:   "compare <old report> <new report>",
+		Short: "Compare two network performance reports",
+		Long: `Compare two reports written by 'cilium connectivity test --perf --perf-report <file>'.
+
+For each test, scenario, target and placement (same-node or cross-node), the
+statistics of the samples of both reports are printed. A result is reported as
+a regression if it got worse by more than the given threshold and the change
+is statistically significant according to Welch's t-test. Significance is not
+assessed for results with less than two samples, use --perf-samples to collect
+more.`,
+		Args:        cobra.ExactArgs(2),
+		Annotations: map[string]string{skipK8sClientAnnotation: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return perf.CompareFiles(os.Stdout, args[0], args[1], params)
+		},
+	}
+
+	cmd.Flags().Float64Var(&params.Threshold, "threshold", 5, "Relative change in percent a result needs to get worse by to be reported as a regression")
+	cmd.Flags().Float64Var(&params.Significance, "significance", 0.05, "P-value below which a change is considered statistically significant")
+	cmd.Flags().StringVarP(&params.Output, "output", "o", perf.OutputText, "Output format. One of: text, json")
+
+	return cmd
+}
+
+func newCmdConnectivityProbe() *cobra.Command {
+	params := probe.Parameters{
+		Writer: os.Stdout,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "probe",
+		Short: "Probe reachability between arbitrary workloads",
+		Long: `Probe the reachability of a destination from an existing pod.
+
+The probe runs curl (http), nc (tcp), dig (udp) or ping (icmp) from the source
+pod. If the pod lacks the required tool, the probe runs in an ephemeral
+container injected in the pod instead. UDP probes send a DNS query, hence the
+destination must be a DNS server. When Hubble Relay is reachable, the result is
+correlated with the observed flows to report whether and where the traffic was
+dropped, and by which policy verdict.`,
+		Example: `  # Probe the HTTP reachability of a service from a pod
+  cilium connectivity probe --from default/client --to kube-system/hubble-relay:80
+
+  # Probe the TCP reachability of a pod, in the namespace of the source pod
+  cilium connectivity probe --from default/client --to backend-0:5432 --protocol tcp`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := probe.NewProber(k8sClient, params)
+			if err != nil {
+				return err
+			}
+			return p.Run(context.Background())
+		},
+	}
+
+	cmd.Flags().StringVar(&params.From, "from", "", "Source pod of the probe, as [namespace/]pod")
+	cmd.Flags().StringVar(&params.To, "to", "", "Destination of the probe, as [namespace/]service[:port], [namespace/]pod[:port], IP[:port] or hostname[:port]")
+	cmd.Flags().StringVar(&params.Protocol, "protocol", probe.ProtocolHTTP, fmt.Sprintf("Protocol of the probe. One of: %s", strings.Join(probe.Protocols, ", ")))
+	cmd.Flags().StringVar(&params.Container, "container", "", "Container of the source pod to run the probe in. Defaults to the first container")
+	cmd.Flags().StringVar(&params.Image, "image", defaults.ConnectivityCheckAlpineCurlImage, "Image of the ephemeral container used if the source pod lacks the required tools")
+	cmd.Flags().BoolVar(&params.Ephemeral, "ephemeral", false, "Always run the probe in an ephemeral container")
+	cmd.Flags().DurationVar(&params.ConnectTimeout, "connect-timeout", defaults.ConnectTimeout, "Maximum time to allow initiation of the connection to take")
+	cmd.Flags().DurationVar(&params.RequestTimeout, "request-timeout", defaults.RequestTimeout, "Maximum time to allow a request to take")
+	cmd.Flags().BoolVar(&params.Hubble, "hubble", true, "Correlate the result with the flows observed by Hubble")
+	cmd.Flags().StringVar(&params.HubbleServer, "hubble-server", "localhost:4245", "Address of the Hubble endpoint")
+	cmd.Flags().DurationVar(&params.FlowWait, "flow-wait", 2*time.Second, "Time to wait for Hubble to observe the flows of the probe")
+
+	return cmd
+}
+
+func newCmdConnectivityMatrix() *cobra.Command {
+	params := matrix.Parameters{
+		Writer: os.Stdout,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "matrix",
+		Short: "Validate NetworkPolicy semantics with a truth table of all pod pairs",
+		Long: `Validate the enforcement of Kubernetes NetworkPolicies against their specification.
+
+Three pods a, b and c are deployed in each of three namespaces x, y and z, each
+serving ports 80 and 81 over TCP and UDP. For each case of a generated family of
+NetworkPolicies, covering namespace and pod selectors, ipBlocks with except,
+named ports, port ranges, protocols, and egress and ingress combinations, all
+pod pairs are probed on all ports, and the verdicts are compared with those
+expected from the NetworkPolicy specification in a truth table.
+
+Cases are tagged by the features they exercise. The cases tagged ip-block, which
+select pods by their IP with ipBlocks, are only run with --include-ip-block: Cilium
+only matches ipBlocks against addresses outside of the cluster, hence these cases
+are expected to fail.`,
+		Example: `  # Run all cases
+  cilium connectivity matrix
+
+  # Only run the cases exercising named ports and port ranges on ingress
+  cilium connectivity matrix --include-tags named-port,port-range --exclude-tags egress
+
+  # Also run the cases selecting pods with ipBlocks
+  cilium connectivity matrix --include-ip-block`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := matrix.NewMatrix(k8sClient, params)
+			if err != nil {
+				return err
+			}
+			return m.Run(context.Background())
+		},
+	}
+
+	cmd.Flags().StringVar(&params.NamespacePrefix, "namespace-prefix", defaults.ConnectivityCheckNamespace+"-knp", "Prefix of the x, y and z namespaces the pods are deployed in")
+	cmd.Flags().StringVar(&params.Image, "image", defaults.ConnectivityCheckAgnhostImage, "Image of the pods, serving and probing the ports")
+	cmd.Flags().StringSliceVar(&params.IncludeTags, "include-tags", nil, "Only run the cases with any of these tags")
+	cmd.Flags().StringSliceVar(&params.ExcludeTags, "exclude-tags", nil, "Skip the cases with any of these tags")
+	cmd.Flags().BoolVar(&params.IncludeIPBlock, "include-ip-block", false, "Also run the cases tagged ip-block, selecting pods with ipBlocks, which Cilium fails")
+	cmd.Flags().DurationVar(&params.PolicyWait, "policy-wait", 5*time.Second, "Time to wait for the policies of a case to be enforced before probing")
+	cmd.Flags().DurationVar(&params.ProbeTimeout, "probe-timeout", time.Second, "Maximum time to allow a probe to connect")
+	cmd.Flags().IntVar(&params.Parallel, "parallel", 20, "Number of probes to run concurrently")
+	cmd.Flags().IntVar(&params.Retries, "retries", 1, "Number of times to retry the probes not matching the expected verdict")
+	cmd.Flags().BoolVar(&params.Cleanup, "cleanup", true, "Delete the namespaces once done")
+
+	return cmd
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cilium/cilium-cli/defaults"
+	"github.com/cilium/cilium-cli/encrypt"
+	"github.com/cilium/cilium-cli/status"
+)
+
+func newCmdEncrypt() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "encryption",
+		Short: "Access to encryption state",
+		Long:  ``,
+	}
+
+	cmd.AddCommand(
+		newCmdEncryptStatus(),
+		newCmdEncryptRotateKey(),
+	)
+
+	return cmd
+}
+
+func newCmdEncryptStatus() *cobra.Command {
+	params := encrypt.Parameters{
+		Writer: os.Stdout,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Display encryption status",
+		Long: `This command displays the IPsec or WireGuard encryption state from all nodes in the cluster.
+
+Nodes whose encryption mode, WireGuard peer count or IPsec key IDs disagree
+with the rest of the cluster are flagged.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params.CiliumNamespace = namespace
+
+			s := encrypt.NewStatus(k8sClient, params)
+			err := s.GetEncryptStatus(context.Background())
+			if err != nil {
+				fatalf("Unable to get encryption status: %s", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&params.AgentPodSelector, "agent-pod-selector", defaults.AgentPodSelector, "Label on cilium-agent pods to select with")
+	cmd.Flags().StringVar(&params.NodeName, "node", "", "Node from which encryption status will be fetched, omit to select all nodes")
+	cmd.Flags().DurationVar(&params.WaitDuration, "wait-duration", 1*time.Minute, "Maximum time to wait for result, default 1 minute")
+	cmd.Flags().StringVarP(&params.Output, "output", "o", status.OutputSummary, "Output format. One of: json, summary")
+
+	return cmd
+}
+
+func newCmdEncryptRotateKey() *cobra.Command {
+	params := encrypt.Parameters{
+		Writer: os.Stdout,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "rotate-key",
+		Short: "Rotate the IPsec key",
+		Long: `This command replaces the IPsec key with a freshly generated one using the next SPI,
+and waits until all agents use it.
+
+XFRM error counters are watched during the rotation. The rotation is refused if
+IPsec is not enabled on all nodes, or if a previous rotation is still in progress.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params.CiliumNamespace = namespace
+
+			s := encrypt.NewStatus(k8sClient, params)
+			err := s.RotateKey(context.Background())
+			if err != nil {
+				fatalf("Unable to rotate IPsec key: %s", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&params.AgentPodSelector, "agent-pod-selector", defaults.AgentPodSelector, "Label on cilium-agent pods to select with")
+	cmd.Flags().DurationVar(&params.WaitDuration, "wait-duration", 10*time.Minute, "Maximum time to wait for all agents to use the new key")
+
+	return cmd
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cilium/cilium-cli/k8s"
+	"github.com/cilium/cilium-cli/policy"
+)
+
+func newCmdPolicy() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Inspect network policies",
+		Long:  ``,
+	}
+
+	cmd.AddCommand(
+		newCmdPolicyGenerate(),
+		newCmdPolicyLint(),
+		newCmdPolicyTrace(),
+	)
+
+	return cmd
+}
+
+func newCmdPolicyTrace() *cobra.Command {
+	params := policy.Parameters{}
+
+	cmd := &cobra.Command{
+		Use:   "trace",
+		Short: "Simulate the policy verdict of a flow",
+		Long: `This command evaluates which CiliumNetworkPolicy, CiliumClusterwideNetworkPolicy
+and NetworkPolicy rules allow or deny a flow, without requiring a running agent.
+Policies are fetched from the cluster, or loaded from files with --policy-file.`,
+		Example: `  # Trace a flow between two pods of the default namespace
+  cilium policy trace --src-labels app=frontend --dst-labels app=backend --port 8080/TCP
+
+  # Trace a flow from a pod to an external IP against local policy files
+  cilium policy trace --src-labels app=frontend --dst-ip 192.0.2.1 --port 443 -f policies/`,
+		// The Kubernetes client is only created without --policy-file, so
+		// that policy files can be traced without access to a cluster.
+		Annotations: map[string]string{skipK8sClientAnnotation: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params.Writer = os.Stdout
+
+			t := policy.NewTracer(nil, params)
+			if len(params.PolicyFiles) == 0 {
+				c, err := k8s.NewClient(contextName, "", namespace)
+				if err != nil {
+					return fmt.Errorf("unable to create Kubernetes client: %w", err)
+				}
+				t = policy.NewTracer(c, params)
+			}
+			if _, err := t.Trace(context.Background()); err != nil {
+				fatalf("Unable to trace policies: %s", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&params.SrcLabels, "src-labels", nil, "Labels of the source endpoint, as [source:]key=value (e.g. app=frontend, reserved:host)")
+	cmd.Flags().StringSliceVar(&params.DstLabels, "dst-labels", nil, "Labels of the destination endpoint, as [source:]key=value")
+	cmd.Flags().StringVar(&params.SrcNamespace, "src-namespace", "default", "Namespace of the source pod")
+	cmd.Flags().StringVar(&params.DstNamespace, "dst-namespace", "default", "Namespace of the destination pod")
+	cmd.Flags().StringVar(&params.SrcIP, "src-ip", "", "IP of a source outside the cluster, instead of --src-labels")
+	cmd.Flags().StringVar(&params.DstIP, "dst-ip", "", "IP of a destination outside the cluster, instead of --dst-labels")
+	cmd.Flags().StringVar(&params.Port, "port", "", "Destination port, as port[/protocol] (protocol defaults to TCP)")
+	cmd.Flags().StringSliceVarP(&params.PolicyFiles, "policy-file", "f", nil, "Files or directories to load policies from, instead of fetching them from the cluster")
+	cmd.Flags().StringVar(&params.ClusterName, "cluster-name", "default", "Name of the cluster the endpoints belong to")
+
+	return cmd
+}
+
+func newCmdPolicyLint() *cobra.Command {
+	params := policy.LintParameters{}
+
+	cmd := &cobra.Command{
+		Use:   "lint [files or directories...]",
+		Short: "Statically analyze network policies",
+		Long: `This command statically analyzes CiliumNetworkPolicy, CiliumClusterwideNetworkPolicy
+and NetworkPolicy manifests for rules which are invalid, shadowed by broader rules,
+made dead by deny rules, or which cannot be enforced.
+
+With --cluster, the policies are also checked against the state of the cluster, such
+as selectors matching no pod and fields unsupported by the running Cilium version.
+The policies are fetched from the cluster if no files are given.
+
+The command fails if any error is found, so that it can gate CI pipelines.`,
+		Example: `  # Lint policy manifests, producing a SARIF report
+  cilium policy lint policies/ -o sarif > policy-lint.sarif
+
+  # Lint the policies of the cluster
+  cilium policy lint --cluster`,
+		// The Kubernetes client is only created for --cluster, so that
+		// files can be linted without access to a cluster.
+		Annotations: map[string]string{skipK8sClientAnnotation: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params.Files = args
+			params.CiliumNamespace = namespace
+			params.Writer = os.Stdout
+			if len(params.Files) == 0 && !params.Cluster {
+				return fmt.Errorf("either policy files or --cluster must be specified")
+			}
+
+			l := policy.NewLinter(nil, params)
+			if params.Cluster {
+				c, err := k8s.NewClient(contextName, "", namespace)
+				if err != nil {
+					return fmt.Errorf("unable to create Kubernetes client: %w", err)
+				}
+				l = policy.NewLinter(c, params)
+			}
+			return l.Run(context.Background())
+		},
+	}
+
+	cmd.Flags().BoolVar(&params.Cluster, "cluster", false, "Check the policies against the state of the cluster, fetching them from the cluster if no files are given")
+	cmd.Flags().StringVar(&params.CiliumVersion, "cilium-version", "", "Cilium version to check the support of policy fields against, detected from the cluster with --cluster if not specified")
+	cmd.Flags().StringVarP(&params.Output, "output", "o", policy.LintOutputText, "Output format. One of: text, json, sarif")
+
+	return cmd
+}
+
+func newCmdPolicyGenerate() *cobra.Command {
+	params := policy.GenerateParameters{}
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate network policies from observed flows",
+		Long: `This command collects the flows observed by Hubble for the workloads of a namespace,
+and generates least-privilege CiliumNetworkPolicies allowing exactly the observed
+traffic. Peers are grouped by their identity labels, and HTTP and DNS traffic
+observed by the proxy is turned into L7 rules.
+
+Hubble Relay must be reachable, e.g. with 'cilium hubble port-forward'. The
+generated policies should be reviewed before being applied.`,
+		Example: `  # Generate policies for the workloads of the foo namespace
+  cilium hubble port-forward &
+  cilium policy generate --namespace foo --since 1h > foo-policies.yaml`,
+		// The flows are only collected from Hubble Relay.
+		Annotations: map[string]string{skipK8sClientAnnotation: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params.Writer = os.Stdout
+
+			g := policy.NewGenerator(params)
+			if err := g.Run(context.Background()); err != nil {
+				fatalf("Unable to generate policies: %s", err)
+			}
+			return nil
+		},
+	}
+
+	// --namespace shadows the global flag, as the namespace of the workloads
+	// is more relevant here than the one Cilium is running in.
+	cmd.Flags().StringVarP(&params.Namespace, "namespace", "n", "default", "Namespace of the workloads to generate policies for")
+	cmd.Flags().DurationVar(&params.Since, "since", time.Hour, "How far back to collect flows from")
+	cmd.Flags().StringVar(&params.HubbleServer, "hubble-server", "localhost:4245", "Address of the Hubble endpoint")
+
+	return cmd
+}
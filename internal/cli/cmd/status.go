@@ -6,10 +6,15 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
 
 	"github.com/cilium/cilium-cli/defaults"
 	"github.com/cilium/cilium-cli/status"
@@ -30,21 +35,46 @@ func newCmdStatus() *cobra.Command {
 				return err
 			}
 
+			if params.ServeMetrics != "" {
+				if params.Watch {
+					return errors.New("--watch and --serve-metrics are mutually exclusive")
+				}
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				fmt.Printf("Serving status metrics on %s%s\n", params.ServeMetrics, status.MetricsPath)
+				return collector.ServeMetrics(ctx, params.ServeMetrics)
+			}
+
+			if params.Watch {
+				if params.Output != status.OutputSummary {
+					return errors.New("--watch only supports the summary output format")
+				}
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				return collector.Watch(ctx, os.Stdout)
+			}
+
 			s, err := collector.Status(context.Background())
 			if err != nil {
 				// Report the most recent status even if an error occurred.
 				fmt.Fprint(os.Stderr, s.Format())
 				fatalf("Unable to determine status:  %s", err)
 			}
-			if params.Output == status.OutputJSON {
-				jsonStatus, err := json.MarshalIndent(s, "", " ")
+
+			if params.Node != "" || params.Verbose {
+				return printAgentStatuses(s, params)
+			}
+
+			switch params.Output {
+			case status.OutputJSON, status.OutputYAML:
+				out, err := marshalStatus(s, params.Output)
 				if err != nil {
 					// Report the most recent status even if an error occurred.
 					fmt.Fprint(os.Stderr, s.Format())
-					fatalf("Unable to marshal status to JSON:  %s", err)
+					fatalf("Unable to marshal status:  %s", err)
 				}
-				fmt.Println(string(jsonStatus))
-			} else {
+				fmt.Println(out)
+			default:
 				fmt.Print(s.Format())
 			}
 			return err
@@ -56,7 +86,51 @@ func newCmdStatus() *cobra.Command {
 	cmd.Flags().IntVar(&params.WorkerCount,
 		"worker-count", status.DefaultWorkerCount,
 		"The number of workers to use")
-	cmd.Flags().StringVarP(&params.Output, "output", "o", status.OutputSummary, "Output format. One of: json, summary")
+	cmd.Flags().StringVarP(&params.Output, "output", "o", status.OutputSummary, "Output format. One of: json, summary, yaml")
+	cmd.Flags().StringVar(&params.Node, "node", "", "Show the detailed status of the Cilium agent running on the given node")
+	cmd.Flags().BoolVar(&params.Verbose, "verbose", false, "Show the detailed status of all Cilium agents")
+	cmd.Flags().BoolVar(&params.Watch, "watch", false, "Keep refreshing the status, highlighting changes and keeping a timeline of transitions")
+	cmd.Flags().DurationVar(&params.WatchInterval, "watch-interval", status.DefaultWatchInterval, "Interval at which the status is refreshed in watch mode and when serving metrics")
+	cmd.Flags().StringVar(&params.ServeMetrics, "serve-metrics", "", "Periodically collect the status and serve it as Prometheus metrics on the given address (e.g. :9090)")
 
 	return cmd
 }
+
+// marshalStatus marshals v to the given output format, either JSON or YAML.
+func marshalStatus(v any, output string) (string, error) {
+	if output == status.OutputYAML {
+		out, err := yaml.Marshal(v)
+		return strings.TrimSuffix(string(out), "\n"), err
+	}
+	out, err := json.MarshalIndent(v, "", " ")
+	return string(out), err
+}
+
+// printAgentStatuses prints the detailed status of the Cilium agents selected
+// by the --node and --verbose flags.
+func printAgentStatuses(s *status.Status, params status.K8sStatusParameters) error {
+	agents, err := s.AgentStatuses(params.Node)
+	if err != nil {
+		return err
+	}
+
+	switch params.Output {
+	case status.OutputJSON, status.OutputYAML:
+		out, err := marshalStatus(agents, params.Output)
+		if err != nil {
+			return fmt.Errorf("unable to marshal agent status: %w", err)
+		}
+		fmt.Println(out)
+	default:
+		// The aggregated status is only relevant when showing all agents.
+		if params.Node == "" {
+			fmt.Print(s.Format())
+		}
+		for _, a := range agents {
+			fmt.Println()
+			fmt.Print(a.Format())
+		}
+	}
+
+	return nil
+}
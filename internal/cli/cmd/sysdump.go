@@ -12,6 +12,7 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/klog/v2"
 
+	connectivitytests "github.com/cilium/cilium-cli/connectivity/tests"
 	"github.com/cilium/cilium-cli/sysdump"
 )
 
@@ -52,6 +53,32 @@ func newCmdSysdump(hooks SysdumpHooks) *cobra.Command {
 
 	initSysdumpFlags(cmd, &sysdumpOptions, "", hooks)
 
+	cmd.AddCommand(newCmdSysdumpAnalyze())
+
+	return cmd
+}
+
+func newCmdSysdumpAnalyze() *cobra.Command {
+	var opts = sysdump.AnalyzeOptions{
+		ErrorLogMessages: connectivitytests.ErrorLogMessages,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "analyze <sysdump.zip>",
+		Short: "Analyze a sysdump archive for known issues",
+		Long: `Analyze a sysdump archive for known issues, such as pods in CrashLoopBackOff,
+known fatal messages in the Cilium agent logs, mismatched Cilium versions,
+unhealthy Cilium agents, running pods without CiliumEndpoint and identity
+anomalies. The analysis runs offline and doesn't require access to the cluster.`,
+		Annotations: map[string]string{skipK8sClientAnnotation: ""},
+		Args:        cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sysdump.AnalyzeFile(os.Stdout, args[0], opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", sysdump.AnalyzeOutputText, "Output format. One of: text, json")
+
 	return cmd
 }
 
@@ -146,6 +173,12 @@ func initSysdumpFlags(cmd *cobra.Command, options *sysdump.Options, optionPrefix
 	cmd.Flags().IntVar(&options.CopyRetryLimit,
 		optionPrefix+"copy-retry-limit", sysdump.DefaultCopyRetryLimit,
 		"Retry limit for file copying operations. If set to -1, copying will be retried indefinitely. Useful for collecting sysdump while on unreliable connection.")
+	cmd.Flags().BoolVar(&options.Redact,
+		optionPrefix+"redact", false,
+		"Whether to redact sensitive data (secrets, private keys and IP addresses) from the sysdump")
+	cmd.Flags().StringVar(&options.RedactionRulesFile,
+		optionPrefix+"redact-rules", "",
+		"YAML file with the redaction rules, replacing the default ones (implies --redact)")
 
 	hooks.AddSysdumpFlags(cmd.Flags())
 }
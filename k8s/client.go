@@ -43,6 +43,7 @@ import (
 	"k8s.io/client-go/rest"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 
+	healthModels "github.com/cilium/cilium/api/v1/health/models"
 	"github.com/cilium/cilium/api/v1/models"
 	ciliumv2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
 	ciliumv2alpha1 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2alpha1"
@@ -259,6 +260,10 @@ func (c *Client) CreateService(ctx context.Context, namespace string, service *c
 	return c.Clientset.CoreV1().Services(namespace).Create(ctx, service, opts)
 }
 
+func (c *Client) UpdateService(ctx context.Context, namespace string, service *corev1.Service, opts metav1.UpdateOptions) (*corev1.Service, error) {
+	return c.Clientset.CoreV1().Services(namespace).Update(ctx, service, opts)
+}
+
 func (c *Client) DeleteService(ctx context.Context, namespace, name string, opts metav1.DeleteOptions) error {
 	return c.Clientset.CoreV1().Services(namespace).Delete(ctx, name, opts)
 }
@@ -482,6 +487,21 @@ func (c *Client) CiliumStatus(ctx context.Context, namespace, pod string) (*mode
 	return &statusResponse, nil
 }
 
+func (c *Client) CiliumHealthStatus(ctx context.Context, namespace, pod string) (*healthModels.HealthStatusResponse, error) {
+	stdout, err := c.ExecInPod(ctx, namespace, pod, defaults.AgentContainerName, []string{"cilium-health", "status", "-o", "json"})
+	if err != nil {
+		return nil, err
+	}
+
+	healthResponse := healthModels.HealthStatusResponse{}
+
+	if err := json.Unmarshal(stdout.Bytes(), &healthResponse); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal response of cilium-health status: %w", err)
+	}
+
+	return &healthResponse, nil
+}
+
 func (c *Client) CreateConfigMap(ctx context.Context, namespace string, config *corev1.ConfigMap, opts metav1.CreateOptions) (*corev1.ConfigMap, error) {
 	return c.Clientset.CoreV1().ConfigMaps(namespace).Create(ctx, config, opts)
 }
@@ -759,6 +779,14 @@ func (c *Client) ListCiliumBGPPeeringPolicies(ctx context.Context, opts metav1.L
 	return c.CiliumClientset.CiliumV2alpha1().CiliumBGPPeeringPolicies().List(ctx, opts)
 }
 
+func (c *Client) CreateCiliumBGPPeeringPolicy(ctx context.Context, cbgpp *ciliumv2alpha1.CiliumBGPPeeringPolicy, opts metav1.CreateOptions) (*ciliumv2alpha1.CiliumBGPPeeringPolicy, error) {
+	return c.CiliumClientset.CiliumV2alpha1().CiliumBGPPeeringPolicies().Create(ctx, cbgpp, opts)
+}
+
+func (c *Client) DeleteCiliumBGPPeeringPolicy(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.CiliumClientset.CiliumV2alpha1().CiliumBGPPeeringPolicies().Delete(ctx, name, opts)
+}
+
 func (c *Client) ListCiliumCIDRGroups(ctx context.Context, opts metav1.ListOptions) (*ciliumv2alpha1.CiliumCIDRGroupList, error) {
 	return c.CiliumClientset.CiliumV2alpha1().CiliumCIDRGroups().List(ctx, opts)
 }
@@ -992,6 +1020,14 @@ func (c *Client) ListCiliumLoadBalancerIPPools(ctx context.Context, opts metav1.
 	return c.CiliumClientset.CiliumV2alpha1().CiliumLoadBalancerIPPools().List(ctx, opts)
 }
 
+func (c *Client) CreateCiliumLoadBalancerIPPool(ctx context.Context, pool *ciliumv2alpha1.CiliumLoadBalancerIPPool, opts metav1.CreateOptions) (*ciliumv2alpha1.CiliumLoadBalancerIPPool, error) {
+	return c.CiliumClientset.CiliumV2alpha1().CiliumLoadBalancerIPPools().Create(ctx, pool, opts)
+}
+
+func (c *Client) DeleteCiliumLoadBalancerIPPool(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.CiliumClientset.CiliumV2alpha1().CiliumLoadBalancerIPPools().Delete(ctx, name, opts)
+}
+
 func (c *Client) ListCiliumLocalRedirectPolicies(ctx context.Context, namespace string, opts metav1.ListOptions) (*ciliumv2.CiliumLocalRedirectPolicyList, error) {
 	return c.CiliumClientset.CiliumV2().CiliumLocalRedirectPolicies(namespace).List(ctx, opts)
 }
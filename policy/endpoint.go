@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package policy
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	k8sConst "github.com/cilium/cilium/pkg/k8s/apis/cilium.io"
+	"github.com/cilium/cilium/pkg/labels"
+	"github.com/cilium/cilium/pkg/labels/cidr"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// endpoint is the source or destination of a traced flow.
+type endpoint struct {
+	// namespace of the pod, empty if the endpoint is not a pod.
+	namespace string
+	// podLabels are the labels of the pod, if the endpoint is a pod.
+	podLabels map[string]string
+	// namespaceLabels are the labels of the namespace of the pod.
+	namespaceLabels map[string]string
+	// ip is set for endpoints outside the cluster.
+	ip netip.Addr
+	// labels are the security identity labels of the endpoint, as seen by
+	// the Cilium agent.
+	labels labels.LabelArray
+}
+
+// newEndpoint returns the endpoint with the given labels or, if no labels are
+// given, the endpoint outside the cluster with the given IP. Labels without
+// source are pod labels, while labels with the reserved source describe
+// special endpoints such as reserved:host.
+func newEndpoint(lbls []string, namespace string, namespaceLabels map[string]string, ip, clusterName string) (*endpoint, error) {
+	if len(lbls) > 0 && ip != "" {
+		return nil, fmt.Errorf("labels and IP are mutually exclusive")
+	}
+
+	if len(lbls) == 0 {
+		if ip == "" {
+			return nil, fmt.Errorf("either labels or IP must be specified")
+		}
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP %q: %w", ip, err)
+		}
+		prefix := netip.PrefixFrom(addr, addr.BitLen())
+		return &endpoint{
+			ip:     addr,
+			labels: cidr.GetCIDRLabels(prefix).LabelArray(),
+		}, nil
+	}
+
+	ep := &endpoint{podLabels: make(map[string]string)}
+	reserved := false
+	for _, s := range lbls {
+		l := labels.ParseLabel(s)
+		switch l.Source {
+		case labels.LabelSourceUnspec:
+			l.Source = labels.LabelSourceK8s
+			ep.podLabels[l.Key] = l.Value
+		case labels.LabelSourceK8s:
+			ep.podLabels[l.Key] = l.Value
+		case labels.LabelSourceReserved:
+			reserved = true
+		}
+		ep.labels = append(ep.labels, l)
+	}
+
+	if !reserved {
+		ep.namespace = namespace
+		ep.namespaceLabels = map[string]string{corev1.LabelMetadataName: namespace}
+		for k, v := range namespaceLabels {
+			ep.namespaceLabels[k] = v
+		}
+		ep.labels = append(ep.labels,
+			labels.NewLabel(k8sConst.PodNamespaceLabel, namespace, labels.LabelSourceK8s),
+			labels.NewLabel(k8sConst.PolicyLabelCluster, clusterName, labels.LabelSourceK8s),
+		)
+		for k, v := range ep.namespaceLabels {
+			ep.labels = append(ep.labels,
+				labels.NewLabel(k8sConst.PodNamespaceMetaLabels+"."+k, v, labels.LabelSourceK8s))
+		}
+	} else {
+		ep.podLabels = nil
+	}
+
+	ep.labels = ep.labels.Sort()
+	return ep, nil
+}
+
+// isPod returns whether the endpoint is a pod.
+func (e *endpoint) isPod() bool {
+	return e.namespace != ""
+}
+
+// isWorld returns whether the endpoint is outside the cluster.
+func (e *endpoint) isWorld() bool {
+	return e.ip.IsValid()
+}
+
+func (e *endpoint) String() string {
+	switch {
+	case e.isWorld():
+		return e.ip.String()
+	case e.isPod():
+		return fmt.Sprintf("%s/{%s}", e.namespace, formatLabels(e.podLabels))
+	}
+	return e.labels.String()
+}
+
+func formatLabels(m map[string]string) string {
+	s := make([]string, 0, len(m))
+	for k, v := range m {
+		s = append(s, k+"="+v)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,672 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cilium/cilium/api/v1/flow"
+	"github.com/cilium/cilium/api/v1/observer"
+	k8sConst "github.com/cilium/cilium/pkg/k8s/apis/cilium.io"
+	ciliumv2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	"github.com/cilium/cilium/pkg/labels"
+	"github.com/cilium/cilium/pkg/policy/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// GenerateParameters configures the generation of policies from flows.
+type GenerateParameters struct {
+	// Namespace of the workloads to generate policies for.
+	Namespace string
+	// Since is how far back to collect flows from.
+	Since time.Duration
+	// HubbleServer is the address of Hubble Relay.
+	HubbleServer string
+
+	Writer io.Writer
+}
+
+// volatileLabels are pod labels which differ between the pods of a workload,
+// or between revisions of a workload, and which must not be selected on.
+var volatileLabels = map[string]struct{}{
+	"pod-template-hash":                  {},
+	"pod-template-generation":            {},
+	"controller-revision-hash":           {},
+	"controller-uid":                     {},
+	"job-name":                           {},
+	"batch.kubernetes.io/controller-uid": {},
+	"batch.kubernetes.io/job-name":       {},
+	"statefulset.kubernetes.io/pod-name": {},
+	"apps.kubernetes.io/pod-index":       {},
+}
+
+// reservedEntities maps the reserved labels to the corresponding entities,
+// in order of precedence.
+var reservedEntities = []struct {
+	label  string
+	entity api.Entity
+}{
+	{labels.LabelSourceReserved + ":" + labels.IDNameKubeAPIServer, api.EntityKubeAPIServer},
+	{labels.LabelSourceReserved + ":" + labels.IDNameHost, api.EntityHost},
+	{labels.LabelSourceReserved + ":" + labels.IDNameRemoteNode, api.EntityRemoteNode},
+	{labels.LabelSourceReserved + ":" + labels.IDNameHealth, api.EntityHealth},
+	{labels.LabelSourceReserved + ":" + labels.IDNameIngress, api.EntityIngress},
+}
+
+// kubeDNSSelector selects the cluster DNS pods, to which the DNS lookups of
+// endpoints with toFQDNs rules must be allowed.
+var kubeDNSSelector = api.NewESFromLabels(
+	labels.NewLabel(k8sConst.PodNamespaceLabel, "kube-system", labels.LabelSourceK8s),
+	labels.NewLabel("k8s-app", "kube-dns", labels.LabelSourceK8s),
+)
+
+// Generator generates least-privilege CiliumNetworkPolicies from the flows
+// observed by Hubble.
+type Generator struct {
+	params   GenerateParameters
+	observer observer.ObserverClient
+}
+
+// NewGenerator returns a Generator.
+func NewGenerator(p GenerateParameters) *Generator {
+	return &Generator{
+		params: p,
+	}
+}
+
+// Run collects the flows of the namespace and writes the generated policies
+// as yaml.
+func (g *Generator) Run(ctx context.Context) error {
+	if g.params.Namespace == "" {
+		return fmt.Errorf("namespace must be specified")
+	}
+	if g.observer == nil {
+		if err := g.enableHubbleClient(ctx); err != nil {
+			return err
+		}
+	}
+
+	until := time.Now()
+	since := until.Add(-g.params.Since)
+	flows, err := g.collectFlows(ctx, since, until)
+	if err != nil {
+		return fmt.Errorf("unable to collect flows: %w", err)
+	}
+
+	gen := newPolicyGenerator(g.params.Namespace)
+	for _, f := range flows {
+		gen.add(f)
+	}
+	description := fmt.Sprintf("Generated by cilium policy generate from the flows observed between %s and %s",
+		since.UTC().Format(time.RFC3339), until.UTC().Format(time.RFC3339))
+
+	fmt.Fprintf(g.params.Writer, "# %d flows observed in namespace %s since %s\n", len(flows), g.params.Namespace, since.UTC().Format(time.RFC3339))
+	for _, w := range gen.skipped() {
+		fmt.Fprintf(g.params.Writer, "# Skipped workload %s: it has no labels to select it with\n", w)
+	}
+	for _, p := range gen.skippedPeers() {
+		fmt.Fprintf(g.params.Writer, "# Skipped peer %s: it has no labels to select it with\n", p)
+	}
+	return writePolicies(g.params.Writer, gen.policies(description))
+}
+
+func (g *Generator) enableHubbleClient(ctx context.Context) error {
+	dialCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	c, err := grpc.DialContext(dialCtx, g.params.HubbleServer, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err == nil {
+		client := observer.NewObserverClient(c)
+		if _, err = client.ServerStatus(ctx, &observer.ServerStatusRequest{}); err == nil {
+			g.observer = client
+			return nil
+		}
+	}
+
+	return fmt.Errorf(`unable to contact Hubble Relay at %s: %w
+Expose Relay locally with:
+   cilium hubble enable
+   cilium hubble port-forward&`, g.params.HubbleServer, err)
+}
+
+// collectFlows retrieves the flows from and to the pods of the namespace.
+func (g *Generator) collectFlows(ctx context.Context, since, until time.Time) ([]*flow.Flow, error) {
+	pods := []string{g.params.Namespace + "/"}
+	req := &observer.GetFlowsRequest{
+		Whitelist: []*flow.FlowFilter{
+			{SourcePod: pods, Verdict: []flow.Verdict{flow.Verdict_FORWARDED, flow.Verdict_AUDIT}},
+			{DestinationPod: pods, Verdict: []flow.Verdict{flow.Verdict_FORWARDED, flow.Verdict_AUDIT}},
+		},
+		Since: timestamppb.New(since),
+		Until: timestamppb.New(until),
+	}
+	b, err := g.observer.GetFlows(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var flows []*flow.Flow
+	for {
+		res, err := b.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if f := res.GetFlow(); f != nil {
+			flows = append(flows, f)
+		}
+	}
+	return flows, nil
+}
+
+// workload is a set of pods selected by the same labels.
+type workload struct {
+	namespace string
+	name      string
+	// labels are the pod labels common to all the observed pods of the
+	// workload.
+	labels map[string]string
+}
+
+func (w *workload) String() string {
+	return w.namespace + "/" + w.name
+}
+
+func (w *workload) selector(namespace string) api.EndpointSelector {
+	lbls := make([]labels.Label, 0, len(w.labels)+1)
+	for k, v := range w.labels {
+		lbls = append(lbls, labels.NewLabel(k, v, labels.LabelSourceK8s))
+	}
+	// Peers of other namespaces are only selected with their namespace.
+	if w.namespace != namespace {
+		lbls = append(lbls, labels.NewLabel(k8sConst.PodNamespaceLabel, w.namespace, labels.LabelSourceK8s))
+	}
+	return api.NewESFromLabels(lbls...)
+}
+
+// peer is the source or destination of the traffic of a workload.
+type peer struct {
+	workload *workload
+	entity   api.Entity
+	cidr     api.CIDR
+	fqdn     string
+}
+
+// unselectable returns whether the peer is a workload which cannot be
+// selected as all its labels are volatile. Its selector would otherwise be
+// empty, selecting all the endpoints.
+func (p *peer) unselectable() bool {
+	return p.workload != nil && len(p.workload.labels) == 0
+}
+
+func (p *peer) key() string {
+	switch {
+	case p.workload != nil:
+		return "0/" + p.workload.String()
+	case p.entity != "":
+		return "1/" + string(p.entity)
+	case p.fqdn != "":
+		return "2/" + p.fqdn
+	}
+	return "3/" + string(p.cidr)
+}
+
+type portProto struct {
+	port  uint32
+	proto api.L4Proto
+}
+
+type httpRequest struct {
+	method string
+	path   string
+}
+
+// l7Rules are the L7 requests observed on a port.
+type l7Rules struct {
+	http map[httpRequest]struct{}
+	dns  map[string]struct{}
+}
+
+// peerTraffic is the traffic observed with a peer, by port.
+type peerTraffic struct {
+	peer  *peer
+	ports map[portProto]*l7Rules
+}
+
+// workloadTraffic is the traffic observed for a workload, by peer.
+type workloadTraffic struct {
+	workload *workload
+	ingress  map[string]*peerTraffic
+	egress   map[string]*peerTraffic
+}
+
+// policyGenerator aggregates flows into policies for the workloads of a
+// namespace.
+type policyGenerator struct {
+	namespace string
+	workloads map[string]*workload
+	traffic   map[string]*workloadTraffic
+}
+
+func newPolicyGenerator(namespace string) *policyGenerator {
+	return &policyGenerator{
+		namespace: namespace,
+		workloads: make(map[string]*workload),
+		traffic:   make(map[string]*workloadTraffic),
+	}
+}
+
+// add accounts for the flow, if it initiates a connection or is an L7
+// request.
+func (g *policyGenerator) add(f *flow.Flow) {
+	if f.GetVerdict() != flow.Verdict_FORWARDED && f.GetVerdict() != flow.Verdict_AUDIT {
+		return
+	}
+	if isReply := f.GetIsReply(); isReply != nil && isReply.GetValue() {
+		return
+	}
+	if l7 := f.GetL7(); l7 != nil && l7.GetType() != flow.L7FlowType_REQUEST {
+		return
+	}
+	if flags := f.GetL4().GetTCP().GetFlags(); f.GetIsReply() == nil && f.GetL7() == nil && flags != nil && !flags.GetSYN() {
+		return
+	}
+
+	pp, ok := flowPort(f)
+	if !ok {
+		return
+	}
+
+	src, dst := f.GetSource(), f.GetDestination()
+	if w := g.workload(src); w != nil && src.GetNamespace() == g.namespace {
+		for _, p := range g.peers(dst, f, true) {
+			g.record(w, true, p, pp, f.GetL7())
+		}
+	}
+	if w := g.workload(dst); w != nil && dst.GetNamespace() == g.namespace {
+		for _, p := range g.peers(src, f, false) {
+			g.record(w, false, p, pp, f.GetL7())
+		}
+	}
+}
+
+func flowPort(f *flow.Flow) (portProto, bool) {
+	l4 := f.GetL4()
+	switch {
+	case l4.GetTCP() != nil:
+		return portProto{port: l4.GetTCP().GetDestinationPort(), proto: api.ProtoTCP}, true
+	case l4.GetUDP() != nil:
+		return portProto{port: l4.GetUDP().GetDestinationPort(), proto: api.ProtoUDP}, true
+	case l4.GetSCTP() != nil:
+		return portProto{port: l4.GetSCTP().GetDestinationPort(), proto: api.ProtoSCTP}, true
+	}
+	return portProto{}, false
+}
+
+// workload returns the workload of the endpoint, or nil if the endpoint is
+// not a pod.
+func (g *policyGenerator) workload(ep *flow.Endpoint) *workload {
+	if ep.GetNamespace() == "" {
+		return nil
+	}
+
+	lbls := make(map[string]string)
+	for _, l := range ep.GetLabels() {
+		lbl := labels.ParseLabel(l)
+		if lbl.Source != labels.LabelSourceK8s || isNamespaceLabel(lbl.Key) {
+			continue
+		}
+		if _, ok := volatileLabels[lbl.Key]; ok {
+			continue
+		}
+		lbls[lbl.Key] = lbl.Value
+	}
+
+	name := ep.GetPodName()
+	if ws := ep.GetWorkloads(); len(ws) > 0 {
+		name = ws[0].GetName()
+	}
+	if name == "" {
+		name = formatLabels(lbls)
+	}
+
+	key := ep.GetNamespace() + "/" + name
+	w, ok := g.workloads[key]
+	if !ok {
+		w = &workload{namespace: ep.GetNamespace(), name: name, labels: lbls}
+		g.workloads[key] = w
+		return w
+	}
+	// Only select on the labels common to all the pods of the workload.
+	for k, v := range w.labels {
+		if lbls[k] != v {
+			delete(w.labels, k)
+		}
+	}
+	return w
+}
+
+// isNamespaceLabel returns whether the label is derived from the namespace or
+// service account of the pod rather than set on the pod.
+func isNamespaceLabel(key string) bool {
+	return key == k8sConst.PodNamespaceLabel ||
+		strings.HasPrefix(key, k8sConst.PodNamespaceMetaLabels+".") ||
+		strings.HasPrefix(key, k8sConst.PolicyLabelServiceAccount) ||
+		strings.HasPrefix(key, k8sConst.PolicyLabelCluster)
+}
+
+// peers returns the peers to allow for the given endpoint. Egress traffic to
+// the world is allowed by FQDN when the destination names are known, and by
+// CIDR otherwise, while ingress traffic from the world is allowed from the
+// world entity as clients are not known in advance.
+func (g *policyGenerator) peers(ep *flow.Endpoint, f *flow.Flow, egress bool) []*peer {
+	if w := g.workload(ep); w != nil {
+		return []*peer{{workload: w}}
+	}
+
+	world := false
+	for _, l := range ep.GetLabels() {
+		for _, e := range reservedEntities {
+			if l == e.label {
+				return []*peer{{entity: e.entity}}
+			}
+		}
+		if strings.HasPrefix(l, labels.LabelSourceReserved+":"+labels.IDNameWorld) || strings.HasPrefix(l, labels.LabelSourceCIDR+":") {
+			world = true
+		}
+	}
+	if !world {
+		return nil
+	}
+	if !egress {
+		return []*peer{{entity: api.EntityWorld}}
+	}
+
+	if names := f.GetDestinationNames(); len(names) > 0 {
+		var peers []*peer
+		for _, name := range names {
+			peers = append(peers, &peer{fqdn: strings.TrimSuffix(name, ".")})
+		}
+		return peers
+	}
+	addr, err := netip.ParseAddr(f.GetIP().GetDestination())
+	if err != nil {
+		return nil
+	}
+	return []*peer{{cidr: api.CIDR(netip.PrefixFrom(addr, addr.BitLen()).String())}}
+}
+
+func (g *policyGenerator) record(w *workload, egress bool, p *peer, pp portProto, l7 *flow.Layer7) {
+	key := w.String()
+	t, ok := g.traffic[key]
+	if !ok {
+		t = &workloadTraffic{
+			workload: w,
+			ingress:  make(map[string]*peerTraffic),
+			egress:   make(map[string]*peerTraffic),
+		}
+		g.traffic[key] = t
+	}
+
+	peers := t.ingress
+	if egress {
+		peers = t.egress
+	}
+	pt, ok := peers[p.key()]
+	if !ok {
+		pt = &peerTraffic{peer: p, ports: make(map[portProto]*l7Rules)}
+		peers[p.key()] = pt
+	}
+	rules, ok := pt.ports[pp]
+	if !ok {
+		rules = &l7Rules{
+			http: make(map[httpRequest]struct{}),
+			dns:  make(map[string]struct{}),
+		}
+		pt.ports[pp] = rules
+	}
+
+	if http := l7.GetHttp(); http != nil {
+		path := "/"
+		if u, err := url.Parse(http.GetUrl()); err == nil && u.Path != "" {
+			path = u.Path
+		}
+		rules.http[httpRequest{method: http.GetMethod(), path: path}] = struct{}{}
+	}
+	if dns := l7.GetDns(); dns != nil && egress {
+		rules.dns[strings.TrimSuffix(dns.GetQuery(), ".")] = struct{}{}
+	}
+}
+
+// skipped returns the workloads which cannot be selected as all their labels
+// are volatile.
+func (g *policyGenerator) skipped() []string {
+	var res []string
+	for _, t := range g.traffic {
+		if len(t.workload.labels) == 0 {
+			res = append(res, t.workload.String())
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// skippedPeers returns the peers of the selectable workloads which cannot be
+// selected as all their labels are volatile, along with the workloads they
+// were observed with.
+func (g *policyGenerator) skippedPeers() []string {
+	set := make(map[string]struct{})
+	for _, t := range g.traffic {
+		if len(t.workload.labels) == 0 {
+			continue
+		}
+		for _, peers := range []map[string]*peerTraffic{t.ingress, t.egress} {
+			for _, pt := range peers {
+				if pt.peer.unselectable() {
+					set[fmt.Sprintf("%s of workload %s", pt.peer.workload, t.workload)] = struct{}{}
+				}
+			}
+		}
+	}
+	res := make([]string, 0, len(set))
+	for p := range set {
+		res = append(res, p)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// policies returns a CiliumNetworkPolicy per workload of the namespace,
+// allowing the observed traffic.
+func (g *policyGenerator) policies(description string) []*ciliumv2.CiliumNetworkPolicy {
+	var res []*ciliumv2.CiliumNetworkPolicy
+	for _, t := range g.traffic {
+		if len(t.workload.labels) == 0 {
+			continue
+		}
+
+		rule := &api.Rule{
+			EndpointSelector: t.workload.selector(g.namespace),
+			Description:      description,
+		}
+		for _, pt := range sortedPeers(t.ingress) {
+			if pt.peer.unselectable() {
+				continue
+			}
+			ir := api.IngressRule{ToPorts: portRules(pt.ports)}
+			switch {
+			case pt.peer.workload != nil:
+				ir.FromEndpoints = []api.EndpointSelector{pt.peer.workload.selector(g.namespace)}
+			case pt.peer.entity != "":
+				ir.FromEntities = api.EntitySlice{pt.peer.entity}
+			default:
+				ir.FromCIDR = api.CIDRSlice{pt.peer.cidr}
+			}
+			rule.Ingress = append(rule.Ingress, ir)
+		}
+
+		hasFQDNs, hasDNSRules := false, false
+		for _, pt := range sortedPeers(t.egress) {
+			if pt.peer.unselectable() {
+				continue
+			}
+			er := api.EgressRule{ToPorts: portRules(pt.ports)}
+			switch {
+			case pt.peer.workload != nil:
+				er.ToEndpoints = []api.EndpointSelector{pt.peer.workload.selector(g.namespace)}
+			case pt.peer.entity != "":
+				er.ToEntities = api.EntitySlice{pt.peer.entity}
+			case pt.peer.fqdn != "":
+				er.ToFQDNs = api.FQDNSelectorSlice{{MatchName: pt.peer.fqdn}}
+				hasFQDNs = true
+			default:
+				er.ToCIDR = api.CIDRSlice{pt.peer.cidr}
+			}
+			for _, p := range er.ToPorts {
+				if p.Rules != nil && len(p.Rules.DNS) > 0 {
+					hasDNSRules = true
+				}
+			}
+			rule.Egress = append(rule.Egress, er)
+		}
+		// toFQDNs rules require the DNS lookups to be observed by the DNS
+		// proxy.
+		if hasFQDNs && !hasDNSRules {
+			rule.Egress = append(rule.Egress, api.EgressRule{
+				EgressCommonRule: api.EgressCommonRule{ToEndpoints: []api.EndpointSelector{kubeDNSSelector}},
+				ToPorts: api.PortRules{{
+					Ports: []api.PortProtocol{{Port: "53", Protocol: api.ProtoAny}},
+					Rules: &api.L7Rules{DNS: []api.PortRuleDNS{{MatchPattern: "*"}}},
+				}},
+			})
+		}
+
+		res = append(res, &ciliumv2.CiliumNetworkPolicy{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: ciliumv2.SchemeGroupVersion.String(),
+				Kind:       ciliumv2.CNPKindDefinition,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      policyName(t.workload.name),
+				Namespace: g.namespace,
+			},
+			Spec: rule,
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+func sortedPeers(peers map[string]*peerTraffic) []*peerTraffic {
+	keys := make([]string, 0, len(peers))
+	for k := range peers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make([]*peerTraffic, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, peers[k])
+	}
+	return res
+}
+
+// portRules returns the port rules allowing the given ports, along with the
+// observed L7 requests.
+func portRules(ports map[portProto]*l7Rules) api.PortRules {
+	keys := make([]portProto, 0, len(ports))
+	for pp := range ports {
+		keys = append(keys, pp)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].port != keys[j].port {
+			return keys[i].port < keys[j].port
+		}
+		return keys[i].proto < keys[j].proto
+	})
+
+	var res api.PortRules
+	for _, pp := range keys {
+		pr := api.PortRule{Ports: []api.PortProtocol{{Port: strconv.FormatUint(uint64(pp.port), 10), Protocol: pp.proto}}}
+		l7 := ports[pp]
+		switch {
+		case len(l7.http) > 0 && pp.proto == api.ProtoTCP:
+			pr.Rules = &api.L7Rules{}
+			requests := make([]httpRequest, 0, len(l7.http))
+			for r := range l7.http {
+				requests = append(requests, r)
+			}
+			sort.Slice(requests, func(i, j int) bool {
+				a, b := requests[i], requests[j]
+				return a.path < b.path || (a.path == b.path && a.method < b.method)
+			})
+			for _, r := range requests {
+				// Paths are matched as regular expressions.
+				pr.Rules.HTTP = append(pr.Rules.HTTP, api.PortRuleHTTP{Method: r.method, Path: regexp.QuoteMeta(r.path)})
+			}
+		case len(l7.dns) > 0:
+			pr.Rules = &api.L7Rules{}
+			names := make([]string, 0, len(l7.dns))
+			for name := range l7.dns {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				pr.Rules.DNS = append(pr.Rules.DNS, api.PortRuleDNS{MatchName: name})
+			}
+		}
+		res = append(res, pr)
+	}
+	return res
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// policyName returns a valid policy name for the workload.
+func policyName(workload string) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(workload), "-")
+	return strings.Trim(name, "-.")
+}
+
+// writePolicies writes the policies as a multi-document yaml, leaving out the
+// empty status and creation timestamp that are only meaningful server side.
+func writePolicies(w io.Writer, policies []*ciliumv2.CiliumNetworkPolicy) error {
+	for _, p := range policies {
+		data, err := yaml.Marshal(p)
+		if err != nil {
+			return err
+		}
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		delete(obj, "status")
+		if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+			delete(metadata, "creationTimestamp")
+		}
+		if data, err = yaml.Marshal(obj); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "---\n%s", data)
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package policy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/cilium/cilium/api/v1/flow"
+	"github.com/cilium/cilium/api/v1/observer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func podEndpoint(namespace, workload string, lbls ...string) *flow.Endpoint {
+	return &flow.Endpoint{
+		Namespace: namespace,
+		PodName:   workload + "-5d8f7c9b4-x2x7z",
+		Labels:    append(lbls, "k8s:io.kubernetes.pod.namespace="+namespace, "k8s:pod-template-hash=5d8f7c9b4"),
+		Workloads: []*flow.Workload{{Name: workload, Kind: "Deployment"}},
+	}
+}
+
+func tcpFlow(src, dst *flow.Endpoint, port uint32) *flow.Flow {
+	return &flow.Flow{
+		Verdict:     flow.Verdict_FORWARDED,
+		Source:      src,
+		Destination: dst,
+		IP:          &flow.IP{Source: "10.0.0.1", Destination: "10.0.0.2"},
+		L4: &flow.Layer4{Protocol: &flow.Layer4_TCP{TCP: &flow.TCP{
+			SourcePort:      43210,
+			DestinationPort: port,
+			Flags:           &flow.TCPFlags{SYN: true},
+		}}},
+	}
+}
+
+var (
+	frontend = podEndpoint("app", "frontend", "k8s:app=frontend")
+	backend  = podEndpoint("app", "backend", "k8s:app=backend", "k8s:tier=api")
+	coredns  = podEndpoint("kube-system", "coredns", "k8s:k8s-app=kube-dns")
+	world    = &flow.Endpoint{Labels: []string{"reserved:world"}}
+	host     = &flow.Endpoint{Labels: []string{"reserved:host"}}
+)
+
+func testFlows() []*flow.Flow {
+	httpReq := tcpFlow(frontend, backend, 8080)
+	httpReq.L7 = &flow.Layer7{
+		Type:   flow.L7FlowType_REQUEST,
+		Record: &flow.Layer7_Http{Http: &flow.HTTP{Method: "GET", Url: "http://backend:8080/api/v1?id=1"}},
+	}
+	httpResp := tcpFlow(backend, frontend, 43210)
+	httpResp.L7 = &flow.Layer7{
+		Type:   flow.L7FlowType_RESPONSE,
+		Record: &flow.Layer7_Http{Http: &flow.HTTP{Code: 200}},
+	}
+	reply := tcpFlow(backend, frontend, 43210)
+	reply.IsReply = &wrapperspb.BoolValue{Value: true}
+	ack := tcpFlow(frontend, backend, 9090)
+	ack.L4.GetTCP().Flags = &flow.TCPFlags{ACK: true}
+	dropped := tcpFlow(frontend, backend, 22)
+	dropped.Verdict = flow.Verdict_DROPPED
+
+	dns := &flow.Flow{
+		Verdict:     flow.Verdict_FORWARDED,
+		Source:      frontend,
+		Destination: coredns,
+		L4:          &flow.Layer4{Protocol: &flow.Layer4_UDP{UDP: &flow.UDP{DestinationPort: 53}}},
+		L7: &flow.Layer7{
+			Type:   flow.L7FlowType_REQUEST,
+			Record: &flow.Layer7_Dns{Dns: &flow.DNS{Query: "api.example.com."}},
+		},
+	}
+	toFQDN := tcpFlow(frontend, world, 443)
+	toFQDN.DestinationNames = []string{"api.example.com"}
+	toCIDR := tcpFlow(backend, world, 5432)
+	toCIDR.IP.Destination = "192.0.2.10"
+
+	return []*flow.Flow{
+		httpReq, httpResp, reply, ack, dropped, dns, toFQDN, toCIDR,
+		tcpFlow(world, frontend, 80),
+		tcpFlow(host, backend, 8080),
+		tcpFlow(coredns, backend, 8080),
+	}
+}
+
+func TestGeneratePolicies(t *testing.T) {
+	gen := newPolicyGenerator("app")
+	for _, f := range testFlows() {
+		gen.add(f)
+	}
+	cnps := gen.policies("test")
+	require.Len(t, cnps, 2)
+
+	var out bytes.Buffer
+	require.NoError(t, writePolicies(&out, cnps))
+	assert.Equal(t, `---
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: backend
+  namespace: app
+spec:
+  description: test
+  egress:
+  - toCIDR:
+    - 192.0.2.10/32
+    toPorts:
+    - ports:
+      - port: "5432"
+        protocol: TCP
+  endpointSelector:
+    matchLabels:
+      k8s:app: backend
+      k8s:tier: api
+  ingress:
+  - fromEndpoints:
+    - matchLabels:
+        k8s:app: frontend
+    toPorts:
+    - ports:
+      - port: "8080"
+        protocol: TCP
+      rules:
+        http:
+        - method: GET
+          path: /api/v1
+  - fromEndpoints:
+    - matchLabels:
+        k8s:io.kubernetes.pod.namespace: kube-system
+        k8s:k8s-app: kube-dns
+    toPorts:
+    - ports:
+      - port: "8080"
+        protocol: TCP
+  - fromEntities:
+    - host
+    toPorts:
+    - ports:
+      - port: "8080"
+        protocol: TCP
+---
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: frontend
+  namespace: app
+spec:
+  description: test
+  egress:
+  - toEndpoints:
+    - matchLabels:
+        k8s:app: backend
+        k8s:tier: api
+    toPorts:
+    - ports:
+      - port: "8080"
+        protocol: TCP
+      rules:
+        http:
+        - method: GET
+          path: /api/v1
+  - toEndpoints:
+    - matchLabels:
+        k8s:io.kubernetes.pod.namespace: kube-system
+        k8s:k8s-app: kube-dns
+    toPorts:
+    - ports:
+      - port: "53"
+        protocol: UDP
+      rules:
+        dns:
+        - matchName: api.example.com
+  - toFQDNs:
+    - matchName: api.example.com
+    toPorts:
+    - ports:
+      - port: "443"
+        protocol: TCP
+  endpointSelector:
+    matchLabels:
+      k8s:app: frontend
+  ingress:
+  - fromEntities:
+    - world
+    toPorts:
+    - ports:
+      - port: "80"
+        protocol: TCP
+`, out.String())
+
+	// The generated policies are valid, and allow the observed traffic.
+	policies, err := ParseYAML(out.String())
+	require.NoError(t, err)
+	assert.Empty(t, lint(policies, nil, nil))
+
+	res, err := tracePolicies(policies, pod(t, "app", nil, "app=frontend"), pod(t, "app", nil, "app=backend", "tier=api"), 8080, "TCP", "default")
+	require.NoError(t, err)
+	assert.Equal(t, VerdictAllowed, res.Verdict)
+	res, err = tracePolicies(policies, pod(t, "app", nil, "app=frontend"), pod(t, "app", nil, "app=backend", "tier=api"), 22, "TCP", "default")
+	require.NoError(t, err)
+	assert.Equal(t, VerdictDenied, res.Verdict)
+}
+
+func TestGenerateFQDNWithoutDNSVisibility(t *testing.T) {
+	toFQDN := tcpFlow(frontend, world, 443)
+	toFQDN.DestinationNames = []string{"api.example.com."}
+
+	gen := newPolicyGenerator("app")
+	gen.add(toFQDN)
+	cnps := gen.policies("test")
+	require.Len(t, cnps, 1)
+	egress := cnps[0].Spec.Egress
+	require.Len(t, egress, 2)
+	assert.Equal(t, "api.example.com", egress[0].ToFQDNs[0].MatchName)
+	assert.Equal(t, "*", egress[1].ToPorts[0].Rules.DNS[0].MatchPattern)
+}
+
+func TestGenerateWorkloadLabels(t *testing.T) {
+	gen := newPolicyGenerator("app")
+	gen.add(tcpFlow(podEndpoint("app", "web", "k8s:app=web", "k8s:version=v1"), world, 443))
+	gen.add(tcpFlow(podEndpoint("app", "web", "k8s:app=web", "k8s:version=v2"), world, 443))
+	gen.add(tcpFlow(podEndpoint("app", "unlabeled"), world, 443))
+
+	assert.Equal(t, []string{"app/unlabeled"}, gen.skipped())
+	cnps := gen.policies("test")
+	require.Len(t, cnps, 1)
+	assert.Equal(t, "web", cnps[0].Name)
+	assert.Equal(t, map[string]string{"k8s.app": "web"}, cnps[0].Spec.EndpointSelector.MatchLabels)
+}
+
+func TestGenerateUnselectablePeers(t *testing.T) {
+	unlabeled := podEndpoint("app", "unlabeled")
+	gen := newPolicyGenerator("app")
+	gen.add(tcpFlow(frontend, unlabeled, 8080))
+	gen.add(tcpFlow(unlabeled, frontend, 80))
+	gen.add(tcpFlow(frontend, backend, 8080))
+
+	assert.Equal(t, []string{"app/unlabeled"}, gen.skipped())
+	assert.Equal(t, []string{"app/unlabeled of workload app/frontend"}, gen.skippedPeers())
+	cnps := gen.policies("test")
+	require.Len(t, cnps, 2)
+	assert.Equal(t, "backend", cnps[0].Name)
+	assert.Equal(t, "frontend", cnps[1].Name)
+	// The unlabeled peer must not turn into a wildcard selector.
+	assert.Empty(t, cnps[1].Spec.Ingress)
+	require.Len(t, cnps[1].Spec.Egress, 1)
+	assert.Equal(t, map[string]string{"k8s.app": "backend", "k8s.tier": "api"}, cnps[1].Spec.Egress[0].ToEndpoints[0].MatchLabels)
+}
+
+func TestPolicyName(t *testing.T) {
+	assert.Equal(t, "frontend", policyName("frontend"))
+	assert.Equal(t, "app-web", policyName("App=web"))
+}
+
+type fakeObserverClient struct {
+	observer.ObserverClient
+	flows []*flow.Flow
+	req   *observer.GetFlowsRequest
+}
+
+func (c *fakeObserverClient) GetFlows(_ context.Context, req *observer.GetFlowsRequest, _ ...grpc.CallOption) (observer.Observer_GetFlowsClient, error) {
+	c.req = req
+	return &fakeGetFlowsClient{flows: c.flows}, nil
+}
+
+type fakeGetFlowsClient struct {
+	grpc.ClientStream
+	flows []*flow.Flow
+}
+
+func (c *fakeGetFlowsClient) Recv() (*observer.GetFlowsResponse, error) {
+	if len(c.flows) == 0 {
+		return nil, io.EOF
+	}
+	f := c.flows[0]
+	c.flows = c.flows[1:]
+	return &observer.GetFlowsResponse{ResponseTypes: &observer.GetFlowsResponse_Flow{Flow: f}}, nil
+}
+
+func TestGenerator(t *testing.T) {
+	var out bytes.Buffer
+	client := &fakeObserverClient{flows: testFlows()}
+	g := NewGenerator(GenerateParameters{Namespace: "app", Writer: &out})
+	g.observer = client
+	require.NoError(t, g.Run(context.Background()))
+
+	require.Len(t, client.req.Whitelist, 2)
+	assert.Equal(t, []string{"app/"}, client.req.Whitelist[0].SourcePod)
+	assert.Equal(t, []string{"app/"}, client.req.Whitelist[1].DestinationPod)
+	assert.Contains(t, out.String(), "# 11 flows observed in namespace app")
+
+	policies, err := ParseYAML(out.String())
+	require.NoError(t, err)
+	assert.Len(t, policies.CNPs, 2)
+
+	assert.Error(t, NewGenerator(GenerateParameters{Writer: &out}).Run(context.Background()))
+}
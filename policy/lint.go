@@ -0,0 +1,964 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/blang/semver/v4"
+	"github.com/cilium/cilium/pkg/policy/api"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cilium/cilium-cli/defaults"
+)
+
+const (
+	LintOutputText  = "text"
+	LintOutputJSON  = "json"
+	LintOutputSARIF = "sarif"
+)
+
+// Severity is the severity of a Finding.
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Names of the lint checks.
+const (
+	checkInvalidPolicy    = "invalid-policy"
+	checkUnsupportedField = "unsupported-field"
+	checkSelectorNoMatch  = "selector-no-match"
+	checkShadowedRule     = "shadowed-rule"
+	checkDeadAllowRule    = "dead-allow-rule"
+	checkFQDNWithoutDNS   = "fqdn-without-dns-visibility"
+	checkL7WithoutProxy   = "l7-without-proxy"
+)
+
+// lintChecks describes the lint checks, in the order they are reported in
+// SARIF.
+var lintChecks = []struct {
+	name        string
+	description string
+}{
+	{checkInvalidPolicy, "The policy is rejected by Cilium"},
+	{checkUnsupportedField, "The policy uses fields unsupported by the Cilium version running in the cluster"},
+	{checkSelectorNoMatch, "A selector of the policy matches no pod or namespace in the cluster"},
+	{checkShadowedRule, "A rule allows a subset of the traffic allowed by a broader rule"},
+	{checkDeadAllowRule, "A rule allows traffic which is always denied by a deny rule"},
+	{checkFQDNWithoutDNS, "toFQDNs rules without a DNS rule allowing the DNS proxy to observe the lookups"},
+	{checkL7WithoutProxy, "L7 rules which cannot be enforced as the L7 proxy is disabled"},
+}
+
+// fieldVersions are the minimum Cilium versions supporting policy fields.
+var fieldVersions = map[string]semver.Version{
+	"nodeSelector":   semver.MustParse("1.8.0"),
+	"ingressDeny":    semver.MustParse("1.9.0"),
+	"egressDeny":     semver.MustParse("1.9.0"),
+	"icmps":          semver.MustParse("1.10.0"),
+	"listener":       semver.MustParse("1.12.0"),
+	"authentication": semver.MustParse("1.14.0"),
+	"cidrGroupRef":   semver.MustParse("1.14.0"),
+}
+
+// Finding is an issue found while linting policies.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	// Check is the name of the check which reported the finding.
+	Check string `json:"check"`
+	// Policy is the kind, namespace and name of the policy.
+	Policy string `json:"policy"`
+	// File is the file the policy was loaded from, if any.
+	File string `json:"file,omitempty"`
+	// Rule locates the rule within the policy, if any.
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+type k8sLintImplementation interface {
+	k8sPolicyImplementation
+	ListPods(ctx context.Context, namespace string, options metav1.ListOptions) (*corev1.PodList, error)
+	ListNamespaces(ctx context.Context, o metav1.ListOptions) (*corev1.NamespaceList, error)
+	GetConfigMap(ctx context.Context, namespace, name string, opts metav1.GetOptions) (*corev1.ConfigMap, error)
+	GetCiliumVersion(ctx context.Context, p *corev1.Pod) (*semver.Version, error)
+}
+
+// LintParameters configures the linting of policies.
+type LintParameters struct {
+	// Files are the files or directories to load policies from. Policies are
+	// fetched from the cluster if no files are given.
+	Files []string
+	// Cluster enables the checks against the state of the cluster, such as
+	// selectors matching no pod.
+	Cluster bool
+	// CiliumNamespace is the namespace Cilium is running in.
+	CiliumNamespace string
+	// CiliumVersion is the Cilium version to check fields support against.
+	// It is detected from the cluster if empty.
+	CiliumVersion string
+	// Output is the output format, one of LintOutputText, LintOutputJSON or
+	// LintOutputSARIF.
+	Output string
+
+	Writer io.Writer
+}
+
+// clusterState is the state of the cluster the policies are checked against.
+type clusterState struct {
+	// endpoints are the pods managed by Cilium.
+	endpoints []*endpoint
+	// namespaces maps the namespaces to their labels.
+	namespaces map[string]map[string]string
+	// l7ProxyDisabled is whether the L7 proxy is disabled in the Cilium
+	// configuration.
+	l7ProxyDisabled bool
+	clusterName     string
+}
+
+// Linter statically analyzes network policies.
+type Linter struct {
+	client k8sLintImplementation
+	params LintParameters
+}
+
+// NewLinter returns a Linter. The client may be nil if the policies are loaded
+// from files and the cluster checks are disabled.
+func NewLinter(client k8sLintImplementation, p LintParameters) *Linter {
+	return &Linter{
+		client: client,
+		params: p,
+	}
+}
+
+// Run lints the policies and writes the findings. It returns an error if any
+// finding has SeverityError.
+func (l *Linter) Run(ctx context.Context) error {
+	switch l.params.Output {
+	case LintOutputText, LintOutputJSON, LintOutputSARIF, "":
+	default:
+		return fmt.Errorf("invalid output format %q", l.params.Output)
+	}
+	if l.client == nil && (l.params.Cluster || len(l.params.Files) == 0) {
+		return fmt.Errorf("no cluster available")
+	}
+
+	policies := &Policies{}
+	files := make(map[string]string)
+	if len(l.params.Files) > 0 {
+		paths, err := policyFiles(l.params.Files)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			p, err := loadFile(path)
+			if err != nil {
+				return err
+			}
+			for _, cnp := range p.CNPs {
+				files[cnpName(cnp)] = path
+			}
+			for _, ccnp := range p.CCNPs {
+				files[ccnpName(ccnp)] = path
+			}
+			for _, knp := range p.KNPs {
+				files[knpName(knp)] = path
+			}
+			policies.CNPs = append(policies.CNPs, p.CNPs...)
+			policies.CCNPs = append(policies.CCNPs, p.CCNPs...)
+			policies.KNPs = append(policies.KNPs, p.KNPs...)
+		}
+	} else {
+		var err error
+		if policies, err = Fetch(ctx, l.client); err != nil {
+			return err
+		}
+	}
+
+	var version *semver.Version
+	if l.params.CiliumVersion != "" {
+		v, err := semver.ParseTolerant(l.params.CiliumVersion)
+		if err != nil {
+			return fmt.Errorf("invalid Cilium version %q: %w", l.params.CiliumVersion, err)
+		}
+		version = &v
+	}
+
+	var state *clusterState
+	if l.params.Cluster {
+		var err error
+		if state, err = l.clusterState(ctx); err != nil {
+			return err
+		}
+		if version == nil {
+			if version, err = l.detectMinimumCiliumVersion(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	findings := lint(policies, state, version)
+	if findings == nil {
+		findings = []Finding{}
+	}
+	for i := range findings {
+		findings[i].File = files[findings[i].Policy]
+	}
+
+	switch l.params.Output {
+	case LintOutputJSON:
+		enc := json.NewEncoder(l.params.Writer)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(findings); err != nil {
+			return err
+		}
+	case LintOutputSARIF:
+		enc := json.NewEncoder(l.params.Writer)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(newSARIFLog(findings)); err != nil {
+			return err
+		}
+	default:
+		writeFindings(l.params.Writer, findings)
+	}
+
+	var errors int
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errors++
+		}
+	}
+	if errors > 0 {
+		return fmt.Errorf("%d errors found in policies", errors)
+	}
+	return nil
+}
+
+func (l *Linter) clusterState(ctx context.Context) (*clusterState, error) {
+	state := &clusterState{
+		namespaces:  make(map[string]map[string]string),
+		clusterName: "default",
+	}
+
+	cm, err := l.client.GetConfigMap(ctx, l.params.CiliumNamespace, defaults.ConfigMapName, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to get Cilium configuration: %w", err)
+	}
+	if cm != nil {
+		if name := cm.Data["cluster-name"]; name != "" {
+			state.clusterName = name
+		}
+		state.l7ProxyDisabled = cm.Data["enable-l7-proxy"] == "false"
+	}
+
+	namespaces, err := l.client.ListNamespaces(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list namespaces: %w", err)
+	}
+	for _, ns := range namespaces.Items {
+		lbls := map[string]string{corev1.LabelMetadataName: ns.Name}
+		for k, v := range ns.Labels {
+			lbls[k] = v
+		}
+		state.namespaces[ns.Name] = lbls
+	}
+
+	pods, err := l.client.ListPods(ctx, corev1.NamespaceAll, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		lbls := make([]string, 0, len(pod.Labels))
+		for k, v := range pod.Labels {
+			lbls = append(lbls, "k8s:"+k+"="+v)
+		}
+		ep, err := newEndpoint(lbls, pod.Namespace, state.namespaces[pod.Namespace], "", state.clusterName)
+		if err != nil || !ep.isPod() {
+			continue
+		}
+		state.endpoints = append(state.endpoints, ep)
+	}
+
+	return state, nil
+}
+
+// detectMinimumCiliumVersion returns the smallest Cilium version running in
+// the cluster.
+func (l *Linter) detectMinimumCiliumVersion(ctx context.Context) (*semver.Version, error) {
+	pods, err := l.client.ListPods(ctx, l.params.CiliumNamespace, metav1.ListOptions{LabelSelector: defaults.AgentPodSelector})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list Cilium pods: %w", err)
+	}
+	var minVersion *semver.Version
+	for i := range pods.Items {
+		podVersion, err := l.client.GetCiliumVersion(ctx, &pods.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		if minVersion == nil || podVersion.LT(*minVersion) {
+			minVersion = podVersion
+		}
+	}
+	return minVersion, nil
+}
+
+func writeFindings(w io.Writer, findings []Finding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "✅ No issues found")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEVERITY\tCHECK\tPOLICY\tRULE\tMESSAGE")
+	for _, f := range findings {
+		rule := f.Rule
+		if rule == "" {
+			rule = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.Severity, f.Check, f.Policy, rule, f.Message)
+	}
+	tw.Flush()
+}
+
+// lint checks the policies for issues. The cluster state and Cilium version
+// are optional, the checks depending on them are skipped if nil.
+func lint(policies *Policies, state *clusterState, version *semver.Version) []Finding {
+	clusterName := "default"
+	if state != nil {
+		clusterName = state.clusterName
+	}
+	api.InitEntities(clusterName, true)
+
+	valid, findings := checkInvalidPolicies(policies)
+	// Invalid policies have been filtered out.
+	rules, _ := ciliumRules(valid)
+
+	if version != nil {
+		findings = append(findings, checkUnsupportedFields(rules, version)...)
+	}
+	if state != nil {
+		findings = append(findings, checkSelectors(rules, valid.KNPs, state)...)
+		findings = append(findings, checkL7Proxy(rules, state)...)
+	}
+	findings = append(findings, checkRedundantRules(rules)...)
+	findings = append(findings, checkFQDNVisibility(rules)...)
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity == SeverityError
+		}
+		return false
+	})
+	return findings
+}
+
+func checkInvalidPolicies(policies *Policies) (*Policies, []Finding) {
+	valid := &Policies{KNPs: policies.KNPs}
+	var findings []Finding
+	invalid := func(policy string, err error) {
+		findings = append(findings, Finding{
+			Severity: SeverityError,
+			Check:    checkInvalidPolicy,
+			Policy:   policy,
+			Message:  err.Error(),
+		})
+	}
+
+	for _, cnp := range policies.CNPs {
+		if _, err := parseRules(cnp.DeepCopy().Parse); err != nil {
+			invalid(cnpName(cnp), err)
+			continue
+		}
+		valid.CNPs = append(valid.CNPs, cnp)
+	}
+	for _, ccnp := range policies.CCNPs {
+		if _, err := parseRules(ccnp.DeepCopy().Parse); err != nil {
+			invalid(ccnpName(ccnp), err)
+			continue
+		}
+		valid.CCNPs = append(valid.CCNPs, ccnp)
+	}
+	return valid, findings
+}
+
+func checkUnsupportedFields(rules []ciliumRule, version *semver.Version) []Finding {
+	// Pre-releases support the fields of their minor version.
+	current := semver.Version{Major: version.Major, Minor: version.Minor}
+
+	var findings []Finding
+	for _, r := range rules {
+		for _, u := range usedFields(r) {
+			if current.GTE(fieldVersions[u.field]) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Check:    checkUnsupportedField,
+				Policy:   r.policy,
+				Rule:     u.rule,
+				Message:  fmt.Sprintf("%s requires Cilium %s or later, but Cilium %s is running", u.field, fieldVersions[u.field], version),
+			})
+		}
+	}
+	return findings
+}
+
+type usedField struct {
+	field string
+	rule  string
+}
+
+// usedFields returns the fields of the rule which are not supported by all
+// Cilium versions.
+func usedFields(r ciliumRule) []usedField {
+	var used []usedField
+	add := func(field, rule string) {
+		used = append(used, usedField{field: field, rule: rule})
+	}
+
+	if r.rule.NodeSelector.LabelSelector != nil {
+		add("nodeSelector", r.spec+".nodeSelector")
+	}
+	if len(r.rule.IngressDeny) > 0 {
+		add("ingressDeny", r.spec+".ingressDeny")
+	}
+	if len(r.rule.EgressDeny) > 0 {
+		add("egressDeny", r.spec+".egressDeny")
+	}
+
+	cidrGroups := func(rule string, cidrs api.CIDRRuleSlice) {
+		for _, c := range cidrs {
+			if c.CIDRGroupRef != "" {
+				add("cidrGroupRef", rule)
+				return
+			}
+		}
+	}
+	listeners := func(rule string, ports api.PortRules) {
+		for _, p := range ports {
+			if p.Listener != nil {
+				add("listener", rule)
+				return
+			}
+		}
+	}
+
+	for i, ir := range r.rule.Ingress {
+		rule := fmt.Sprintf("%s.ingress[%d]", r.spec, i)
+		if len(ir.ICMPs) > 0 {
+			add("icmps", rule)
+		}
+		if ir.Authentication != nil {
+			add("authentication", rule)
+		}
+		cidrGroups(rule, ir.FromCIDRSet)
+		listeners(rule, ir.ToPorts)
+	}
+	for i, ir := range r.rule.IngressDeny {
+		rule := fmt.Sprintf("%s.ingressDeny[%d]", r.spec, i)
+		if len(ir.ICMPs) > 0 {
+			add("icmps", rule)
+		}
+		cidrGroups(rule, ir.FromCIDRSet)
+	}
+	for i, er := range r.rule.Egress {
+		rule := fmt.Sprintf("%s.egress[%d]", r.spec, i)
+		if len(er.ICMPs) > 0 {
+			add("icmps", rule)
+		}
+		if er.Authentication != nil {
+			add("authentication", rule)
+		}
+		cidrGroups(rule, er.ToCIDRSet)
+		listeners(rule, er.ToPorts)
+	}
+	for i, er := range r.rule.EgressDeny {
+		rule := fmt.Sprintf("%s.egressDeny[%d]", r.spec, i)
+		if len(er.ICMPs) > 0 {
+			add("icmps", rule)
+		}
+		cidrGroups(rule, er.ToCIDRSet)
+	}
+	return used
+}
+
+// checkSelectors reports the selectors matching no pod or namespace in the
+// cluster. Node selectors and selectors of reserved labels are not checked.
+func checkSelectors(rules []ciliumRule, knps []*networkingv1.NetworkPolicy, state *clusterState) []Finding {
+	var findings []Finding
+	noMatch := func(policy, rule, msg string) {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Check:    checkSelectorNoMatch,
+			Policy:   policy,
+			Rule:     rule,
+			Message:  msg,
+		})
+	}
+	matchesAny := func(sel api.EndpointSelector) bool {
+		for _, ep := range state.endpoints {
+			if sel.Matches(ep.labels) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, r := range rules {
+		if r.rule.NodeSelector.LabelSelector == nil && !selectsReserved(r.rule.EndpointSelector) && !matchesAny(r.rule.EndpointSelector) {
+			noMatch(r.policy, r.spec+".endpointSelector", "endpointSelector selects no pod, the policy has no effect")
+		}
+		peers := func(rule, field string, selectors []api.EndpointSelector) {
+			for i, sel := range selectors {
+				if !selectsReserved(sel) && !matchesAny(sel) {
+					noMatch(r.policy, fmt.Sprintf("%s.%s[%d]", rule, field, i), fmt.Sprintf("%s selector matches no pod", field))
+				}
+			}
+		}
+		for i, ir := range r.rule.Ingress {
+			peers(fmt.Sprintf("%s.ingress[%d]", r.spec, i), "fromEndpoints", ir.FromEndpoints)
+		}
+		for i, ir := range r.rule.IngressDeny {
+			peers(fmt.Sprintf("%s.ingressDeny[%d]", r.spec, i), "fromEndpoints", ir.FromEndpoints)
+		}
+		for i, er := range r.rule.Egress {
+			peers(fmt.Sprintf("%s.egress[%d]", r.spec, i), "toEndpoints", er.ToEndpoints)
+		}
+		for i, er := range r.rule.EgressDeny {
+			peers(fmt.Sprintf("%s.egressDeny[%d]", r.spec, i), "toEndpoints", er.ToEndpoints)
+		}
+	}
+
+	for _, np := range knps {
+		name := knpName(np)
+		if !knpMatchesAnyPod(state, func(ep *endpoint) bool {
+			return ep.namespace == np.Namespace && labelSelectorMatches(&np.Spec.PodSelector, ep.podLabels)
+		}) {
+			noMatch(name, "spec.podSelector", "podSelector selects no pod, the policy has no effect")
+		}
+		peers := func(rule string, peers []networkingv1.NetworkPolicyPeer) {
+			for i, p := range peers {
+				if p.IPBlock != nil {
+					continue
+				}
+				location := fmt.Sprintf("%s[%d]", rule, i)
+				if p.NamespaceSelector != nil && !namespaceMatchesAny(state, p.NamespaceSelector) {
+					noMatch(name, location, "namespaceSelector matches no namespace")
+					continue
+				}
+				if !knpMatchesAnyPod(state, func(ep *endpoint) bool {
+					return knpPeersMatch(np, []networkingv1.NetworkPolicyPeer{p}, ep)
+				}) {
+					noMatch(name, location, "peer matches no pod")
+				}
+			}
+		}
+		for i, r := range np.Spec.Ingress {
+			peers(fmt.Sprintf("spec.ingress[%d].from", i), r.From)
+		}
+		for i, r := range np.Spec.Egress {
+			peers(fmt.Sprintf("spec.egress[%d].to", i), r.To)
+		}
+	}
+	return findings
+}
+
+// selectsReserved returns whether the selector refers to reserved labels,
+// selecting endpoints other than pods.
+func selectsReserved(sel api.EndpointSelector) bool {
+	if sel.LabelSelector == nil {
+		return false
+	}
+	for k := range sel.MatchLabels {
+		if strings.HasPrefix(k, "reserved") {
+			return true
+		}
+	}
+	for _, e := range sel.MatchExpressions {
+		if strings.HasPrefix(e.Key, "reserved") {
+			return true
+		}
+	}
+	return false
+}
+
+func knpMatchesAnyPod(state *clusterState, match func(*endpoint) bool) bool {
+	for _, ep := range state.endpoints {
+		if match(ep) {
+			return true
+		}
+	}
+	return false
+}
+
+func namespaceMatchesAny(state *clusterState, sel *metav1.LabelSelector) bool {
+	for _, lbls := range state.namespaces {
+		if labelSelectorMatches(sel, lbls) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkL7Proxy reports the L7 rules which cannot be enforced as the L7 proxy
+// is disabled in the cluster.
+func checkL7Proxy(rules []ciliumRule, state *clusterState) []Finding {
+	if !state.l7ProxyDisabled {
+		return nil
+	}
+	var findings []Finding
+	for _, r := range rules {
+		for _, direction := range []string{directionIngress, directionEgress} {
+			for _, pr := range r.peerRules(direction, false) {
+				for _, p := range pr.ports {
+					if l7 := l7Description(&p); l7 != "" {
+						findings = append(findings, Finding{
+							Severity: SeverityError,
+							Check:    checkL7WithoutProxy,
+							Policy:   r.policy,
+							Rule:     pr.section,
+							Message:  fmt.Sprintf("%s rules cannot be enforced as the L7 proxy is disabled (enable-l7-proxy=false)", l7),
+						})
+						break
+					}
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// lintRule is an allow or deny rule of a policy, in one direction.
+type lintRule struct {
+	policy *ciliumRule
+	peer   peerRule
+	deny   bool
+}
+
+// checkRedundantRules reports the allow rules shadowed by broader allow
+// rules, and those whose traffic is always denied by deny rules.
+func checkRedundantRules(rules []ciliumRule) []Finding {
+	var findings []Finding
+	for _, direction := range []string{directionIngress, directionEgress} {
+		var allow, deny []lintRule
+		for i := range rules {
+			for _, pr := range rules[i].peerRules(direction, false) {
+				allow = append(allow, lintRule{policy: &rules[i], peer: pr})
+			}
+			for _, pr := range rules[i].peerRules(direction, true) {
+				deny = append(deny, lintRule{policy: &rules[i], peer: pr, deny: true})
+			}
+		}
+
+	allowRules:
+		for i, narrow := range allow {
+			for _, d := range deny {
+				if covers(&d, &narrow) {
+					findings = append(findings, Finding{
+						Severity: SeverityWarning,
+						Check:    checkDeadAllowRule,
+						Policy:   narrow.policy.policy,
+						Rule:     narrow.peer.section,
+						Message:  fmt.Sprintf("all traffic allowed by the rule is denied by %s (%s)", d.policy.policy, d.peer.section),
+					})
+					continue allowRules
+				}
+			}
+			for j, broad := range allow {
+				if i == j || !covers(&broad, &narrow) {
+					continue
+				}
+				// Only report the last of equivalent rules.
+				if j > i && covers(&narrow, &broad) {
+					continue
+				}
+				msg := fmt.Sprintf("the rule is shadowed by %s (%s), which allows a superset of its traffic", broad.policy.policy, broad.peer.section)
+				for _, p := range narrow.peer.ports {
+					if l7Description(&p) != "" {
+						msg += ", its L7 rules are not enforced"
+						break
+					}
+				}
+				findings = append(findings, Finding{
+					Severity: SeverityWarning,
+					Check:    checkShadowedRule,
+					Policy:   narrow.policy.policy,
+					Rule:     narrow.peer.section,
+					Message:  msg,
+				})
+				continue allowRules
+			}
+		}
+	}
+	return findings
+}
+
+// covers returns whether all the traffic matching the narrow rule also
+// matches the broad rule. It errs on the side of returning false for rules
+// which cannot be compared statically.
+func covers(broad, narrow *lintRule) bool {
+	for _, r := range []*lintRule{broad, narrow} {
+		if len(r.peer.requires) > 0 || len(r.peer.unsupported) > 0 || r.peer.hasICMPs || r.peer.authenticated {
+			return false
+		}
+	}
+	if !subjectCovers(broad.policy.rule, narrow.policy.rule) {
+		return false
+	}
+
+	broadPeers, narrowPeers := peerSelectors(&broad.peer), peerSelectors(&narrow.peer)
+	if len(broadPeers) == 0 || len(narrowPeers) == 0 {
+		return false
+	}
+	for _, n := range narrowPeers {
+		covered := false
+		for _, b := range broadPeers {
+			if selectorCovers(b, n) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+
+	return portsCover(broad.peer.ports, narrow.peer.ports)
+}
+
+func subjectCovers(broad, narrow *api.Rule) bool {
+	broadNode, narrowNode := broad.NodeSelector.LabelSelector != nil, narrow.NodeSelector.LabelSelector != nil
+	if broadNode != narrowNode {
+		return false
+	}
+	if broadNode {
+		return selectorCovers(broad.NodeSelector, narrow.NodeSelector)
+	}
+	return selectorCovers(broad.EndpointSelector, narrow.EndpointSelector)
+}
+
+// peerSelectors returns the selectors of the peers of the rule, L4-only rules
+// applying to all peers.
+func peerSelectors(pr *peerRule) api.EndpointSelectorSlice {
+	selectors := pr.selectors(nil)
+	if len(selectors) == 0 && len(pr.ports) > 0 && pr.allowsWildcarding {
+		return api.EndpointSelectorSlice{api.WildcardEndpointSelector}
+	}
+	return selectors
+}
+
+// selectorCovers returns whether all the labels matching the narrow selector
+// also match the broad selector, that is if the requirements of the broad
+// selector are a subset of those of the narrow selector.
+func selectorCovers(broad, narrow api.EndpointSelector) bool {
+	if broad.LabelSelector == nil || narrow.LabelSelector == nil {
+		return false
+	}
+	for k, v := range broad.MatchLabels {
+		if nv, ok := narrow.MatchLabels[k]; !ok || nv != v {
+			return false
+		}
+	}
+	for _, be := range broad.MatchExpressions {
+		found := false
+		for _, ne := range narrow.MatchExpressions {
+			if reflect.DeepEqual(be, ne) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// portsCover returns whether the broad port rules allow all the ports of the
+// narrow port rules. Port rules with L7 rules only cover ports if the rules
+// are empty.
+func portsCover(broad, narrow []api.PortRule) bool {
+	if len(broad) == 0 {
+		return true
+	}
+	if len(narrow) == 0 {
+		return false
+	}
+	for _, np := range narrow {
+		if len(np.Ports) == 0 {
+			return false
+		}
+		for _, npp := range np.Ports {
+			covered := false
+			for _, bp := range broad {
+				if l7Description(&bp) != "" {
+					continue
+				}
+				for _, bpp := range bp.Ports {
+					if (bpp.Port == "" || bpp.Port == "0" || bpp.Port == npp.Port) &&
+						(bpp.Protocol == "" || bpp.Protocol == api.ProtoAny || bpp.Protocol == npp.Protocol) {
+						covered = true
+					}
+				}
+			}
+			if !covered {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkFQDNVisibility reports the toFQDNs rules of endpoints without an
+// egress rule with DNS rules. Without such a rule the DNS proxy doesn't
+// observe the DNS lookups of the endpoints, and toFQDNs rules never match.
+func checkFQDNVisibility(rules []ciliumRule) []Finding {
+	hasDNSRules := func(r *api.Rule) bool {
+		for _, er := range r.Egress {
+			for _, p := range er.ToPorts {
+				if p.Rules != nil && len(p.Rules.DNS) > 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var findings []Finding
+	for _, r := range rules {
+		for i, er := range r.rule.Egress {
+			if len(er.ToFQDNs) == 0 {
+				continue
+			}
+			visible := false
+			for _, other := range rules {
+				if hasDNSRules(other.rule) && subjectCovers(other.rule, r.rule) {
+					visible = true
+					break
+				}
+			}
+			if !visible {
+				findings = append(findings, Finding{
+					Severity: SeverityError,
+					Check:    checkFQDNWithoutDNS,
+					Policy:   r.policy,
+					Rule:     fmt.Sprintf("%s.egress[%d]", r.spec, i),
+					Message:  "toFQDNs requires an egress rule with DNS rules (toPorts.rules.dns) for the selected endpoints, so that the DNS proxy observes the DNS lookups",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// sarifLog is a SARIF 2.1.0 log, as consumed by code scanning tools.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+func newSARIFLog(findings []Finding) *sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "cilium-policy-lint",
+			InformationURI: "https://github.com/cilium/cilium-cli",
+		}},
+		Results: []sarifResult{},
+	}
+	for _, c := range lintChecks {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               c.name,
+			ShortDescription: sarifMessage{Text: c.description},
+		})
+	}
+
+	for _, f := range findings {
+		name := f.Policy
+		if f.Rule != "" {
+			name += "/" + f.Rule
+		}
+		location := sarifLocation{
+			LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: name, Kind: "object"}},
+		}
+		if f.File != "" {
+			location.PhysicalLocation = &sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: f.File}}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    f.Check,
+			Level:     string(f.Severity),
+			Message:   sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	return &sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
@@ -0,0 +1,347 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	ciliumv2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const lintPolicies = `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: backend
+  namespace: app
+spec:
+  endpointSelector:
+    matchLabels:
+      app: backend
+  ingress:
+  - fromEndpoints:
+    - {}
+    toPorts:
+    - ports:
+      - port: "8080"
+  - fromEndpoints:
+    - matchLabels:
+        app: frontend
+    toPorts:
+    - ports:
+      - port: "8080"
+        protocol: TCP
+      rules:
+        http:
+        - method: GET
+  - fromEndpoints:
+    - matchLabels:
+        app: legacy
+  ingressDeny:
+  - fromEndpoints:
+    - matchLabels:
+        app: legacy
+---
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: egress
+  namespace: app
+spec:
+  endpointSelector:
+    matchLabels:
+      app: frontend
+  egress:
+  - toFQDNs:
+    - matchName: cilium.io
+  - toEndpoints:
+    - matchLabels:
+        app: missing
+    icmps:
+    - fields:
+      - type: 8
+`
+
+func lintFindings(t *testing.T, yaml string, state *clusterState, version string) map[string][]Finding {
+	t.Helper()
+	policies, err := ParseYAML(yaml)
+	require.NoError(t, err)
+	var v *semver.Version
+	if version != "" {
+		parsed := semver.MustParse(version)
+		v = &parsed
+	}
+	res := make(map[string][]Finding)
+	for _, f := range lint(policies, state, v) {
+		res[f.Check] = append(res[f.Check], f)
+	}
+	return res
+}
+
+func TestLintRedundantRules(t *testing.T) {
+	findings := lintFindings(t, lintPolicies, nil, "")
+
+	require.Len(t, findings[checkShadowedRule], 1)
+	f := findings[checkShadowedRule][0]
+	assert.Equal(t, "CiliumNetworkPolicy app/backend", f.Policy)
+	assert.Equal(t, "spec.ingress[1]", f.Rule)
+	assert.Contains(t, f.Message, "spec.ingress[0]")
+	assert.Contains(t, f.Message, "L7 rules are not enforced")
+
+	require.Len(t, findings[checkDeadAllowRule], 1)
+	f = findings[checkDeadAllowRule][0]
+	assert.Equal(t, "spec.ingress[2]", f.Rule)
+	assert.Contains(t, f.Message, "spec.ingressDeny[0]")
+}
+
+func TestLintEquivalentRules(t *testing.T) {
+	findings := lintFindings(t, `
+apiVersion: cilium.io/v2
+kind: CiliumClusterwideNetworkPolicy
+metadata:
+  name: dup
+spec:
+  endpointSelector: {}
+  egress:
+  - toEntities:
+    - kube-apiserver
+  - toEntities:
+    - kube-apiserver
+`, nil, "")
+	require.Len(t, findings[checkShadowedRule], 1)
+	assert.Equal(t, "spec.egress[1]", findings[checkShadowedRule][0].Rule)
+}
+
+func TestLintFQDNVisibility(t *testing.T) {
+	findings := lintFindings(t, lintPolicies, nil, "")
+	require.Len(t, findings[checkFQDNWithoutDNS], 1)
+	assert.Equal(t, "CiliumNetworkPolicy app/egress", findings[checkFQDNWithoutDNS][0].Policy)
+	assert.Equal(t, SeverityError, findings[checkFQDNWithoutDNS][0].Severity)
+
+	findings = lintFindings(t, lintPolicies+`
+---
+apiVersion: cilium.io/v2
+kind: CiliumClusterwideNetworkPolicy
+metadata:
+  name: dns
+spec:
+  endpointSelector: {}
+  egress:
+  - toEndpoints:
+    - matchLabels:
+        k8s:io.kubernetes.pod.namespace: kube-system
+        k8s:k8s-app: kube-dns
+    toPorts:
+    - ports:
+      - port: "53"
+        protocol: ANY
+      rules:
+        dns:
+        - matchPattern: "*"
+`, nil, "")
+	assert.Empty(t, findings[checkFQDNWithoutDNS])
+}
+
+func TestLintUnsupportedFields(t *testing.T) {
+	findings := lintFindings(t, lintPolicies, nil, "1.9.5")
+	require.Len(t, findings[checkUnsupportedField], 1)
+	f := findings[checkUnsupportedField][0]
+	assert.Equal(t, "spec.egress[1]", f.Rule)
+	assert.Contains(t, f.Message, "icmps requires Cilium 1.10.0")
+
+	findings = lintFindings(t, lintPolicies, nil, "1.10.0-rc.1")
+	assert.Empty(t, findings[checkUnsupportedField])
+	findings = lintFindings(t, lintPolicies, nil, "1.8.0")
+	assert.Len(t, findings[checkUnsupportedField], 2)
+}
+
+func TestLintInvalidPolicy(t *testing.T) {
+	findings := lintFindings(t, `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: invalid
+spec:
+  endpointSelector: {}
+  ingress:
+  - toPorts:
+    - ports:
+      - port: "53"
+        protocol: UDP
+      rules:
+        dns:
+        - matchPattern: "*"
+`, nil, "")
+	require.Len(t, findings[checkInvalidPolicy], 1)
+	assert.Contains(t, findings[checkInvalidPolicy][0].Message, "DNS is not supported on ingress")
+}
+
+func TestLintClusterState(t *testing.T) {
+	state := &clusterState{
+		namespaces: map[string]map[string]string{
+			"app": {corev1.LabelMetadataName: "app"},
+		},
+		l7ProxyDisabled: true,
+		clusterName:     "default",
+	}
+	for _, lbls := range []string{"app=backend", "app=frontend", "app=legacy"} {
+		state.endpoints = append(state.endpoints, pod(t, "app", state.namespaces["app"], lbls))
+	}
+
+	findings := lintFindings(t, lintPolicies+`
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: monitoring
+  namespace: app
+spec:
+  podSelector:
+    matchLabels:
+      app: backend
+  ingress:
+  - from:
+    - namespaceSelector:
+        matchLabels:
+          team: monitoring
+  - from:
+    - podSelector:
+        matchLabels:
+          app: frontend
+`, state, "")
+	require.Len(t, findings[checkSelectorNoMatch], 2)
+	assert.Equal(t, "spec.egress[1].toEndpoints[0]", findings[checkSelectorNoMatch][0].Rule)
+	assert.Equal(t, "NetworkPolicy app/monitoring", findings[checkSelectorNoMatch][1].Policy)
+	assert.Equal(t, "spec.ingress[0].from[0]", findings[checkSelectorNoMatch][1].Rule)
+
+	require.Len(t, findings[checkL7WithoutProxy], 1)
+	assert.Equal(t, "spec.ingress[1]", findings[checkL7WithoutProxy][0].Rule)
+}
+
+type fakeLintClient struct {
+	pods       []corev1.Pod
+	namespaces []corev1.Namespace
+	config     map[string]string
+	versions   map[string]string
+	cnps       []ciliumv2.CiliumNetworkPolicy
+}
+
+func (c *fakeLintClient) ListCiliumNetworkPolicies(_ context.Context, _ string, _ metav1.ListOptions) (*ciliumv2.CiliumNetworkPolicyList, error) {
+	return &ciliumv2.CiliumNetworkPolicyList{Items: c.cnps}, nil
+}
+
+func (c *fakeLintClient) ListCiliumClusterwideNetworkPolicies(_ context.Context, _ metav1.ListOptions) (*ciliumv2.CiliumClusterwideNetworkPolicyList, error) {
+	return &ciliumv2.CiliumClusterwideNetworkPolicyList{}, nil
+}
+
+func (c *fakeLintClient) ListKubernetesNetworkPolicies(_ context.Context, _ string, _ metav1.ListOptions) (*networkingv1.NetworkPolicyList, error) {
+	return &networkingv1.NetworkPolicyList{}, nil
+}
+
+func (c *fakeLintClient) GetNamespace(_ context.Context, namespace string, _ metav1.GetOptions) (*corev1.Namespace, error) {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, nil
+}
+
+func (c *fakeLintClient) ListPods(_ context.Context, _ string, options metav1.ListOptions) (*corev1.PodList, error) {
+	if options.LabelSelector != "" {
+		var pods []corev1.Pod
+		for name := range c.versions {
+			pods = append(pods, corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: name}})
+		}
+		return &corev1.PodList{Items: pods}, nil
+	}
+	return &corev1.PodList{Items: c.pods}, nil
+}
+
+func (c *fakeLintClient) ListNamespaces(_ context.Context, _ metav1.ListOptions) (*corev1.NamespaceList, error) {
+	return &corev1.NamespaceList{Items: c.namespaces}, nil
+}
+
+func (c *fakeLintClient) GetConfigMap(_ context.Context, _, _ string, _ metav1.GetOptions) (*corev1.ConfigMap, error) {
+	return &corev1.ConfigMap{Data: c.config}, nil
+}
+
+func (c *fakeLintClient) GetCiliumVersion(_ context.Context, p *corev1.Pod) (*semver.Version, error) {
+	v := semver.MustParse(c.versions[p.Name])
+	return &v, nil
+}
+
+func TestLinterCluster(t *testing.T) {
+	policies, err := ParseYAML(lintPolicies)
+	require.NoError(t, err)
+	client := &fakeLintClient{
+		namespaces: []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "app"}}},
+		pods: []corev1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "backend", Labels: map[string]string{"app": "backend"}}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "frontend", Labels: map[string]string{"app": "frontend"}}},
+			{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "missing", Labels: map[string]string{"app": "missing"}},
+				Spec:       corev1.PodSpec{HostNetwork: true},
+			},
+		},
+		config:   map[string]string{"cluster-name": "test"},
+		versions: map[string]string{"cilium-1": "1.14.2", "cilium-2": "1.9.0"},
+	}
+	for _, cnp := range policies.CNPs {
+		client.cnps = append(client.cnps, *cnp)
+	}
+
+	var out bytes.Buffer
+	err = NewLinter(client, LintParameters{Cluster: true, Output: LintOutputJSON, Writer: &out}).Run(context.Background())
+	assert.ErrorContains(t, err, "2 errors found")
+
+	var findings []Finding
+	require.NoError(t, json.Unmarshal(out.Bytes(), &findings))
+	checks := make(map[string]int)
+	for _, f := range findings {
+		checks[f.Check]++
+	}
+	assert.Equal(t, map[string]int{
+		checkFQDNWithoutDNS:   1,
+		checkUnsupportedField: 1,
+		checkSelectorNoMatch:  3,
+		checkShadowedRule:     1,
+		checkDeadAllowRule:    1,
+	}, checks)
+	assert.Equal(t, SeverityError, findings[0].Severity)
+}
+
+func TestLinterSARIF(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "policies.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(lintPolicies), 0o644))
+
+	var out bytes.Buffer
+	err := NewLinter(nil, LintParameters{Files: []string{dir}, Output: LintOutputSARIF, Writer: &out}).Run(context.Background())
+	assert.ErrorContains(t, err, "1 errors found")
+
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(out.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	assert.Len(t, log.Runs[0].Tool.Driver.Rules, len(lintChecks))
+	require.Len(t, log.Runs[0].Results, 3)
+	res := log.Runs[0].Results[0]
+	assert.Equal(t, checkFQDNWithoutDNS, res.RuleID)
+	assert.Equal(t, "error", res.Level)
+	assert.Equal(t, file, res.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, "CiliumNetworkPolicy app/egress/spec.egress[0]", res.Locations[0].LogicalLocations[0].FullyQualifiedName)
+
+	out.Reset()
+	err = NewLinter(nil, LintParameters{Files: []string{file}, Output: "yaml", Writer: &out}).Run(context.Background())
+	assert.Error(t, err)
+	err = NewLinter(nil, LintParameters{Cluster: true, Writer: &out}).Run(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	ciliumdefaults "github.com/cilium/cilium/pkg/defaults"
+	"github.com/cilium/cilium/pkg/fqdn/re"
+	ciliumv2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	ciliumscheme "github.com/cilium/cilium/pkg/k8s/client/clientset/versioned/scheme"
+	"github.com/cilium/cilium/pkg/policy/api"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+type k8sPolicyImplementation interface {
+	ListCiliumNetworkPolicies(ctx context.Context, namespace string, opts metav1.ListOptions) (*ciliumv2.CiliumNetworkPolicyList, error)
+	ListCiliumClusterwideNetworkPolicies(ctx context.Context, opts metav1.ListOptions) (*ciliumv2.CiliumClusterwideNetworkPolicyList, error)
+	ListKubernetesNetworkPolicies(ctx context.Context, namespace string, opts metav1.ListOptions) (*networkingv1.NetworkPolicyList, error)
+	GetNamespace(ctx context.Context, namespace string, options metav1.GetOptions) (*corev1.Namespace, error)
+}
+
+// Parameters of a policy trace.
+type Parameters struct {
+	// SrcLabels and DstLabels are the labels of the source and destination
+	// endpoints, as [source:]key=value. Labels without source are pod labels.
+	SrcLabels []string
+	DstLabels []string
+	// SrcNamespace and DstNamespace are the namespaces of the source and
+	// destination pods.
+	SrcNamespace string
+	DstNamespace string
+	// SrcIP and DstIP are the IPs of endpoints outside the cluster, used
+	// instead of labels.
+	SrcIP string
+	DstIP string
+	// Port is the destination port, as port[/protocol].
+	Port string
+	// PolicyFiles are the files or directories to load policies from,
+	// instead of fetching them from the cluster.
+	PolicyFiles []string
+	// ClusterName is the name of the cluster the endpoints belong to.
+	ClusterName string
+
+	Writer io.Writer
+}
+
+// Policies is a set of policies to trace flows against.
+type Policies struct {
+	CNPs  []*ciliumv2.CiliumNetworkPolicy
+	CCNPs []*ciliumv2.CiliumClusterwideNetworkPolicy
+	KNPs  []*networkingv1.NetworkPolicy
+}
+
+// Len returns the number of policies in the set.
+func (p *Policies) Len() int {
+	return len(p.CNPs) + len(p.CCNPs) + len(p.KNPs)
+}
+
+func cnpName(cnp *ciliumv2.CiliumNetworkPolicy) string {
+	return fmt.Sprintf("CiliumNetworkPolicy %s/%s", cnp.Namespace, cnp.Name)
+}
+
+func ccnpName(ccnp *ciliumv2.CiliumClusterwideNetworkPolicy) string {
+	return fmt.Sprintf("CiliumClusterwideNetworkPolicy %s", ccnp.Name)
+}
+
+func knpName(knp *networkingv1.NetworkPolicy) string {
+	return fmt.Sprintf("NetworkPolicy %s/%s", knp.Namespace, knp.Name)
+}
+
+var initRegexCompileLRU sync.Once
+
+// parseRules parses the rules of a CiliumNetworkPolicy or
+// CiliumClusterwideNetworkPolicy, as done by the agent.
+func parseRules(parse func() (api.Rules, error)) (api.Rules, error) {
+	// Parsing toFQDNs rules requires the regex cache of the agent.
+	initRegexCompileLRU.Do(func() {
+		re.InitRegexCompileLRU(ciliumdefaults.FQDNRegexCompileLRUSize)
+	})
+	return parse()
+}
+
+var policyScheme = func() *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := ciliumscheme.AddToScheme(s); err != nil {
+		panic(err)
+	}
+	if err := clientsetscheme.AddToScheme(s); err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+// isEmptyDocument returns true if the yaml document contains nothing but
+// whitespace and comments.
+func isEmptyDocument(yaml string) bool {
+	for _, line := range strings.Split(yaml, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseYAML decodes a multi-document yaml into CiliumNetworkPolicies,
+// CiliumClusterwideNetworkPolicies and NetworkPolicies. Policies without
+// namespace are assigned the default namespace.
+func ParseYAML(data string) (*Policies, error) {
+	policies := &Policies{}
+	decoder := serializer.NewCodecFactory(policyScheme, serializer.EnableStrict).UniversalDeserializer()
+
+	for _, yaml := range strings.Split(data, "\n---") {
+		if isEmptyDocument(yaml) {
+			continue
+		}
+
+		obj, kind, err := decoder.Decode([]byte(yaml), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("decoding policy yaml: %s\nerror: %w", yaml, err)
+		}
+
+		switch policy := obj.(type) {
+		case *ciliumv2.CiliumNetworkPolicy:
+			if policy.Namespace == "" {
+				policy.Namespace = corev1.NamespaceDefault
+			}
+			policies.CNPs = append(policies.CNPs, policy)
+		case *ciliumv2.CiliumClusterwideNetworkPolicy:
+			policies.CCNPs = append(policies.CCNPs, policy)
+		case *networkingv1.NetworkPolicy:
+			if policy.Namespace == "" {
+				policy.Namespace = corev1.NamespaceDefault
+			}
+			policies.KNPs = append(policies.KNPs, policy)
+		default:
+			return nil, fmt.Errorf("unknown policy type '%s' in: %s", kind.Kind, yaml)
+		}
+	}
+
+	return policies, nil
+}
+
+// LoadFiles loads the policies from the given files. The yaml and json files
+// of directories are loaded, non-recursively.
+func LoadFiles(paths []string) (*Policies, error) {
+	files, err := policyFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := &Policies{}
+	for _, file := range files {
+		p, err := loadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		policies.CNPs = append(policies.CNPs, p.CNPs...)
+		policies.CCNPs = append(policies.CCNPs, p.CCNPs...)
+		policies.KNPs = append(policies.KNPs, p.KNPs...)
+	}
+	return policies, nil
+}
+
+// policyFiles returns the given files and the yaml and json files of the given
+// directories.
+func policyFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			switch filepath.Ext(e.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !e.IsDir() {
+					files = append(files, filepath.Join(path, e.Name()))
+				}
+			}
+		}
+	}
+	return files, nil
+}
+
+func loadFile(file string) (*Policies, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return p, nil
+}
+
+// Fetch retrieves the policies of all namespaces from the cluster.
+func Fetch(ctx context.Context, client k8sPolicyImplementation) (*Policies, error) {
+	policies := &Policies{}
+
+	cnps, err := client.ListCiliumNetworkPolicies(ctx, corev1.NamespaceAll, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list CiliumNetworkPolicies: %w", err)
+	}
+	for i := range cnps.Items {
+		policies.CNPs = append(policies.CNPs, &cnps.Items[i])
+	}
+
+	ccnps, err := client.ListCiliumClusterwideNetworkPolicies(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list CiliumClusterwideNetworkPolicies: %w", err)
+	}
+	for i := range ccnps.Items {
+		policies.CCNPs = append(policies.CCNPs, &ccnps.Items[i])
+	}
+
+	knps, err := client.ListKubernetesNetworkPolicies(ctx, corev1.NamespaceAll, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list NetworkPolicies: %w", err)
+	}
+	for i := range knps.Items {
+		policies.KNPs = append(policies.KNPs, &knps.Items[i])
+	}
+
+	return policies, nil
+}
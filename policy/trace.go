@@ -0,0 +1,628 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	slimmetav1 "github.com/cilium/cilium/pkg/k8s/slim/k8s/apis/meta/v1"
+	"github.com/cilium/cilium/pkg/policy/api"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+)
+
+// Verdict is the outcome of a traced flow.
+type Verdict string
+
+const (
+	VerdictAllowed Verdict = "ALLOWED"
+	VerdictDenied  Verdict = "DENIED"
+)
+
+const (
+	directionIngress = "ingress"
+	directionEgress  = "egress"
+)
+
+// RuleMatch describes a policy rule matching a traced flow.
+type RuleMatch struct {
+	// Policy is the kind, namespace and name of the policy.
+	Policy string
+	// Rule locates the rule within the policy, e.g. spec.ingressDeny[0].
+	Rule string
+	// L7 describes the L7 rules the flow is redirected to the proxy for, if
+	// any.
+	L7 string
+}
+
+func (m RuleMatch) String() string {
+	return fmt.Sprintf("%s (%s)", m.Policy, m.Rule)
+}
+
+// DirectionResult is the outcome of a traced flow in one direction.
+type DirectionResult struct {
+	Direction string
+	// Enforced is whether policies select the subject in this direction.
+	Enforced bool
+	Verdict  Verdict
+	// Deny is the deny rule matching the flow, if any. Deny rules take
+	// precedence over allow rules.
+	Deny *RuleMatch
+	// Allow are the allow rules matching the flow.
+	Allow []RuleMatch
+	// Notes lists the parts of the policies which could not be evaluated
+	// offline.
+	Notes []string
+}
+
+// Result is the outcome of a traced flow.
+type Result struct {
+	Egress  DirectionResult
+	Ingress DirectionResult
+	Verdict Verdict
+}
+
+// Tracer evaluates which policies allow or deny a given flow, without
+// requiring a running agent.
+type Tracer struct {
+	client k8sPolicyImplementation
+	params Parameters
+}
+
+// NewTracer returns a Tracer. The client may be nil if the policies are
+// loaded from files.
+func NewTracer(client k8sPolicyImplementation, p Parameters) *Tracer {
+	if p.ClusterName == "" {
+		p.ClusterName = "default"
+	}
+	return &Tracer{
+		client: client,
+		params: p,
+	}
+}
+
+// Trace loads the policies and traces the flow described by the parameters.
+func (t *Tracer) Trace(ctx context.Context) (*Result, error) {
+	port, proto, err := parsePort(t.params.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := newEndpoint(t.params.SrcLabels, t.params.SrcNamespace, t.namespaceLabels(ctx, t.params.SrcNamespace), t.params.SrcIP, t.params.ClusterName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source: %w", err)
+	}
+	dst, err := newEndpoint(t.params.DstLabels, t.params.DstNamespace, t.namespaceLabels(ctx, t.params.DstNamespace), t.params.DstIP, t.params.ClusterName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination: %w", err)
+	}
+
+	var policies *Policies
+	if len(t.params.PolicyFiles) > 0 {
+		policies, err = LoadFiles(t.params.PolicyFiles)
+	} else if t.client != nil {
+		policies, err = Fetch(ctx, t.client)
+	} else {
+		err = fmt.Errorf("no policy files given and no cluster available")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(t.params.Writer, "🔎 Tracing %s -> %s on port %d/%s against %d policies\n", src, dst, port, proto, policies.Len())
+
+	res, err := tracePolicies(policies, src, dst, port, proto, t.params.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	t.printDirection(res.Egress, src)
+	t.printDirection(res.Ingress, dst)
+	fmt.Fprintf(t.params.Writer, "\nFinal verdict: %s\n", res.Verdict)
+	return res, nil
+}
+
+// namespaceLabels returns the labels of the namespace from the cluster, if
+// available.
+func (t *Tracer) namespaceLabels(ctx context.Context, namespace string) map[string]string {
+	if t.client == nil || namespace == "" {
+		return nil
+	}
+	ns, err := t.client.GetNamespace(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	return ns.Labels
+}
+
+func (t *Tracer) printDirection(r DirectionResult, subject *endpoint) {
+	w := t.params.Writer
+	fmt.Fprintf(w, "\n%s of %s:\n", strings.ToUpper(r.Direction[:1])+r.Direction[1:], subject)
+	switch {
+	case r.Deny != nil:
+		fmt.Fprintf(w, "  ⛔ %s by deny rule %s, deny rules take precedence over allow rules\n", r.Verdict, r.Deny)
+	case !r.Enforced:
+		fmt.Fprintf(w, "  ✅ %s, no policy selects the endpoint for %s (default allow)\n", r.Verdict, r.Direction)
+	case len(r.Allow) == 0:
+		fmt.Fprintf(w, "  ⛔ %s, no rule allows the flow (default deny)\n", r.Verdict)
+	default:
+		fmt.Fprintf(w, "  ✅ %s by:\n", r.Verdict)
+		for _, m := range r.Allow {
+			if m.L7 != "" {
+				fmt.Fprintf(w, "     - %s, redirected to the proxy for %s rules\n", m, m.L7)
+			} else {
+				fmt.Fprintf(w, "     - %s\n", m)
+			}
+		}
+	}
+	for _, note := range r.Notes {
+		fmt.Fprintf(w, "  ℹ️  %s\n", note)
+	}
+}
+
+// tracePolicies evaluates the flow from src to dst on the given port and
+// protocol against the policies. Egress policies are evaluated for the source
+// and ingress policies for the destination, if they are Cilium endpoints.
+func tracePolicies(policies *Policies, src, dst *endpoint, port uint16, proto api.L4Proto, clusterName string) (*Result, error) {
+	api.InitEntities(clusterName, true)
+
+	rules, err := ciliumRules(policies)
+	if err != nil {
+		return nil, err
+	}
+
+	f := tracedFlow{port: port, proto: proto}
+	res := &Result{
+		Egress:  traceDirection(directionEgress, rules, policies.KNPs, src, dst, f),
+		Ingress: traceDirection(directionIngress, rules, policies.KNPs, dst, src, f),
+	}
+	res.Verdict = VerdictAllowed
+	if res.Egress.Verdict == VerdictDenied || res.Ingress.Verdict == VerdictDenied {
+		res.Verdict = VerdictDenied
+	}
+	return res, nil
+}
+
+type tracedFlow struct {
+	port  uint16
+	proto api.L4Proto
+}
+
+// ciliumRule is a rule of a CiliumNetworkPolicy or
+// CiliumClusterwideNetworkPolicy.
+type ciliumRule struct {
+	policy string
+	spec   string
+	rule   *api.Rule
+}
+
+func ciliumRules(policies *Policies) ([]ciliumRule, error) {
+	var rules []ciliumRule
+	add := func(policy string, hasSpec bool, parsed api.Rules) {
+		for i, r := range parsed {
+			spec := "spec"
+			if !hasSpec || i > 0 {
+				idx := i
+				if hasSpec {
+					idx--
+				}
+				spec = fmt.Sprintf("specs[%d]", idx)
+			}
+			rules = append(rules, ciliumRule{policy: policy, spec: spec, rule: r})
+		}
+	}
+
+	for _, cnp := range policies.CNPs {
+		name := cnpName(cnp)
+		parsed, err := parseRules(cnp.DeepCopy().Parse)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		add(name, cnp.Spec != nil, parsed)
+	}
+	for _, ccnp := range policies.CCNPs {
+		name := ccnpName(ccnp)
+		parsed, err := parseRules(ccnp.DeepCopy().Parse)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		add(name, ccnp.Spec != nil, parsed)
+	}
+	return rules, nil
+}
+
+// selects returns whether the rule applies to the endpoint. The host endpoint
+// is only selected by node selectors.
+func (r *ciliumRule) selects(ep *endpoint) bool {
+	if ep.isWorld() {
+		return false
+	}
+	isHost := ep.labels.Has("reserved.host")
+	if r.rule.NodeSelector.LabelSelector != nil {
+		return isHost && r.rule.NodeSelector.Matches(ep.labels)
+	}
+	return !isHost && r.rule.EndpointSelector.Matches(ep.labels)
+}
+
+// peerRule is an ingress or egress, allow or deny rule, abstracting over the
+// direction.
+type peerRule struct {
+	section           string
+	selectors         func(requirements []slimmetav1.LabelSelectorRequirement) api.EndpointSelectorSlice
+	requires          []api.EndpointSelector
+	allowsWildcarding bool
+	ports             []api.PortRule
+	hasICMPs          bool
+	authenticated     bool
+	// unsupported lists the peer selectors which cannot be evaluated offline.
+	unsupported []string
+}
+
+func (r *ciliumRule) peerRules(direction string, deny bool) []peerRule {
+	var rules []peerRule
+	switch {
+	case direction == directionIngress && !deny:
+		for i := range r.rule.Ingress {
+			ir := &r.rule.Ingress[i]
+			rules = append(rules, peerRule{
+				section:           fmt.Sprintf("%s.ingress[%d]", r.spec, i),
+				selectors:         ir.GetSourceEndpointSelectorsWithRequirements,
+				requires:          ir.FromRequires,
+				allowsWildcarding: ir.AllowsWildcarding(),
+				ports:             ir.ToPorts,
+				hasICMPs:          len(ir.ICMPs) > 0,
+				authenticated:     ir.Authentication != nil,
+			})
+		}
+	case direction == directionIngress && deny:
+		for i := range r.rule.IngressDeny {
+			ir := &r.rule.IngressDeny[i]
+			rules = append(rules, peerRule{
+				section:           fmt.Sprintf("%s.ingressDeny[%d]", r.spec, i),
+				selectors:         ir.GetSourceEndpointSelectorsWithRequirements,
+				requires:          ir.FromRequires,
+				allowsWildcarding: ir.AllowsWildcarding(),
+				ports:             denyPorts(ir.ToPorts),
+				hasICMPs:          len(ir.ICMPs) > 0,
+			})
+		}
+	case direction == directionEgress && !deny:
+		for i := range r.rule.Egress {
+			er := &r.rule.Egress[i]
+			rules = append(rules, peerRule{
+				section:           fmt.Sprintf("%s.egress[%d]", r.spec, i),
+				selectors:         er.GetDestinationEndpointSelectorsWithRequirements,
+				requires:          er.ToRequires,
+				allowsWildcarding: er.AllowsWildcarding(),
+				ports:             er.ToPorts,
+				hasICMPs:          len(er.ICMPs) > 0,
+				authenticated:     er.Authentication != nil,
+				unsupported:       unsupportedEgressPeers(&er.EgressCommonRule, len(er.ToFQDNs) > 0),
+			})
+		}
+	case direction == directionEgress && deny:
+		for i := range r.rule.EgressDeny {
+			er := &r.rule.EgressDeny[i]
+			rules = append(rules, peerRule{
+				section:           fmt.Sprintf("%s.egressDeny[%d]", r.spec, i),
+				selectors:         er.GetDestinationEndpointSelectorsWithRequirements,
+				requires:          er.ToRequires,
+				allowsWildcarding: er.AllowsWildcarding(),
+				ports:             denyPorts(er.ToPorts),
+				hasICMPs:          len(er.ICMPs) > 0,
+				unsupported:       unsupportedEgressPeers(&er.EgressCommonRule, false),
+			})
+		}
+	}
+	return rules
+}
+
+func denyPorts(ports api.PortDenyRules) []api.PortRule {
+	res := make([]api.PortRule, 0, len(ports))
+	for _, p := range ports {
+		res = append(res, api.PortRule{Ports: p.Ports})
+	}
+	return res
+}
+
+func unsupportedEgressPeers(r *api.EgressCommonRule, toFQDNs bool) []string {
+	var res []string
+	if toFQDNs {
+		res = append(res, "toFQDNs")
+	}
+	if len(r.ToServices) > 0 {
+		res = append(res, "toServices")
+	}
+	if len(r.ToGroups) > 0 {
+		res = append(res, "toGroups")
+	}
+	return res
+}
+
+// traceDirection evaluates the flow in the given direction, for the subject
+// endpoint the policies apply to and its peer.
+func traceDirection(direction string, rules []ciliumRule, knps []*networkingv1.NetworkPolicy, subject, peer *endpoint, f tracedFlow) DirectionResult {
+	res := DirectionResult{Direction: direction, Verdict: VerdictAllowed}
+
+	var selecting []ciliumRule
+	var allowRequirements, denyRequirements []slimmetav1.LabelSelectorRequirement
+	for _, r := range rules {
+		if !r.selects(subject) {
+			continue
+		}
+		selecting = append(selecting, r)
+		for _, pr := range r.peerRules(direction, false) {
+			res.Enforced = true
+			for _, sel := range pr.requires {
+				allowRequirements = append(allowRequirements, sel.ConvertToLabelSelectorRequirementSlice()...)
+			}
+		}
+		for _, pr := range r.peerRules(direction, true) {
+			res.Enforced = true
+			for _, sel := range pr.requires {
+				denyRequirements = append(denyRequirements, sel.ConvertToLabelSelectorRequirementSlice()...)
+			}
+		}
+	}
+
+	// Deny rules take precedence over any allow rule.
+	for _, r := range selecting {
+		for _, pr := range r.peerRules(direction, true) {
+			if m, _ := pr.matches(peer, f, denyRequirements, &res.Notes, r.policy); m {
+				res.Deny = &RuleMatch{Policy: r.policy, Rule: pr.section}
+				res.Verdict = VerdictDenied
+				return res
+			}
+		}
+	}
+
+	for _, r := range selecting {
+		for _, pr := range r.peerRules(direction, false) {
+			if m, l7 := pr.matches(peer, f, allowRequirements, &res.Notes, r.policy); m {
+				res.Allow = append(res.Allow, RuleMatch{Policy: r.policy, Rule: pr.section, L7: l7})
+			}
+		}
+	}
+
+	for _, np := range knps {
+		if !knpSelects(np, subject, direction) {
+			continue
+		}
+		res.Enforced = true
+		name := knpName(np)
+		if direction == directionIngress {
+			for i, r := range np.Spec.Ingress {
+				if knpPeersMatch(np, r.From, peer) && knpPortsMatch(r.Ports, f, &res.Notes, name) {
+					res.Allow = append(res.Allow, RuleMatch{Policy: name, Rule: fmt.Sprintf("spec.ingress[%d]", i)})
+				}
+			}
+		} else {
+			for i, r := range np.Spec.Egress {
+				if knpPeersMatch(np, r.To, peer) && knpPortsMatch(r.Ports, f, &res.Notes, name) {
+					res.Allow = append(res.Allow, RuleMatch{Policy: name, Rule: fmt.Sprintf("spec.egress[%d]", i)})
+				}
+			}
+		}
+	}
+
+	if res.Enforced && len(res.Allow) == 0 {
+		res.Verdict = VerdictDenied
+	}
+	return res
+}
+
+// matches returns whether the rule matches the peer and flow and, if so, the
+// L7 rules the flow is redirected to the proxy for.
+func (pr *peerRule) matches(peer *endpoint, f tracedFlow, requirements []slimmetav1.LabelSelectorRequirement, notes *[]string, policy string) (bool, string) {
+	for _, u := range pr.unsupported {
+		addNote(notes, fmt.Sprintf("%s (%s) uses %s, which cannot be evaluated offline", policy, pr.section, u))
+	}
+
+	selectors := pr.selectors(requirements)
+	if len(selectors) == 0 {
+		// L4-only rules apply to all peers, while rules without peer nor
+		// port select nothing.
+		if len(pr.ports) == 0 || !pr.allowsWildcarding {
+			return false, ""
+		}
+		selectors = api.EndpointSelectorSlice{api.WildcardEndpointSelector}
+	}
+	if !selectors.Matches(peer.labels) {
+		return false, ""
+	}
+
+	if len(pr.ports) == 0 {
+		if pr.hasICMPs {
+			// ICMP-only rules do not apply to the traced port.
+			return false, ""
+		}
+		return true, ""
+	}
+	for _, p := range pr.ports {
+		if len(p.Ports) == 0 {
+			return true, l7Description(&p)
+		}
+		for _, pp := range p.Ports {
+			if portMatches(pp, f, notes, policy, pr.section) {
+				return true, l7Description(&p)
+			}
+		}
+	}
+	return false, ""
+}
+
+// portMatches returns whether the port and protocol select the flow. Named
+// ports cannot be resolved offline and never match.
+func portMatches(pp api.PortProtocol, f tracedFlow, notes *[]string, policy, section string) bool {
+	if pp.Protocol != "" && pp.Protocol != api.ProtoAny && pp.Protocol != f.proto {
+		return false
+	}
+	if pp.Port == "" || pp.Port == "0" {
+		return true
+	}
+	port, err := strconv.ParseUint(pp.Port, 10, 16)
+	if err != nil {
+		addNote(notes, fmt.Sprintf("%s (%s) uses named port %q, which cannot be resolved offline", policy, section, pp.Port))
+		return false
+	}
+	return uint16(port) == f.port
+}
+
+// l7Description describes the L7 rules or TLS settings requiring the flow to
+// be redirected to the proxy, if any.
+func l7Description(p *api.PortRule) string {
+	var res []string
+	if p.Rules != nil {
+		if len(p.Rules.HTTP) > 0 {
+			res = append(res, "HTTP")
+		}
+		if len(p.Rules.Kafka) > 0 {
+			res = append(res, "Kafka")
+		}
+		if len(p.Rules.DNS) > 0 {
+			res = append(res, "DNS")
+		}
+		if p.Rules.L7Proto != "" {
+			res = append(res, p.Rules.L7Proto)
+		}
+	}
+	if p.TerminatingTLS != nil || p.OriginatingTLS != nil {
+		res = append(res, "TLS")
+	}
+	if p.Listener != nil {
+		res = append(res, fmt.Sprintf("listener %s", p.Listener.Name))
+	}
+	return strings.Join(res, ", ")
+}
+
+func addNote(notes *[]string, note string) {
+	for _, n := range *notes {
+		if n == note {
+			return
+		}
+	}
+	*notes = append(*notes, note)
+}
+
+// knpSelects returns whether the NetworkPolicy applies to the endpoint in the
+// given direction.
+func knpSelects(np *networkingv1.NetworkPolicy, ep *endpoint, direction string) bool {
+	if !ep.isPod() || np.Namespace != ep.namespace || !labelSelectorMatches(&np.Spec.PodSelector, ep.podLabels) {
+		return false
+	}
+	if len(np.Spec.PolicyTypes) == 0 {
+		return direction == directionIngress || len(np.Spec.Egress) > 0
+	}
+	for _, t := range np.Spec.PolicyTypes {
+		if strings.EqualFold(string(t), direction) {
+			return true
+		}
+	}
+	return false
+}
+
+func knpPeersMatch(np *networkingv1.NetworkPolicy, peers []networkingv1.NetworkPolicyPeer, peer *endpoint) bool {
+	if len(peers) == 0 {
+		return true
+	}
+	for _, p := range peers {
+		if p.IPBlock != nil {
+			if peer.isWorld() && ipBlockMatches(p.IPBlock, peer.ip) {
+				return true
+			}
+			continue
+		}
+		if !peer.isPod() {
+			continue
+		}
+		if p.NamespaceSelector == nil {
+			if peer.namespace != np.Namespace {
+				continue
+			}
+		} else if !labelSelectorMatches(p.NamespaceSelector, peer.namespaceLabels) {
+			continue
+		}
+		if p.PodSelector != nil && !labelSelectorMatches(p.PodSelector, peer.podLabels) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func ipBlockMatches(b *networkingv1.IPBlock, ip netip.Addr) bool {
+	prefix, err := netip.ParsePrefix(b.CIDR)
+	if err != nil || !prefix.Contains(ip) {
+		return false
+	}
+	for _, except := range b.Except {
+		if p, err := netip.ParsePrefix(except); err == nil && p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func knpPortsMatch(ports []networkingv1.NetworkPolicyPort, f tracedFlow, notes *[]string, policy string) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, p := range ports {
+		proto := corev1.ProtocolTCP
+		if p.Protocol != nil {
+			proto = *p.Protocol
+		}
+		if string(proto) != string(f.proto) {
+			continue
+		}
+		if p.Port == nil {
+			return true
+		}
+		if p.Port.StrVal != "" {
+			addNote(notes, fmt.Sprintf("%s uses named port %q, which cannot be resolved offline", policy, p.Port.StrVal))
+			continue
+		}
+		start, end := p.Port.IntVal, p.Port.IntVal
+		if p.EndPort != nil {
+			end = *p.EndPort
+		}
+		if int32(f.port) >= start && int32(f.port) <= end {
+			return true
+		}
+	}
+	return false
+}
+
+func labelSelectorMatches(sel *metav1.LabelSelector, lbls map[string]string) bool {
+	s, err := metav1.LabelSelectorAsSelector(sel)
+	if err != nil {
+		return false
+	}
+	return s.Matches(k8slabels.Set(lbls))
+}
+
+// parsePort parses port[/protocol], the protocol defaulting to TCP.
+func parsePort(s string) (uint16, api.L4Proto, error) {
+	portStr, protoStr, _ := strings.Cut(s, "/")
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || port == 0 {
+		return 0, "", fmt.Errorf("invalid port %q, must be port[/protocol]", s)
+	}
+	proto := api.ProtoTCP
+	if protoStr != "" {
+		proto = api.L4Proto(strings.ToUpper(protoStr))
+	}
+	switch proto {
+	case api.ProtoTCP, api.ProtoUDP, api.ProtoSCTP:
+	default:
+		return 0, "", fmt.Errorf("unsupported protocol %q, must be one of TCP, UDP, SCTP", protoStr)
+	}
+	return uint16(port), proto, nil
+}
@@ -0,0 +1,268 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package policy
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/cilium/pkg/policy/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicies = `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: backend
+  namespace: app
+spec:
+  endpointSelector:
+    matchLabels:
+      app: backend
+  ingress:
+  - fromEndpoints:
+    - matchLabels:
+        app: frontend
+    toPorts:
+    - ports:
+      - port: "8080"
+        protocol: TCP
+      rules:
+        http:
+        - method: GET
+          path: /api
+  - fromEndpoints:
+    - matchLabels:
+        app: frontend
+    toPorts:
+    - ports:
+      - port: "9090"
+  ingressDeny:
+  - fromEndpoints:
+    - matchLabels:
+        legacy: "true"
+---
+apiVersion: cilium.io/v2
+kind: CiliumClusterwideNetworkPolicy
+metadata:
+  name: db
+spec:
+  endpointSelector:
+    matchLabels:
+      app: db
+  ingress:
+  - fromEndpoints:
+    - matchLabels:
+        k8s:io.kubernetes.pod.namespace: app
+  - fromEntities:
+    - world
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: monitoring
+  namespace: metrics
+spec:
+  podSelector:
+    matchLabels:
+      app: prometheus
+  policyTypes:
+  - Egress
+  egress:
+  - to:
+    - namespaceSelector:
+        matchLabels:
+          team: app
+    ports:
+    - port: 9000
+      endPort: 9100
+  - to:
+    - ipBlock:
+        cidr: 192.0.2.0/24
+        except:
+        - 192.0.2.128/25
+`
+
+func trace(t *testing.T, src, dst *endpoint, port uint16, proto api.L4Proto) *Result {
+	t.Helper()
+	policies, err := ParseYAML(testPolicies)
+	require.NoError(t, err)
+	res, err := tracePolicies(policies, src, dst, port, proto, "default")
+	require.NoError(t, err)
+	return res
+}
+
+func pod(t *testing.T, namespace string, namespaceLabels map[string]string, lbls ...string) *endpoint {
+	t.Helper()
+	ep, err := newEndpoint(lbls, namespace, namespaceLabels, "", "default")
+	require.NoError(t, err)
+	return ep
+}
+
+func ip(t *testing.T, addr string) *endpoint {
+	t.Helper()
+	ep, err := newEndpoint(nil, "", nil, addr, "default")
+	require.NoError(t, err)
+	return ep
+}
+
+func TestParseYAML(t *testing.T) {
+	policies, err := ParseYAML(testPolicies)
+	require.NoError(t, err)
+	assert.Len(t, policies.CNPs, 1)
+	assert.Len(t, policies.CCNPs, 1)
+	assert.Len(t, policies.KNPs, 1)
+	assert.Equal(t, 3, policies.Len())
+
+	_, err = ParseYAML("apiVersion: v1\nkind: Pod\nmetadata:\n  name: foo\n")
+	assert.Error(t, err)
+}
+
+func TestNewEndpoint(t *testing.T) {
+	_, err := newEndpoint([]string{"app=foo"}, "default", nil, "10.0.0.1", "default")
+	assert.Error(t, err)
+	_, err = newEndpoint(nil, "default", nil, "", "default")
+	assert.Error(t, err)
+	_, err = newEndpoint(nil, "", nil, "foo", "default")
+	assert.Error(t, err)
+
+	ep := pod(t, "app", map[string]string{"team": "app"}, "app=frontend")
+	assert.True(t, ep.isPod())
+	assert.Equal(t, "frontend", ep.labels.Get("k8s.app"))
+	assert.Equal(t, "app", ep.labels.Get("k8s.io.kubernetes.pod.namespace"))
+	assert.Equal(t, "app", ep.labels.Get("k8s.io.cilium.k8s.namespace.labels.team"))
+	assert.Equal(t, "app/{app=frontend}", ep.String())
+
+	host := pod(t, "app", nil, "reserved:host")
+	assert.False(t, host.isPod())
+	assert.False(t, host.isWorld())
+
+	world := ip(t, "192.0.2.1")
+	assert.True(t, world.isWorld())
+	assert.True(t, world.labels.Has("reserved.world"))
+}
+
+func TestTraceL7Redirect(t *testing.T) {
+	res := trace(t, pod(t, "app", nil, "app=frontend"), pod(t, "app", nil, "app=backend"), 8080, api.ProtoTCP)
+	assert.Equal(t, VerdictAllowed, res.Verdict)
+	assert.False(t, res.Egress.Enforced)
+	assert.True(t, res.Ingress.Enforced)
+	require.Len(t, res.Ingress.Allow, 1)
+	assert.Equal(t, "CiliumNetworkPolicy app/backend", res.Ingress.Allow[0].Policy)
+	assert.Equal(t, "spec.ingress[0]", res.Ingress.Allow[0].Rule)
+	assert.Equal(t, "HTTP", res.Ingress.Allow[0].L7)
+
+	res = trace(t, pod(t, "app", nil, "app=frontend"), pod(t, "app", nil, "app=backend"), 9090, api.ProtoUDP)
+	assert.Equal(t, VerdictAllowed, res.Verdict, "port rules without protocol match any protocol")
+	require.Len(t, res.Ingress.Allow, 1)
+	assert.Empty(t, res.Ingress.Allow[0].L7)
+
+	res = trace(t, pod(t, "app", nil, "app=frontend"), pod(t, "app", nil, "app=backend"), 443, api.ProtoTCP)
+	assert.Equal(t, VerdictDenied, res.Verdict)
+	assert.Nil(t, res.Ingress.Deny)
+	assert.Empty(t, res.Ingress.Allow)
+}
+
+func TestTraceDenyPrecedence(t *testing.T) {
+	// The legacy frontend also matches the allow rule.
+	res := trace(t, pod(t, "app", nil, "app=frontend", "legacy=true"), pod(t, "app", nil, "app=backend"), 9090, api.ProtoTCP)
+	assert.Equal(t, VerdictDenied, res.Verdict)
+	require.NotNil(t, res.Ingress.Deny)
+	assert.Equal(t, "spec.ingressDeny[0]", res.Ingress.Deny.Rule)
+
+	// The deny rule is namespaced to the policy namespace.
+	res = trace(t, pod(t, "other", nil, "app=frontend", "legacy=true"), pod(t, "app", nil, "app=backend"), 9090, api.ProtoTCP)
+	assert.Equal(t, VerdictDenied, res.Verdict)
+	assert.Nil(t, res.Ingress.Deny)
+}
+
+func TestTraceClusterwide(t *testing.T) {
+	res := trace(t, pod(t, "app", nil, "app=backend"), pod(t, "db", nil, "app=db"), 5432, api.ProtoTCP)
+	assert.Equal(t, VerdictAllowed, res.Verdict)
+	require.Len(t, res.Ingress.Allow, 1)
+	assert.Equal(t, "CiliumClusterwideNetworkPolicy db", res.Ingress.Allow[0].Policy)
+
+	res = trace(t, ip(t, "203.0.113.1"), pod(t, "db", nil, "app=db"), 5432, api.ProtoTCP)
+	assert.Equal(t, VerdictAllowed, res.Verdict)
+	require.Len(t, res.Ingress.Allow, 1)
+	assert.Equal(t, "spec.ingress[1]", res.Ingress.Allow[0].Rule)
+
+	res = trace(t, pod(t, "other", nil, "app=backend"), pod(t, "db", nil, "app=db"), 5432, api.ProtoTCP)
+	assert.Equal(t, VerdictDenied, res.Verdict)
+}
+
+func TestTraceNetworkPolicy(t *testing.T) {
+	prometheus := pod(t, "metrics", nil, "app=prometheus")
+
+	res := trace(t, prometheus, pod(t, "app", map[string]string{"team": "app"}, "app=frontend"), 9050, api.ProtoTCP)
+	assert.Equal(t, VerdictAllowed, res.Verdict)
+	require.Len(t, res.Egress.Allow, 1)
+	assert.Equal(t, "NetworkPolicy metrics/monitoring", res.Egress.Allow[0].Policy)
+	assert.Equal(t, "spec.egress[0]", res.Egress.Allow[0].Rule)
+
+	res = trace(t, prometheus, pod(t, "app", map[string]string{"team": "app"}, "app=frontend"), 8080, api.ProtoTCP)
+	assert.Equal(t, VerdictDenied, res.Verdict)
+	assert.True(t, res.Egress.Enforced)
+
+	res = trace(t, prometheus, pod(t, "app", nil, "app=frontend"), 9050, api.ProtoTCP)
+	assert.Equal(t, VerdictDenied, res.Verdict)
+
+	res = trace(t, prometheus, ip(t, "192.0.2.1"), 443, api.ProtoTCP)
+	assert.Equal(t, VerdictAllowed, res.Verdict)
+	res = trace(t, prometheus, ip(t, "192.0.2.129"), 443, api.ProtoTCP)
+	assert.Equal(t, VerdictDenied, res.Verdict)
+}
+
+func TestTraceDefaultAllow(t *testing.T) {
+	res := trace(t, pod(t, "other", nil, "app=foo"), pod(t, "other", nil, "app=bar"), 80, api.ProtoTCP)
+	assert.Equal(t, VerdictAllowed, res.Verdict)
+	assert.False(t, res.Egress.Enforced)
+	assert.False(t, res.Ingress.Enforced)
+}
+
+func TestTracer(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "policies.yaml"), []byte(testPolicies), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a policy"), 0o644))
+
+	var out bytes.Buffer
+	res, err := NewTracer(nil, Parameters{
+		SrcLabels:    []string{"app=frontend"},
+		SrcNamespace: "app",
+		DstLabels:    []string{"app=backend"},
+		DstNamespace: "app",
+		Port:         "8080",
+		PolicyFiles:  []string{dir},
+		Writer:       &out,
+	}).Trace(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, VerdictAllowed, res.Verdict)
+	assert.Contains(t, out.String(), "against 3 policies")
+	assert.Contains(t, out.String(), "redirected to the proxy for HTTP rules")
+	assert.Contains(t, out.String(), "Final verdict: ALLOWED")
+
+	_, err = NewTracer(nil, Parameters{SrcLabels: []string{"app=frontend"}, DstIP: "192.0.2.1", Port: "443", Writer: &out}).Trace(context.Background())
+	assert.Error(t, err, "no policies available")
+}
+
+func TestParsePort(t *testing.T) {
+	port, proto, err := parsePort("8080/udp")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(8080), port)
+	assert.Equal(t, api.ProtoUDP, proto)
+
+	_, proto, err = parsePort("53")
+	require.NoError(t, err)
+	assert.Equal(t, api.ProtoTCP, proto)
+
+	for _, s := range []string{"", "http", "0", "70000", "80/ICMP"} {
+		_, _, err = parsePort(s)
+		assert.Error(t, err, s)
+	}
+}
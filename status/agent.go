@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package status
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	healthModels "github.com/cilium/cilium/api/v1/health/models"
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/cilium/cilium/pkg/client"
+	healthClient "github.com/cilium/cilium/pkg/health/client"
+
+	"github.com/cilium/cilium-cli/defaults"
+)
+
+// AgentStatus is the detailed status reported by a single Cilium agent.
+type AgentStatus struct {
+	Pod    string                             `json:"pod"`
+	Node   string                             `json:"node"`
+	Status *models.StatusResponse             `json:"status,omitempty"`
+	Health *healthModels.HealthStatusResponse `json:"health,omitempty"`
+
+	// Errors are the errors encountered while collecting the status of the
+	// agent.
+	Errors []error `json:"-"`
+}
+
+// AgentStatuses returns the detailed status of the Cilium agents, sorted by
+// node. If node is not empty, only the agent running on the given node is
+// returned.
+func (s *Status) AgentStatuses(node string) ([]AgentStatus, error) {
+	var agents []AgentStatus
+	for pod, r := range s.CiliumStatus {
+		if node != "" && s.CiliumNodes[pod] != node {
+			continue
+		}
+		agents = append(agents, AgentStatus{
+			Pod:    pod,
+			Node:   s.CiliumNodes[pod],
+			Status: r,
+			Health: s.CiliumHealth[pod],
+			Errors: s.agentErrors(pod),
+		})
+	}
+
+	if node != "" && len(agents) == 0 {
+		return nil, fmt.Errorf("no Cilium agent found on node %s", node)
+	}
+
+	sort.Slice(agents, func(i, j int) bool {
+		if agents[i].Node != agents[j].Node {
+			return agents[i].Node < agents[j].Node
+		}
+		return agents[i].Pod < agents[j].Pod
+	})
+
+	return agents, nil
+}
+
+func (s *Status) agentErrors(pod string) []error {
+	if e := s.Errors[defaults.AgentDaemonSetName][pod]; e != nil {
+		return e.Errors
+	}
+	return nil
+}
+
+// Format renders the detailed status of the agent the same way
+// 'cilium status --verbose' does when run in the agent pod.
+func (a AgentStatus) Format() string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "Cilium agent %s on node %s:\n", a.Pod, a.Node)
+	if a.Status == nil {
+		buf.WriteString(Red + "Status not available" + Reset + "\n")
+		for _, err := range a.Errors {
+			fmt.Fprintf(&buf, "  %s%s%s\n", Red, err, Reset)
+		}
+		return buf.String()
+	}
+
+	w := tabwriter.NewWriter(&buf, 2, 0, 3, ' ', 0)
+	client.FormatStatusResponse(w, a.Status, client.StatusAllDetails)
+	if a.Health != nil {
+		healthClient.FormatHealthStatusResponse(w, a.Health, true, true, true, 0)
+	}
+	w.Flush()
+
+	return buf.String()
+}
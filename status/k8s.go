@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	healthModels "github.com/cilium/cilium/api/v1/health/models"
 	"github.com/cilium/cilium/api/v1/models"
 	ciliumv2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
 	"github.com/cilium/workerpool"
@@ -50,6 +51,23 @@ type K8sStatusParameters struct {
 
 	// The output format
 	Output string
+
+	// Watch keeps refreshing the status, highlighting the changes between
+	// refreshes.
+	Watch bool
+	// WatchInterval is the interval at which the status is refreshed in
+	// watch mode and when serving metrics.
+	WatchInterval time.Duration
+
+	// Node restricts the detailed status of the Cilium agents to the agent
+	// running on the given node.
+	Node string
+	// Verbose collects and reports the detailed status of all Cilium agents.
+	Verbose bool
+
+	// ServeMetrics is the address to serve the status as Prometheus metrics
+	// on. Metrics are not served if empty.
+	ServeMetrics string
 }
 
 type K8sStatusCollector struct {
@@ -59,6 +77,7 @@ type K8sStatusCollector struct {
 
 type k8sImplementation interface {
 	CiliumStatus(ctx context.Context, namespace, pod string) (*models.StatusResponse, error)
+	CiliumHealthStatus(ctx context.Context, namespace, pod string) (*healthModels.HealthStatusResponse, error)
 	GetDaemonSet(ctx context.Context, namespace, name string, options metav1.GetOptions) (*appsv1.DaemonSet, error)
 	GetDeployment(ctx context.Context, namespace, name string, options metav1.GetOptions) (*appsv1.Deployment, error)
 	ListPods(ctx context.Context, namespace string, options metav1.ListOptions) (*corev1.PodList, error)
@@ -281,6 +300,12 @@ func (s K8sStatusParameters) waitTimeout() time.Duration {
 	return 5 * time.Minute
 }
 
+// agentDetailsRequested returns true if the detailed status of the Cilium agent
+// running on the given node is requested.
+func (k *K8sStatusCollector) agentDetailsRequested(node string) bool {
+	return k.params.Verbose || (k.params.Node != "" && k.params.Node == node)
+}
+
 func (k *K8sStatusCollector) statusIsReady(s *Status) bool {
 	if s.totalErrors() > 0 {
 		return false
@@ -586,11 +611,25 @@ func (k *K8sStatusCollector) status(ctx context.Context) *Status {
 						err = fmt.Errorf("container %s %s: %s", defaults.AgentContainerName, desc, lastLog)
 					}
 
+					// Retrieve the cluster health only if the detailed status
+					// of the agent is requested, as it is not aggregated.
+					var health *healthModels.HealthStatusResponse
+					var healthErr error
+					if err == nil && k.agentDetailsRequested(pod.Spec.NodeName) {
+						health, healthErr = k.client.CiliumHealthStatus(ctx, k.params.Namespace, pod.Name)
+					}
+
 					status.mutex.Lock()
 					defer status.mutex.Unlock()
 
 					status.parseStatusResponse(defaults.AgentDaemonSetName, pod.Name, s, err)
 					status.CiliumStatus[pod.Name] = s
+					status.CiliumNodes[pod.Name] = pod.Spec.NodeName
+					if healthErr != nil {
+						status.CollectionError(fmt.Errorf("unable to retrieve cilium-health status of pod %s: %w", pod.Name, healthErr))
+					} else if health != nil {
+						status.CiliumHealth[pod.Name] = health
+					}
 
 					return nil
 				},
@@ -5,14 +5,18 @@ package status
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
+	healthModels "github.com/cilium/cilium/api/v1/health/models"
 	"github.com/cilium/cilium/api/v1/models"
 	ciliumv2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
 	"github.com/go-openapi/strfmt"
+	"github.com/prometheus/client_golang/prometheus"
 	"gopkg.in/check.v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -40,6 +44,7 @@ type k8sStatusMockClient struct {
 	deployment         map[string]*appsv1.Deployment
 	podList            map[string]*corev1.PodList
 	status             map[string]*models.StatusResponse
+	health             map[string]*healthModels.HealthStatusResponse
 	ciliumEndpointList map[string]*ciliumv2.CiliumEndpointList
 }
 
@@ -53,6 +58,7 @@ func (c *k8sStatusMockClient) reset() {
 	c.daemonSet = map[string]*appsv1.DaemonSet{}
 	c.podList = map[string]*corev1.PodList{}
 	c.status = map[string]*models.StatusResponse{}
+	c.health = map[string]*healthModels.HealthStatusResponse{}
 	c.ciliumEndpointList = map[string]*ciliumv2.CiliumEndpointList{}
 }
 
@@ -156,6 +162,14 @@ func (c *k8sStatusMockClient) CiliumStatus(_ context.Context, _, pod string) (*m
 	return s, nil
 }
 
+func (c *k8sStatusMockClient) CiliumHealthStatus(_ context.Context, _, pod string) (*healthModels.HealthStatusResponse, error) {
+	s, ok := c.health[pod]
+	if !ok {
+		return nil, fmt.Errorf("pod %s not found", pod)
+	}
+	return s, nil
+}
+
 func (b *StatusSuite) TestMockClient(c *check.C) {
 	client := newK8sStatusMockClient()
 	c.Assert(client, check.Not(check.IsNil))
@@ -248,3 +262,162 @@ func (b *StatusSuite) TestFormat(c *check.C) {
 	buf = nilStatus.Format()
 	c.Assert(buf, check.Equals, "")
 }
+
+func (b *StatusSuite) TestDiffStatus(c *check.C) {
+	now := time.Now()
+
+	prev := newStatus()
+	prev.PodState[defaults.AgentDaemonSetName] = PodStateCount{Type: "DaemonSet", Desired: 3, Ready: 3, Available: 3}
+	prev.PhaseCount[defaults.AgentDaemonSetName] = MapCount{"Running": 3}
+	prev.CiliumStatus["cilium-0"] = &models.StatusResponse{}
+	prev.CiliumStatus["cilium-1"] = &models.StatusResponse{}
+	prev.AddAggregatedWarning(defaults.AgentDaemonSetName, "cilium-1", fmt.Errorf("Kubernetes: degraded"))
+
+	c.Assert(diffStatus(nil, prev, now), check.HasLen, 0)
+	c.Assert(diffStatus(prev, prev, now), check.HasLen, 0)
+
+	cur := newStatus()
+	cur.PodState[defaults.AgentDaemonSetName] = PodStateCount{Type: "DaemonSet", Desired: 3, Ready: 2, Available: 2, Unavailable: 1}
+	cur.PodState[defaults.OperatorDeploymentName] = PodStateCount{Type: "Deployment", Desired: 1, Ready: 1, Available: 1}
+	cur.PhaseCount[defaults.AgentDaemonSetName] = MapCount{"Running": 2, "Pending": 1}
+	cur.CiliumStatus["cilium-0"] = &models.StatusResponse{}
+	cur.CiliumStatus["cilium-2"] = &models.StatusResponse{}
+	cur.AddAggregatedError(defaults.AgentDaemonSetName, "cilium-2", fmt.Errorf("pod is pending"))
+
+	changes := diffStatus(prev, cur, now)
+	var got []string
+	for _, t := range changes {
+		c.Assert(t.Time, check.Equals, now)
+		got = append(got, t.String())
+	}
+	c.Assert(got, check.DeepEquals, []string{
+		"cilium: ready 3 → 2, available 3 → 2, unavailable 0 → 1",
+		"cilium: containers Pending 0 → 1, Running 3 → 2",
+		"cilium/cilium-1: pod disappeared",
+		"cilium/cilium-1: warnings 1 → 0",
+		"cilium/cilium-2: pod appeared",
+		"cilium/cilium-2: errors 0 → 1, latest: pod is pending",
+		"cilium-operator: Deployment appeared (Desired: 1, Ready: " + Green + "1/1" + Reset + ", Available: " + Green + "1/1" + Reset + ")",
+	})
+
+	out := formatWatch(cur, changes, changes, now, DefaultWatchInterval)
+	c.Assert(strings.Contains(out, "Changed since last refresh:"), check.Equals, true)
+	c.Assert(strings.Contains(out, "pod is pending"), check.Equals, true)
+}
+
+func (b *StatusSuite) TestStatusMetrics(c *check.C) {
+	s := newStatus()
+	s.PodState[defaults.AgentDaemonSetName] = PodStateCount{Type: "DaemonSet", Desired: 3, Ready: 2, Available: 2, Unavailable: 1}
+	s.PhaseCount[defaults.AgentDaemonSetName] = MapCount{"Running": 2, "Failed": 1}
+	s.ImageCount[defaults.AgentDaemonSetName] = MapCount{"cilium:1.14": 3}
+	for pod, r := range map[string]*models.StatusResponse{
+		"cilium-0": {
+			Kubernetes: &models.K8sStatus{State: "Warning", Msg: "degraded"},
+			Controllers: models.ControllerStatuses{
+				{Name: "sync-endpoints", Status: &models.ControllerStatusStatus{ConsecutiveFailureCount: 4}},
+				{Name: "sync-policy", Status: &models.ControllerStatusStatus{}},
+			},
+		},
+		"cilium-1": {Kubernetes: &models.K8sStatus{State: "Ok"}},
+	} {
+		s.CiliumStatus[pod] = r
+		s.parseStatusResponse(defaults.AgentDaemonSetName, pod, r, nil)
+	}
+
+	metrics := newStatusMetrics()
+	registry := prometheus.NewRegistry()
+	c.Assert(registry.Register(metrics), check.IsNil)
+
+	// Nothing is exposed until the status has been collected once.
+	families, err := registry.Gather()
+	c.Assert(err, check.IsNil)
+	c.Assert(families, check.HasLen, 0)
+
+	metrics.update(s, time.Unix(1700000000, 0))
+	families, err = registry.Gather()
+	c.Assert(err, check.IsNil)
+
+	values := map[string]float64{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			var labels []string
+			for _, l := range m.GetLabel() {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			values[f.GetName()+"{"+strings.Join(labels, ",")+"}"] = m.GetGauge().GetValue()
+		}
+	}
+
+	c.Assert(values["cilium_status_pods_desired{component=cilium,type=DaemonSet}"], check.Equals, 3.0)
+	c.Assert(values["cilium_status_pods_ready{component=cilium,type=DaemonSet}"], check.Equals, 2.0)
+	c.Assert(values["cilium_status_pods_unavailable{component=cilium,type=DaemonSet}"], check.Equals, 1.0)
+	c.Assert(values["cilium_status_pod_phases{component=cilium,phase=Running}"], check.Equals, 2.0)
+	c.Assert(values["cilium_status_errors{component=cilium,pod=cilium-0}"], check.Equals, 1.0)
+	c.Assert(values["cilium_status_warnings{component=cilium,pod=cilium-0}"], check.Equals, 1.0)
+	c.Assert(values["cilium_status_subsystem_healthy{pod=cilium-0,subsystem=kubernetes}"], check.Equals, 0.0)
+	c.Assert(values["cilium_status_subsystem_healthy{pod=cilium-1,subsystem=kubernetes}"], check.Equals, 1.0)
+	c.Assert(values["cilium_status_subsystem_state_info{pod=cilium-0,state=Warning,subsystem=kubernetes}"], check.Equals, 1.0)
+	c.Assert(values["cilium_status_subsystem_state_info{pod=cilium-1,state=Ok,subsystem=kubernetes}"], check.Equals, 1.0)
+	c.Assert(values["cilium_status_controllers_failing{pod=cilium-0}"], check.Equals, 1.0)
+	c.Assert(values["cilium_status_controllers_failing{pod=cilium-1}"], check.Equals, 0.0)
+	c.Assert(values["cilium_status_controller_consecutive_failures{controller=sync-endpoints,pod=cilium-0}"], check.Equals, 4.0)
+	c.Assert(values["cilium_status_images{component=cilium,image=cilium:1.14}"], check.Equals, 3.0)
+	c.Assert(values["cilium_status_last_refresh_timestamp_seconds{}"], check.Equals, 1700000000.0)
+
+	_, ok := values["cilium_status_controller_consecutive_failures{controller=sync-policy,pod=cilium-0}"]
+	c.Assert(ok, check.Equals, false)
+}
+
+func (b *StatusSuite) TestAgentStatuses(c *check.C) {
+	s := newStatus()
+	s.CiliumStatus["cilium-b"] = &models.StatusResponse{
+		Kvstore: &models.Status{State: "Ok", Msg: "Disabled"},
+		Controllers: models.ControllerStatuses{
+			{Name: "sync-endpoints", Status: &models.ControllerStatusStatus{ConsecutiveFailureCount: 2, LastFailureMsg: "timeout"}},
+		},
+	}
+	s.CiliumStatus["cilium-a"] = &models.StatusResponse{}
+	s.CiliumStatus["cilium-c"] = nil
+	s.AddAggregatedError(defaults.AgentDaemonSetName, "cilium-c", errors.New("unable to retrieve cilium status: connection refused"))
+	s.CiliumNodes = map[string]string{"cilium-a": "node-2", "cilium-b": "node-1", "cilium-c": "node-3"}
+	s.CiliumHealth["cilium-b"] = &healthModels.HealthStatusResponse{Timestamp: "now"}
+
+	agents, err := s.AgentStatuses("")
+	c.Assert(err, check.IsNil)
+	c.Assert(agents, check.HasLen, 3)
+	c.Assert(agents[0].Pod, check.Equals, "cilium-b")
+	c.Assert(agents[1].Pod, check.Equals, "cilium-a")
+	c.Assert(agents[2].Pod, check.Equals, "cilium-c")
+	for _, a := range agents {
+		c.Assert(a.Format(), check.Not(check.Equals), "")
+	}
+
+	agents, err = s.AgentStatuses("node-1")
+	c.Assert(err, check.IsNil)
+	c.Assert(agents, check.HasLen, 1)
+	c.Assert(agents[0].Health, check.Not(check.IsNil))
+
+	out := agents[0].Format()
+	c.Assert(strings.HasPrefix(out, "Cilium agent cilium-b on node node-1:\n"), check.Equals, true)
+	c.Assert(strings.Contains(out, "KVStore:"), check.Equals, true)
+	c.Assert(strings.Contains(out, "sync-endpoints"), check.Equals, true)
+	c.Assert(strings.Contains(out, "Cluster health:"), check.Equals, true)
+
+	agents, err = s.AgentStatuses("node-3")
+	c.Assert(err, check.IsNil)
+	out = agents[0].Format()
+	c.Assert(strings.Contains(out, "Status not available"), check.Equals, true)
+	c.Assert(strings.Contains(out, "unable to retrieve cilium status: connection refused"), check.Equals, true)
+
+	_, err = s.AgentStatuses("node-4")
+	c.Assert(err, check.ErrorMatches, "no Cilium agent found on node node-4")
+
+	collector, err := NewK8sStatusCollector(newK8sStatusMockClient(), K8sStatusParameters{Node: "node-1"})
+	c.Assert(err, check.IsNil)
+	c.Assert(collector.agentDetailsRequested("node-1"), check.Equals, true)
+	c.Assert(collector.agentDetailsRequested("node-2"), check.Equals, false)
+
+	collector, err = NewK8sStatusCollector(newK8sStatusMockClient(), K8sStatusParameters{Verbose: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(collector.agentDetailsRequested("node-2"), check.Equals, true)
+}
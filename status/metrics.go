@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package status
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cilium/cilium/api/v1/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace = "cilium_status"
+
+	// MetricsPath is the HTTP path the status metrics are served on.
+	MetricsPath = "/metrics"
+)
+
+// statusMetrics is a prometheus.Collector exposing the most recently
+// collected Status.
+type statusMetrics struct {
+	mutex       sync.Mutex
+	status      *Status
+	lastRefresh time.Time
+
+	podsDesired          *prometheus.Desc
+	podsReady            *prometheus.Desc
+	podsAvailable        *prometheus.Desc
+	podsUnavailable      *prometheus.Desc
+	podPhases            *prometheus.Desc
+	errors               *prometheus.Desc
+	warnings             *prometheus.Desc
+	subsystemHealthy     *prometheus.Desc
+	subsystemState       *prometheus.Desc
+	controllersFailing   *prometheus.Desc
+	controllerFailures   *prometheus.Desc
+	images               *prometheus.Desc
+	collectionErrors     *prometheus.Desc
+	lastRefreshTimestamp *prometheus.Desc
+	clusterPods          *prometheus.Desc
+	clusterPodsManaged   *prometheus.Desc
+}
+
+func newStatusMetrics() *statusMetrics {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", name), help, labels, nil)
+	}
+
+	return &statusMetrics{
+		podsDesired:          desc("pods_desired", "Number of desired pods per component", "component", "type"),
+		podsReady:            desc("pods_ready", "Number of ready pods per component", "component", "type"),
+		podsAvailable:        desc("pods_available", "Number of available pods per component", "component", "type"),
+		podsUnavailable:      desc("pods_unavailable", "Number of unavailable pods per component", "component", "type"),
+		podPhases:            desc("pod_phases", "Number of pods per component and phase", "component", "phase"),
+		errors:               desc("errors", "Number of errors reported per component and pod", "component", "pod"),
+		warnings:             desc("warnings", "Number of warnings reported per component and pod", "component", "pod"),
+		subsystemHealthy:     desc("subsystem_healthy", "Whether a subsystem of a Cilium agent is healthy (1) or not (0)", "pod", "subsystem"),
+		subsystemState:       desc("subsystem_state_info", "State reported by a subsystem of a Cilium agent", "pod", "subsystem", "state"),
+		controllersFailing:   desc("controllers_failing", "Number of failing controllers per Cilium agent", "pod"),
+		controllerFailures:   desc("controller_consecutive_failures", "Number of consecutive failures of a failing controller", "pod", "controller"),
+		images:               desc("images", "Number of pods running a given image per component", "component", "image"),
+		collectionErrors:     desc("collection_errors", "Number of errors encountered while collecting the status"),
+		lastRefreshTimestamp: desc("last_refresh_timestamp_seconds", "Unix timestamp of the last status collection"),
+		clusterPods:          desc("cluster_pods", "Number of pods in the cluster"),
+		clusterPodsManaged:   desc("cluster_pods_managed", "Number of pods in the cluster managed by Cilium"),
+	}
+}
+
+// update replaces the status exposed by the collector.
+func (m *statusMetrics) update(s *Status, now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.status = s
+	m.lastRefresh = now
+}
+
+// Describe implements prometheus.Collector.
+func (m *statusMetrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		m.podsDesired, m.podsReady, m.podsAvailable, m.podsUnavailable, m.podPhases,
+		m.errors, m.warnings, m.subsystemHealthy, m.subsystemState, m.controllersFailing, m.controllerFailures,
+		m.images, m.collectionErrors, m.lastRefreshTimestamp, m.clusterPods, m.clusterPodsManaged,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *statusMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s := m.status
+	if s == nil {
+		return
+	}
+
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+
+	for name, state := range s.PodState {
+		gauge(m.podsDesired, float64(state.Desired), name, state.Type)
+		gauge(m.podsReady, float64(state.Ready), name, state.Type)
+		gauge(m.podsAvailable, float64(state.Available), name, state.Type)
+		gauge(m.podsUnavailable, float64(state.Unavailable), name, state.Type)
+	}
+
+	for name, phases := range s.PhaseCount {
+		for phase, count := range phases {
+			gauge(m.podPhases, float64(count), name, phase)
+		}
+	}
+
+	for name, pods := range s.Errors {
+		for pod, count := range pods {
+			gauge(m.errors, float64(len(count.Errors)), name, pod)
+			gauge(m.warnings, float64(len(count.Warnings)), name, pod)
+		}
+	}
+
+	for pod, r := range s.CiliumStatus {
+		if r == nil {
+			continue
+		}
+
+		for subsystem, state := range ciliumSubsystemStates(r) {
+			healthy := 1.0
+			switch strings.ToLower(state) {
+			case "warning", "failure":
+				healthy = 0
+			}
+			gauge(m.subsystemHealthy, healthy, pod, subsystem)
+			gauge(m.subsystemState, 1, pod, subsystem, state)
+		}
+
+		var failing int
+		for _, ctrl := range r.Controllers {
+			if ctrl.Status == nil || ctrl.Status.ConsecutiveFailureCount == 0 {
+				continue
+			}
+			failing++
+			gauge(m.controllerFailures, float64(ctrl.Status.ConsecutiveFailureCount), pod, ctrl.Name)
+		}
+		gauge(m.controllersFailing, float64(failing), pod)
+	}
+
+	for name, images := range s.ImageCount {
+		for image, count := range images {
+			gauge(m.images, float64(count), name, image)
+		}
+	}
+
+	gauge(m.collectionErrors, float64(len(s.CollectionErrors)))
+	gauge(m.lastRefreshTimestamp, float64(m.lastRefresh.Unix()))
+	gauge(m.clusterPods, float64(s.PodsCount.All))
+	gauge(m.clusterPodsManaged, float64(s.PodsCount.ByCilium))
+}
+
+// ciliumSubsystemStates returns the state of the subsystems reported by a
+// Cilium agent, indexed by subsystem name. Subsystems the agent did not report
+// on are omitted.
+func ciliumSubsystemStates(r *models.StatusResponse) map[string]string {
+	states := make(map[string]string)
+	add := func(subsystem string, s *models.Status) {
+		if s != nil {
+			states[subsystem] = s.State
+		}
+	}
+
+	add("cilium", r.Cilium)
+	if r.Cluster != nil {
+		add("health", r.Cluster.CiliumHealth)
+	}
+	if r.Hubble != nil {
+		states["hubble"] = r.Hubble.State
+	}
+	if r.Kubernetes != nil {
+		states["kubernetes"] = r.Kubernetes.State
+	}
+	add("kvstore", r.Kvstore)
+	add("container-runtime", r.ContainerRuntime)
+
+	return states
+}
+
+// ServeMetrics periodically collects the status of the Cilium installation
+// and exposes it as Prometheus metrics on addr. It returns when ctx is done or
+// the HTTP server fails.
+func (k *K8sStatusCollector) ServeMetrics(ctx context.Context, addr string) error {
+	interval := k.params.WatchInterval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	metrics := newStatusMetrics()
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(metrics); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(l)
+	}()
+
+	for {
+		collectCtx, cancel := context.WithTimeout(ctx, k.params.waitTimeout())
+		s := k.status(collectCtx)
+		cancel()
+
+		if ctx.Err() == nil {
+			metrics.update(s, time.Now())
+		}
+
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return srv.Shutdown(shutdownCtx)
+		case err := <-serveErr:
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return err
+		case <-time.After(interval):
+		}
+	}
+}
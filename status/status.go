@@ -11,6 +11,7 @@ import (
 	"text/tabwriter"
 	"time"
 
+	healthModels "github.com/cilium/cilium/api/v1/health/models"
 	"github.com/cilium/cilium/api/v1/models"
 
 	"github.com/cilium/cilium-cli/defaults"
@@ -30,6 +31,7 @@ const (
 const (
 	OutputJSON    = "json"
 	OutputSummary = "summary"
+	OutputYAML    = "yaml"
 )
 
 // MapCount is a map to count number of occurrences of a string
@@ -69,6 +71,8 @@ type PodStateMap map[string]PodStateCount
 
 type CiliumStatusMap map[string]*models.StatusResponse
 
+type CiliumHealthMap map[string]*healthModels.HealthStatusResponse
+
 type ErrorCount struct {
 	Errors   []error
 	Warnings []error
@@ -99,6 +103,14 @@ type Status struct {
 
 	CiliumStatus CiliumStatusMap `json:"cilium_status,omitempty"`
 
+	// CiliumNodes maps the name of each Cilium agent pod to the node it
+	// runs on
+	CiliumNodes map[string]string `json:"cilium_nodes,omitempty"`
+
+	// CiliumHealth is the cluster health reported by the Cilium agents. It
+	// is only collected for the agents whose detailed status is requested.
+	CiliumHealth CiliumHealthMap `json:"cilium_health,omitempty"`
+
 	// Errors is the aggregated errors and warnings of all pods of a
 	// particular deployment type
 	Errors ErrorCountMapMap `json:"errors,omitempty"`
@@ -121,6 +133,8 @@ func newStatus() *Status {
 		PodState:     PodStateMap{},
 		PodsCount:    PodsCount{},
 		CiliumStatus: CiliumStatusMap{},
+		CiliumNodes:  map[string]string{},
+		CiliumHealth: CiliumHealthMap{},
 		Errors:       ErrorCountMapMap{},
 		mutex:        &sync.Mutex{},
 	}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package status
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// DefaultWatchInterval is the default interval at which the status is
+	// refreshed in watch mode.
+	DefaultWatchInterval = 5 * time.Second
+
+	// maxTimelineEntries is the number of transitions kept in the timeline
+	// of the watch mode.
+	maxTimelineEntries = 30
+
+	clearScreen = "\033[H\033[2J"
+)
+
+// Transition is a change between two consecutive status snapshots.
+type Transition struct {
+	// Time is the time at which the change was observed.
+	Time time.Time
+	// Component is the deployment or daemonset the change relates to.
+	Component string
+	// Pod is the name of the pod which changed, if any.
+	Pod string
+	// Message describes the change.
+	Message string
+}
+
+func (t Transition) String() string {
+	name := t.Component
+	if t.Pod != "" && t.Pod != t.Component {
+		name = t.Component + "/" + t.Pod
+	}
+	return fmt.Sprintf("%s: %s", name, t.Message)
+}
+
+// Watch periodically collects the status of the Cilium installation and
+// renders it to w, along with the changes since the previous refresh and a
+// timeline of all the changes observed so far. It returns when ctx is done.
+func (k *K8sStatusCollector) Watch(ctx context.Context, w io.Writer) error {
+	interval := k.params.WatchInterval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	var (
+		prev     *Status
+		timeline []Transition
+	)
+
+	for {
+		collectCtx, cancel := context.WithTimeout(ctx, k.params.waitTimeout())
+		s := k.status(collectCtx)
+		cancel()
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		now := time.Now()
+		changes := diffStatus(prev, s, now)
+		timeline = append(timeline, changes...)
+		if len(timeline) > maxTimelineEntries {
+			timeline = timeline[len(timeline)-maxTimelineEntries:]
+		}
+
+		fmt.Fprint(w, clearScreen)
+		fmt.Fprint(w, formatWatch(s, changes, timeline, now, interval))
+		prev = s
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// diffStatus returns the transitions between the previous and the current
+// status, sorted by component and pod. No transitions are returned for the
+// initial status.
+func diffStatus(prev, cur *Status, now time.Time) []Transition {
+	if prev == nil || cur == nil {
+		return nil
+	}
+
+	var out []Transition
+	add := func(component, pod, format string, a ...interface{}) {
+		out = append(out, Transition{Time: now, Component: component, Pod: pod, Message: fmt.Sprintf(format, a...)})
+	}
+
+	for _, name := range unionKeys(prev.PodState, cur.PodState) {
+		o, okOld := prev.PodState[name]
+		n, okNew := cur.PodState[name]
+		switch {
+		case !okOld:
+			add(name, "", "%s appeared (%s)", n.Type, n.Format())
+		case !okNew:
+			add(name, "", "%s disappeared", o.Type)
+		case o != n:
+			add(name, "", "%s", formatPodStateDelta(o, n))
+		}
+	}
+
+	for _, name := range unionKeys(prev.PhaseCount, cur.PhaseCount) {
+		if delta := formatCountDelta(prev.PhaseCount[name], cur.PhaseCount[name]); delta != "" {
+			add(name, "", "containers %s", delta)
+		}
+	}
+
+	for _, pod := range unionKeys(prev.CiliumStatus, cur.CiliumStatus) {
+		_, okOld := prev.CiliumStatus[pod]
+		_, okNew := cur.CiliumStatus[pod]
+		switch {
+		case !okOld:
+			add("cilium", pod, "pod appeared")
+		case !okNew:
+			add("cilium", pod, "pod disappeared")
+		}
+	}
+
+	for _, component := range unionKeys(prev.Errors, cur.Errors) {
+		oldPods, newPods := prev.Errors[component], cur.Errors[component]
+		for _, pod := range unionKeys(oldPods, newPods) {
+			o, n := oldPods[pod], newPods[pod]
+			if o == nil {
+				o = &ErrorCount{}
+			}
+			if n == nil {
+				n = &ErrorCount{}
+			}
+
+			var items []string
+			if len(o.Errors) != len(n.Errors) {
+				items = append(items, fmt.Sprintf("errors %d → %d", len(o.Errors), len(n.Errors)))
+			}
+			if len(o.Warnings) != len(n.Warnings) {
+				items = append(items, fmt.Sprintf("warnings %d → %d", len(o.Warnings), len(n.Warnings)))
+			}
+			if len(items) == 0 {
+				continue
+			}
+
+			// Include the latest error or warning if the pod got worse.
+			switch {
+			case len(n.Errors) > len(o.Errors):
+				items = append(items, fmt.Sprintf("latest: %s", n.Errors[len(n.Errors)-1]))
+			case len(n.Warnings) > len(o.Warnings):
+				items = append(items, fmt.Sprintf("latest: %s", n.Warnings[len(n.Warnings)-1]))
+			}
+
+			add(component, pod, "%s", strings.Join(items, ", "))
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Component != out[j].Component {
+			return out[i].Component < out[j].Component
+		}
+		return out[i].Pod < out[j].Pod
+	})
+
+	return out
+}
+
+// unionKeys returns the sorted union of the keys of both maps.
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatPodStateDelta(o, n PodStateCount) string {
+	var items []string
+	for _, f := range []struct {
+		name     string
+		from, to int
+	}{
+		{"desired", o.Desired, n.Desired},
+		{"ready", o.Ready, n.Ready},
+		{"available", o.Available, n.Available},
+		{"unavailable", o.Unavailable, n.Unavailable},
+	} {
+		if f.from != f.to {
+			items = append(items, fmt.Sprintf("%s %d → %d", f.name, f.from, f.to))
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+func formatCountDelta(o, n MapCount) string {
+	var items []string
+	for _, k := range unionKeys(o, n) {
+		if o[k] != n[k] {
+			items = append(items, fmt.Sprintf("%s %d → %d", k, o[k], n[k]))
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// formatWatch renders a single refresh of the watch mode.
+func formatWatch(s *Status, changes, timeline []Transition, now time.Time, interval time.Duration) string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "Every %s, last refresh at %s. Press Ctrl-C to exit.\n\n", interval, now.Format(time.RFC3339))
+	buf.WriteString(s.Format())
+
+	buf.WriteString("\nChanged since last refresh:\n")
+	if len(changes) == 0 {
+		buf.WriteString("  (none)\n")
+	}
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, c := range changes {
+		name := c.Component
+		if c.Pod != "" && c.Pod != c.Component {
+			name = c.Pod
+		}
+		fmt.Fprintf(w, "  "+Yellow+"%s"+Reset+"\t%s\n", name, c.Message)
+	}
+	w.Flush()
+
+	buf.WriteString("\nTimeline:\n")
+	if len(timeline) == 0 {
+		buf.WriteString("  (no transitions observed yet)\n")
+	}
+	for _, t := range timeline {
+		fmt.Fprintf(&buf, "  %s  %s\n", t.Time.Format(time.TimeOnly), t)
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,585 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package sysdump
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/blang/semver/v4"
+	ciliumv2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"github.com/cilium/cilium-cli/defaults"
+)
+
+const (
+	AnalyzeOutputText = "text"
+	AnalyzeOutputJSON = "json"
+
+	// maxIdentities is the number of cluster-local security identities
+	// available to a Cilium cluster.
+	maxIdentities = 65535 - 256
+	// identityUsageWarningRatio is the ratio of maxIdentities above which the
+	// number of identities is reported.
+	identityUsageWarningRatio = 0.8
+	// minUnusedIdentities is the number of identities not used by any
+	// endpoint above which unused identities are reported, provided they are
+	// the majority of all identities.
+	minUnusedIdentities = 100
+
+	timestampRegex = `\d{8}-\d{6}`
+)
+
+// Severity is the severity of a Finding.
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Finding is an issue found while analyzing a sysdump archive.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	// Check is the name of the check which reported the finding.
+	Check string `json:"check"`
+	// Object is the object the finding relates to, if any.
+	Object  string `json:"object,omitempty"`
+	Message string `json:"message"`
+}
+
+// AnalyzeOptions configures the analysis of a sysdump archive.
+type AnalyzeOptions struct {
+	// ErrorLogMessages returns the messages which must not appear in the
+	// cilium-agent logs of the given Cilium version, mapped to the messages
+	// for which they are ignored.
+	ErrorLogMessages func(ciliumVersion semver.Version) map[string][]string
+	// Output is the output format, one of AnalyzeOutputText or
+	// AnalyzeOutputJSON.
+	Output string
+}
+
+// sysdumpArchive provides access to the files of a sysdump archive, indexed by
+// their path relative to the sysdump directory.
+type sysdumpArchive struct {
+	files map[string]*zip.File
+}
+
+func (a *sysdumpArchive) read(name string) ([]byte, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found", name)
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// find returns the sorted names of the files matching re, along with the
+// submatches of re.
+func (a *sysdumpArchive) find(re *regexp.Regexp) (names []string, matches [][]string) {
+	for name := range a.files {
+		if re.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		matches = append(matches, re.FindStringSubmatch(name))
+	}
+	return names, matches
+}
+
+// readYAML unmarshals the first file matching the given sysdump file name into
+// v. It returns false if the archive doesn't contain such a file.
+func (a *sysdumpArchive) readYAML(fileName string, v interface{}) (bool, error) {
+	names, _ := a.find(fileNameRegexp(fileName))
+	if len(names) == 0 {
+		return false, nil
+	}
+	data, err := a.read(names[0])
+	if err != nil {
+		return true, err
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return true, fmt.Errorf("failed to parse %s: %w", names[0], err)
+	}
+	return true, nil
+}
+
+// fileNameRegexp returns a regular expression matching the given sysdump file
+// name, in which the timestamp placeholder and the '%s' verbs are replaced by
+// the given regular expressions, in order.
+func fileNameRegexp(fileName string, args ...string) *regexp.Regexp {
+	re := regexp.QuoteMeta(fileName)
+	re = strings.ReplaceAll(re, timestampPlaceholderFileName, timestampRegex)
+	for _, arg := range args {
+		re = strings.Replace(re, "%s", arg, 1)
+	}
+	return regexp.MustCompile("^" + re + "$")
+}
+
+func openSysdumpArchive(r *zip.Reader) *sysdumpArchive {
+	a := &sysdumpArchive{files: make(map[string]*zip.File)}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		// Strip the top-level sysdump directory.
+		name := f.Name
+		if i := strings.Index(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+		a.files[name] = f
+	}
+	return a
+}
+
+// AnalyzeFile analyzes the sysdump archive at the given path and writes the
+// findings to w. It returns an error if any finding has SeverityError.
+func AnalyzeFile(w io.Writer, fileName string, opts AnalyzeOptions) error {
+	r, err := zip.OpenReader(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to open sysdump archive: %w", err)
+	}
+	defer r.Close()
+
+	findings, err := Analyze(&r.Reader, opts)
+	if err != nil {
+		return err
+	}
+
+	switch opts.Output {
+	case AnalyzeOutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(findings); err != nil {
+			return err
+		}
+	case AnalyzeOutputText, "":
+		writeFindings(w, findings)
+	default:
+		return fmt.Errorf("invalid output format %q", opts.Output)
+	}
+
+	var errors int
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errors++
+		}
+	}
+	if errors > 0 {
+		return fmt.Errorf("%d errors found in sysdump", errors)
+	}
+	return nil
+}
+
+func writeFindings(w io.Writer, findings []Finding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "✅ No issues found")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEVERITY\tCHECK\tOBJECT\tMESSAGE")
+	for _, f := range findings {
+		object := f.Object
+		if object == "" {
+			object = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Severity, f.Check, object, f.Message)
+	}
+	tw.Flush()
+}
+
+// Analyze looks for known issues in the sysdump archive read by r. Missing
+// files are skipped, as sysdumps may be collected partially.
+func Analyze(r *zip.Reader, opts AnalyzeOptions) ([]Finding, error) {
+	a := openSysdumpArchive(r)
+
+	var (
+		pods       corev1.PodList
+		endpoints  ciliumv2.CiliumEndpointList
+		identities ciliumv2.CiliumIdentityList
+	)
+	hasPods, err := a.readYAML(kubernetesPodsFileName, &pods)
+	if err != nil {
+		return nil, err
+	}
+	hasEndpoints, err := a.readYAML(ciliumEndpointsFileName, &endpoints)
+	if err != nil {
+		return nil, err
+	}
+	hasIdentities, err := a.readYAML(ciliumIdentitiesFileName, &identities)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	if hasPods {
+		findings = append(findings, checkCrashLoops(&pods)...)
+		findings = append(findings, checkImageVersions(&pods)...)
+		if hasEndpoints {
+			findings = append(findings, checkMissingEndpoints(&pods, &endpoints)...)
+		}
+	}
+	if hasIdentities {
+		var eps *ciliumv2.CiliumEndpointList
+		if hasEndpoints {
+			eps = &endpoints
+		}
+		findings = append(findings, checkIdentities(&identities, eps)...)
+	}
+
+	if opts.ErrorLogMessages != nil {
+		f, err := checkAgentLogs(a, opts.ErrorLogMessages(ciliumVersion(&pods)))
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, f...)
+	}
+
+	f, err := checkBugtoolStatus(a)
+	if err != nil {
+		return nil, err
+	}
+	findings = append(findings, f...)
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity == SeverityError
+		}
+		return false
+	})
+
+	return findings, nil
+}
+
+func podName(p *corev1.Pod) string {
+	return p.Namespace + "/" + p.Name
+}
+
+// selectPods returns the pods matching the given label selector.
+func selectPods(pods *corev1.PodList, selector string) []*corev1.Pod {
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil
+	}
+	var out []*corev1.Pod
+	for i := range pods.Items {
+		if sel.Matches(labels.Set(pods.Items[i].Labels)) {
+			out = append(out, &pods.Items[i])
+		}
+	}
+	return out
+}
+
+// containerImage returns the image of the given container of the pod.
+func containerImage(p *corev1.Pod, container string) string {
+	for _, c := range p.Spec.Containers {
+		if c.Name == container {
+			return c.Image
+		}
+	}
+	return ""
+}
+
+// imageTag returns the tag of the image, without the digest.
+func imageTag(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return ""
+}
+
+// ciliumVersion returns the version of Cilium running in the cluster, based on
+// the image of the agents, defaulting to the version installed by default.
+func ciliumVersion(pods *corev1.PodList) semver.Version {
+	for _, p := range selectPods(pods, DefaultCiliumLabelSelector) {
+		if v, err := semver.ParseTolerant(imageTag(containerImage(p, defaults.AgentContainerName))); err == nil {
+			return v
+		}
+	}
+	return semver.MustParse(strings.TrimPrefix(defaults.Version, "v"))
+}
+
+func checkCrashLoops(pods *corev1.PodList) []Finding {
+	var findings []Finding
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		statuses := append(append([]corev1.ContainerStatus{}, p.Status.InitContainerStatuses...), p.Status.ContainerStatuses...)
+		for _, s := range statuses {
+			if s.State.Waiting == nil || s.State.Waiting.Reason != "CrashLoopBackOff" {
+				continue
+			}
+			msg := fmt.Sprintf("container %s is in CrashLoopBackOff (%d restarts)", s.Name, s.RestartCount)
+			if t := s.LastTerminationState.Terminated; t != nil {
+				msg += fmt.Sprintf(", last terminated with exit code %d (%s)", t.ExitCode, t.Reason)
+			}
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Check:    "crash-loop",
+				Object:   podName(p),
+				Message:  msg,
+			})
+		}
+	}
+	return findings
+}
+
+func checkImageVersions(pods *corev1.PodList) []Finding {
+	var findings []Finding
+
+	agentImages := make(map[string][]string)
+	for _, p := range selectPods(pods, DefaultCiliumLabelSelector) {
+		if image := containerImage(p, defaults.AgentContainerName); image != "" {
+			agentImages[image] = append(agentImages[image], p.Name)
+		}
+	}
+	if len(agentImages) > 1 {
+		var items []string
+		for image, pods := range agentImages {
+			items = append(items, fmt.Sprintf("%s (%d pods)", image, len(pods)))
+		}
+		sort.Strings(items)
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Check:    "image-versions",
+			Message:  fmt.Sprintf("Cilium agents run different images: %s", strings.Join(items, ", ")),
+		})
+	}
+
+	agentTags := make(map[string]struct{})
+	for image := range agentImages {
+		agentTags[imageTag(image)] = struct{}{}
+	}
+	for _, p := range selectPods(pods, DefaultCiliumOperatorLabelSelector) {
+		image := containerImage(p, defaults.OperatorContainerName)
+		if image == "" || len(agentTags) == 0 {
+			continue
+		}
+		if _, ok := agentTags[imageTag(image)]; !ok {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Check:    "image-versions",
+				Object:   podName(p),
+				Message:  fmt.Sprintf("Cilium operator image %s doesn't match the version of the Cilium agents", image),
+			})
+		}
+	}
+
+	return findings
+}
+
+func checkMissingEndpoints(pods *corev1.PodList, endpoints *ciliumv2.CiliumEndpointList) []Finding {
+	ceps := make(map[string]struct{}, len(endpoints.Items))
+	for _, ep := range endpoints.Items {
+		ceps[ep.Namespace+"/"+ep.Name] = struct{}{}
+	}
+
+	var findings []Finding
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		if p.Spec.HostNetwork || p.Status.Phase != corev1.PodRunning || p.Status.PodIP == "" || p.DeletionTimestamp != nil {
+			continue
+		}
+		if _, ok := ceps[podName(p)]; !ok {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Check:    "missing-endpoint",
+				Object:   podName(p),
+				Message:  "running pod has no CiliumEndpoint, it might not be managed by Cilium",
+			})
+		}
+	}
+	return findings
+}
+
+func checkIdentities(identities *ciliumv2.CiliumIdentityList, endpoints *ciliumv2.CiliumEndpointList) []Finding {
+	var findings []Finding
+
+	total := len(identities.Items)
+	if float64(total) > identityUsageWarningRatio*maxIdentities {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Check:    "identities",
+			Message:  fmt.Sprintf("%d identities allocated, close to the maximum of %d", total, maxIdentities),
+		})
+	}
+
+	if endpoints == nil {
+		return findings
+	}
+
+	used := make(map[string]struct{})
+	for _, ep := range endpoints.Items {
+		if ep.Status.Identity != nil {
+			used[strconv.FormatInt(ep.Status.Identity.ID, 10)] = struct{}{}
+		}
+	}
+	var unused int
+	for _, id := range identities.Items {
+		if _, ok := used[id.Name]; !ok {
+			unused++
+		}
+	}
+	if unused > minUnusedIdentities && unused*2 > total {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Check:    "identities",
+			Message:  fmt.Sprintf("%d out of %d identities are not used by any endpoint, identity garbage collection might be lagging behind", unused, total),
+		})
+	}
+
+	return findings
+}
+
+func checkAgentLogs(a *sysdumpArchive, messages map[string][]string) ([]Finding, error) {
+	var findings []Finding
+
+	for _, fileName := range []string{ciliumLogsFileName, ciliumPreviousLogsFileName} {
+		names, matches := a.find(fileNameRegexp(fileName, "(.+)", regexp.QuoteMeta(defaults.AgentContainerName)))
+		for i, name := range names {
+			data, err := a.read(name)
+			if err != nil {
+				return nil, err
+			}
+			object := matches[i][1]
+			if fileName == ciliumPreviousLogsFileName {
+				object += " (previous)"
+			}
+
+			counts, examples := matchLogMessages(string(data), messages)
+			for _, msg := range sortedKeys(counts) {
+				findings = append(findings, Finding{
+					Severity: SeverityError,
+					Check:    "agent-logs",
+					Object:   object,
+					Message:  fmt.Sprintf("found %q %d times, e.g. %s", msg, counts[msg], examples[msg]),
+				})
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// matchLogMessages counts the log lines matching each message, unless they
+// match one of the exceptions of the message. It also returns the first
+// matching line of each message.
+func matchLogMessages(logs string, messages map[string][]string) (map[string]int, map[string]string) {
+	counts := make(map[string]int)
+	examples := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+	nextMessage:
+		for msg, exceptions := range messages {
+			if !strings.Contains(line, msg) {
+				continue
+			}
+			for _, e := range exceptions {
+				if strings.Contains(line, e) {
+					continue nextMessage
+				}
+			}
+			if counts[msg] == 0 {
+				examples[msg] = strings.TrimSpace(line)
+			}
+			counts[msg]++
+		}
+	}
+
+	return counts, examples
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	bugtoolStatusStateRegex      = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9 -]*):\s+(Warning|Failure)\s*(.*)$`)
+	bugtoolStatusControllerRegex = regexp.MustCompile(`^Controller Status:\s+(\d+)/(\d+) healthy`)
+	bugtoolStatusHealthRegex     = regexp.MustCompile(`^Cluster health:\s+(\d+)/(\d+) reachable`)
+)
+
+// checkBugtoolStatus looks for unhealthy subsystems in the 'cilium status
+// --verbose' output of the bugtool archives.
+func checkBugtoolStatus(a *sysdumpArchive) ([]Finding, error) {
+	bugtoolDir := fileNameRegexp(strings.TrimSuffix(ciliumBugtoolFileName, ".tar.gz"), "(.+)")
+
+	var findings []Finding
+	for _, name := range sortedKeys(a.files) {
+		dir, _, ok := strings.Cut(name, "/")
+		if !ok || !strings.Contains(path.Base(name), "status---verbose") {
+			continue
+		}
+		m := bugtoolDir.FindStringSubmatch(dir)
+		if m == nil {
+			continue
+		}
+
+		data, err := a.read(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range unhealthyStatusLines(string(data)) {
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Check:    "agent-status",
+				Object:   m[1],
+				Message:  msg,
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+// unhealthyStatusLines returns the lines of the given 'cilium status' output
+// reporting an unhealthy state.
+func unhealthyStatusLines(status string) []string {
+	var out []string
+	for _, line := range strings.Split(status, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case bugtoolStatusStateRegex.MatchString(line):
+			out = append(out, line)
+		case bugtoolStatusControllerRegex.MatchString(line):
+			m := bugtoolStatusControllerRegex.FindStringSubmatch(line)
+			if m[1] != m[2] {
+				out = append(out, line)
+			}
+		case bugtoolStatusHealthRegex.MatchString(line):
+			m := bugtoolStatusHealthRegex.FindStringSubmatch(line)
+			if m[1] != m[2] {
+				out = append(out, line)
+			}
+		}
+	}
+	return out
+}
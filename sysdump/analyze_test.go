@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package sysdump
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const analyzeTestPods = `apiVersion: v1
+kind: List
+items:
+- metadata:
+    name: cilium-aaaaa
+    namespace: kube-system
+    labels:
+      k8s-app: cilium
+  spec:
+    hostNetwork: true
+    containers:
+    - name: cilium-agent
+      image: quay.io/cilium/cilium:v1.14.1
+  status:
+    phase: Running
+    podIP: 10.0.0.1
+- metadata:
+    name: cilium-bbbbb
+    namespace: kube-system
+    labels:
+      k8s-app: cilium
+  spec:
+    hostNetwork: true
+    containers:
+    - name: cilium-agent
+      image: quay.io/cilium/cilium:v1.14.0
+  status:
+    phase: Running
+    podIP: 10.0.0.2
+    containerStatuses:
+    - name: cilium-agent
+      restartCount: 7
+      state:
+        waiting:
+          reason: CrashLoopBackOff
+      lastState:
+        terminated:
+          exitCode: 1
+          reason: Error
+- metadata:
+    name: client
+    namespace: default
+  spec:
+    containers:
+    - name: client
+      image: alpine
+  status:
+    phase: Running
+    podIP: 10.1.0.1
+- metadata:
+    name: server
+    namespace: default
+  spec:
+    containers:
+    - name: server
+      image: nginx
+  status:
+    phase: Running
+    podIP: 10.1.0.2
+`
+
+const analyzeTestEndpoints = `apiVersion: v1
+kind: List
+items:
+- metadata:
+    name: client
+    namespace: default
+  status:
+    identity:
+      id: 1000
+`
+
+const analyzeTestStatus = `KVStore:                 Ok   Disabled
+Kubernetes:              Ok   1.27 (v1.27.3) [linux/amd64]
+Cilium:                  Warning   Kubernetes service is not ready
+Controller Status:       40/42 healthy
+Cluster health:          2/2 reachable
+`
+
+func newAnalyzeTestArchive(t *testing.T, files map[string]string) *zip.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create("cilium-sysdump-20230101-000000/" + name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return r
+}
+
+func TestAnalyze(t *testing.T) {
+	r := newAnalyzeTestArchive(t, map[string]string{
+		"k8s-pods-20230101-000000.yaml":        analyzeTestPods,
+		"ciliumendpoints-20230101-000000.yaml": analyzeTestEndpoints,
+		"logs-cilium-aaaaa-cilium-agent-20230101-000000.log": "level=info msg=ok\n" +
+			"level=error msg=\"unable to sync\"\n" +
+			"level=error msg=\"Error in delegate stream, restarting\"\n" +
+			"level=error msg=\"unable to sync again\"\n",
+		"cilium-bugtool-cilium-aaaaa-20230101-000000/cmd/cilium-status---verbose.md": analyzeTestStatus,
+	})
+
+	var version semver.Version
+	findings, err := Analyze(r, AnalyzeOptions{
+		ErrorLogMessages: func(v semver.Version) map[string][]string {
+			version = v
+			return map[string][]string{
+				"level=error": {"Error in delegate stream, restarting"},
+				"panic:":      nil,
+			}
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, semver.MustParse("1.14.1"), version)
+
+	var errors, warnings []Finding
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errors = append(errors, f)
+		} else {
+			warnings = append(warnings, f)
+		}
+	}
+	assert.Equal(t, findings, append(errors, warnings...), "errors must be reported first")
+
+	assert.ElementsMatch(t, []Finding{
+		{
+			Severity: SeverityError,
+			Check:    "crash-loop",
+			Object:   "kube-system/cilium-bbbbb",
+			Message:  "container cilium-agent is in CrashLoopBackOff (7 restarts), last terminated with exit code 1 (Error)",
+		},
+		{
+			Severity: SeverityError,
+			Check:    "agent-logs",
+			Object:   "cilium-aaaaa",
+			Message:  `found "level=error" 2 times, e.g. level=error msg="unable to sync"`,
+		},
+		{
+			Severity: SeverityError,
+			Check:    "agent-status",
+			Object:   "cilium-aaaaa",
+			Message:  "Cilium:                  Warning   Kubernetes service is not ready",
+		},
+		{
+			Severity: SeverityError,
+			Check:    "agent-status",
+			Object:   "cilium-aaaaa",
+			Message:  "Controller Status:       40/42 healthy",
+		},
+	}, errors)
+
+	assert.ElementsMatch(t, []Finding{
+		{
+			Severity: SeverityWarning,
+			Check:    "image-versions",
+			Message:  "Cilium agents run different images: quay.io/cilium/cilium:v1.14.0 (1 pods), quay.io/cilium/cilium:v1.14.1 (1 pods)",
+		},
+		{
+			Severity: SeverityWarning,
+			Check:    "missing-endpoint",
+			Object:   "default/server",
+			Message:  "running pod has no CiliumEndpoint, it might not be managed by Cilium",
+		},
+	}, warnings)
+}
+
+func TestAnalyzeEmptyArchive(t *testing.T) {
+	findings, err := Analyze(newAnalyzeTestArchive(t, nil), AnalyzeOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestImageTag(t *testing.T) {
+	assert.Equal(t, "v1.14.1", imageTag("quay.io/cilium/cilium:v1.14.1"))
+	assert.Equal(t, "v1.14.1", imageTag("quay.io/cilium/cilium:v1.14.1@sha256:abcd"))
+	assert.Equal(t, "", imageTag("localhost:5000/cilium/cilium"))
+}
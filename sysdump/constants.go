@@ -93,6 +93,7 @@ const (
 	kubernetesPodsSummaryFileName            = "k8s-pods-<ts>.txt"
 	kubernetesServicesFileName               = "k8s-services-<ts>.yaml"
 	kubernetesVersionInfoFileName            = "k8s-version-<ts>.txt"
+	redactionManifestFileName                = "redaction-manifest-<ts>.json"
 	securityGroupPoliciesFileName            = "aws-securitygrouppolicies-<ts>.yaml"
 	timestampPlaceholderFileName             = "<ts>"
 	gatewayClassesFileName                   = "gatewayapi-gatewayclasses-<ts>.yaml"
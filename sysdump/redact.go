@@ -0,0 +1,439 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package sysdump
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	redactionRuleIPs              = "ip-addresses"
+	redactionRuleSecretFields     = "secret-fields"
+	redactionRulePrivateKeys      = "private-keys"
+	redactionRuleBase64PrivateKey = "base64-private-keys"
+)
+
+var (
+	// ipv4Regex matches IPv4 address candidates, which are validated by
+	// netip.ParseAddr.
+	ipv4Regex = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	// ipv6Regex matches IPv6 address candidates, which are validated by
+	// netip.ParseAddr.
+	ipv6Regex = regexp.MustCompile(`(?i)[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}(?:(?:\d{1,3}\.){3}\d{1,3})?`)
+
+	privateKeyRegex       = regexp.MustCompile(`-----BEGIN ([A-Z ]*)PRIVATE KEY-----[\s\S]*?-----END ([A-Z ]*)PRIVATE KEY-----`)
+	base64PrivateKeyRegex = regexp.MustCompile(`LS0tLS1CRUdJTi[A-Za-z0-9+/]+=*`)
+
+	// The ranges reserved for benchmarking and documentation, which IPv4 and
+	// IPv6 addresses are respectively anonymized to.
+	anonymizedIPv4Prefix = netip.MustParsePrefix("198.18.0.0/15")
+	anonymizedIPv6Prefix = netip.MustParsePrefix("2001:db8::/32")
+)
+
+// DefaultRedactionSecretFields are the names of the fields whose values are
+// redacted by default, regardless of their format.
+var DefaultRedactionSecretFields = []string{
+	"password",
+	"passwd",
+	"token",
+	"bearerToken",
+	"apiKey",
+	"api-key",
+	"clientSecret",
+	"client-secret",
+	"client-key-data",
+	"privateKey",
+	"private-key",
+	"tls.key",
+	"ca.key",
+}
+
+// RedactionPattern redacts the matches of a regular expression.
+type RedactionPattern struct {
+	// Name identifies the pattern in the redaction manifest.
+	Name string `json:"name"`
+	// Regex is the regular expression to redact.
+	Regex string `json:"regex"`
+	// Replacement replaces the matches of Regex, it may reference submatches
+	// like regexp.Regexp.ReplaceAllString. Defaults to XXXXXX.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// RedactionRules configures the redaction of sensitive data in a sysdump.
+type RedactionRules struct {
+	// AnonymizeIPs replaces IP addresses by addresses from reserved ranges.
+	// The same IP address is always replaced by the same address.
+	AnonymizeIPs bool `json:"anonymizeIPs"`
+	// SecretFields are the names of the YAML or JSON fields, or key=value
+	// pairs, whose values are redacted.
+	SecretFields []string `json:"secretFields,omitempty"`
+	// Patterns are additional regular expressions to redact.
+	Patterns []RedactionPattern `json:"patterns,omitempty"`
+}
+
+// DefaultRedactionRules returns the redaction rules used when no rules file is
+// provided.
+func DefaultRedactionRules() RedactionRules {
+	return RedactionRules{
+		AnonymizeIPs: true,
+		SecretFields: append([]string{}, DefaultRedactionSecretFields...),
+	}
+}
+
+// LoadRedactionRules loads the redaction rules from the given YAML or JSON
+// file.
+func LoadRedactionRules(fileName string) (RedactionRules, error) {
+	var rules RedactionRules
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return rules, fmt.Errorf("failed to read redaction rules: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return rules, fmt.Errorf("failed to parse redaction rules %s: %w", fileName, err)
+	}
+	return rules, nil
+}
+
+// RedactionManifest lists the redactions applied to the files of a sysdump.
+type RedactionManifest struct {
+	// Rules are the names of the redaction rules in use.
+	Rules []string `json:"rules"`
+	// Files maps the names of the redacted files to the number of redactions
+	// of each rule.
+	Files map[string]map[string]int `json:"files"`
+	// AnonymizedIPs is the number of distinct IP addresses anonymized.
+	AnonymizedIPs int `json:"anonymizedIPs"`
+}
+
+type redactionRule struct {
+	name string
+	re   *regexp.Regexp
+	// replace returns the replacement of the given match, or false if the
+	// match must be left untouched.
+	replace func(match string) (string, bool)
+	// apply redacts the whole data at once instead, for redactions which
+	// can't be expressed as the replacement of a match. It returns the
+	// redacted data and the number of redactions.
+	apply func(data string) (string, int)
+}
+
+// redactor redacts sensitive data from the files written to a sysdump. It is
+// safe for concurrent use.
+type redactor struct {
+	rules []redactionRule
+
+	mu       sync.Mutex
+	ips      map[netip.Addr]netip.Addr
+	nextIPv4 netip.Addr
+	nextIPv6 netip.Addr
+	// ipKey is the key IP addresses are hashed with once the anonymized
+	// range is exhausted.
+	ipKey    []byte
+	manifest RedactionManifest
+}
+
+func newRedactor(rules RedactionRules) (*redactor, error) {
+	r := &redactor{
+		ips:      make(map[netip.Addr]netip.Addr),
+		nextIPv4: anonymizedIPv4Prefix.Addr(),
+		nextIPv6: anonymizedIPv6Prefix.Addr(),
+		ipKey:    make([]byte, sha256.Size),
+		manifest: RedactionManifest{Files: make(map[string]map[string]int)},
+	}
+	if _, err := rand.Read(r.ipKey); err != nil {
+		return nil, fmt.Errorf("failed to generate IP anonymization key: %w", err)
+	}
+
+	// Private keys are redacted first, so that their content isn't mistaken
+	// for anything else.
+	r.rules = append(r.rules,
+		redactionRule{
+			name:    redactionRulePrivateKeys,
+			re:      privateKeyRegex,
+			replace: func(string) (string, bool) { return redacted, true },
+		},
+		redactionRule{
+			name:    redactionRuleBase64PrivateKey,
+			re:      base64PrivateKeyRegex,
+			replace: redactBase64PrivateKey,
+		},
+	)
+
+	if len(rules.SecretFields) > 0 {
+		fields := make([]string, 0, len(rules.SecretFields))
+		for _, f := range rules.SecretFields {
+			fields = append(fields, regexp.QuoteMeta(f))
+		}
+		// Matches 'field: value', '"field": "value"' and 'field=value', but
+		// not YAML block scalars nor empty values.
+		re, err := regexp.Compile(`(?i)("?\b(?:` + strings.Join(fields, "|") + `)"?\s*[:=]\s*)("[^"\n]+"|'[^'\n]+'|[^\s,}\]"'|>][^\s,}\]"']*)`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret fields: %w", err)
+		}
+		r.rules = append(r.rules, redactionRule{
+			name: redactionRuleSecretFields,
+			re:   re,
+			replace: func(match string) (string, bool) {
+				m := re.FindStringSubmatch(match)
+				if strings.Trim(m[2], `"'`) == redacted {
+					return "", false
+				}
+				// Keep quoted values quoted, so that JSON stays valid.
+				if q := m[2][0]; q == '"' || q == '\'' {
+					return m[1] + string(q) + redacted + string(q), true
+				}
+				return m[1] + redacted, true
+			},
+		})
+
+		// Matches the 'field: |' and 'field: >-' headers of YAML block
+		// scalars, e.g. multi-line keys and certificates.
+		header, err := regexp.Compile(`(?i)^([ \t]*(?:-[ \t]+)*)"?(?:` + strings.Join(fields, "|") + `)"?[ \t]*:[ \t]*[|>][-+0-9]*[ \t]*(?:#.*)?$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret fields: %w", err)
+		}
+		r.rules = append(r.rules, redactionRule{
+			name:  redactionRuleSecretFields,
+			apply: func(data string) (string, int) { return redactBlockScalars(header, data) },
+		})
+	}
+
+	for _, p := range rules.Patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p.Name, err)
+		}
+		if p.Name == "" {
+			p.Name = p.Regex
+		}
+		replacement := p.Replacement
+		if replacement == "" {
+			replacement = redacted
+		}
+		r.rules = append(r.rules, redactionRule{
+			name: p.Name,
+			re:   re,
+			replace: func(match string) (string, bool) {
+				return re.ReplaceAllString(match, replacement), true
+			},
+		})
+	}
+
+	if rules.AnonymizeIPs {
+		r.rules = append(r.rules,
+			redactionRule{name: redactionRuleIPs, re: ipv6Regex, replace: r.anonymizeIP},
+			redactionRule{name: redactionRuleIPs, re: ipv4Regex, replace: r.anonymizeIP},
+		)
+	}
+
+	for _, rule := range r.rules {
+		if len(r.manifest.Rules) == 0 || r.manifest.Rules[len(r.manifest.Rules)-1] != rule.name {
+			r.manifest.Rules = append(r.manifest.Rules, rule.name)
+		}
+	}
+
+	return r, nil
+}
+
+// redactBlockScalars redacts the content of the YAML block scalars whose
+// header matches the given regular expression, whose first submatch is the
+// indentation of the field. The content of a block scalar spans the following
+// lines which are either blank or more indented than the field.
+func redactBlockScalars(header *regexp.Regexp, data string) (string, int) {
+	if !strings.ContainsAny(data, "|>") {
+		return data, 0
+	}
+
+	lines := strings.SplitAfter(data, "\n")
+	out := make([]string, 0, len(lines))
+	var n int
+	for i := 0; i < len(lines); i++ {
+		out = append(out, lines[i])
+		m := header.FindStringSubmatch(strings.TrimRight(lines[i], "\r\n"))
+		if m == nil {
+			continue
+		}
+
+		indent := len(m[1])
+		contentIndent := -1
+		end := i + 1
+		for j := i + 1; j < len(lines); j++ {
+			line := strings.TrimRight(lines[j], "\r\n")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
+			if lineIndent <= indent {
+				break
+			}
+			if contentIndent < 0 {
+				contentIndent = lineIndent
+			}
+			end = j + 1
+		}
+		if contentIndent < 0 {
+			// Empty block scalar.
+			continue
+		}
+
+		content := strings.TrimSpace(strings.Join(lines[i+1:end], ""))
+		if content != redacted {
+			out = append(out, strings.Repeat(" ", contentIndent)+redacted+"\n")
+			n++
+		} else {
+			out = append(out, lines[i+1:end]...)
+		}
+		i = end - 1
+	}
+
+	// Keep the data as-is if it didn't end with a newline.
+	s := strings.Join(out, "")
+	if !strings.HasSuffix(data, "\n") {
+		s = strings.TrimSuffix(s, "\n")
+	}
+	return s, n
+}
+
+// redactBase64PrivateKey redacts base64-encoded PEM blocks which contain a
+// private key, leaving certificates untouched.
+func redactBase64PrivateKey(match string) (string, bool) {
+	b, err := base64.StdEncoding.DecodeString(match)
+	if err != nil || !strings.Contains(string(b), "PRIVATE KEY") {
+		return "", false
+	}
+	return redacted, true
+}
+
+// anonymizeIP returns the anonymized address of the given IP address.
+// Unspecified, loopback and link-local addresses are left untouched as they
+// don't reveal anything about the cluster.
+func (r *redactor) anonymizeIP(match string) (string, bool) {
+	addr, err := netip.ParseAddr(match)
+	if err != nil || addr.IsUnspecified() || addr.IsLoopback() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr == netip.AddrFrom4([4]byte{255, 255, 255, 255}) {
+		return "", false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if anon, ok := r.ips[addr]; ok {
+		return anon.String(), true
+	}
+	prefix, next := anonymizedIPv6Prefix, &r.nextIPv6
+	if addr.Is4() {
+		prefix, next = anonymizedIPv4Prefix, &r.nextIPv4
+	}
+	var anon netip.Addr
+	if prefix.Contains(*next) {
+		anon, *next = *next, next.Next()
+	} else {
+		// The range is exhausted, fall back to hashing the address into
+		// it, at the risk of anonymizing distinct addresses to the same
+		// one.
+		anon = hashAddrIntoPrefix(r.ipKey, addr, prefix)
+	}
+	r.ips[addr] = anon
+	return anon.String(), true
+}
+
+// hashAddrIntoPrefix returns the address of the given prefix whose host bits
+// are taken from the keyed hash of addr. The hash is keyed such that the
+// original address can't be recovered by hashing all the candidates.
+func hashAddrIntoPrefix(key []byte, addr netip.Addr, prefix netip.Prefix) netip.Addr {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(addr.AsSlice())
+	sum := mac.Sum(nil)
+
+	b := prefix.Masked().Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		bit := sum[i/8] >> (7 - i%8) & 1
+		b[i/8] |= bit << (7 - i%8)
+	}
+	anon, _ := netip.AddrFromSlice(b)
+	return anon
+}
+
+// redact returns data with the sensitive data redacted, and records the
+// redactions under the given file name in the manifest. Data which isn't valid
+// UTF-8, such as profiles and archives, is returned as-is.
+func (r *redactor) redact(fileName string, data []byte) []byte {
+	if !utf8.Valid(data) {
+		return data
+	}
+
+	counts := make(map[string]int)
+	s := string(data)
+	for _, rule := range r.rules {
+		if rule.apply != nil {
+			var n int
+			if s, n = rule.apply(s); n > 0 {
+				counts[rule.name] += n
+			}
+			continue
+		}
+		s = rule.re.ReplaceAllStringFunc(s, func(match string) string {
+			repl, ok := rule.replace(match)
+			if !ok {
+				return match
+			}
+			counts[rule.name]++
+			return repl
+		})
+	}
+
+	if len(counts) > 0 {
+		r.mu.Lock()
+		r.manifest.Files[fileName] = counts
+		r.mu.Unlock()
+	}
+	return []byte(s)
+}
+
+// writeManifest writes the redaction manifest as JSON to the given path.
+func (r *redactor) writeManifest(p string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.manifest.AnonymizedIPs = len(r.ips)
+	b, err := json.MarshalIndent(r.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeBytes(p, b)
+}
+
+// redactDir redacts the files in the given directory in place, e.g. the
+// extracted content of bugtool archives. The files are recorded in the manifest
+// by their path relative to the sysdump directory.
+func (r *redactor) redactDir(sysdumpDir, dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sysdumpDir, p)
+		if err != nil {
+			return err
+		}
+		return writeBytes(p, r.redact(filepath.ToSlash(rel), data))
+	})
+}
@@ -111,6 +111,11 @@ type Options struct {
 	TetragonNamespace string
 	// Retry limit for copying files from pods
 	CopyRetryLimit int
+	// Whether to redact sensitive data from the collected files.
+	Redact bool
+	// File with the redaction rules, enabling redaction. If empty and Redact is
+	// set, DefaultRedactionRules is used.
+	RedactionRulesFile string
 }
 
 // Task defines a task for the sysdump collector to execute.
@@ -148,6 +153,8 @@ type Collector struct {
 	NodeList []string
 	// additionalTasks keeps track of additional tasks added via AddTasks.
 	additionalTasks []Task
+	// redactor redacts the collected files, if redaction is enabled.
+	redactor *redactor
 }
 
 // NewCollector returns a new sysdump collector.
@@ -168,6 +175,18 @@ func NewCollector(k KubernetesClient, o Options, startTime time.Time, cliVersion
 	if err = c.setupLogging(o.Writer); err != nil {
 		return nil, err
 	}
+	if c.Options.Redact || c.Options.RedactionRulesFile != "" {
+		rules := DefaultRedactionRules()
+		if c.Options.RedactionRulesFile != "" {
+			if rules, err = LoadRedactionRules(c.Options.RedactionRulesFile); err != nil {
+				return nil, err
+			}
+		}
+		if c.redactor, err = newRedactor(rules); err != nil {
+			return nil, err
+		}
+		c.log("🔒 Redacting sensitive data from the sysdump")
+	}
 	c.logDebug("Using %v as a temporary directory", c.sysdumpDir)
 	c.logTask("Collecting sysdump with cilium-cli version: %s, args: %s", cliVersion, os.Args[1:])
 
@@ -267,6 +286,16 @@ func (c *Collector) teardownLogging() {
 	c.logWriter = os.Stdout
 }
 
+// redactLogFile redacts the sysdump collector log file, once it is closed.
+func (c *Collector) redactLogFile() error {
+	p := filepath.Join(c.sysdumpDir, sysdumpLogFile)
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	return writeBytes(p, c.redactor.redact(sysdumpLogFile, b))
+}
+
 // replaceTimestamp can be used to replace the special timestamp placeholder in file and directory names.
 func (c *Collector) replaceTimestamp(f string) string {
 	return strings.Replace(f, timestampPlaceholderFileName, c.startTime.Format(timeFormat), -1)
@@ -279,22 +308,34 @@ func (c *Collector) AbsoluteTempPath(f string) string {
 
 // WriteYAML writes a kubernetes object to a file as YAML.
 func (c *Collector) WriteYAML(filename string, o runtime.Object) error {
-	return writeYaml(c.AbsoluteTempPath(filename), o)
+	b, err := marshalYaml(o)
+	if err != nil {
+		return err
+	}
+	return c.WriteBytes(filename, b)
 }
 
 // WriteString writes a string to a file.
 func (c *Collector) WriteString(filename string, value string) error {
-	return writeString(c.AbsoluteTempPath(filename), value)
+	return c.WriteBytes(filename, []byte(value))
 }
 
-// WriteBytes writes a byte array to a file.
+// WriteBytes writes a byte array to a file, redacting its sensitive data if
+// redaction is enabled.
 func (c *Collector) WriteBytes(filename string, value []byte) error {
+	if c.redactor != nil {
+		value = c.redactor.redact(c.replaceTimestamp(filename), value)
+	}
 	return writeBytes(c.AbsoluteTempPath(filename), value)
 }
 
 // WriteTable writes a kubernetes table to a file.
 func (c *Collector) WriteTable(filename string, value *metav1.Table) error {
-	return writeTable(c.AbsoluteTempPath(filename), value)
+	b, err := marshalTable(value)
+	if err != nil {
+		return err
+	}
+	return c.WriteBytes(filename, b)
 }
 
 // Run performs the actual sysdump collection.
@@ -1627,6 +1668,15 @@ func (c *Collector) Run() error {
 
 	c.teardownLogging()
 
+	if c.redactor != nil {
+		if err := c.redactLogFile(); err != nil {
+			c.logWarn("Failed to redact the sysdump log file: %v", err)
+		}
+		if err := c.redactor.writeManifest(c.AbsoluteTempPath(redactionManifestFileName)); err != nil {
+			c.logWarn("Failed to write the redaction manifest: %v", err)
+		}
+	}
+
 	// Create the zip file in the current directory.
 	c.log("🗳 Compiling sysdump")
 	f := c.replaceTimestamp(c.Options.OutputFileName) + ".zip"
@@ -1698,13 +1748,11 @@ func (c *Collector) SubmitTetragonBugtoolTasks(pods []*corev1.Pod, tetragonAgent
 			if err != nil {
 				return fmt.Errorf("failed to collect 'tetragon-bugtool' output for %q: %w", p.Name, err)
 			}
-			// Untar the resulting file.
-			t := archiver.TarGz{
-				Tar: &archiver.Tar{
-					StripComponents: 1,
-				},
-			}
-			if err := t.Unarchive(f, strings.Replace(f, ".tar.gz", "", -1)); err != nil {
+			// Untar, and redact if needed, the resulting file.
+			if err := c.extractArchive(f); err != nil {
+				if c.redactor != nil {
+					return fmt.Errorf("failed to redact 'tetragon-bugtool' output for %q: %w", p.Name, err)
+				}
 				c.logWarn("Failed to unarchive 'tetragon-bugtool' output for %q: %v", p.Name, err)
 				return nil
 			}
@@ -1725,6 +1773,33 @@ func (c *Collector) SubmitTetragonBugtoolTasks(pods []*corev1.Pod, tetragonAgent
 	return nil
 }
 
+// extractArchive extracts the .tar.gz archive f next to it. If the sysdump is
+// redacted, the extracted files are redacted as well, and if either step fails
+// both the archive and the extracted files are removed, so that nothing which
+// couldn't be redacted ends up in the sysdump.
+func (c *Collector) extractArchive(f string) error {
+	dir := strings.Replace(f, ".tar.gz", "", -1)
+	t := archiver.TarGz{
+		Tar: &archiver.Tar{
+			StripComponents: 1,
+		},
+	}
+	err := t.Unarchive(f, dir)
+	// Redact the extracted files, as they aren't written by the collector.
+	if err == nil && c.redactor != nil {
+		err = c.redactor.redactDir(c.sysdumpDir, dir)
+	}
+	if err != nil && c.redactor != nil {
+		if rmErr := os.RemoveAll(dir); rmErr != nil {
+			return fmt.Errorf("%w, and failed to remove the extracted files: %v", err, rmErr)
+		}
+		if rmErr := os.Remove(f); rmErr != nil {
+			return fmt.Errorf("%w, and failed to remove the archive: %v", err, rmErr)
+		}
+	}
+	return err
+}
+
 func (c *Collector) submitCiliumBugtoolTasks(pods []*corev1.Pod) error {
 	for _, p := range pods {
 		p := p
@@ -1774,13 +1849,11 @@ func (c *Collector) submitCiliumBugtoolTasks(pods []*corev1.Pod) error {
 			if err != nil {
 				return fmt.Errorf("failed to collect 'cilium-bugtool' output for %q: %w", p.Name, err)
 			}
-			// Untar the resulting file.
-			t := archiver.TarGz{
-				Tar: &archiver.Tar{
-					StripComponents: 1,
-				},
-			}
-			if err := t.Unarchive(f, strings.Replace(f, ".tar.gz", "", -1)); err != nil {
+			// Untar, and redact if needed, the resulting file.
+			if err := c.extractArchive(f); err != nil {
+				if c.redactor != nil {
+					return fmt.Errorf("failed to redact 'cilium-bugtool' output for %q: %w", p.Name, err)
+				}
 				c.logWarn("Failed to unarchive 'cilium-bugtool' output for %q: %v", p.Name, err)
 				return nil
 			}
@@ -27,32 +27,28 @@ func writeBytes(p string, b []byte) error {
 	return os.WriteFile(p, b, fileMode)
 }
 
-func writeString(p, v string) error {
-	return writeBytes(p, []byte(v))
-}
-
-func writeTable(p string, o *metav1.Table) error {
+func marshalTable(o *metav1.Table) ([]byte, error) {
 	var b bytes.Buffer
 	if err := printers.NewTablePrinter(printers.PrintOptions{
 		Wide:          true,
 		WithNamespace: true,
 	}).PrintObj(o, &b); err != nil {
-		return err
+		return nil, err
 	}
-	return writeString(p, b.String())
+	return b.Bytes(), nil
 }
 
-func writeYaml(p string, o runtime.Object) error {
+func marshalYaml(o runtime.Object) ([]byte, error) {
 	var j printers.YAMLPrinter
 	w, err := printers.NewTypeSetter(scheme.Scheme).WrapToPrinter(&j, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var b bytes.Buffer
 	if err := w.PrintObj(o, &b); err != nil {
-		return err
+		return nil, err
 	}
-	return writeString(p, b.String())
+	return b.Bytes(), nil
 }
 
 // writeEventTable writes a html summary of cluster events.